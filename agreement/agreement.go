@@ -18,6 +18,7 @@ import (
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/producer"
+	"github.com/open-horizon/anax/propertyprovider"
 	"github.com/open-horizon/anax/worker"
 	"net/http"
 	"reflect"
@@ -45,10 +46,13 @@ const (
 	EL_AG_UNABLE_WRITE_NODE_EXCH_PATTERN_TO_DB   = "Unable to save the new node exchange pattern %v to the local database. Error: %v"
 	EL_AG_TERM_UNABLE_SYNC_CONTAINERS            = "anax terminating, unable to sync up containers."
 	EL_AG_TERM_UNABLE_SYNC_AGS                   = "anax terminating, unable to complete agreement sync up. %v"
+	EL_AG_UNABLE_UPDATE_DYNAMIC_PROPS            = "Unable to update the dynamic node properties %v in the node policy. Error: %v"
+	EL_AG_DYNAMIC_PROPS_UPDATED                  = "Dynamic node properties %v updated in the node policy: %v"
 )
 
-// name for the subworker
+// names for the subworkers
 const NODE_POLICY_WATCHER = "NodePolicyWatcher"
+const DYNAMIC_PROPERTY_WATCHER = "DynamicPropertyWatcher"
 
 // This is does nothing useful at run time.
 // This code is only used at compile time to make the eventlog messages get into the catalog so that
@@ -75,6 +79,8 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_AG_UNABLE_WRITE_NODE_EXCH_PATTERN_TO_DB)
 	msgPrinter.Sprintf(EL_AG_TERM_UNABLE_SYNC_CONTAINERS)
 	msgPrinter.Sprintf(EL_AG_TERM_UNABLE_SYNC_AGS)
+	msgPrinter.Sprintf(EL_AG_UNABLE_UPDATE_DYNAMIC_PROPS)
+	msgPrinter.Sprintf(EL_AG_DYNAMIC_PROPS_UPDATED)
 }
 
 // must be safely-constructed!!
@@ -90,6 +96,7 @@ type AgreementWorker struct {
 	lastExchVerCheck         int64
 	hznOffline               bool
 	limitedRetryEC           exchange.ExchangeContext
+	propertyManager          *propertyprovider.PropertyManager
 }
 
 func NewAgreementWorker(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *AgreementWorker {
@@ -316,6 +323,16 @@ func (w *AgreementWorker) Initialize() bool {
 	// this subworker will catch if the node policy built-in properties have changed without the agent restarting
 	w.DispatchSubworker(NODE_POLICY_WATCHER, w.reconcileNodePolicy, 60, false)
 
+	// this subworker pushes the dynamic node properties to the node policy when their values have changed enough
+	if len(w.Config.Edge.DynamicProperties) != 0 {
+		if propertyManager, err := propertyprovider.NewPropertyManager(w.Config.Edge.DynamicProperties); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("Unable to start the dynamic node properties. %v", err)))
+		} else {
+			w.propertyManager = propertyManager
+			w.DispatchSubworker(DYNAMIC_PROPERTY_WATCHER, w.reconcileDynamicProperties, propertyManager.CheckIntervalS(), false)
+		}
+	}
+
 	glog.Info(logString(fmt.Sprintf("waiting for commands.")))
 
 	return true
@@ -359,6 +376,14 @@ func (w *AgreementWorker) reconcileNodePolicy() int {
 	return 60
 }
 
+func (w *AgreementWorker) reconcileDynamicProperties() int {
+	if !w.hznOffline && w.limitedRetryEC != nil {
+		w.checkDynamicProperties()
+	}
+
+	return w.propertyManager.CheckIntervalS()
+}
+
 // Enter the command processing loop. Initialization is complete so wait for commands to
 // perform. Commands are created as the result of events that are triggered elsewhere
 // in the system. This function returns ture if the command was handled, false if not.
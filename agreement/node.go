@@ -14,6 +14,7 @@ import (
 	"github.com/open-horizon/anax/policy"
 	"sort"
 	"strings"
+	"time"
 )
 
 // Check node changes on the exchange and save it on local node
@@ -272,6 +273,60 @@ func (w *AgreementWorker) checkNodePolicyChanges() {
 	return
 }
 
+// Compute the dynamic node properties that are due and push the ones that are not in the node policy, or that have
+// changed enough, to the node policy on the exchange.
+func (w *AgreementWorker) checkDynamicProperties() {
+	glog.V(5).Infof(logString(fmt.Sprintf("checking the dynamic node properties.")))
+
+	// get the node
+	pDevice, err := persistence.FindExchangeDevice(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to read node object from the local database. %v", err)))
+		eventlog.LogDatabaseEvent(w.db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_AG_UNABLE_READ_NODE_FROM_DB, err.Error()),
+			persistence.EC_DATABASE_ERROR)
+		return
+	} else if pDevice == nil {
+		glog.Errorf(logString(fmt.Sprintf("No device is found from the local database.")))
+		return
+	}
+
+	// the node policy is set up when the node is registered
+	nodePolicy, err := persistence.FindNodePolicy(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to read the node policy from the local database. %v", err)))
+		return
+	} else if nodePolicy == nil {
+		return
+	}
+
+	changed := w.propertyManager.ChangedProperties(nodePolicy.Properties, time.Now())
+	if len(changed) == 0 {
+		return
+	}
+
+	uc_deployment, uc_management, newNodePolicy, err := exchangesync.PatchNodePolicy(pDevice, w.db, "properties", changed, exchange.GetHTTPNodePolicyHandler(w.limitedRetryEC), exchange.GetHTTPPutNodePolicyHandler(w.limitedRetryEC))
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to update the dynamic node properties %v in the node policy. Error: %v", changed, err)))
+		eventlog.LogNodeEvent(w.db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_AG_UNABLE_UPDATE_DYNAMIC_PROPS, changed.ShortString(), err.Error()),
+			persistence.EC_ERROR_NODE_POLICY_UPDATE,
+			exchange.GetOrg(w.GetExchangeId()),
+			exchange.GetId(w.GetExchangeId()),
+			w.devicePattern, "")
+	} else if uc_deployment != externalpolicy.EP_COMPARE_NOCHANGE || uc_management != externalpolicy.EP_COMPARE_NOCHANGE {
+		glog.V(3).Infof(logString(fmt.Sprintf("Dynamic node properties %v updated in the node policy: %v", changed, newNodePolicy)))
+		eventlog.LogNodeEvent(w.db, persistence.SEVERITY_INFO,
+			persistence.NewMessageMeta(EL_AG_DYNAMIC_PROPS_UPDATED, changed.ShortString(), newNodePolicy),
+			persistence.EC_NODE_POLICY_UPDATED,
+			exchange.GetOrg(w.GetExchangeId()),
+			exchange.GetId(w.GetExchangeId()),
+			w.devicePattern, "")
+
+		w.Messages() <- events.NewNodePolicyMessage(events.UPDATE_POLICY, uc_deployment, uc_management)
+	}
+}
+
 func (w *AgreementWorker) isOffline() {
 	msgPrinter := i18n.GetMessagePrinterWithLocale("en")
 	eventLogs, err := eventlog.GetEventLogs(w.db, false, nil, msgPrinter)
@@ -0,0 +1,90 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/metrics"
+	"github.com/open-horizon/anax/policy"
+	"net/http"
+)
+
+// Metrics published by the agbot on its /metrics API. The agreement counters allow an agbot that has stopped
+// making agreements to be detected, the work queue metrics show when the agreement workers are falling behind.
+var (
+	agbotAgreements = metrics.DefaultRegistry.NewGaugeVec("anax_agbot_agreements",
+		"Number of agreements in the agbot database by agreement protocol and state.", "protocol", "state")
+	agbotAgreementsProposed = metrics.DefaultRegistry.NewCounterVec("anax_agbot_agreements_proposed_total",
+		"Number of agreement proposals sent to nodes.", "protocol")
+	agbotAgreementsFinalized = metrics.DefaultRegistry.NewCounterVec("anax_agbot_agreements_finalized_total",
+		"Number of agreements finalized.", "protocol")
+	agbotWorkloadUsages = metrics.DefaultRegistry.NewGaugeVec("anax_agbot_workload_usages",
+		"Number of workload usage records, i.e. nodes using a policy with workload rollback.")
+
+	agbotWorkQueueDepth = metrics.DefaultRegistry.NewGaugeVec("anax_agbot_workqueue_depth",
+		"Number of buffered work items waiting for an agreement worker.", "priority")
+	agbotWorkQueueQueued = metrics.DefaultRegistry.NewCounterVec("anax_agbot_workqueue_queued_total",
+		"Number of work items added to the work queue.", "priority")
+	agbotWorkQueueDispatched = metrics.DefaultRegistry.NewCounterVec("anax_agbot_workqueue_dispatched_total",
+		"Number of work items dispatched to an agreement worker.", "priority")
+	agbotWorkQueueWait = metrics.DefaultRegistry.NewHistogramVec("anax_agbot_workqueue_wait_seconds",
+		"Time that work items wait in the work queue before being dispatched to an agreement worker.",
+		[]float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600}, "priority")
+)
+
+// The agreement states reported by the agbot.
+const (
+	AG_METRIC_STATE_PENDING     = "pending"
+	AG_METRIC_STATE_CREATED     = "created"
+	AG_METRIC_STATE_FINALIZED   = "finalized"
+	AG_METRIC_STATE_TERMINATING = "terminating"
+	AG_METRIC_STATE_ARCHIVED    = "archived"
+)
+
+func (a *API) metrics(w http.ResponseWriter, r *http.Request) {
+	metrics.DefaultRegistry.ServeHTTP(w, r)
+}
+
+// Returns the state of an agreement as reported in the metrics.
+func agbotAgreementMetricState(ag *persistence.Agreement) string {
+	if ag.Archived {
+		return AG_METRIC_STATE_ARCHIVED
+	} else if ag.AgreementTimedout != 0 {
+		return AG_METRIC_STATE_TERMINATING
+	} else if ag.AgreementFinalizedTime != 0 {
+		return AG_METRIC_STATE_FINALIZED
+	} else if ag.AgreementCreationTime != 0 {
+		return AG_METRIC_STATE_CREATED
+	}
+	return AG_METRIC_STATE_PENDING
+}
+
+// Called by the metrics registry just before the metrics are written out.
+func (a *API) collectMetrics() {
+
+	counts := make(map[string]map[string]int)
+	for _, agp := range policy.AllAgreementProtocols() {
+		ags, err := a.db.FindAgreements([]persistence.AFilter{}, agp)
+		if err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("unable to read agreements for metrics, error: %v", err)))
+			return
+		}
+		counts[agp] = make(map[string]int)
+		for _, ag := range ags {
+			counts[agp][agbotAgreementMetricState(&ag)] += 1
+		}
+	}
+
+	agbotAgreements.Reset()
+	for agp, states := range counts {
+		for state, count := range states {
+			agbotAgreements.Set(float64(count), agp, state)
+		}
+	}
+
+	if wlusages, err := a.db.FindWorkloadUsages([]persistence.WUFilter{}); err != nil {
+		glog.Errorf(APIlogString(fmt.Sprintf("unable to read workload usages for metrics, error: %v", err)))
+	} else {
+		agbotWorkloadUsages.Set(float64(len(wlusages)))
+	}
+}
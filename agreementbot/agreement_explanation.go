@@ -0,0 +1,425 @@
+package agreementbot
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/gorilla/mux"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/basicprotocol"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/policy"
+	"golang.org/x/text/message"
+)
+
+// The types of policies that can be explained.
+const (
+	EXPLAIN_DEPLOYMENT_POLICY = "deployment_policy"
+	EXPLAIN_PATTERN           = "pattern"
+)
+
+// The output of the /explain API. It gathers what the agbot knows about a node and a deployment policy or pattern, so
+// that the reason why the node does or does not have an agreement can be found in one place. Parts that could not be
+// determined are listed in Errors.
+type AgreementExplanation struct {
+	NodeId            string                    `json:"node_id"`
+	Policy            string                    `json:"policy"`      // the deployment policy or pattern, org/name
+	PolicyType        string                    `json:"policy_type"` // deployment_policy or pattern, empty when the agbot does not serve it
+	Served            bool                      `json:"served"`      // the agbot serves the deployment policy or pattern
+	Summary           []string                  `json:"summary"`     // the reasons, most important first
+	Agreement         *ActiveAgreementSummary   `json:"agreement,omitempty"`
+	Search            []NodeSearchExplanation   `json:"search"`
+	Compatibility     *CompatibilityExplanation `json:"compatibility,omitempty"`
+	ProposalRejection *TerminationExplanation   `json:"proposal_rejection,omitempty"` // the last proposal or update the node rejected
+	LastTermination   *TerminationExplanation   `json:"last_termination,omitempty"`
+	WorkloadUsage     []WorkloadUsageSummary    `json:"workload_usage"`
+	HAGroup           string                    `json:"ha_group,omitempty"`
+	Upgrades          []UpgradeExplanation      `json:"upgrades,omitempty"`
+	Errors            map[string]string         `json:"errors,omitempty"`
+}
+
+func (e AgreementExplanation) String() string {
+	return fmt.Sprintf("NodeId: %v, Policy: %v, PolicyType: %v, Served: %v, Summary: %v, Agreement: %v, Search: %v, Compatibility: %v, ProposalRejection: %v, LastTermination: %v, WorkloadUsage: %v, HAGroup: %v, Upgrades: %v, Errors: %v",
+		e.NodeId, e.Policy, e.PolicyType, e.Served, e.Summary, e.Agreement, e.Search, e.Compatibility, e.ProposalRejection, e.LastTermination, e.WorkloadUsage, e.HAGroup, e.Upgrades, e.Errors)
+}
+
+// The active agreement with the node.
+type ActiveAgreementSummary struct {
+	AgreementId   string `json:"agreement_id"`
+	Policy        string `json:"policy"` // the agbot's internal policy name
+	Protocol      string `json:"protocol"`
+	InceptionTime uint64 `json:"inception_time"`
+	CreationTime  uint64 `json:"creation_time,omitempty"`  // the node accepted the proposal
+	FinalizedTime uint64 `json:"finalized_time,omitempty"` // the agreement is finalized
+}
+
+// The result of the compatibility check of the node with the deployment policy or pattern.
+type CompatibilityExplanation struct {
+	Compatible bool              `json:"compatible"`
+	Reason     map[string]string `json:"reason,omitempty"`
+}
+
+// An archived agreement with the node.
+type TerminationExplanation struct {
+	AgreementId    string `json:"agreement_id"`
+	Policy         string `json:"policy"`
+	InceptionTime  uint64 `json:"inception_time"`
+	ReasonCode     uint   `json:"reason_code"`
+	Reason         string `json:"reason"`
+	RejectedByNode bool   `json:"rejected_by_node"`
+}
+
+// The workload usage of the node, without the policy, which is large.
+type WorkloadUsageSummary struct {
+	Policy             string `json:"policy"`
+	AgreementId        string `json:"agreement_id,omitempty"`
+	Priority           int    `json:"priority"`
+	RetryCount         int    `json:"retry_count"`
+	RetryDurationS     int    `json:"retry_durations"`
+	FirstTryTime       uint64 `json:"first_try_time,omitempty"`
+	LatestRetryTime    uint64 `json:"latest_retry_time,omitempty"`
+	DisableRetry       bool   `json:"disable_retry"`
+	ReqsNotMet         bool   `json:"requirements_not_met"`
+	PendingUpgradeTime uint64 `json:"pending_upgrade_time,omitempty"`
+}
+
+// What holds back an upgrade of the workload on the node.
+type UpgradeExplanation struct {
+	Policy              string `json:"policy"`
+	PendingUpgradeTime  uint64 `json:"pending_upgrade_time,omitempty"`
+	InMaintenanceWindow bool   `json:"in_maintenance_window"`
+	UpgradingNode       string `json:"upgrading_node,omitempty"` // the node of the HA group that is upgrading the workload
+	RolloutState        string `json:"rollout_state,omitempty"`
+	RolloutNodeState    string `json:"rollout_node_state,omitempty"`
+	RolloutNodeReason   string `json:"rollout_node_reason,omitempty"`
+}
+
+// Explain why a node does or does not have an agreement for a deployment policy or pattern.
+func (a *API) explain(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		pathVars := mux.Vars(r)
+		nodeId := fmt.Sprintf("%v/%v", pathVars["node_org"], pathVars["node_id"])
+		polId := fmt.Sprintf("%v/%v", pathVars["policy_org"], pathVars["policy_name"])
+
+		if e, err := a.explainAgreement(nodeId, polId, time.Now(), i18n.GetMessagePrinter()); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error explaining the agreement of node %v with %v, error: %v", nodeId, polId, err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		} else {
+			writeResponse(w, e, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Gather the explanation from the agbot's database, its in memory state and the exchange. Only database errors are
+// returned, the other errors are saved in the explanation.
+func (a *API) explainAgreement(nodeId string, polId string, now time.Time, msgPrinter *message.Printer) (*AgreementExplanation, error) {
+
+	e := &AgreementExplanation{
+		NodeId:        nodeId,
+		Policy:        polId,
+		Search:        searchOutcomes.Explain(polId, nodeId),
+		WorkloadUsage: []WorkloadUsageSummary{},
+		Errors:        make(map[string]string),
+	}
+
+	polOrg, polName := cutil.SplitOrgSpecUrl(polId)
+	var deploymentPol *policy.Policy
+	if businessPolManager != nil {
+		if pBE, ok := businessPolManager.GetOrgPolicies()[polOrg][polName]; ok {
+			e.Served = true
+			e.PolicyType = EXPLAIN_DEPLOYMENT_POLICY
+			deploymentPol = pBE.Policy
+		}
+	}
+	if !e.Served && patternManager != nil {
+		if _, ok := patternManager.GetOrgPatterns()[polOrg][polName]; ok {
+			e.Served = true
+			e.PolicyType = EXPLAIN_PATTERN
+		}
+	}
+
+	// The agbot's internal policy names for the deployment policy or pattern. A pattern has one for each architecture.
+	policyNames := map[string]bool{polId: true}
+	for _, s := range e.Search {
+		policyNames[s.Policy] = true
+	}
+
+	// The active and archived agreements with the node, newest first.
+	agreements := []persistence.Agreement{}
+	nodePolAFilter := func(a persistence.Agreement) bool {
+		return a.DeviceId == nodeId && (a.PolicyName == polId || a.Pattern == polId)
+	}
+	for _, agp := range policy.AllAgreementProtocols() {
+		if ags, err := a.db.FindAgreements([]persistence.AFilter{nodePolAFilter}, agp); err != nil {
+			return nil, err
+		} else {
+			agreements = append(agreements, ags...)
+		}
+	}
+	sort.Slice(agreements, func(i, j int) bool {
+		return agreements[i].AgreementInceptionTime > agreements[j].AgreementInceptionTime
+	})
+
+	for _, ag := range agreements {
+		policyNames[ag.PolicyName] = true
+		if !ag.Archived {
+			if e.Agreement == nil {
+				e.Agreement = &ActiveAgreementSummary{
+					AgreementId:   ag.CurrentAgreementId,
+					Policy:        ag.PolicyName,
+					Protocol:      ag.AgreementProtocol,
+					InceptionTime: ag.AgreementInceptionTime,
+					CreationTime:  ag.AgreementCreationTime,
+					FinalizedTime: ag.AgreementFinalizedTime,
+				}
+			}
+			continue
+		}
+
+		t := newTerminationExplanation(ag)
+		if e.LastTermination == nil {
+			e.LastTermination = t
+		}
+		if e.ProposalRejection == nil && t.RejectedByNode {
+			e.ProposalRejection = t
+		}
+	}
+
+	// The workload usages hold the retry state of the workload priorities and the pending upgrades.
+	wuFilter := func(w persistence.WorkloadUsage) bool { return w.DeviceId == nodeId && policyNames[w.PolicyName] }
+	wlusages, err := a.db.FindWorkloadUsages([]persistence.WUFilter{wuFilter})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(WorkloadUsagesByDeviceId(wlusages))
+	for _, wu := range wlusages {
+		e.WorkloadUsage = append(e.WorkloadUsage, newWorkloadUsageSummary(wu))
+	}
+
+	// Check the compatibility of the node with the deployment policy or pattern the same way as hzn deploycheck.
+	if e.Served {
+		ccInput := compcheck.CompCheck{NodeId: nodeId}
+		if e.PolicyType == EXPLAIN_PATTERN {
+			ccInput.PatternId = polId
+		} else {
+			ccInput.BusinessPolId = polId
+		}
+		if output, err := compcheck.DeployCompatible(a, "", &ccInput, false, msgPrinter); err != nil {
+			e.Errors["compatibility"] = err.Error()
+		} else {
+			e.Compatibility = &CompatibilityExplanation{Compatible: output.Compatible, Reason: output.Reason}
+		}
+	}
+
+	// The HA group of the node decides whether an upgrade has to wait for another node.
+	if dev, err := GetDevice(a.GetHTTPFactory().NewHTTPClient(nil), nodeId, a.GetExchangeURL(), a.GetExchangeId(), a.GetExchangeToken()); err != nil {
+		e.Errors["node"] = err.Error()
+	} else if dev == nil {
+		e.Errors["node"] = msgPrinter.Sprintf("Node %v not found in the exchange.", nodeId)
+	} else {
+		e.HAGroup = dev.HAGroup
+	}
+
+	if upgrades, err := a.explainUpgrades(e, deploymentPol, now); err != nil {
+		return nil, err
+	} else {
+		e.Upgrades = upgrades
+	}
+
+	if len(e.Errors) == 0 {
+		e.Errors = nil
+	}
+	e.Summary = summarizeExplanation(e, msgPrinter)
+
+	glog.V(3).Infof(APIlogString(fmt.Sprintf("explained the agreement of node %v with %v: %v", nodeId, polId, e.Summary)))
+	return e, nil
+}
+
+// Find what holds back the upgrade of the workloads on the node: the other nodes of its HA group, the maintenance
+// windows and the staged rollouts.
+func (a *API) explainUpgrades(e *AgreementExplanation, deploymentPol *policy.Policy, now time.Time) ([]UpgradeExplanation, error) {
+
+	rollouts := make(map[string]*PolicyRollout)
+	if rolloutManager != nil {
+		var err error
+		if rollouts, err = rolloutManager.GetRollouts(); err != nil {
+			return nil, err
+		}
+	}
+
+	upgrades := []UpgradeExplanation{}
+	for _, wu := range e.WorkloadUsage {
+		u := UpgradeExplanation{Policy: wu.Policy, PendingUpgradeTime: wu.PendingUpgradeTime}
+
+		if e.HAGroup != "" {
+			if haWlu, err := a.db.GetHAUpgradingWorkload(exchange.GetOrg(e.NodeId), e.HAGroup, wu.Policy); err != nil {
+				return nil, err
+			} else if haWlu != nil && haWlu.NodeId != e.NodeId {
+				u.UpgradingNode = haWlu.NodeId
+			}
+		}
+
+		if r, ok := rollouts[wu.Policy]; ok {
+			if node, ok := r.Nodes[e.NodeId]; ok {
+				u.RolloutState = r.State
+				u.RolloutNodeState = node.State
+				u.RolloutNodeReason = node.Reason
+			}
+		}
+
+		if u.PendingUpgradeTime == 0 && u.UpgradingNode == "" && u.RolloutState == "" {
+			continue
+		}
+
+		if u.PendingUpgradeTime != 0 {
+			u.InMaintenanceWindow = InMaintenanceWindow(a, nil, e.NodeId, wu.Policy, now)
+			if deploymentPol != nil && !deploymentPol.MaintenanceWindows.IsOpen(now) {
+				u.InMaintenanceWindow = false
+			}
+		}
+		upgrades = append(upgrades, u)
+	}
+	return upgrades, nil
+}
+
+func newTerminationExplanation(ag persistence.Agreement) *TerminationExplanation {
+	reason := ag.TerminatedDescription
+	if reason == "" {
+		reason = basicprotocol.DecodeReasonCode(uint64(ag.TerminatedReason))
+	}
+	return &TerminationExplanation{
+		AgreementId:    ag.CurrentAgreementId,
+		Policy:         ag.PolicyName,
+		InceptionTime:  ag.AgreementInceptionTime,
+		ReasonCode:     ag.TerminatedReason,
+		Reason:         reason,
+		RejectedByNode: ag.TerminatedReason == basicprotocol.AB_CANCEL_NEGATIVE_REPLY || ag.TerminatedReason == basicprotocol.AB_CANCEL_UPDATE_REJECTED,
+	}
+}
+
+func newWorkloadUsageSummary(wu persistence.WorkloadUsage) WorkloadUsageSummary {
+	return WorkloadUsageSummary{
+		Policy:             wu.PolicyName,
+		AgreementId:        wu.CurrentAgreementId,
+		Priority:           wu.Priority,
+		RetryCount:         wu.RetryCount,
+		RetryDurationS:     wu.RetryDurationS,
+		FirstTryTime:       wu.FirstTryTime,
+		LatestRetryTime:    wu.LatestRetryTime,
+		DisableRetry:       wu.DisableRetry,
+		ReqsNotMet:         wu.ReqsNotMet,
+		PendingUpgradeTime: wu.PendingUpgradeTime,
+	}
+}
+
+// Turn the explanation into a list of reasons, most important first.
+func summarizeExplanation(e *AgreementExplanation, msgPrinter *message.Printer) []string {
+
+	summary := []string{}
+
+	if !e.Served {
+		summary = append(summary, msgPrinter.Sprintf("The agbot does not serve the deployment policy or pattern %v.", e.Policy))
+	}
+
+	if e.Agreement != nil {
+		if e.Agreement.FinalizedTime != 0 {
+			summary = append(summary, msgPrinter.Sprintf("The node has agreement %v for %v.", e.Agreement.AgreementId, e.Agreement.Policy))
+		} else {
+			summary = append(summary, msgPrinter.Sprintf("Agreement %v for %v is being negotiated with the node.", e.Agreement.AgreementId, e.Agreement.Policy))
+		}
+	}
+
+	if e.Compatibility != nil && !e.Compatibility.Compatible {
+		reasons := make([]string, 0, len(e.Compatibility.Reason))
+		for _, reason := range e.Compatibility.Reason {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		summary = append(summary, msgPrinter.Sprintf("The node is not compatible with %v: %v", e.Policy, reasons))
+	}
+
+	if e.Agreement == nil && e.Served {
+		found := false
+		for _, s := range e.Search {
+			if s.SearchError != "" {
+				summary = append(summary, msgPrinter.Sprintf("The last search for nodes with %v failed: %v", s.Policy, s.SearchError))
+			}
+			if !s.NodeFound {
+				continue
+			}
+			found = true
+			switch s.Outcome {
+			case SEARCH_OUTCOME_NODE_NOT_READY:
+				summary = append(summary, msgPrinter.Sprintf("The node is not ready to make agreements, it has no public key in the exchange."))
+			case SEARCH_OUTCOME_BLOCKCHAIN_NOT_READY:
+				summary = append(summary, msgPrinter.Sprintf("The blockchain %v required by %v is not ready.", s.Detail, s.Policy))
+			case SEARCH_OUTCOME_NO_PROTOCOL_HANDLER:
+				summary = append(summary, msgPrinter.Sprintf("The agbot does not support the agreement protocol %v of %v.", s.Detail, s.Policy))
+			case SEARCH_OUTCOME_HANDLER_BUSY:
+				summary = append(summary, msgPrinter.Sprintf("The agbot was too busy to start an agreement with the node for %v, it will try again at the next search.", s.Policy))
+			case SEARCH_OUTCOME_AGREEMENT_QUEUED:
+				summary = append(summary, msgPrinter.Sprintf("An agreement attempt with the node for %v was started at %v, but did not lead to an agreement.", s.Policy, time.Unix(int64(s.NodeTime), 0).Format(cutil.ExchangeTimeFormat)))
+			}
+		}
+		if !found {
+			summary = append(summary, msgPrinter.Sprintf("The node has not been returned by a search for nodes with %v in the last %v hours. The exchange only returns nodes that are heartbeating, that are in the node orgs the agbot serves, and that have changed since the last search.", e.Policy, SEARCH_OUTCOME_RETENTION_S/3600))
+		}
+	}
+
+	if e.Agreement == nil {
+		if e.ProposalRejection != nil {
+			summary = append(summary, msgPrinter.Sprintf("The node rejected agreement %v: %v. The reason is in the event log of the node.", e.ProposalRejection.AgreementId, e.ProposalRejection.Reason))
+		}
+		if e.LastTermination != nil && e.LastTermination != e.ProposalRejection {
+			summary = append(summary, msgPrinter.Sprintf("The last agreement %v was terminated: %v.", e.LastTermination.AgreementId, e.LastTermination.Reason))
+		}
+	}
+
+	for _, wu := range e.WorkloadUsage {
+		if wu.ReqsNotMet {
+			summary = append(summary, msgPrinter.Sprintf("The node does not meet the requirements of a higher priority workload of %v, it runs the workload with priority %v.", wu.Policy, wu.Priority))
+		}
+		if wu.RetryCount > 0 && !wu.DisableRetry {
+			summary = append(summary, msgPrinter.Sprintf("The workload with priority %v of %v has been retried %v times.", wu.Priority, wu.Policy, wu.RetryCount))
+		}
+	}
+
+	for _, u := range e.Upgrades {
+		if u.UpgradingNode != "" {
+			summary = append(summary, msgPrinter.Sprintf("The upgrade of %v waits for node %v of HA group %v to finish its upgrade.", u.Policy, u.UpgradingNode, e.HAGroup))
+		}
+		if u.PendingUpgradeTime != 0 && !u.InMaintenanceWindow {
+			summary = append(summary, msgPrinter.Sprintf("The upgrade of %v waits for the maintenance window of the node or the deployment policy to open.", u.Policy))
+		}
+		if u.RolloutState == ROLLOUT_HALTED {
+			summary = append(summary, msgPrinter.Sprintf("The rollout of %v is halted.", u.Policy))
+		} else if u.RolloutNodeState == ROLLOUT_NODE_PENDING {
+			summary = append(summary, msgPrinter.Sprintf("The node waits for its wave of the rollout of %v.", u.Policy))
+		}
+	}
+
+	parts := make([]string, 0, len(e.Errors))
+	for part := range e.Errors {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	for _, part := range parts {
+		summary = append(summary, msgPrinter.Sprintf("Unable to explain the %v: %v", part, e.Errors[part]))
+	}
+
+	return summary
+}
@@ -0,0 +1,88 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/basicprotocol"
+	"github.com/open-horizon/anax/i18n"
+	"strings"
+	"testing"
+)
+
+func Test_newTerminationExplanation(t *testing.T) {
+
+	ag := persistence.Agreement{CurrentAgreementId: "ag1", PolicyName: "e2edev/bp_gpstest", TerminatedReason: basicprotocol.AB_CANCEL_NEGATIVE_REPLY}
+	if te := newTerminationExplanation(ag); !te.RejectedByNode || te.Reason != "agreement bot received negative reply" {
+		t.Errorf("wrong termination explanation %v", te)
+	}
+
+	ag.TerminatedReason = basicprotocol.AB_CANCEL_NODE_HEARTBEAT
+	ag.TerminatedDescription = "node heartbeat stopped"
+	if te := newTerminationExplanation(ag); te.RejectedByNode || te.Reason != "node heartbeat stopped" {
+		t.Errorf("wrong termination explanation %v", te)
+	}
+}
+
+func Test_summarizeExplanation(t *testing.T) {
+
+	msgPrinter := i18n.GetMessagePrinter()
+
+	contains := func(summary []string, s string) bool {
+		for _, line := range summary {
+			if strings.Contains(line, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// the policy is not served
+	e := &AgreementExplanation{NodeId: "userdev/an12345", Policy: "e2edev/bp_other"}
+	if summary := summarizeExplanation(e, msgPrinter); len(summary) != 1 || !contains(summary, "does not serve") {
+		t.Errorf("wrong summary %v", summary)
+	}
+
+	// the node is not compatible, was never returned by a search and rejected the last proposal
+	rejection := &TerminationExplanation{AgreementId: "ag1", Reason: "agreement bot received negative reply", RejectedByNode: true}
+	e = &AgreementExplanation{
+		NodeId:            "userdev/an12345",
+		Policy:            "e2edev/bp_gpstest",
+		Served:            true,
+		Compatibility:     &CompatibilityExplanation{Compatible: false, Reason: map[string]string{"e2edev/gps": "Policy Incompatible"}},
+		Search:            []NodeSearchExplanation{{Policy: "e2edev/bp_gpstest", SearchTime: 100}},
+		ProposalRejection: rejection,
+		LastTermination:   rejection,
+	}
+	summary := summarizeExplanation(e, msgPrinter)
+	if !contains(summary, "not compatible") || !contains(summary, "Policy Incompatible") {
+		t.Errorf("the summary %v should contain the compatibility", summary)
+	} else if !contains(summary, "has not been returned by a search") {
+		t.Errorf("the summary %v should contain the search", summary)
+	} else if !contains(summary, "The node rejected agreement ag1") || contains(summary, "was terminated") {
+		t.Errorf("the summary %v should contain the rejection once", summary)
+	}
+
+	// the node has an agreement that waits for its HA partner to upgrade
+	e = &AgreementExplanation{
+		NodeId:        "userdev/an12345",
+		Policy:        "e2edev/bp_gpstest",
+		Served:        true,
+		Agreement:     &ActiveAgreementSummary{AgreementId: "ag2", Policy: "e2edev/bp_gpstest", FinalizedTime: 100},
+		Compatibility: &CompatibilityExplanation{Compatible: true},
+		WorkloadUsage: []WorkloadUsageSummary{{Policy: "e2edev/bp_gpstest", Priority: 2, RetryCount: 3, ReqsNotMet: true, PendingUpgradeTime: 200}},
+		HAGroup:       "group1",
+		Upgrades:      []UpgradeExplanation{{Policy: "e2edev/bp_gpstest", PendingUpgradeTime: 200, InMaintenanceWindow: true, UpgradingNode: "userdev/an54321"}},
+	}
+	summary = summarizeExplanation(e, msgPrinter)
+	if len(summary) != 4 {
+		t.Errorf("the summary should have 4 reasons, but is %v", summary)
+	} else if !strings.Contains(summary[0], "has agreement ag2") {
+		t.Errorf("the summary %v should start with the agreement", summary)
+	} else if !contains(summary, "higher priority") || !contains(summary, "retried 3 times") {
+		t.Errorf("the summary %v should contain the workload usage", summary)
+	} else if !contains(summary, "node userdev/an54321 of HA group group1") || contains(summary, "maintenance window") {
+		t.Errorf("the summary %v should contain the HA upgrade only", summary)
+	}
+}
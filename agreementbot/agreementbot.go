@@ -45,6 +45,9 @@ type DVState struct {
 // package level variable
 var patternManager *PatternManager
 var businessPolManager *BusinessPolicyManager
+var rolloutManager *RolloutManager
+var maintenanceManager *MaintenanceManager
+var searchOutcomes *SearchOutcomes
 
 // must be safely-constructed!!
 type AgreementBotWorker struct {
@@ -358,6 +361,9 @@ func (w *AgreementBotWorker) Initialize() bool {
 	// Give the policy manager a chance to read in all the policies. The agbot worker will not proceed past this point
 	// until it has some policies to work with.
 	businessPolManager = NewBusinessPolicyManager(w.Messages())
+	rolloutManager = NewRolloutManager(w.db)
+	maintenanceManager = NewMaintenanceManager(w.db)
+	searchOutcomes = NewSearchOutcomes()
 	w.MMSObjectPM = NewMMSObjectPolicyManager(w.BaseWorker.Manager.Config)
 	for {
 
@@ -438,6 +444,8 @@ func (w *AgreementBotWorker) Initialize() bool {
 	// Start the governance routines using the subworker APIs.
 	w.DispatchSubworker(GOVERN_AGREEMENTS, w.GovernAgreements, int(w.BaseWorker.Manager.Config.AgreementBot.ProcessGovernanceIntervalS), false)
 	w.DispatchSubworker(GOVERN_ARCHIVED_AGREEMENTS, w.GovernArchivedAgreements, 1800, false)
+	w.DispatchSubworker(GOVERN_ROLLOUTS, w.GovernRollouts, 30, false)
+	w.DispatchSubworker(GOVERN_DEFERRED_CANCELLATIONS, w.GovernDeferredCancellations, 60, false)
 	//w.DispatchSubworker(GOVERN_BC_NEEDS, w.GovernBlockchainNeeds, 60, false)
 	w.DispatchSubworker(MESSAGE_KEY_CHECK, w.messageKeyCheck, w.BaseWorker.Manager.Config.AgreementBot.MessageKeyCheck, false)
 	w.DispatchSubworker(SECRETS_UPDATE, w.secretsUpdate, w.BaseWorker.Manager.Config.GetSecretsUpdateCheck(), false)
@@ -19,6 +19,7 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
 )
@@ -54,6 +55,10 @@ func NewAPIListener(name string, config *config.HorizonConfig, db persistence.Ag
 		secretProvider: s,
 	}
 
+	if db != nil {
+		metrics.DefaultRegistry.RegisterCollector("agbot", listener.collectMetrics)
+	}
+
 	listener.listen(config.AgreementBot.APIListen)
 	return listener
 }
@@ -357,9 +362,12 @@ func (a *API) listen(apiListen string) {
 		router.HandleFunc("/policy/{org}/{name}", a.policy).Methods("GET", "OPTIONS")
 		router.HandleFunc("/policy/{name}/upgrade", a.policy).Methods("POST", "OPTIONS")
 		router.HandleFunc("/workloadusage", a.workloadusage).Methods("GET", "OPTIONS")
+		router.HandleFunc("/rollout", a.rollout).Methods("GET", "OPTIONS")
+		router.HandleFunc("/explain/{node_org}/{node_id}/{policy_org}/{policy_name}", a.explain).Methods("GET", "OPTIONS")
 		router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 		router.HandleFunc("/health", a.health).Methods("GET", "OPTIONS")
 		router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
+		router.HandleFunc("/metrics", a.metrics).Methods("GET", "OPTIONS")
 		router.HandleFunc("/node", a.node).Methods("GET", "DELETE", "OPTIONS")
 		router.HandleFunc("/config", a.config).Methods("GET", "OPTIONS")
 		router.HandleFunc("/cache/servedorg", a.ListServedOrgs).Methods("GET", "OPTIONS")
@@ -652,6 +660,29 @@ func (a *API) workloadusage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a *API) rollout(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		rollouts := make(map[string]*PolicyRollout)
+		if rolloutManager != nil {
+			var err error
+			if rollouts, err = rolloutManager.GetRollouts(); err != nil {
+				glog.Error(APIlogString(err))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		writeResponse(w, rollouts, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) status(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
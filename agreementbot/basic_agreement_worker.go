@@ -254,6 +254,8 @@ func (a *BasicAgreementWorker) start(work *PrioritizedWorkQueue) {
 					glog.Errorf(bwlogstring(a.workerID, fmt.Sprintf("error demarshalling policy from agreement %v, error: %v", wi.Reply.AgreementId(), err)))
 				} else if err := a.protocolHandler.RecordConsumerAgreementState(wi.Reply.AgreementId(), pol, ag.Org, "Finalized Agreement", a.workerID); err != nil {
 					glog.Errorf(bwlogstring(a.workerID, fmt.Sprintf("error setting agreement %v finalized state in exchange: %v", wi.Reply.AgreementId(), err)))
+				} else {
+					agbotAgreementsFinalized.Inc(a.protocolHandler.Name())
 				}
 				lock.Unlock()
 			}
@@ -164,6 +164,8 @@ func (c *BasicProtocolHandler) GetTerminationCode(reason string) uint {
 		return basicprotocol.AB_CANCEL_NODE_HEARTBEAT
 	case TERM_REASON_AG_MISSING:
 		return basicprotocol.AB_CANCEL_AG_MISSING
+	case TERM_REASON_ROLLOUT_ROLLBACK:
+		return basicprotocol.AB_CANCEL_ROLLOUT_ROLLBACK
 	default:
 		return 999
 	}
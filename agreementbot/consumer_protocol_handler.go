@@ -67,6 +67,7 @@ type ConsumerProtocolHandler interface {
 	SetBlockchainWritable(ev *events.AccountFundedMessage)
 	IsBlockchainWritable(typeName string, name string, org string) bool
 	CanCancelNow(agreement *persistence.Agreement) bool
+	CancelAgreement(ag persistence.Agreement, reason string, cph ConsumerProtocolHandler, policyMatches bool)
 	DeferCommand(cmd AgreementWork)
 	GetDeferredCommands() []AgreementWork
 	HandleDeferredCommands()
@@ -306,6 +307,7 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 		}
 
 		stillValidAgs := []string{}
+		rolloutNodes := []RolloutNode{}
 
 		if agreements, err := b.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), InProgress()}, cph.Name()); err == nil {
 			for _, ag := range agreements {
@@ -340,14 +342,28 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 						glog.Infof(BCPHlogstring(b.Name(), fmt.Sprintf("for current agreement %v: agStillValid: %v, policyMatches: %v, noNewPriority: %v, clusterNSNotChange: %v", ag.CurrentAgreementId, agStillValid, policyMatches, noNewPriority, clusterNSNotChange)))
 					}
 
-					if !agStillValid {
+					if !agStillValid && policyMatches && !noNewPriority && eventPol.Rollout != nil {
+						// Only the service version changed, leave the upgrade to the staged rollout.
+						if node, ok := b.stageRolloutUpgrade(ag, cph); ok {
+							glog.V(3).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v will be upgraded by the staged rollout of policy %v", ag.CurrentAgreementId, pol.Header.Name)))
+							rolloutNodes = append(rolloutNodes, *node)
+							stillValidAgs = append(stillValidAgs, ag.CurrentAgreementId)
+						} else if b.CancelOrDeferAgreement(ag, TERM_REASON_POLICY_CHANGED, cph, policyMatches) {
+							stillValidAgs = append(stillValidAgs, ag.CurrentAgreementId)
+						}
+					} else if !agStillValid {
 						glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v has a policy %v that has changed incompatibly. Cancelling agreement: %v", ag.CurrentAgreementId, pol.Header.Name, err)))
-						b.CancelAgreement(ag, TERM_REASON_POLICY_CHANGED, cph, policyMatches)
+						if b.CancelOrDeferAgreement(ag, TERM_REASON_POLICY_CHANGED, cph, policyMatches) {
+							stillValidAgs = append(stillValidAgs, ag.CurrentAgreementId)
+						}
 					} else {
 						if glog.V(5) {
 							glog.Infof(BCPHlogstring(b.Name(), fmt.Sprintf("current agreement %v is still valid", ag.CurrentAgreementId)))
 						}
 						stillValidAgs = append(stillValidAgs, ag.CurrentAgreementId)
+						if err := maintenanceManager.Remove(ag.CurrentAgreementId); err != nil {
+							glog.Errorf(BCPHlogstring(b.Name(), err))
+						}
 					}
 				} else {
 					if glog.V(5) {
@@ -360,6 +376,12 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 			glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("error searching database: %v", err)))
 		}
 
+		if len(rolloutNodes) != 0 {
+			if err := rolloutManager.AddRollout(eventPol, eventPol.NextHighestPriorityWorkload(0, 0, 0).Priority.PriorityValue, rolloutNodes); err != nil {
+				glog.Errorf(BCPHlogstring(b.Name(), err.Error()))
+			}
+		}
+
 		AgNotKept := func(validAgs []string) persistence.WUFilter {
 			return func(w persistence.WorkloadUsage) bool { return !cutil.SliceContains(validAgs, w.CurrentAgreementId) }
 		}
@@ -383,6 +405,41 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 	}
 }
 
+// Prepare an agreement to be upgraded by the staged rollout of its policy. Nodes in an HA group are not staged because the
+// HA group upgrade already upgrades them one at a time. The node's workload usage record is pinned at its current priority
+// until the rollout gets to it. Returns false if the agreement should be cancelled right away instead.
+func (b *BaseConsumerProtocolHandler) stageRolloutUpgrade(ag persistence.Agreement, cph ConsumerProtocolHandler) (*RolloutNode, bool) {
+	if rolloutManager == nil {
+		return nil, false
+	}
+
+	if theDev, err := GetDevice(b.config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), ag.DeviceId, b.config.AgreementBot.ExchangeURL, cph.GetExchangeId(), cph.GetExchangeToken()); err != nil {
+		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("error getting device %v, error: %v", ag.DeviceId, err)))
+		return nil, false
+	} else if theDev == nil || theDev.HAGroup != "" {
+		return nil, false
+	}
+
+	node := &RolloutNode{
+		DeviceId:    ag.DeviceId,
+		AgreementId: ag.CurrentAgreementId,
+		State:       ROLLOUT_NODE_PENDING,
+		Protocol:    ag.AgreementProtocol,
+	}
+
+	if wlUsage, err := b.db.FindSingleWorkloadUsageByDeviceAndPolicyName(ag.DeviceId, ag.PolicyName); err != nil {
+		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("error retreiving workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		return nil, false
+	} else if wlUsage != nil {
+		node.PreviousPriority = wlUsage.Priority
+		if _, err := b.db.DisableRollbackChecking(ag.DeviceId, ag.PolicyName); err != nil {
+			glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("unable to disable rollback checking for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		}
+	}
+
+	return node, true
+}
+
 // first bool is true if the policy still matches, false otherwise
 // second bool is true unless a higher priority workload than the current one has been added or changed
 // third bool is true if the cluster namespace is not changed, this return value should be check only when device type is cluster
@@ -572,6 +629,12 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyDeleted(cmd *PolicyDeletedComm
 		glog.Infof(BCPHlogstring(b.Name(), "received policy deleted command."))
 	}
 
+	if rolloutManager != nil {
+		if err := rolloutManager.DeleteRollout(cmd.Msg.PolicyName()); err != nil {
+			glog.Errorf(BCPHlogstring(b.Name(), err.Error()))
+		}
+	}
+
 	// Remove the workloadusage that has the same policy name and does not have the agreement id associated.
 	// For the ones with the agreement id, the agreements will get canceled and the workload usage will be removed anyway.
 	if eventPol, err := policy.DemarshalPolicy(cmd.Msg.PolicyString()); err != nil {
@@ -639,7 +702,11 @@ func (b *BaseConsumerProtocolHandler) HandleServicePolicyChanged(cmd *ServicePol
 				agStillValid := policyMatches && noNewPriority
 				if !agStillValid {
 					glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v has a service policy %v that has changed.", ag.CurrentAgreementId, ag.ServiceId)))
-					b.CancelAgreement(ag, TERM_REASON_POLICY_CHANGED, cph, policyMatches)
+					b.CancelOrDeferAgreement(ag, TERM_REASON_POLICY_CHANGED, cph, policyMatches)
+				} else {
+					if err := maintenanceManager.Remove(ag.CurrentAgreementId); err != nil {
+						glog.Errorf(BCPHlogstring(b.Name(), err))
+					}
 				}
 			}
 		}
@@ -664,8 +731,12 @@ func (b *BaseConsumerProtocolHandler) HandleNodePolicyChanged(cmd *NodePolicyCha
 				agStillValid := policyMatches && noNewPriority
 				if !agStillValid {
 					glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v has a node policy %v that has changed.", ag.CurrentAgreementId, ag.ServiceId)))
-					b.CancelAgreement(ag, TERM_REASON_POLICY_CHANGED, cph, policyMatches)
+					b.CancelOrDeferAgreement(ag, TERM_REASON_POLICY_CHANGED, cph, policyMatches)
 				} else {
+					if err := maintenanceManager.Remove(ag.CurrentAgreementId); err != nil {
+						glog.Errorf(BCPHlogstring(b.Name(), err))
+					}
+
 					// If the agreement is still valid, then handlePolicyChangeFor MMS object
 					b.HandlePolicyChangeForMMSObject(ag, cph)
 				}
@@ -1071,6 +1142,7 @@ const TERM_REASON_CANCEL_FORCED_UPGRADE = "ForceUpgrade"
 const TERM_REASON_CANCEL_BC_WRITE_FAILED = "WriteFailed"
 const TERM_REASON_NODE_HEARTBEAT = "NodeHeartbeat"
 const TERM_REASON_AG_MISSING = "AgreementMissing"
+const TERM_REASON_ROLLOUT_ROLLBACK = "RolloutRollback"
 
 var BCPHlogstring = func(p string, v interface{}) string {
 	return fmt.Sprintf("Base Consumer Protocol Handler (%v) %v", p, v)
@@ -453,6 +453,12 @@ func (w *AgreementBotWorker) governHAPartners() {
 			} else if device == nil {
 				// ignore it? continue to next waiting workload
 				continue
+			} else if !InMaintenanceWindow(w, w.pm, wlu.DeviceId, wlu.PolicyName, time.Now()) {
+				// the upgrade waits for the maintenance window of the node and the policy to open
+				if glog.V(5) {
+					glog.Infof(logString(fmt.Sprintf("maintenance window is closed, deferring upgrade of workload %v.", wlu.String())))
+				}
+				continue
 			} else if device.HAGroup == "" {
 				// update this workload:
 				if glog.V(5) {
@@ -0,0 +1,256 @@
+package agreementbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/policy"
+	"sync"
+	"time"
+)
+
+// Maintenance windows restrict when non-urgent workload changes are made on a node. Both the node policy and the
+// deployment policy can define maintenance windows, a change is only made when the windows of both are open. The
+// non-urgent changes are the agreement cancellations caused by policy changes, the HA group workload upgrades and the
+// waves of a staged rollout. Cancellations caused by deleted policies, node shutdown or a node that is no longer
+// healthy are not deferred.
+//
+// A deferred cancellation is kept in the agbot database until the maintenance windows open, partitioned like the agreement
+// it cancels. It is scheduled for the next time the windows can open, and the windows are not checked again before then.
+// A change to the node policy or the deployment policy defers the cancellation again, which schedules it for the changed
+// windows. The maintenance manager reads the deferred cancellations from the database each time it governs them, so they
+// are carried out after the agbot restarts, and the ones of a partition taken over from another agbot are picked up along
+// with its agreements.
+
+const GOVERN_DEFERRED_CANCELLATIONS = "AgBotGovernDeferredCancellations"
+
+type DeferredCancellation = persistence.DeferredCancellation
+
+// The maintenance manager holds the deferred cancellations. They are kept in the database, the manager serializes the
+// updates that this agbot makes to them.
+type MaintenanceManager struct {
+	deferredLock sync.Mutex
+	db           persistence.AgbotDatabase
+}
+
+func NewMaintenanceManager(db persistence.AgbotDatabase) *MaintenanceManager {
+	return &MaintenanceManager{
+		db: db,
+	}
+}
+
+// Return the deferred cancellation of the agreement, or nil if there isn't one.
+func (mm *MaintenanceManager) find(agreementId string) (*DeferredCancellation, error) {
+	deferred, err := mm.db.FindDeferredCancellations()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read deferred cancellations, error: %v", err))
+	}
+	for ix := range deferred {
+		if deferred[ix].AgreementId == agreementId {
+			return &deferred[ix], nil
+		}
+	}
+	return nil, nil
+}
+
+// Defer the cancellation of an agreement until the given time. A later deferral of the same agreement replaces the earlier
+// one, but keeps the time the agreement was first deferred.
+func (mm *MaintenanceManager) Defer(ag persistence.Agreement, reason string, policyMatches bool, nextCheck time.Time) error {
+	mm.deferredLock.Lock()
+	defer mm.deferredLock.Unlock()
+
+	deferredTime := uint64(time.Now().Unix())
+	if d, err := mm.find(ag.CurrentAgreementId); err != nil {
+		return err
+	} else if d != nil {
+		deferredTime = d.DeferredTime
+	}
+
+	d := DeferredCancellation{
+		AgreementId:   ag.CurrentAgreementId,
+		Protocol:      ag.AgreementProtocol,
+		DeviceId:      ag.DeviceId,
+		PolicyName:    ag.PolicyName,
+		Reason:        reason,
+		PolicyMatches: policyMatches,
+		DeferredTime:  deferredTime,
+		NextCheckTime: checkTime(nextCheck),
+	}
+	if err := mm.db.SaveDeferredCancellation(&d); err != nil {
+		return errors.New(fmt.Sprintf("unable to save deferred cancellation of agreement %v, error: %v", ag.CurrentAgreementId, err))
+	}
+	return nil
+}
+
+// Schedule the next check of a deferred cancellation whose maintenance windows are still closed.
+func (mm *MaintenanceManager) Reschedule(agreementId string, nextCheck time.Time) error {
+	mm.deferredLock.Lock()
+	defer mm.deferredLock.Unlock()
+
+	if d, err := mm.find(agreementId); err != nil || d == nil {
+		return err
+	} else {
+		d.NextCheckTime = checkTime(nextCheck)
+		if err := mm.db.SaveDeferredCancellation(d); err != nil {
+			return errors.New(fmt.Sprintf("unable to save deferred cancellation of agreement %v, error: %v", agreementId, err))
+		}
+	}
+	return nil
+}
+
+func checkTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix())
+}
+
+// Forget the deferred cancellation of an agreement. This is called for every agreement that is still valid after a policy
+// change, so the database is only written when the agreement has a deferred cancellation.
+func (mm *MaintenanceManager) Remove(agreementId string) error {
+	if mm == nil {
+		return nil
+	}
+	mm.deferredLock.Lock()
+	defer mm.deferredLock.Unlock()
+
+	if d, err := mm.find(agreementId); err != nil || d == nil {
+		return err
+	} else if err := mm.db.DeleteDeferredCancellation(agreementId); err != nil {
+		return errors.New(fmt.Sprintf("unable to delete deferred cancellation of agreement %v, error: %v", agreementId, err))
+	}
+	return nil
+}
+
+// Return the deferred cancellations.
+func (mm *MaintenanceManager) GetDeferred() ([]DeferredCancellation, error) {
+	mm.deferredLock.Lock()
+	defer mm.deferredLock.Unlock()
+
+	deferred, err := mm.db.FindDeferredCancellations()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read deferred cancellations, error: %v", err))
+	}
+	return deferred, nil
+}
+
+// Returns the maintenance windows that restrict the changes on the node, the node's windows come from its node policy and
+// the policy's windows from the deployment policy. If either policy cannot be found, it does not restrict the changes.
+func getMaintenanceWindows(ec exchange.ExchangeContext, pm *policy.PolicyManager, deviceId string, policyName string) []exchangecommon.MaintenanceWindows {
+	windows := []exchangecommon.MaintenanceWindows{}
+	if nodePol, err := exchange.GetNodePolicy(ec, deviceId); err != nil {
+		glog.Warningf(mwLogString(fmt.Sprintf("unable to get node policy for %v, error: %v", deviceId, err)))
+	} else if nodePol != nil {
+		windows = append(windows, nodePol.MaintenanceWindows)
+	}
+
+	if pm != nil {
+		if pol := pm.GetPolicy(exchange.GetOrg(policyName), policyName); pol != nil {
+			windows = append(windows, pol.MaintenanceWindows)
+		}
+	}
+	return windows
+}
+
+// Returns true if all of the maintenance windows are open at the given time.
+func maintenanceWindowsOpen(windows []exchangecommon.MaintenanceWindows, now time.Time) bool {
+	for _, w := range windows {
+		if !w.IsOpen(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the earliest time at which all of the maintenance windows can be open. The windows that are closed open at their
+// next opening time at the earliest, so it is the latest of those times. The zero time is returned if one of the closed
+// windows never opens.
+func nextMaintenanceWindow(windows []exchangecommon.MaintenanceWindows, now time.Time) time.Time {
+	next := now
+	for _, w := range windows {
+		if w.IsOpen(now) {
+			continue
+		} else if n := w.NextOpen(now); n.IsZero() {
+			return time.Time{}
+		} else if n.After(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// Returns true if non-urgent workload changes can be made on the node at the given time.
+func InMaintenanceWindow(ec exchange.ExchangeContext, pm *policy.PolicyManager, deviceId string, policyName string, now time.Time) bool {
+	return maintenanceWindowsOpen(getMaintenanceWindows(ec, pm, deviceId, policyName), now)
+}
+
+// Cancel an agreement because of a policy change, or defer the cancellation until the maintenance windows of the node
+// and the deployment policy open. Returns true if the cancellation was deferred.
+func (b *BaseConsumerProtocolHandler) CancelOrDeferAgreement(ag persistence.Agreement, reason string, cph ConsumerProtocolHandler, policyMatches bool) bool {
+	if maintenanceManager != nil {
+		now := time.Now()
+		if windows := getMaintenanceWindows(b, b.pm, ag.DeviceId, ag.PolicyName); !maintenanceWindowsOpen(windows, now) {
+			next := nextMaintenanceWindow(windows, now)
+			if err := maintenanceManager.Defer(ag, reason, policyMatches, next); err != nil {
+				glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("cancelling agreement %v without waiting for the maintenance window, %v", ag.CurrentAgreementId, err)))
+			} else {
+				glog.V(3).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("deferring cancellation of agreement %v until the maintenance window of node %v and policy %v opens, next check at %v", ag.CurrentAgreementId, ag.DeviceId, ag.PolicyName, next)))
+				return true
+			}
+		}
+	}
+
+	if err := maintenanceManager.Remove(ag.CurrentAgreementId); err != nil {
+		glog.Errorf(BCPHlogstring(b.Name(), err))
+	}
+	b.CancelAgreement(ag, reason, cph, policyMatches)
+	return false
+}
+
+// The deferred cancellation governor subworker. It cancels the deferred agreements whose maintenance windows are open,
+// and forgets the ones that have already ended. The deferrals that are scheduled for a later time are skipped.
+func (w *AgreementBotWorker) GovernDeferredCancellations() int {
+	if maintenanceManager == nil {
+		return 0
+	}
+
+	deferred, err := maintenanceManager.GetDeferred()
+	if err != nil {
+		glog.Errorf(mwLogString(err))
+		return 0
+	}
+
+	unarchived := []persistence.AFilter{persistence.UnarchivedAFilter()}
+	now := time.Now()
+	for _, d := range deferred {
+		if d.NextCheckTime > uint64(now.Unix()) {
+			continue
+		} else if ag, err := w.db.FindSingleAgreementByAgreementId(d.AgreementId, d.Protocol, unarchived); err != nil {
+			glog.Errorf(mwLogString(fmt.Sprintf("unable to read agreement %v from database, error: %v", d.AgreementId, err)))
+		} else if ag == nil || ag.AgreementTimedout != 0 {
+			glog.V(5).Infof(mwLogString(fmt.Sprintf("agreement %v with a deferred cancellation has already ended", d.AgreementId)))
+			if err := maintenanceManager.Remove(d.AgreementId); err != nil {
+				glog.Errorf(mwLogString(err))
+			}
+		} else if windows := getMaintenanceWindows(w, w.pm, d.DeviceId, d.PolicyName); maintenanceWindowsOpen(windows, now) {
+			glog.V(3).Infof(mwLogString(fmt.Sprintf("maintenance window open, cancelling agreement %v deferred since %v", d.AgreementId, d.DeferredTime)))
+			if err := maintenanceManager.Remove(d.AgreementId); err != nil {
+				glog.Errorf(mwLogString(err))
+			}
+			cph := w.consumerPH.Get(d.Protocol)
+			cph.CancelAgreement(*ag, d.Reason, cph, d.PolicyMatches)
+		} else {
+			if err := maintenanceManager.Reschedule(d.AgreementId, nextMaintenanceWindow(windows, now)); err != nil {
+				glog.Errorf(mwLogString(err))
+			}
+		}
+	}
+	return 0
+}
+
+var mwLogString = func(v interface{}) string {
+	return fmt.Sprintf("Maintenance Manager: %v", v)
+}
@@ -0,0 +1,121 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/agreementbot/persistence/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchangecommon"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_nextMaintenanceWindow(t *testing.T) {
+
+	// Saturday 2am for an hour, and every day at 4am for two hours.
+	saturday := exchangecommon.MaintenanceWindows{{Schedule: "0 2 * * 6", Duration: 3600}}
+	daily := exchangecommon.MaintenanceWindows{{Schedule: "0 4 * * *", Duration: 7200}}
+
+	// Thursday 10am, neither window is open.
+	now := time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)
+	if next := nextMaintenanceWindow([]exchangecommon.MaintenanceWindows{daily}, now); !next.Equal(time.Date(2020, 10, 2, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("the daily window should open next on Friday at 4am, not %v", next)
+	} else if next := nextMaintenanceWindow([]exchangecommon.MaintenanceWindows{daily, saturday}, now); !next.Equal(time.Date(2020, 10, 3, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("both windows cannot be open before Saturday at 2am, not %v", next)
+	}
+
+	// While the daily window is open, the other window is the one to wait for.
+	now = time.Date(2020, 10, 1, 5, 0, 0, 0, time.UTC)
+	if next := nextMaintenanceWindow([]exchangecommon.MaintenanceWindows{daily, saturday}, now); !next.Equal(time.Date(2020, 10, 3, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("the Saturday window should be waited for, not %v", next)
+	} else if next := nextMaintenanceWindow([]exchangecommon.MaintenanceWindows{daily, {}}, now); !next.Equal(now) {
+		t.Errorf("open windows should not be waited for, not %v", next)
+	}
+
+	// A window that never opens is checked every time.
+	never := exchangecommon.MaintenanceWindows{{Schedule: "0 0 30 2 *", Duration: 3600}}
+	if next := nextMaintenanceWindow([]exchangecommon.MaintenanceWindows{daily, never}, now); !next.IsZero() {
+		t.Errorf("a window that never opens should not be scheduled, not %v", next)
+	}
+}
+
+// Start a maintenance manager on a bolt database in the directory.
+func newTestMaintenanceManager(t *testing.T, dir string) (*MaintenanceManager, *bolt.AgbotBoltDB) {
+	db := new(bolt.AgbotBoltDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: dir}}); err != nil {
+		t.Fatalf("unable to initialize the database, error: %v", err)
+	}
+	return NewMaintenanceManager(db), db
+}
+
+func getTestDeferred(t *testing.T, mm *MaintenanceManager) []DeferredCancellation {
+	deferred, err := mm.GetDeferred()
+	if err != nil {
+		t.Fatalf("unable to get deferred cancellations, error: %v", err)
+	}
+	return deferred
+}
+
+func Test_MaintenanceManager_Reschedule(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "maintenance-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mm, db := newTestMaintenanceManager(t, dir)
+	defer db.Close()
+
+	ag := persistence.Agreement{CurrentAgreementId: "ag1", AgreementProtocol: "Basic", DeviceId: "myorg/node1", PolicyName: "myorg/bp1"}
+	next := time.Date(2020, 10, 3, 2, 0, 0, 0, time.UTC)
+
+	if err := mm.Defer(ag, "policy changed", true, next); err != nil {
+		t.Fatalf("unable to defer cancellation, error: %v", err)
+	} else if d := getTestDeferred(t, mm); len(d) != 1 || d[0].NextCheckTime != uint64(next.Unix()) || d[0].DeferredTime == 0 {
+		t.Errorf("the cancellation should be deferred until %v: %v", next, d)
+	}
+
+	deferredTime := getTestDeferred(t, mm)[0].DeferredTime
+	if err := mm.Reschedule("ag1", time.Time{}); err != nil {
+		t.Errorf("unable to reschedule cancellation, error: %v", err)
+	} else if d := getTestDeferred(t, mm); len(d) != 1 || d[0].NextCheckTime != 0 || d[0].DeferredTime != deferredTime {
+		t.Errorf("the cancellation should be checked every time and keep its deferred time: %v", d)
+	}
+
+	if err := mm.Reschedule("ag2", next); err != nil {
+		t.Errorf("unable to reschedule cancellation, error: %v", err)
+	} else if err := mm.Remove("ag1"); err != nil {
+		t.Errorf("unable to remove cancellation, error: %v", err)
+	} else if d := getTestDeferred(t, mm); len(d) != 0 {
+		t.Errorf("rescheduling should not add deferred cancellations: %v", d)
+	}
+}
+
+func Test_MaintenanceManager_Restart(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "maintenance-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mm, db := newTestMaintenanceManager(t, dir)
+	ag := persistence.Agreement{CurrentAgreementId: "ag1", AgreementProtocol: "Basic", DeviceId: "myorg/node1", PolicyName: "myorg/bp1"}
+	next := time.Date(2020, 10, 3, 2, 0, 0, 0, time.UTC)
+	if err := mm.Defer(ag, "policy changed", false, next); err != nil {
+		t.Fatalf("unable to defer cancellation, error: %v", err)
+	}
+	db.Close()
+
+	// After a restart, the deferred cancellation is still scheduled.
+	mm, db = newTestMaintenanceManager(t, dir)
+	defer db.Close()
+
+	if d := getTestDeferred(t, mm); len(d) != 1 || d[0].AgreementId != "ag1" || d[0].Reason != "policy changed" || d[0].NextCheckTime != uint64(next.Unix()) {
+		t.Errorf("the deferred cancellation should be restored: %v", d)
+	}
+}
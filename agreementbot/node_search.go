@@ -274,6 +274,9 @@ func (n *NodeSearch) findAndMakeAgreements() {
 		n.setLastSearchHasErr()
 	}
 
+	// Forget the search outcomes of nodes that have not been returned by a search for a while.
+	searchOutcomes.RemoveOlderThan(uint64(time.Now().Unix()) - SEARCH_OUTCOME_RETENTION_S)
+
 	if doClearSearchedMap {
 		glog.V(3).Infof(AWlogString(fmt.Sprintf("OK to clear search map; length of map %d", len(n.completedSearches))))
 		n.completedSearches = make(map[string]bool)
@@ -312,9 +315,11 @@ func (n *NodeSearch) getOrderedPolicies(org string) []policy.Policy {
 func (n *NodeSearch) searchNodesAndMakeAgreements(consumerPolicy *policy.Policy, org string, polName string, polLastUpdateTime uint64) (bool, error) {
 
 	endOfResults := true
+	searchTime := uint64(time.Now().Unix())
 
 	if devices, err := n.searchExchange(consumerPolicy, org, polName, polLastUpdateTime); err != nil {
 		glog.Errorf(AWlogString(fmt.Sprintf("received error searching for %v, error: %v", consumerPolicy, err)))
+		searchOutcomes.RecordSearch(consumerPolicy.Header.Name, consumerPolicy.PatternId, 0, false, err, searchTime)
 		return endOfResults, err
 
 	} else {
@@ -324,6 +329,9 @@ func (n *NodeSearch) searchNodesAndMakeAgreements(consumerPolicy *policy.Policy,
 			endOfResults = false
 		}
 
+		// Remember the search so that the agbot can explain why a node does not have an agreement.
+		searchOutcomes.RecordSearch(consumerPolicy.Header.Name, consumerPolicy.PatternId, len(*devices), endOfResults, nil, searchTime)
+
 		// Get all the agreements for this policy that are still active.
 		pendingAgreementFilter := func() persistence.AFilter {
 			return func(a persistence.Agreement) bool {
@@ -359,12 +367,14 @@ func (n *NodeSearch) searchNodesAndMakeAgreements(consumerPolicy *policy.Policy,
 			// Check for agreements already in progress with this device
 			if found := n.alreadyMakingAgreementWith(&dev, consumerPolicy, ags); found {
 				glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, agreement attempt already in progress with %v", dev.Id, consumerPolicy.Header.Name)))
+				searchOutcomes.RecordNode(consumerPolicy.Header.Name, dev.Id, SEARCH_OUTCOME_AGREEMENT_EXISTS, "", searchTime)
 				continue
 			}
 
 			// If the device is not ready to make agreements yet, then skip it.
 			if dev.PublicKey == "" {
 				glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, node is not ready to exchange messages", dev.Id)))
+				searchOutcomes.RecordNode(consumerPolicy.Header.Name, dev.Id, SEARCH_OUTCOME_NODE_NOT_READY, "the node has no public key", searchTime)
 				continue
 			}
 
@@ -390,16 +400,20 @@ func (n *NodeSearch) searchNodesAndMakeAgreements(consumerPolicy *policy.Policy,
 
 			if !n.ph.Has(protocol) {
 				glog.Errorf(AWlogString(fmt.Sprintf("unable to find protocol handler for %v.", protocol)))
+				searchOutcomes.RecordNode(consumerPolicy.Header.Name, dev.Id, SEARCH_OUTCOME_NO_PROTOCOL_HANDLER, protocol, searchTime)
 			} else if bcType != "" && !n.ph.Get(protocol).IsBlockchainWritable(bcType, bcName, bcOrg) {
 				// Get that blockchain running if it isn't up.
 				glog.V(5).Infof(AWlogString(fmt.Sprintf("skipping device id %v, requires blockchain %v %v %v that isnt ready yet.", dev.Id, bcType, bcName, bcOrg)))
+				searchOutcomes.RecordNode(consumerPolicy.Header.Name, dev.Id, SEARCH_OUTCOME_BLOCKCHAIN_NOT_READY, fmt.Sprintf("%v %v %v", bcType, bcName, bcOrg), searchTime)
 				n.msgs <- events.NewNewBCContainerMessage(events.NEW_BC_CLIENT, bcType, bcName, bcOrg, n.ec.GetExchangeURL(), n.ec.GetExchangeId(), n.ec.GetExchangeToken())
 				continue
 			} else if !n.ph.Get(protocol).AcceptCommand(cmd) {
 				glog.Errorf(AWlogString(fmt.Sprintf("protocol handler for %v not accepting new agreement commands.", protocol)))
+				searchOutcomes.RecordNode(consumerPolicy.Header.Name, dev.Id, SEARCH_OUTCOME_HANDLER_BUSY, protocol, searchTime)
 			} else {
 				n.ph.Get(protocol).HandleMakeAgreement(cmd, n.ph.Get(protocol))
 				glog.V(5).Infof(AWlogString(fmt.Sprintf("queued agreement attempt for policy %v and node %v using protocol %v", consumerPolicy.Header.Name, dev.Id, protocol)))
+				searchOutcomes.RecordNode(consumerPolicy.Header.Name, dev.Id, SEARCH_OUTCOME_AGREEMENT_QUEUED, protocol, searchTime)
 			}
 		}
 
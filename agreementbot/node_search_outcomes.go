@@ -0,0 +1,156 @@
+package agreementbot
+
+import (
+	"sort"
+	"sync"
+)
+
+// The outcomes of the node searches are kept in memory so that the agbot can explain why a node does or does not have an
+// agreement for a policy. They are lost when the agbot restarts, and the outcomes of a node are dropped when the node
+// has not been returned by a search for SEARCH_OUTCOME_RETENTION_S seconds.
+const SEARCH_OUTCOME_RETENTION_S = 86400
+
+// What the agbot did with a node returned by a node search.
+const (
+	SEARCH_OUTCOME_AGREEMENT_EXISTS     = "agreement_in_progress" // there is already an agreement with the node for the policy, it may not be finalized yet
+	SEARCH_OUTCOME_NODE_NOT_READY       = "node_not_ready"        // the node has not published its public key yet
+	SEARCH_OUTCOME_BLOCKCHAIN_NOT_READY = "blockchain_not_ready"  // the blockchain required by the policy is not ready
+	SEARCH_OUTCOME_NO_PROTOCOL_HANDLER  = "no_protocol_handler"   // the agbot does not support the agreement protocol of the policy
+	SEARCH_OUTCOME_HANDLER_BUSY         = "protocol_handler_busy" // the protocol handler did not accept a new agreement attempt
+	SEARCH_OUTCOME_AGREEMENT_QUEUED     = "agreement_queued"      // an agreement attempt was queued, the agreement worker checks the compatibility
+)
+
+// The last search of a policy.
+type policySearchOutcome struct {
+	pattern    string // the pattern the policy was generated from, empty for a deployment policy
+	time       uint64
+	nodesFound int
+	complete   bool // all the pages of the search result have been returned
+	err        string
+}
+
+// The last time a node was returned by a search of a policy.
+type nodeSearchOutcome struct {
+	time    uint64
+	outcome string // one of the SEARCH_OUTCOME_* values
+	detail  string
+}
+
+// The search outcomes of a policy for a node, as returned by the explain API.
+type NodeSearchExplanation struct {
+	Policy      string `json:"policy"`            // the agbot's internal policy name
+	Pattern     string `json:"pattern,omitempty"` // the pattern the policy was generated from
+	SearchTime  uint64 `json:"search_time"`       // the time of the last search of the policy
+	NodesFound  int    `json:"nodes_found"`       // the number of nodes returned by the last search
+	Complete    bool   `json:"complete"`          // false when the last search returned a page of a larger result set
+	SearchError string `json:"search_error,omitempty"`
+	NodeFound   bool   `json:"node_found"`          // the node was returned by a search of the policy within the retention time
+	NodeTime    uint64 `json:"node_time,omitempty"` // the last time the node was returned by a search of the policy
+	Outcome     string `json:"outcome,omitempty"`   // what the agbot did with the node, one of the SEARCH_OUTCOME_* values
+	Detail      string `json:"detail,omitempty"`
+}
+
+// The SearchOutcomes object is written by the node search thread and read by the API. All the functions are thread safe,
+// and can be called on a nil object, which records nothing.
+type SearchOutcomes struct {
+	lock     sync.Mutex
+	policies map[string]*policySearchOutcome          // keyed by the internal policy name
+	nodes    map[string]map[string]*nodeSearchOutcome // keyed by the internal policy name and then by the node id
+}
+
+func NewSearchOutcomes() *SearchOutcomes {
+	return &SearchOutcomes{
+		policies: make(map[string]*policySearchOutcome),
+		nodes:    make(map[string]map[string]*nodeSearchOutcome),
+	}
+}
+
+// Record a search of the given policy. The pattern is empty for a deployment policy.
+func (s *SearchOutcomes) RecordSearch(policyName string, pattern string, nodesFound int, complete bool, err error, now uint64) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o := &policySearchOutcome{pattern: pattern, time: now, nodesFound: nodesFound, complete: complete}
+	if err != nil {
+		o.err = err.Error()
+	}
+	s.policies[policyName] = o
+}
+
+// Record what the agbot did with a node returned by a search of the given policy.
+func (s *SearchOutcomes) RecordNode(policyName string, nodeId string, outcome string, detail string, now uint64) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.nodes[policyName]; !ok {
+		s.nodes[policyName] = make(map[string]*nodeSearchOutcome)
+	}
+	s.nodes[policyName][nodeId] = &nodeSearchOutcome{time: now, outcome: outcome, detail: detail}
+}
+
+// Drop the node outcomes that were recorded before the given time, and the searches of the policies that have not been
+// searched since then.
+func (s *SearchOutcomes) RemoveOlderThan(cutoff uint64) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for policyName, nodes := range s.nodes {
+		for nodeId, o := range nodes {
+			if o.time < cutoff {
+				delete(nodes, nodeId)
+			}
+		}
+		if len(nodes) == 0 {
+			delete(s.nodes, policyName)
+		}
+	}
+	for policyName, o := range s.policies {
+		if o.time < cutoff {
+			delete(s.policies, policyName)
+		}
+	}
+}
+
+// Return the search outcomes of the node for the given deployment policy or pattern (org/name). A pattern has an
+// internal policy for each architecture of its services, so there can be more than one result.
+func (s *SearchOutcomes) Explain(policyOrPattern string, nodeId string) []NodeSearchExplanation {
+	res := []NodeSearchExplanation{}
+	if s == nil {
+		return res
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for policyName, o := range s.policies {
+		if policyName != policyOrPattern && o.pattern != policyOrPattern {
+			continue
+		}
+		e := NodeSearchExplanation{
+			Policy:      policyName,
+			Pattern:     o.pattern,
+			SearchTime:  o.time,
+			NodesFound:  o.nodesFound,
+			Complete:    o.complete,
+			SearchError: o.err,
+		}
+		if n, ok := s.nodes[policyName][nodeId]; ok {
+			e.NodeFound = true
+			e.NodeTime = n.time
+			e.Outcome = n.outcome
+			e.Detail = n.detail
+		}
+		res = append(res, e)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Policy < res[j].Policy })
+	return res
+}
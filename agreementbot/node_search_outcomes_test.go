@@ -0,0 +1,70 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_SearchOutcomes(t *testing.T) {
+
+	s := NewSearchOutcomes()
+
+	// a deployment policy and the 2 policies generated from a pattern
+	s.RecordSearch("e2edev/bp_gpstest", "", 2, true, nil, 100)
+	s.RecordNode("e2edev/bp_gpstest", "userdev/an12345", SEARCH_OUTCOME_AGREEMENT_QUEUED, "Basic", 100)
+	s.RecordNode("e2edev/bp_gpstest", "userdev/an54321", SEARCH_OUTCOME_NODE_NOT_READY, "", 100)
+	s.RecordSearch("e2edev/sall_amd64", "e2edev/sall", 1, true, nil, 150)
+	s.RecordNode("e2edev/sall_amd64", "userdev/an12345", SEARCH_OUTCOME_AGREEMENT_EXISTS, "", 150)
+	s.RecordSearch("e2edev/sall_arm", "e2edev/sall", 0, false, errors.New("exchange unavailable"), 200)
+
+	res := s.Explain("e2edev/bp_gpstest", "userdev/an12345")
+	if len(res) != 1 {
+		t.Fatalf("there should be 1 search outcome, but there are %v", res)
+	} else if !res[0].NodeFound || res[0].Outcome != SEARCH_OUTCOME_AGREEMENT_QUEUED || res[0].Detail != "Basic" || res[0].NodesFound != 2 || !res[0].Complete {
+		t.Errorf("wrong search outcome %v", res[0])
+	}
+
+	res = s.Explain("e2edev/sall", "userdev/an12345")
+	if len(res) != 2 {
+		t.Fatalf("there should be 2 search outcomes, but there are %v", res)
+	} else if res[0].Policy != "e2edev/sall_amd64" || res[0].Outcome != SEARCH_OUTCOME_AGREEMENT_EXISTS || res[0].Pattern != "e2edev/sall" {
+		t.Errorf("wrong search outcome %v", res[0])
+	} else if res[1].Policy != "e2edev/sall_arm" || res[1].NodeFound || res[1].SearchError != "exchange unavailable" || res[1].Complete {
+		t.Errorf("wrong search outcome %v", res[1])
+	}
+
+	if res := s.Explain("e2edev/bp_other", "userdev/an12345"); len(res) != 0 {
+		t.Errorf("there should be no search outcome, but there are %v", res)
+	}
+
+	// a newer search keeps the outcome of a node that was not returned again
+	s.RecordSearch("e2edev/bp_gpstest", "", 0, true, nil, 300)
+	s.RecordNode("e2edev/bp_gpstest", "userdev/an54321", SEARCH_OUTCOME_AGREEMENT_QUEUED, "Basic", 300)
+	if res := s.Explain("e2edev/bp_gpstest", "userdev/an12345"); len(res) != 1 || res[0].SearchTime != 300 || res[0].NodeTime != 100 {
+		t.Errorf("wrong search outcome %v", res)
+	}
+
+	// the old outcomes are dropped
+	s.RemoveOlderThan(250)
+	if res := s.Explain("e2edev/bp_gpstest", "userdev/an12345"); len(res) != 1 || res[0].NodeFound {
+		t.Errorf("the node outcome should have been dropped, but is %v", res)
+	}
+	if res := s.Explain("e2edev/bp_gpstest", "userdev/an54321"); len(res) != 1 || !res[0].NodeFound {
+		t.Errorf("the node outcome should have been kept, but is %v", res)
+	}
+	if res := s.Explain("e2edev/sall", "userdev/an12345"); len(res) != 0 {
+		t.Errorf("the pattern searches should have been dropped, but are %v", res)
+	}
+
+	// a nil object records nothing
+	var none *SearchOutcomes
+	none.RecordSearch("e2edev/bp_gpstest", "", 1, true, nil, 100)
+	none.RecordNode("e2edev/bp_gpstest", "userdev/an12345", SEARCH_OUTCOME_AGREEMENT_QUEUED, "", 100)
+	none.RemoveOlderThan(100)
+	if res := none.Explain("e2edev/bp_gpstest", "userdev/an12345"); len(res) != 0 {
+		t.Errorf("there should be no search outcome, but there are %v", res)
+	}
+}
@@ -93,6 +93,11 @@ func (db *AgbotBoltDB) AgreementAttempt(agreementid string, org string, deviceid
 	}
 }
 
+// Write an agreement from another agbot database as it is.
+func (db *AgbotBoltDB) ImportAgreement(ag *persistence.Agreement, protocol string) error {
+	return db.persistNew(ag.CurrentAgreementId, bucketName(protocol), ag)
+}
+
 func (db *AgbotBoltDB) AgreementUpdate(agreementid string, proposal string, policy string, dvPolicy policy.DataVerification, defaultCheckRate uint64, hash string, sig string, protocol string, agreementProtoVersion int) (*persistence.Agreement, error) {
 	return persistence.AgreementUpdate(db, agreementid, proposal, policy, dvPolicy, defaultCheckRate, hash, sig, protocol, agreementProtoVersion)
 }
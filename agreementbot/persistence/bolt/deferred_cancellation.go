@@ -0,0 +1,59 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+const DEFERRED_CANCELLATION_BUCKET = "deferred_cancellation"
+
+// The deferred cancellations are keyed by agreement id, so saving one replaces the deferral of the same agreement.
+func (db *AgbotBoltDB) SaveDeferredCancellation(d *persistence.DeferredCancellation) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(DEFERRED_CANCELLATION_BUCKET)); err != nil {
+			return err
+		} else if serialized, err := json.Marshal(d); err != nil {
+			return fmt.Errorf("Failed to serialize deferred cancellation record: %v. Error: %v", d, err)
+		} else if err := b.Put([]byte(d.AgreementId), serialized); err != nil {
+			return fmt.Errorf("Failed to write deferred cancellation with key: %v. Error: %v", d.AgreementId, err)
+		} else {
+			glog.V(5).Infof("Succeeded writing deferred cancellation record %v", d)
+			return nil
+		}
+	})
+}
+
+func (db *AgbotBoltDB) FindDeferredCancellations() ([]persistence.DeferredCancellation, error) {
+	deferred := make([]persistence.DeferredCancellation, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(DEFERRED_CANCELLATION_BUCKET)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var d persistence.DeferredCancellation
+				if err := json.Unmarshal(v, &d); err != nil {
+					return fmt.Errorf("Failed to deserialize deferred cancellation record: %v. Error: %v", string(v), err)
+				}
+				deferred = append(deferred, d)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return deferred, nil
+}
+
+func (db *AgbotBoltDB) DeleteDeferredCancellation(agreementId string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(DEFERRED_CANCELLATION_BUCKET)); b != nil {
+			return b.Delete([]byte(agreementId))
+		}
+		return nil
+	})
+}
@@ -21,9 +21,9 @@ func (db *AgbotBoltDB) CheckIfGroupPresentAndUpdateHATable(requestingNode persis
 
 			// there is no node in this group updating. put the requesting node into the table
 			if dbNodeJson == nil {
-				if serialized, err := json.Marshal(dbNodeJson); err != nil {
+				if serialized, err := json.Marshal(requestingNode); err != nil {
 					return err
-				} else if err = b.Put([]byte(HABUCKET), serialized); err != nil {
+				} else if err = b.Put([]byte(groupId(requestingNode.OrgId, requestingNode.GroupName)), serialized); err != nil {
 					return err
 				}
 				updatedDBNode = requestingNode
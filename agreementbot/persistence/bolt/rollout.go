@@ -0,0 +1,59 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+const ROLLOUT_BUCKET = "rollout"
+
+// The rollouts are keyed by policy name, so saving a rollout replaces the rollout of the same policy.
+func (db *AgbotBoltDB) SaveRollout(r *persistence.Rollout) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(ROLLOUT_BUCKET)); err != nil {
+			return err
+		} else if serialized, err := json.Marshal(r); err != nil {
+			return fmt.Errorf("Failed to serialize rollout record: %v. Error: %v", r, err)
+		} else if err := b.Put([]byte(r.PolicyName), serialized); err != nil {
+			return fmt.Errorf("Failed to write rollout with key: %v. Error: %v", r.PolicyName, err)
+		} else {
+			glog.V(5).Infof("Succeeded writing rollout record %v", r)
+			return nil
+		}
+	})
+}
+
+func (db *AgbotBoltDB) FindRollouts() ([]persistence.Rollout, error) {
+	rollouts := make([]persistence.Rollout, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(ROLLOUT_BUCKET)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var r persistence.Rollout
+				if err := json.Unmarshal(v, &r); err != nil {
+					return fmt.Errorf("Failed to deserialize rollout record: %v. Error: %v", string(v), err)
+				}
+				rollouts = append(rollouts, r)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return rollouts, nil
+}
+
+func (db *AgbotBoltDB) DeleteRollout(policyName string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(ROLLOUT_BUCKET)); b != nil {
+			return b.Delete([]byte(policyName))
+		}
+		return nil
+	})
+}
@@ -1,5 +1,9 @@
 package bolt
 
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
 func (db *AgbotBoltDB) AddManagedPolicySecret(secretOrg, secretName, policyOrg, policyName string, secretExists bool, updateTime int64) error {
 	return nil
 }
@@ -55,3 +59,7 @@ func (db *AgbotBoltDB) DeleteSecretsForPattern(polOrg, polName string) error {
 func (db *AgbotBoltDB) DeletePatternSecret(secretOrg, secretName, policyOrg, policyName string) error {
 	return nil
 }
+
+func (db *AgbotBoltDB) FindManagedSecrets() ([]persistence.ManagedSecret, error) {
+	return []persistence.ManagedSecret{}, nil
+}
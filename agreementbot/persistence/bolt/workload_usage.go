@@ -25,6 +25,18 @@ func (db *AgbotBoltDB) NewWorkloadUsage(deviceId string, policy string, policyNa
 	}
 }
 
+// Write a workload usage from another agbot database. The record gets a new primary key from this database.
+func (db *AgbotBoltDB) ImportWorkloadUsage(wu *persistence.WorkloadUsage) error {
+	wlUsage := *wu
+	if existing, err := db.FindSingleWorkloadUsageByDeviceAndPolicyName(wu.DeviceId, wu.PolicyName); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("Workload usage record for device %v and policy name %v already exists.", wu.DeviceId, wu.PolicyName)
+	} else {
+		return db.WUPersistNew(wuBucketName(), &wlUsage)
+	}
+}
+
 func (db *AgbotBoltDB) GetWorkloadUsagesCount(partition string) (int64, error) {
 	if wus, err := db.FindWorkloadUsages([]persistence.WUFilter{}); err != nil {
 		return 0, err
@@ -72,6 +72,18 @@ type AgbotDatabase interface {
 
 	DeleteWorkloadUsage(deviceid string, policyName string) error
 
+	// Functions related to persistence of the staged rollouts of deployment policies. Saving a rollout replaces the rollout
+	// of the same policy.
+	SaveRollout(r *Rollout) error
+	FindRollouts() ([]Rollout, error)
+	DeleteRollout(policyName string) error
+
+	// Functions related to persistence of the agreement cancellations that are deferred until the maintenance windows open.
+	// Saving a deferred cancellation replaces the one for the same agreement.
+	SaveDeferredCancellation(d *DeferredCancellation) error
+	FindDeferredCancellations() ([]DeferredCancellation, error)
+	DeleteDeferredCancellation(agreementId string) error
+
 	// Functions related to persistence of search sessions with the Exchange.
 	ObtainSearchSession(policyName string) (string, uint64, error)
 	UpdateSearchSessionChangedSince(currentChangedSince uint64, newChangedSince uint64, policyName string) (bool, error)
@@ -112,4 +124,10 @@ type AgbotDatabase interface {
 	GetHAUpgradingWorkload(org string, haGroupName string, policyName string) (*UpgradingHAGroupWorkload, error)
 	UpdateHAUpgradingWorkloadForGroupAndPolicy(org string, haGroupName string, policyName string, deviceId string) error
 	InsertHAUpgradingWorkloadForGroupAndPolicy(org string, haGroupName string, policyName string, deviceId string) (string, error)
+
+	// Functions related to migrating the records of one agbot database to another. Imported records are written as they are
+	// into the primary partition, and an error is returned if the record already exists.
+	ImportAgreement(ag *Agreement, protocol string) error
+	ImportWorkloadUsage(wu *WorkloadUsage) error
+	FindManagedSecrets() ([]ManagedSecret, error)
 }
@@ -0,0 +1,24 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+// An agreement cancellation caused by a policy change that is deferred until the maintenance windows of the node and the
+// deployment policy open. The maintenance manager in the agbot cancels the agreement when the windows open, the database
+// keeps the deferral across agbot restarts. Saving a deferred cancellation replaces the one for the same agreement.
+type DeferredCancellation struct {
+	AgreementId   string `json:"agreementId"`
+	Protocol      string `json:"protocol"`
+	DeviceId      string `json:"deviceId"`
+	PolicyName    string `json:"policyName"`
+	Reason        string `json:"reason"`
+	PolicyMatches bool   `json:"policyMatches"`
+	DeferredTime  uint64 `json:"deferredTime"`  // the time the cancellation was first deferred
+	NextCheckTime uint64 `json:"nextCheckTime"` // the next time the maintenance windows can open, 0 to check them every time
+}
+
+func (d DeferredCancellation) String() string {
+	return fmt.Sprintf("AgreementId: %v, Protocol: %v, DeviceId: %v, PolicyName: %v, Reason: %v, PolicyMatches: %v, DeferredTime: %v, NextCheckTime: %v",
+		d.AgreementId, d.Protocol, d.DeviceId, d.PolicyName, d.Reason, d.PolicyMatches, d.DeferredTime, d.NextCheckTime)
+}
@@ -0,0 +1,553 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+)
+
+// This package moves the state of an agbot from one database to another, for example from the bolt DB used by a single agbot
+// to a postgresql DB that can be shared by several agbots. Every record is read from the source database through the
+// AgbotDatabase interface and written to the primary partition of the target database, then read back from the target to
+// verify that it was written correctly. When the migration is done, the partition in the target database is quiesced so that
+// the agbots running on the target database can move the migrated records into their own partitions, which means that the
+// target agbots do not have to be stopped.
+//
+// Search sessions are not migrated. The agbots on the target database start new search sessions, which causes a full scan of
+// the nodes in the exchange, the same as when an agbot restarts. The source database should not be in use by an agbot while it
+// is migrated, otherwise changes made after the records are read would be lost.
+
+// The counts of one kind of record processed by the migration. In a dry run, Migrated is the number of records that would
+// be written to the target.
+type RecordCount struct {
+	Found    int `json:"found"`    // the number of records in the source database
+	Existing int `json:"existing"` // the number of records that are already in the target database and are not migrated
+	Migrated int `json:"migrated"` // the number of records written to the target database
+	Verified int `json:"verified"` // the number of migrated records that were read back from the target database unchanged
+}
+
+func (r RecordCount) String() string {
+	return fmt.Sprintf("Found: %v, Existing: %v, Migrated: %v, Verified: %v", r.Found, r.Existing, r.Migrated, r.Verified)
+}
+
+// The result of a migration.
+type Report struct {
+	Source                string      `json:"source"`
+	Target                string      `json:"target"`
+	DryRun                bool        `json:"dryRun"`
+	Agreements            RecordCount `json:"agreements"`
+	WorkloadUsages        RecordCount `json:"workloadUsages"`
+	HANodes               RecordCount `json:"haNodes"`
+	HAWorkloads           RecordCount `json:"haWorkloads"`
+	Secrets               RecordCount `json:"secrets"`
+	Rollouts              RecordCount `json:"rollouts"`
+	DeferredCancellations RecordCount `json:"deferredCancellations"`
+	Problems              []string    `json:"problems"` // records that were not migrated or did not verify
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("Source: %v, Target: %v, DryRun: %v, Agreements: {%v}, WorkloadUsages: {%v}, HANodes: {%v}, HAWorkloads: {%v}, Secrets: {%v}, Rollouts: {%v}, DeferredCancellations: {%v}, Problems: %v",
+		r.Source, r.Target, r.DryRun, r.Agreements, r.WorkloadUsages, r.HANodes, r.HAWorkloads, r.Secrets, r.Rollouts, r.DeferredCancellations, r.Problems)
+}
+
+// Returns true when every record in the source database is in the target database. Records that already existed in the target
+// database are not compared with the source.
+func (r Report) Succeeded() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) problem(msg string) {
+	glog.Warningf("AgbotDB migration: %v", msg)
+	r.Problems = append(r.Problems, msg)
+}
+
+// Open the source and target databases from their configs and migrate the source to the target. The configs must configure
+// different types of database. Opening a database initializes it, which creates the tables and claims a partition, so the
+// target database is not opened in a dry run.
+func Run(sourceCfg *config.HorizonConfig, targetCfg *config.HorizonConfig, dryRun bool) (*Report, error) {
+
+	sourceType := persistence.ConfiguredDatabase(sourceCfg)
+	targetType := persistence.ConfiguredDatabase(targetCfg)
+	if sourceType == "" {
+		return nil, errors.New("no database is configured in the source config")
+	} else if targetType == "" {
+		return nil, errors.New("no database is configured in the target config")
+	} else if sourceType == targetType {
+		return nil, errors.New(fmt.Sprintf("the source and target databases are both %v, they must be different types of database", sourceType))
+	}
+
+	source, err := persistence.InitDatabase(sourceCfg)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to open source database, error: %v", err))
+	}
+	defer source.Close()
+
+	if dryRun {
+		report, err := Migrate(source, nil, policy.AllAgreementProtocols(), dryRun)
+		if report != nil {
+			report.Target = fmt.Sprintf("%v database, not opened in a dry run", targetType)
+		}
+		return report, err
+	}
+
+	target, err := persistence.InitDatabase(targetCfg)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to open target database, error: %v", err))
+	}
+	defer target.Close()
+
+	report, err := Migrate(source, target, policy.AllAgreementProtocols(), dryRun)
+
+	// Release the target partition so that the agbots running on the target database pick up the migrated records.
+	if qerr := target.QuiescePartition(); qerr != nil && err == nil {
+		err = errors.New(fmt.Sprintf("unable to quiesce target partition, error: %v", qerr))
+	}
+	return report, err
+}
+
+// Migrate the records of the source database to the target database. Records that already exist in the target database are
+// left as they are. In a dry run, the target database is checked for existing records but nothing is written to it. The
+// target can be nil in a dry run, then every record in the source is reported as a record to migrate. An error is returned
+// when the source database cannot be read. Problems with individual records are listed in the report.
+func Migrate(source persistence.AgbotDatabase, target persistence.AgbotDatabase, protocols []string, dryRun bool) (*Report, error) {
+
+	if target == nil && !dryRun {
+		return nil, errors.New("a target database is required to migrate the records")
+	}
+
+	report := &Report{
+		Source:   fmt.Sprintf("%v", source),
+		Target:   fmt.Sprintf("%v", target),
+		DryRun:   dryRun,
+		Problems: []string{},
+	}
+
+	glog.V(1).Infof("AgbotDB migration: starting migration from %v to %v, dry run: %v", report.Source, report.Target, dryRun)
+
+	for _, protocol := range protocols {
+		if err := migrateAgreements(source, target, protocol, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := migrateWorkloadUsages(source, target, report); err != nil {
+		return nil, err
+	} else if err := migrateHANodes(source, target, report); err != nil {
+		return nil, err
+	} else if err := migrateHAWorkloads(source, target, report); err != nil {
+		return nil, err
+	} else if err := migrateSecrets(source, target, report); err != nil {
+		return nil, err
+	} else if err := migrateRollouts(source, target, report); err != nil {
+		return nil, err
+	} else if err := migrateDeferredCancellations(source, target, report); err != nil {
+		return nil, err
+	}
+
+	glog.V(1).Infof("AgbotDB migration: completed, %v", report)
+	return report, nil
+}
+
+func migrateAgreements(source persistence.AgbotDatabase, target persistence.AgbotDatabase, protocol string, report *Report) error {
+
+	ags, err := source.FindAgreements([]persistence.AFilter{}, protocol)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read %v agreements, error: %v", protocol, err))
+	}
+
+	for _, ag := range ags {
+		report.Agreements.Found += 1
+
+		if target == nil {
+			report.Agreements.Migrated += 1
+			continue
+		}
+
+		if existing, err := target.FindSingleAgreementByAgreementId(ag.CurrentAgreementId, protocol, []persistence.AFilter{}); err != nil {
+			report.problem(fmt.Sprintf("unable to check target for agreement %v, error: %v", ag.CurrentAgreementId, err))
+			continue
+		} else if existing != nil {
+			report.Agreements.Existing += 1
+			continue
+		} else if report.DryRun {
+			report.Agreements.Migrated += 1
+			continue
+		}
+
+		if err := target.ImportAgreement(&ag, protocol); err != nil {
+			report.problem(fmt.Sprintf("unable to write agreement %v, error: %v", ag.CurrentAgreementId, err))
+			continue
+		}
+		report.Agreements.Migrated += 1
+
+		if migrated, err := target.FindSingleAgreementByAgreementId(ag.CurrentAgreementId, protocol, []persistence.AFilter{}); err != nil {
+			report.problem(fmt.Sprintf("unable to verify agreement %v, error: %v", ag.CurrentAgreementId, err))
+		} else if migrated == nil || !sameJSON(ag, *migrated) {
+			report.problem(fmt.Sprintf("agreement %v was not migrated correctly, source: %v, target: %v", ag.CurrentAgreementId, ag, migrated))
+		} else {
+			report.Agreements.Verified += 1
+		}
+	}
+	return nil
+}
+
+func migrateWorkloadUsages(source persistence.AgbotDatabase, target persistence.AgbotDatabase, report *Report) error {
+
+	wus, err := source.FindWorkloadUsages([]persistence.WUFilter{})
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read workload usages, error: %v", err))
+	}
+
+	for _, wu := range wus {
+		report.WorkloadUsages.Found += 1
+
+		if target == nil {
+			report.WorkloadUsages.Migrated += 1
+			continue
+		}
+
+		if existing, err := target.FindSingleWorkloadUsageByDeviceAndPolicyName(wu.DeviceId, wu.PolicyName); err != nil {
+			report.problem(fmt.Sprintf("unable to check target for workload usage %v, error: %v", wu.ShortString(), err))
+			continue
+		} else if existing != nil {
+			report.WorkloadUsages.Existing += 1
+			continue
+		} else if report.DryRun {
+			report.WorkloadUsages.Migrated += 1
+			continue
+		}
+
+		if err := target.ImportWorkloadUsage(&wu); err != nil {
+			report.problem(fmt.Sprintf("unable to write workload usage %v, error: %v", wu.ShortString(), err))
+			continue
+		}
+		report.WorkloadUsages.Migrated += 1
+
+		// The record id is the primary key of the bolt DB, so it can change when the record is migrated.
+		if migrated, err := target.FindSingleWorkloadUsageByDeviceAndPolicyName(wu.DeviceId, wu.PolicyName); err != nil {
+			report.problem(fmt.Sprintf("unable to verify workload usage %v, error: %v", wu.ShortString(), err))
+		} else if migrated == nil {
+			report.problem(fmt.Sprintf("workload usage %v was not migrated", wu.ShortString()))
+		} else {
+			migrated.Id = wu.Id
+			if !sameJSON(wu, *migrated) {
+				report.problem(fmt.Sprintf("workload usage %v was not migrated correctly, target: %v", wu.ShortString(), migrated.ShortString()))
+			} else {
+				report.WorkloadUsages.Verified += 1
+			}
+		}
+	}
+	return nil
+}
+
+func migrateHANodes(source persistence.AgbotDatabase, target persistence.AgbotDatabase, report *Report) error {
+
+	nodes, err := source.ListAllUpgradingHANode()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read upgrading HA group nodes, error: %v", err))
+	}
+
+	for _, node := range nodes {
+		report.HANodes.Found += 1
+
+		if target == nil {
+			report.HANodes.Migrated += 1
+			continue
+		}
+
+		if existing, err := target.ListUpgradingNodeInGroup(node.OrgId, node.GroupName); err != nil {
+			report.problem(fmt.Sprintf("unable to check target for upgrading HA group node %v, error: %v", node, err))
+			continue
+		} else if existing != nil {
+			// Only one node in a group can be upgrading, so a different node in the target is a conflict.
+			if !existing.DeepEqual(node) {
+				report.problem(fmt.Sprintf("upgrading HA group node %v conflicts with %v in the target", node, existing))
+			}
+			report.HANodes.Existing += 1
+			continue
+		} else if report.DryRun {
+			report.HANodes.Migrated += 1
+			continue
+		}
+
+		// The target returns the upgrading node of the group, which is the migrated node if it was written.
+		migrated, err := target.CheckIfGroupPresentAndUpdateHATable(node)
+		if err != nil {
+			report.problem(fmt.Sprintf("unable to write upgrading HA group node %v, error: %v", node, err))
+			continue
+		}
+		report.HANodes.Migrated += 1
+
+		if migrated == nil || !migrated.DeepEqual(node) {
+			report.problem(fmt.Sprintf("upgrading HA group node %v was not migrated correctly, target: %v", node, migrated))
+		} else {
+			report.HANodes.Verified += 1
+		}
+	}
+	return nil
+}
+
+func migrateHAWorkloads(source persistence.AgbotDatabase, target persistence.AgbotDatabase, report *Report) error {
+
+	wls, err := source.ListAllHAUpgradingWorkloads()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read upgrading HA group workloads, error: %v", err))
+	}
+
+	for _, wl := range wls {
+		report.HAWorkloads.Found += 1
+
+		if target == nil {
+			report.HAWorkloads.Migrated += 1
+			continue
+		}
+
+		if existing, err := target.GetHAUpgradingWorkload(wl.OrgId, wl.GroupName, wl.PolicyName); err != nil {
+			report.problem(fmt.Sprintf("unable to check target for upgrading HA group workload %v, error: %v", wl, err))
+			continue
+		} else if existing != nil {
+			if !existing.DeepEqual(wl) {
+				report.problem(fmt.Sprintf("upgrading HA group workload %v conflicts with %v in the target", wl, existing))
+			}
+			report.HAWorkloads.Existing += 1
+			continue
+		} else if report.DryRun {
+			report.HAWorkloads.Migrated += 1
+			continue
+		}
+
+		// The target returns the node upgrading the workload, which is the migrated node if it was written.
+		nodeId, err := target.InsertHAUpgradingWorkloadForGroupAndPolicy(wl.OrgId, wl.GroupName, wl.PolicyName, wl.NodeId)
+		if err != nil {
+			report.problem(fmt.Sprintf("unable to write upgrading HA group workload %v, error: %v", wl, err))
+			continue
+		}
+		report.HAWorkloads.Migrated += 1
+
+		if nodeId != wl.NodeId {
+			report.problem(fmt.Sprintf("upgrading HA group workload %v was not migrated correctly, target node: %v", wl, nodeId))
+		} else {
+			report.HAWorkloads.Verified += 1
+		}
+	}
+	return nil
+}
+
+func migrateSecrets(source persistence.AgbotDatabase, target persistence.AgbotDatabase, report *Report) error {
+
+	secrets, err := source.FindManagedSecrets()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read managed secrets, error: %v", err))
+	}
+
+	existing := []persistence.ManagedSecret{}
+	if target != nil {
+		if existing, err = target.FindManagedSecrets(); err != nil {
+			return errors.New(fmt.Sprintf("unable to read managed secrets in the target, error: %v", err))
+		}
+	}
+
+	for _, secret := range secrets {
+		report.Secrets.Found += 1
+
+		if findSecret(existing, secret, false) {
+			report.Secrets.Existing += 1
+			continue
+		} else if report.DryRun {
+			report.Secrets.Migrated += 1
+			continue
+		}
+
+		if secret.Pattern {
+			err = target.AddManagedPatternSecret(secret.SecretOrg, secret.SecretName, secret.DeploymentOrg, secret.DeploymentName, secret.SecretExists, secret.LastUpdateCheck)
+		} else {
+			err = target.AddManagedPolicySecret(secret.SecretOrg, secret.SecretName, secret.DeploymentOrg, secret.DeploymentName, secret.SecretExists, secret.LastUpdateCheck)
+		}
+
+		if err != nil {
+			report.problem(fmt.Sprintf("unable to write managed secret %v, error: %v", secret, err))
+		} else {
+			report.Secrets.Migrated += 1
+		}
+	}
+
+	if report.DryRun || report.Secrets.Migrated == 0 {
+		return nil
+	}
+
+	// The secrets are verified all at once, there is no interface to read a single secret record.
+	migrated, err := target.FindManagedSecrets()
+	if err != nil {
+		report.problem(fmt.Sprintf("unable to verify managed secrets, error: %v", err))
+		return nil
+	}
+	for _, secret := range secrets {
+		if findSecret(existing, secret, false) {
+			continue
+		} else if findSecret(migrated, secret, true) {
+			report.Secrets.Verified += 1
+		} else {
+			report.problem(fmt.Sprintf("managed secret %v was not migrated correctly", secret))
+		}
+	}
+	return nil
+}
+
+func migrateRollouts(source persistence.AgbotDatabase, target persistence.AgbotDatabase, report *Report) error {
+
+	rollouts, err := source.FindRollouts()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read rollouts, error: %v", err))
+	}
+
+	existing := []persistence.Rollout{}
+	if target != nil {
+		if existing, err = target.FindRollouts(); err != nil {
+			return errors.New(fmt.Sprintf("unable to read rollouts in the target, error: %v", err))
+		}
+	}
+
+	for ix := range rollouts {
+		r := &rollouts[ix]
+		report.Rollouts.Found += 1
+
+		if e := findRollout(existing, r.PolicyName); e != nil {
+			if !sameJSON(e, r) {
+				report.problem(fmt.Sprintf("rollout %v conflicts with %v in the target", r, e))
+			}
+			report.Rollouts.Existing += 1
+			continue
+		} else if report.DryRun {
+			report.Rollouts.Migrated += 1
+			continue
+		}
+
+		if err := target.SaveRollout(r); err != nil {
+			report.problem(fmt.Sprintf("unable to write rollout %v, error: %v", r, err))
+		} else {
+			report.Rollouts.Migrated += 1
+		}
+	}
+
+	if report.DryRun || report.Rollouts.Migrated == 0 {
+		return nil
+	}
+
+	// The rollouts are verified all at once, there is no interface to read a single rollout record.
+	migrated, err := target.FindRollouts()
+	if err != nil {
+		report.problem(fmt.Sprintf("unable to verify rollouts, error: %v", err))
+		return nil
+	}
+	for ix := range rollouts {
+		r := &rollouts[ix]
+		if findRollout(existing, r.PolicyName) != nil {
+			continue
+		} else if m := findRollout(migrated, r.PolicyName); m != nil && sameJSON(m, r) {
+			report.Rollouts.Verified += 1
+		} else {
+			report.problem(fmt.Sprintf("rollout %v was not migrated correctly, target: %v", r, m))
+		}
+	}
+	return nil
+}
+
+// Returns the rollout of the policy in the list, or nil if there isn't one.
+func findRollout(rollouts []persistence.Rollout, policyName string) *persistence.Rollout {
+	for ix := range rollouts {
+		if rollouts[ix].PolicyName == policyName {
+			return &rollouts[ix]
+		}
+	}
+	return nil
+}
+
+func migrateDeferredCancellations(source persistence.AgbotDatabase, target persistence.AgbotDatabase, report *Report) error {
+
+	deferred, err := source.FindDeferredCancellations()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read deferred cancellations, error: %v", err))
+	}
+
+	existing := []persistence.DeferredCancellation{}
+	if target != nil {
+		if existing, err = target.FindDeferredCancellations(); err != nil {
+			return errors.New(fmt.Sprintf("unable to read deferred cancellations in the target, error: %v", err))
+		}
+	}
+
+	for ix := range deferred {
+		d := &deferred[ix]
+		report.DeferredCancellations.Found += 1
+
+		if e := findDeferredCancellation(existing, d.AgreementId); e != nil {
+			if *e != *d {
+				report.problem(fmt.Sprintf("deferred cancellation %v conflicts with %v in the target", d, e))
+			}
+			report.DeferredCancellations.Existing += 1
+			continue
+		} else if report.DryRun {
+			report.DeferredCancellations.Migrated += 1
+			continue
+		}
+
+		if err := target.SaveDeferredCancellation(d); err != nil {
+			report.problem(fmt.Sprintf("unable to write deferred cancellation %v, error: %v", d, err))
+		} else {
+			report.DeferredCancellations.Migrated += 1
+		}
+	}
+
+	if report.DryRun || report.DeferredCancellations.Migrated == 0 {
+		return nil
+	}
+
+	// The deferred cancellations are verified all at once, there is no interface to read a single deferred cancellation.
+	migrated, err := target.FindDeferredCancellations()
+	if err != nil {
+		report.problem(fmt.Sprintf("unable to verify deferred cancellations, error: %v", err))
+		return nil
+	}
+	for ix := range deferred {
+		d := &deferred[ix]
+		if findDeferredCancellation(existing, d.AgreementId) != nil {
+			continue
+		} else if m := findDeferredCancellation(migrated, d.AgreementId); m != nil && *m == *d {
+			report.DeferredCancellations.Verified += 1
+		} else {
+			report.problem(fmt.Sprintf("deferred cancellation %v was not migrated correctly, target: %v", d, m))
+		}
+	}
+	return nil
+}
+
+// Returns the deferred cancellation of the agreement in the list, or nil if there isn't one.
+func findDeferredCancellation(deferred []persistence.DeferredCancellation, agreementId string) *persistence.DeferredCancellation {
+	for ix := range deferred {
+		if deferred[ix].AgreementId == agreementId {
+			return &deferred[ix]
+		}
+	}
+	return nil
+}
+
+// Returns true if the secret binding is in the list. When exact is true, the state of the secret has to match too.
+func findSecret(secrets []persistence.ManagedSecret, secret persistence.ManagedSecret, exact bool) bool {
+	for _, s := range secrets {
+		if s.SecretOrg == secret.SecretOrg && s.SecretName == secret.SecretName && s.DeploymentOrg == secret.DeploymentOrg && s.DeploymentName == secret.DeploymentName && s.Pattern == secret.Pattern {
+			return !exact || s == secret
+		}
+	}
+	return false
+}
+
+// Records are compared in their serialized form, which is how every database stores them.
+func sameJSON(a interface{}, b interface{}) bool {
+	aBytes, aErr := json.Marshal(a)
+	bBytes, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aBytes) == string(bBytes)
+}
@@ -0,0 +1,208 @@
+//go:build unit
+// +build unit
+
+package migrate
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/agreementbot/persistence/bolt"
+	"github.com/open-horizon/anax/agreementbot/persistence/sqlite"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+	"os"
+	"path"
+	"testing"
+)
+
+func newBoltDB(t *testing.T, dir string) *bolt.AgbotBoltDB {
+	db := new(bolt.AgbotBoltDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: dir}}); err != nil {
+		t.Fatalf("unable to initialize bolt database, error: %v", err)
+	}
+	return db
+}
+
+func newSqliteDB(t *testing.T, file string) *sqlite.AgbotSqliteDB {
+	db := new(sqlite.AgbotSqliteDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{Sqlite: config.SqliteConfig{File: file}}}); err != nil {
+		t.Fatalf("unable to initialize sqlite database, error: %v", err)
+	}
+	return db
+}
+
+func Test_Migrate(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotmigrate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newBoltDB(t, dir)
+	defer source.Close()
+	target := newSqliteDB(t, path.Join(dir, "agbot.db"))
+	defer target.Close()
+
+	// ag2 is already in the target database.
+	for _, db := range []persistence.AgbotDatabase{source, target} {
+		if err := db.AgreementAttempt("ag2", "myorg", "myorg/node2", "device", "pol1", "", "", "", policy.BasicProtocol, "", []string{"svc1"}, policy.NodeHealth{}, 0, 0); err != nil {
+			t.Fatalf("unable to create agreement, error: %v", err)
+		}
+	}
+
+	if err := source.AgreementAttempt("ag1", "myorg", "myorg/node1", "device", "pol1", "", "", "", policy.BasicProtocol, "", []string{"svc1"}, policy.NodeHealth{}, 0, 0); err != nil {
+		t.Fatalf("unable to create agreement, error: %v", err)
+	} else if _, err := source.AgreementMade("ag1", "counterparty", "sig", policy.BasicProtocol, "", "", ""); err != nil {
+		t.Fatalf("unable to update agreement, error: %v", err)
+	} else if _, err := source.AgreementTimedout("ag1", policy.BasicProtocol); err != nil {
+		t.Fatalf("unable to update agreement, error: %v", err)
+	} else if err := source.NewWorkloadUsage("myorg/node1", "", "pol1", 1, 60, 60, false, "ag1"); err != nil {
+		t.Fatalf("unable to create workload usage, error: %v", err)
+	} else if _, err := source.UpdateRetryCount("myorg/node1", "pol1", 2, "ag1"); err != nil {
+		t.Fatalf("unable to update workload usage, error: %v", err)
+	} else if _, err := source.CheckIfGroupPresentAndUpdateHATable(persistence.UpgradingHAGroupNode{GroupName: "g1", OrgId: "myorg", NodeId: "node1", NMPName: "nmp1"}); err != nil {
+		t.Fatalf("unable to create upgrading HA group node, error: %v", err)
+	} else if _, err := source.InsertHAUpgradingWorkloadForGroupAndPolicy("myorg", "g1", "pol1", "myorg/node1"); err != nil {
+		t.Fatalf("unable to create upgrading HA group workload, error: %v", err)
+	} else if err := source.SaveRollout(&persistence.Rollout{PolicyName: "pol1", TargetPriority: 1, State: "active", Wave: 1, Nodes: map[string]*persistence.RolloutNode{"myorg/node1": {DeviceId: "myorg/node1", State: "upgrading", Wave: 1}}}); err != nil {
+		t.Fatalf("unable to save rollout, error: %v", err)
+	} else if err := source.SaveDeferredCancellation(&persistence.DeferredCancellation{AgreementId: "ag2", Protocol: policy.BasicProtocol, DeviceId: "myorg/node1", PolicyName: "pol1", DeferredTime: 100}); err != nil {
+		t.Fatalf("unable to save deferred cancellation, error: %v", err)
+	}
+
+	// A dry run reports the records to migrate without writing them.
+	if report, err := Migrate(source, target, policy.AllAgreementProtocols(), true); err != nil {
+		t.Fatalf("unable to run migration, error: %v", err)
+	} else if !report.Succeeded() {
+		t.Errorf("the dry run should succeed, report: %v", report)
+	} else if report.Agreements != (RecordCount{Found: 2, Existing: 1, Migrated: 1}) {
+		t.Errorf("unexpected agreement counts: %v", report.Agreements)
+	} else if report.WorkloadUsages != (RecordCount{Found: 1, Migrated: 1}) || report.HANodes != (RecordCount{Found: 1, Migrated: 1}) || report.HAWorkloads != (RecordCount{Found: 1, Migrated: 1}) || report.Rollouts != (RecordCount{Found: 1, Migrated: 1}) || report.DeferredCancellations != (RecordCount{Found: 1, Migrated: 1}) {
+		t.Errorf("unexpected counts in report: %v", report)
+	} else if ag, err := target.FindSingleAgreementByAgreementId("ag1", policy.BasicProtocol, []persistence.AFilter{}); err != nil || ag != nil {
+		t.Errorf("the dry run should not write agreement %v, error: %v", ag, err)
+	}
+
+	// The records are migrated and verified.
+	if report, err := Migrate(source, target, policy.AllAgreementProtocols(), false); err != nil {
+		t.Fatalf("unable to run migration, error: %v", err)
+	} else if !report.Succeeded() {
+		t.Errorf("the migration should succeed, report: %v", report)
+	} else if report.Agreements != (RecordCount{Found: 2, Existing: 1, Migrated: 1, Verified: 1}) {
+		t.Errorf("unexpected agreement counts: %v", report.Agreements)
+	} else if report.WorkloadUsages != (RecordCount{Found: 1, Migrated: 1, Verified: 1}) || report.HANodes != (RecordCount{Found: 1, Migrated: 1, Verified: 1}) || report.HAWorkloads != (RecordCount{Found: 1, Migrated: 1, Verified: 1}) || report.Rollouts != (RecordCount{Found: 1, Migrated: 1, Verified: 1}) || report.DeferredCancellations != (RecordCount{Found: 1, Migrated: 1, Verified: 1}) {
+		t.Errorf("unexpected counts in report: %v", report)
+	}
+
+	if ag, err := target.FindSingleAgreementByAgreementId("ag1", policy.BasicProtocol, []persistence.AFilter{}); err != nil || ag == nil {
+		t.Errorf("agreement ag1 should be migrated, agreement %v, error: %v", ag, err)
+	} else if ag.CounterPartyAddress != "counterparty" || ag.AgreementTimedout == 0 {
+		t.Errorf("agreement ag1 was not migrated with its state: %v", ag)
+	} else if wu, err := target.FindSingleWorkloadUsageByDeviceAndPolicyName("myorg/node1", "pol1"); err != nil || wu == nil || wu.RetryCount != 2 {
+		t.Errorf("the workload usage should be migrated with its retry count, workload usage %v, error: %v", wu, err)
+	} else if node, err := target.ListUpgradingNodeInGroup("myorg", "g1"); err != nil || node == nil || node.NodeId != "node1" {
+		t.Errorf("the upgrading HA group node should be migrated, node %v, error: %v", node, err)
+	} else if rollouts, err := target.FindRollouts(); err != nil || len(rollouts) != 1 || rollouts[0].Nodes["myorg/node1"].State != "upgrading" {
+		t.Errorf("the rollout should be migrated with its nodes, rollouts %v, error: %v", rollouts, err)
+	} else if deferred, err := target.FindDeferredCancellations(); err != nil || len(deferred) != 1 || deferred[0].DeferredTime != 100 {
+		t.Errorf("the deferred cancellation should be migrated, deferred %v, error: %v", deferred, err)
+	}
+
+	// Running the migration again finds everything in the target.
+	if report, err := Migrate(source, target, policy.AllAgreementProtocols(), false); err != nil {
+		t.Fatalf("unable to run migration, error: %v", err)
+	} else if report.Agreements.Migrated != 0 || report.WorkloadUsages.Existing != 1 || report.HAWorkloads.Existing != 1 || report.Rollouts.Existing != 1 || report.DeferredCancellations.Existing != 1 {
+		t.Errorf("nothing should be migrated again, report: %v", report)
+	}
+}
+
+func Test_MigrateSecrets(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotmigrate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newSqliteDB(t, path.Join(dir, "source.db"))
+	defer source.Close()
+	target := newSqliteDB(t, path.Join(dir, "target.db"))
+	defer target.Close()
+
+	if err := source.AddManagedPolicySecret("myorg", "secret1", "myorg", "pol1", true, 10); err != nil {
+		t.Fatalf("unable to add secret, error: %v", err)
+	} else if err := source.AddManagedPatternSecret("myorg", "secret2", "myorg", "pat1", false, 20); err != nil {
+		t.Fatalf("unable to add secret, error: %v", err)
+	} else if err := target.AddManagedPolicySecret("myorg", "secret1", "myorg", "pol1", true, 30); err != nil {
+		t.Fatalf("unable to add secret, error: %v", err)
+	}
+
+	if report, err := Migrate(source, target, policy.AllAgreementProtocols(), false); err != nil {
+		t.Fatalf("unable to run migration, error: %v", err)
+	} else if !report.Succeeded() {
+		t.Errorf("the migration should succeed, report: %v", report)
+	} else if report.Secrets != (RecordCount{Found: 2, Existing: 1, Migrated: 1, Verified: 1}) {
+		t.Errorf("unexpected secret counts: %v", report.Secrets)
+	}
+
+	// The existing secret keeps its state, the migrated secret keeps the state it had in the source.
+	expected := []persistence.ManagedSecret{
+		{SecretOrg: "myorg", SecretName: "secret1", DeploymentOrg: "myorg", DeploymentName: "pol1", SecretExists: true, LastUpdateCheck: 30},
+		{SecretOrg: "myorg", SecretName: "secret2", DeploymentOrg: "myorg", DeploymentName: "pat1", Pattern: true, LastUpdateCheck: 20},
+	}
+	if secrets, err := target.FindManagedSecrets(); err != nil {
+		t.Errorf("unable to read secrets, error: %v", err)
+	} else if len(secrets) != len(expected) || secrets[0] != expected[0] || secrets[1] != expected[1] {
+		t.Errorf("expected secrets %v, got %v", expected, secrets)
+	}
+}
+
+func Test_Run(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotmigrate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourceCfg := &config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: path.Join(dir, "bolt")}}
+	targetFile := path.Join(dir, "agbot.db")
+	targetCfg := &config.HorizonConfig{AgreementBot: config.AGConfig{Sqlite: config.SqliteConfig{File: targetFile}}}
+
+	source := newBoltDB(t, sourceCfg.AgreementBot.DBPath)
+	if err := source.AgreementAttempt("ag1", "myorg", "myorg/node1", "device", "pol1", "", "", "", policy.BasicProtocol, "", []string{"svc1"}, policy.NodeHealth{}, 0, 0); err != nil {
+		t.Fatalf("unable to create agreement, error: %v", err)
+	}
+	source.Close()
+
+	// The configs are compared before a database is opened.
+	otherBoltCfg := &config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: path.Join(dir, "otherbolt")}}
+	if _, err := Run(sourceCfg, otherBoltCfg, false); err == nil {
+		t.Errorf("the migration between bolt databases should fail")
+	} else if _, err := os.Stat(otherBoltCfg.AgreementBot.DBPath); !os.IsNotExist(err) {
+		t.Errorf("the target bolt database should not be created, error: %v", err)
+	}
+
+	// A dry run does not create the target database.
+	if report, err := Run(sourceCfg, targetCfg, true); err != nil {
+		t.Fatalf("unable to run migration, error: %v", err)
+	} else if !report.Succeeded() || report.Agreements != (RecordCount{Found: 1, Migrated: 1}) {
+		t.Errorf("unexpected dry run report: %v", report)
+	} else if _, err := os.Stat(targetFile); !os.IsNotExist(err) {
+		t.Errorf("the dry run should not create the target database, error: %v", err)
+	}
+
+	// The records are imported into the target by the postgresql implementation, which SQLite shares.
+	if report, err := Run(sourceCfg, targetCfg, false); err != nil {
+		t.Fatalf("unable to run migration, error: %v", err)
+	} else if !report.Succeeded() || report.Agreements != (RecordCount{Found: 1, Migrated: 1, Verified: 1}) {
+		t.Errorf("unexpected migration report: %v", report)
+	}
+
+	// An agbot started on the target claims the released partition.
+	target := newSqliteDB(t, targetFile)
+	defer target.Close()
+	if ag, err := target.FindSingleAgreementByAgreementId("ag1", policy.BasicProtocol, []persistence.AFilter{}); err != nil || ag == nil {
+		t.Errorf("agreement ag1 should be in the target, agreement %v, error: %v", ag, err)
+	}
+}
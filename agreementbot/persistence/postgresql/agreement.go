@@ -127,6 +127,7 @@ type AgbotPostgresqlDB struct {
 	db               *sql.DB  // A handle to the underlying database.
 	primaryPartition string   // The partition to use when creating new agreements.
 	partitions       []string // The list of partitions this agbot is responsible to maintain.
+	dialect          Dialect  // The statements that replace the Postgresql statements the database does not support.
 }
 
 func (db *AgbotPostgresqlDB) String() string {
@@ -146,7 +147,7 @@ func (db *AgbotPostgresqlDB) GetAgreementPartitionTableName(partition string) st
 }
 
 func (db *AgbotPostgresqlDB) GetPrimaryAgreementPartitionTableCreate() string {
-	sql := strings.Replace(AGREEMENT_CREATE_PARTITION_TABLE, AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(db.PrimaryPartition()), 1)
+	sql := strings.Replace(db.stmt(AGREEMENT_CREATE_PARTITION_TABLE), AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(db.PrimaryPartition()), 1)
 	sql = strings.Replace(sql, AGREEMENT_PARTITION_FILLIN, db.PrimaryPartition(), 1)
 	return sql
 }
@@ -169,13 +170,13 @@ func (db *AgbotPostgresqlDB) GetAgreementPartitionTableCount(partition string) s
 // The SQL template used by this function is slightly different than the others and therefore does it's own calculation
 // of how the table partition is substituted into the SQL. The difference is in the required use of single quotes.
 func (db *AgbotPostgresqlDB) GetAgreementPartitionTableExists(partition string) string {
-	sql := strings.Replace(AGREEMENT_PARTITION_TABLE_EXISTS, AGREEMENT_TABLE_NAME_ROOT, AGREEMENT_TABLE_NAME_ROOT+partition, 1)
+	sql := strings.Replace(db.stmt(AGREEMENT_PARTITION_TABLE_EXISTS), AGREEMENT_TABLE_NAME_ROOT, AGREEMENT_TABLE_NAME_ROOT+partition, 1)
 	return sql
 }
 
 // The partition table name replacement scheme used in this function is slightly different from the others above.
 func (db *AgbotPostgresqlDB) GetAgreementPartitionMove(fromPartition string, toPartition string) string {
-	sql := strings.Replace(AGREEMENT_MOVE, AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(toPartition), 2)
+	sql := strings.Replace(db.stmt(AGREEMENT_MOVE), AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(toPartition), 2)
 	sql = strings.Replace(sql, db.GetAgreementPartitionTableName(toPartition), db.GetAgreementPartitionTableName(fromPartition), 1)
 	sql = strings.Replace(sql, AGREEMENT_PARTITION_FILLIN, toPartition, 1)
 	return sql
@@ -187,7 +188,7 @@ func (db *AgbotPostgresqlDB) FindAgreementPartitions() ([]string, error) {
 	partitions := make([]string, 0, 10)
 	foundPrimary := false
 
-	rows, err := db.db.Query(AGREEMENT_PARTITIONS)
+	rows, err := db.db.Query(db.stmt(AGREEMENT_PARTITIONS))
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("error querying for agreement partitions: %v", err))
 	}
@@ -368,6 +369,17 @@ func (db *AgbotPostgresqlDB) AgreementAttempt(agreementid string, org string, de
 	}
 }
 
+// Write an agreement from another agbot database as it is, into the primary partition.
+func (db *AgbotPostgresqlDB) ImportAgreement(ag *persistence.Agreement, protocol string) error {
+	if existing, partition, err := db.internalFindSingleAgreementByAgreementId(nil, ag.CurrentAgreementId, protocol, []persistence.AFilter{}); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("Agreement %v already exists in partition %v.", ag.CurrentAgreementId, partition)
+	} else {
+		return db.insertAgreement(ag, protocol)
+	}
+}
+
 func (db *AgbotPostgresqlDB) AgreementFinalized(agreementId string, protocol string) (*persistence.Agreement, error) {
 	return persistence.AgreementFinalized(db, agreementId, protocol)
 }
@@ -443,9 +455,9 @@ func (db *AgbotPostgresqlDB) DeleteAgreement(agreementid string, protocol string
 }
 
 func (db *AgbotPostgresqlDB) Close() {
-	glog.V(2).Infof("Closing Postgresql database")
+	glog.V(2).Infof("Closing %v database", db.dialect.Name)
 	db.db.Close()
-	glog.V(2).Infof("Closed Postgresql database")
+	glog.V(2).Infof("Closed %v database", db.dialect.Name)
 }
 
 // Utility functions used by the public functions in this package.
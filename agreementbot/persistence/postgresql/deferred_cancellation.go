@@ -0,0 +1,192 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"strings"
+)
+
+// Constants for the SQL statements that are used to work with the agreement cancellations that are deferred until the
+// maintenance windows of the node and the deployment policy open. Deferred cancellations are partitioned by agbot instances
+// in the same way as the agreements they cancel, there is a main table (called deferred_cancellations) that defines the
+// schema and a table for each partition (called deferred_cancellations_<partition_name>) which inherits from the main table.
+// The deferred cancellations of a partition are moved with its agreements when the partition is taken over by another agbot.
+
+// deferred_cancellations schema:
+// agreement_id:          The id of the agreement whose cancellation is deferred.
+// partition:             The agbot partition that this deferred cancellation lives in.
+// deferred_cancellation: The deferred cancellation object which is a JSON blob. The blob schema is defined by the DeferredCancellation struct in the persistence package.
+// updated:               A timestamp to record last updated time.
+//
+
+const DEFERRED_CANCELLATION_CREATE_MAIN_TABLE = `CREATE TABLE IF NOT EXISTS deferred_cancellations (
+	agreement_id text NOT NULL,
+	partition text NOT NULL,
+	deferred_cancellation jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp
+);`
+const DEFERRED_CANCELLATION_CREATE_PARTITION_TABLE = `CREATE TABLE IF NOT EXISTS "deferred_cancellations_ (
+	CHECK ( partition = 'partition_name' )
+) INHERITS (deferred_cancellations);`
+const DEFERRED_CANCELLATION_CREATE_PARTITION_INDEX = `CREATE INDEX IF NOT EXISTS "agreement_index_on_deferred_cancellations_ ON "deferred_cancellations_ (agreement_id);`
+
+// Please note that the following SQL statement has a different syntax where the table name is specified. Note the use of
+// single quotes instead of double quotes that are used in all the other SQL.
+const DEFERRED_CANCELLATION_PARTITION_TABLE_EXISTS = `SELECT to_regclass('deferred_cancellations_');`
+
+const DEFERRED_CANCELLATION_TABLE_NAME_ROOT = `deferred_cancellations_`
+const DEFERRED_CANCELLATION_PARTITION_FILLIN = `partition_name`
+
+const ALL_DEFERRED_CANCELLATION_QUERY = `SELECT deferred_cancellation FROM "deferred_cancellations_;`
+
+const DEFERRED_CANCELLATION_INSERT = `INSERT INTO "deferred_cancellations_ (agreement_id, partition, deferred_cancellation) VALUES ($1, $2, $3);`
+const DEFERRED_CANCELLATION_DELETE = `DELETE FROM "deferred_cancellations_ WHERE agreement_id = $1;`
+
+const DEFERRED_CANCELLATION_MOVE = `WITH moved_rows AS (
+    DELETE FROM "deferred_cancellations_ a
+    RETURNING a.agreement_id, a.deferred_cancellation
+)
+INSERT INTO "deferred_cancellations_ (agreement_id, partition, deferred_cancellation) SELECT agreement_id, 'partition_name', deferred_cancellation FROM moved_rows;
+`
+
+const DEFERRED_CANCELLATION_DROP_PARTITION = `DROP TABLE "deferred_cancellations_;`
+
+func (db *AgbotPostgresqlDB) GetDeferredCancellationPartitionTableName(partition string) string {
+	return DEFERRED_CANCELLATION_TABLE_NAME_ROOT + partition + `"`
+}
+
+func (db *AgbotPostgresqlDB) GetPrimaryDeferredCancellationPartitionTableCreate() string {
+	sql := strings.Replace(db.stmt(DEFERRED_CANCELLATION_CREATE_PARTITION_TABLE), DEFERRED_CANCELLATION_TABLE_NAME_ROOT, db.GetDeferredCancellationPartitionTableName(db.PrimaryPartition()), 1)
+	sql = strings.Replace(sql, DEFERRED_CANCELLATION_PARTITION_FILLIN, db.PrimaryPartition(), 1)
+	return sql
+}
+
+func (db *AgbotPostgresqlDB) GetPrimaryDeferredCancellationPartitionTableIndexCreate() string {
+	sql := strings.Replace(DEFERRED_CANCELLATION_CREATE_PARTITION_INDEX, DEFERRED_CANCELLATION_TABLE_NAME_ROOT, db.GetDeferredCancellationPartitionTableName(db.PrimaryPartition()), 2)
+	return sql
+}
+
+func (db *AgbotPostgresqlDB) GetDeferredCancellationPartitionTableDrop(partition string) string {
+	sql := strings.Replace(DEFERRED_CANCELLATION_DROP_PARTITION, DEFERRED_CANCELLATION_TABLE_NAME_ROOT, db.GetDeferredCancellationPartitionTableName(partition), 1)
+	return sql
+}
+
+// The SQL template used by this function is slightly different than the others and therefore does it's own calculation
+// of how the table partition is substituted into the SQL. The difference is in the required use of single quotes.
+func (db *AgbotPostgresqlDB) GetDeferredCancellationPartitionTableExists(partition string) string {
+	sql := strings.Replace(db.stmt(DEFERRED_CANCELLATION_PARTITION_TABLE_EXISTS), DEFERRED_CANCELLATION_TABLE_NAME_ROOT, DEFERRED_CANCELLATION_TABLE_NAME_ROOT+partition, 1)
+	return sql
+}
+
+// The partition table name replacement scheme used in this function is slightly different from the others above.
+func (db *AgbotPostgresqlDB) GetDeferredCancellationPartitionMove(fromPartition string, toPartition string) string {
+	sql := strings.Replace(db.stmt(DEFERRED_CANCELLATION_MOVE), DEFERRED_CANCELLATION_TABLE_NAME_ROOT, db.GetDeferredCancellationPartitionTableName(toPartition), 2)
+	sql = strings.Replace(sql, db.GetDeferredCancellationPartitionTableName(toPartition), db.GetDeferredCancellationPartitionTableName(fromPartition), 1)
+	sql = strings.Replace(sql, DEFERRED_CANCELLATION_PARTITION_FILLIN, toPartition, 1)
+	return sql
+}
+
+// Move the deferred cancellations of a partition into the primary partition and drop the partition's table. This runs in
+// the transaction of the partition move. Partitions of agbots that never deferred a cancellation do not have the table.
+func (db *AgbotPostgresqlDB) moveDeferredCancellationPartition(tx *sql.Tx, fromPartition string) error {
+	var tableName []byte
+	// This query always retuns a row in the result set. The returned table name is empty if the table does not exist.
+	if err := tx.QueryRow(db.GetDeferredCancellationPartitionTableExists(fromPartition)).Scan(&tableName); err != nil {
+		return errors.New(fmt.Sprintf("error scanning result for deferred cancellation partition %v table check, error: %v", fromPartition, err))
+	} else if string(tableName) == "" {
+		return nil
+	} else if _, err := tx.Exec(db.GetDeferredCancellationPartitionMove(fromPartition, db.PrimaryPartition())); err != nil {
+		return err
+	} else if _, err := tx.Exec(db.GetDeferredCancellationPartitionTableDrop(fromPartition)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) FindDeferredCancellations() ([]persistence.DeferredCancellation, error) {
+	deferred := make([]persistence.DeferredCancellation, 0, 10)
+
+	for _, currentPartition := range db.AllPartitions() {
+
+		sqlStr := strings.Replace(ALL_DEFERRED_CANCELLATION_QUERY, DEFERRED_CANCELLATION_TABLE_NAME_ROOT, db.GetDeferredCancellationPartitionTableName(currentPartition), 1)
+		rows, err := db.db.Query(sqlStr)
+		if err != nil && db.isMissingTable(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.New(fmt.Sprintf("error querying for deferred cancellations, error: %v", err))
+		}
+
+		// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+		defer rows.Close()
+		for rows.Next() {
+			dBytes := make([]byte, 0, 512)
+			var d persistence.DeferredCancellation
+			if err := rows.Scan(&dBytes); err != nil {
+				return nil, errors.New(fmt.Sprintf("error scanning row: %v", err))
+			} else if err := json.Unmarshal(dBytes, &d); err != nil {
+				return nil, errors.New(fmt.Sprintf("error demarshalling row: %v, error: %v", string(dBytes), err))
+			} else {
+				deferred = append(deferred, d)
+			}
+		}
+
+		// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+		if err = rows.Err(); err != nil {
+			return nil, errors.New(fmt.Sprintf("error iterating: %v", err))
+		}
+	}
+
+	return deferred, nil
+}
+
+// Replace the deferred cancellation of the agreement in all partitions with the given one, which is written to the
+// primary partition.
+func (db *AgbotPostgresqlDB) SaveDeferredCancellation(d *persistence.DeferredCancellation) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := db.deleteDeferredCancellation(tx, d.AgreementId); err != nil {
+		return err
+	}
+
+	sqlStr := strings.Replace(DEFERRED_CANCELLATION_INSERT, DEFERRED_CANCELLATION_TABLE_NAME_ROOT, db.GetDeferredCancellationPartitionTableName(db.PrimaryPartition()), 1)
+	if dm, err := json.Marshal(d); err != nil {
+		return err
+	} else if _, err := tx.Exec(sqlStr, d.AgreementId, db.PrimaryPartition(), dm); err != nil {
+		return err
+	} else if err := tx.Commit(); err != nil {
+		return err
+	}
+	glog.V(5).Infof("Succeeded writing deferred cancellation record %v", d)
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) DeleteDeferredCancellation(agreementId string) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := db.deleteDeferredCancellation(tx, agreementId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *AgbotPostgresqlDB) deleteDeferredCancellation(tx *sql.Tx, agreementId string) error {
+	for _, currentPartition := range db.AllPartitions() {
+		sqlStr := strings.Replace(DEFERRED_CANCELLATION_DELETE, DEFERRED_CANCELLATION_TABLE_NAME_ROOT, db.GetDeferredCancellationPartitionTableName(currentPartition), 1)
+		if _, err := tx.Exec(sqlStr, agreementId); err != nil {
+			return errors.New(fmt.Sprintf("error deleting deferred cancellation of agreement %v in partition %v, error: %v", agreementId, currentPartition, err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package postgresql
+
+import (
+	"strings"
+)
+
+// The tables and SQL statements in this package are written for Postgresql. Other databases that can run (nearly) the same
+// SQL, such as SQLite, are handled by the same code with a dialect. The dialect replaces the statements that the database
+// does not support, such as the stored procedures, table inheritance and the DELETE ... RETURNING moves. A replacement
+// takes the same parameters and the same partition name fill-ins as the statement it replaces, so that it can be used by
+// the code in this package as it is. An empty replacement means that the database does not need the statement, e.g. the
+// creation of a stored procedure whose replacement is a plain statement.
+type Dialect struct {
+	Name         string            // The name of the database, used in log messages.
+	Statements   map[string]string // The replacement statements, keyed by the statement they replace.
+	MissingTable string            // A part of the error message returned by the database when a table does not exist.
+}
+
+var postgresqlDialect = Dialect{
+	Name:         "Postgresql",
+	MissingTable: "not exist",
+}
+
+// Returns the statement to run for the given statement of this package.
+func (db *AgbotPostgresqlDB) stmt(sql string) string {
+	if replacement, ok := db.dialect.Statements[sql]; ok {
+		return replacement
+	}
+	return sql
+}
+
+// Returns true when the error was returned because a table does not exist, which is normal for the tables of a partition
+// that was removed.
+func (db *AgbotPostgresqlDB) isMissingTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), db.dialect.MissingTable)
+}
@@ -53,7 +53,7 @@ const HA_GROUP_GET_ALL_NODES = `SELECT group_name, org_id, node_id, nmp_id FROM
 func (db *AgbotPostgresqlDB) CheckIfGroupPresentAndUpdateHATable(requestingNode persistence.UpgradingHAGroupNode) (*persistence.UpgradingHAGroupNode, error) {
 	var dbNodeId sql.NullString
 	var dbNmpId sql.NullString
-	qerr := db.db.QueryRow(HA_GROUP_ADD_IF_NOT_PRESENT_BY_FUNCTION, requestingNode.GroupName, requestingNode.OrgId, requestingNode.NodeId, requestingNode.NMPName).Scan(&dbNodeId, &dbNmpId)
+	qerr := db.db.QueryRow(db.stmt(HA_GROUP_ADD_IF_NOT_PRESENT_BY_FUNCTION), requestingNode.GroupName, requestingNode.OrgId, requestingNode.NodeId, requestingNode.NMPName).Scan(&dbNodeId, &dbNmpId)
 
 	if qerr != nil && qerr != sql.ErrNoRows {
 		return nil, fmt.Errorf("error scanning row for ha nodes in group %v currently updating error: %v", requestingNode.GroupName, qerr)
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/agreementbot/persistence"
-	"strings"
 )
 
 // Constants for the sql table operations required to manage workload upgrades for service in HA groups
@@ -106,9 +105,9 @@ func (db *AgbotPostgresqlDB) GetHAUpgradingWorkload(org string, haGroupName stri
 	var dbNodeId sql.NullString
 
 	qerr := db.db.QueryRow(HA_WORKLOAD_GET, haGroupName, org, policyName).Scan(&dbHAGroup, &dbOrg, &dbPolicyName, &dbNodeId)
-	if qerr != nil && qerr != sql.ErrNoRows && !strings.Contains(qerr.Error(), "not exist") {
+	if qerr != nil && qerr != sql.ErrNoRows && !db.isMissingTable(qerr) {
 		return nil, errors.New(fmt.Sprintf("error scanning row for ha upgrading workload for org: %v, hagroup: %v and policy name %v, error: %v", org, haGroupName, policyName, qerr))
-	} else if qerr == sql.ErrNoRows || (qerr != nil && strings.Contains(qerr.Error(), "not exist")) {
+	} else if qerr == sql.ErrNoRows || (qerr != nil && db.isMissingTable(qerr)) {
 		// error is: sql: no rows in result set
 		return nil, nil
 	}
@@ -157,7 +156,7 @@ func (db *AgbotPostgresqlDB) UpdateHAUpgradingWorkloadForGroupAndPolicy(org stri
 // Check if there is an entry for the given haGroupName, org, policyName. If exists, return the node id of the existing row. If not, insert a new row.
 func (db *AgbotPostgresqlDB) InsertHAUpgradingWorkloadForGroupAndPolicy(org string, haGroupName string, policyName string, deviceId string) (string, error) {
 	var dbNodeId sql.NullString
-	qerr := db.db.QueryRow(HA_WORKLOAD_ADD_IF_NOT_PRESENT_BY_FUNCTION, haGroupName, org, policyName, deviceId).Scan(&dbNodeId)
+	qerr := db.db.QueryRow(db.stmt(HA_WORKLOAD_ADD_IF_NOT_PRESENT_BY_FUNCTION), haGroupName, org, policyName, deviceId).Scan(&dbNodeId)
 
 	if qerr != nil && qerr != sql.ErrNoRows {
 		return deviceId, fmt.Errorf("error scanning row for ha workloads currently upgrading in group %v/%v for policy %v. %v", org, haGroupName, policyName, qerr)
@@ -26,38 +26,48 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 	} else if err := pgdb.Ping(); err != nil {
 		return errors.New(fmt.Sprintf("unable to ping Postgresql database, error: %v", err))
 	} else {
-		db.db = pgdb
-
 		// Set the max open connections
-		db.db.SetMaxOpenConns(cfg.AgreementBot.Postgresql.MaxOpenConnections)
+		pgdb.SetMaxOpenConns(cfg.AgreementBot.Postgresql.MaxOpenConnections)
+
+		return db.InitializeDatabase(pgdb, postgresqlDialect, cfg)
+	}
+}
+
+// Create the tables in the opened database and initialize them as necessary. The dialect replaces the statements that the
+// database does not support.
+func (db *AgbotPostgresqlDB) InitializeDatabase(handle *sql.DB, dialect Dialect, cfg *config.HorizonConfig) error {
 
+	db.db = handle
+	db.dialect = dialect
+
+	{
 		// Initialize the DB instance fields.
 		db.identity = uuid.NewV4().String()
 		glog.V(1).Infof("Agreementbot %v initializing partitions", db.identity)
 
 		// Now create the tables and initialize them as necessary.
-		glog.V(3).Infof("Postgresql database tables initializing.")
+		glog.V(3).Infof("%v database tables initializing.", db.dialect.Name)
 
 		// Create the version table if necessary, and insert the current version row if necessary.
 		if _, err := db.db.Exec(VERSION_CREATE_TABLE); err != nil {
 			return errors.New(fmt.Sprintf("unable to create version table, error: %v", err))
-		} else if _, err := db.db.Exec(VERSION_INSERT); err != nil {
+		} else if _, err := db.db.Exec(db.stmt(VERSION_INSERT)); err != nil {
 			return errors.New(fmt.Sprintf("unable to insert singleton version row, error: %v", err))
 		}
 
 		// Create the search session table if necessary, and initialize the stored procedure functions.
 		if _, err := db.db.Exec(SEARCH_SESSIONS_CREATE_MAIN_TABLE); err != nil {
 			return errors.New(fmt.Sprintf("unable to create search session table, error: %v", err))
-		} else if _, err := db.db.Exec(SEARCH_SESSIONS_UPDATE_SESSION); err != nil {
+		} else if _, err := db.db.Exec(db.stmt(SEARCH_SESSIONS_UPDATE_SESSION)); err != nil {
 			return errors.New(fmt.Sprintf("unable to create search session update function, error: %v", err))
-		} else if _, err := db.db.Exec(SEARCH_SESSIONS_RESET_CHANGED_SINCE); err != nil {
+		} else if _, err := db.db.Exec(db.stmt(SEARCH_SESSIONS_RESET_CHANGED_SINCE)); err != nil {
 			return errors.New(fmt.Sprintf("unable to create search session reset function, error: %v", err))
 		}
 
 		// Create the partition tables and create the postgresql procedure that manages the table.
-		if _, err := db.db.Exec(PARTITION_CREATE_MAIN_TABLE); err != nil {
+		if _, err := db.db.Exec(db.stmt(PARTITION_CREATE_MAIN_TABLE)); err != nil {
 			return errors.New(fmt.Sprintf("unable to create partition table, error: %v", err))
-		} else if _, err := db.db.Exec(PARTITION_CLAIM_UNOWNED_FUNCTION); err != nil {
+		} else if _, err := db.db.Exec(db.stmt(PARTITION_CLAIM_UNOWNED_FUNCTION)); err != nil {
 			return errors.New(fmt.Sprintf("unable to create claim unowned partition function, error: %v", err))
 		}
 
@@ -78,6 +88,24 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 			return errors.New(fmt.Sprintf("unable to create workload usage partition table index, error: %v", err))
 		}
 
+		// Create the rollout table, partition and index if necessary.
+		if _, err := db.db.Exec(ROLLOUT_CREATE_MAIN_TABLE); err != nil {
+			return errors.New(fmt.Sprintf("unable to create rollouts table, error: %v", err))
+		} else if _, err := db.db.Exec(db.GetPrimaryRolloutPartitionTableCreate()); err != nil {
+			return errors.New(fmt.Sprintf("unable to create rollouts partition table, error: %v", err))
+		} else if _, err := db.db.Exec(db.GetPrimaryRolloutPartitionTableIndexCreate()); err != nil {
+			return errors.New(fmt.Sprintf("unable to create rollouts partition table index, error: %v", err))
+		}
+
+		// Create the deferred cancellation table, partition and index if necessary.
+		if _, err := db.db.Exec(DEFERRED_CANCELLATION_CREATE_MAIN_TABLE); err != nil {
+			return errors.New(fmt.Sprintf("unable to create deferred cancellations table, error: %v", err))
+		} else if _, err := db.db.Exec(db.GetPrimaryDeferredCancellationPartitionTableCreate()); err != nil {
+			return errors.New(fmt.Sprintf("unable to create deferred cancellations partition table, error: %v", err))
+		} else if _, err := db.db.Exec(db.GetPrimaryDeferredCancellationPartitionTableIndexCreate()); err != nil {
+			return errors.New(fmt.Sprintf("unable to create deferred cancellations partition table index, error: %v", err))
+		}
+
 		// Create the agreement table, partition and index if necessary.
 		if _, err := db.db.Exec(AGREEMENT_CREATE_MAIN_TABLE); err != nil {
 			return errors.New(fmt.Sprintf("unable to create agreements table, error: %v", err))
@@ -105,18 +133,18 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 		// Create the ha group upgrade table. Do not partition it.
 		if _, err := db.db.Exec(CREATE_HA_GROUP_UPGRADE_MAIN_TABLE); err != nil {
 			return fmt.Errorf("unable to create ha group update table, error: %v", err)
-		} else if _, err := db.db.Exec(HA_GROUP_ADD_IF_NOT_PRESENT); err != nil {
+		} else if _, err := db.db.Exec(db.stmt(HA_GROUP_ADD_IF_NOT_PRESENT)); err != nil {
 			return fmt.Errorf("unable to create ha group add if not present function, error: %v", err)
 		}
 
 		// Create the ha group service upgrade table. Do not partition it.
 		if _, err := db.db.Exec(CREATE_HA_WORKLOAD_UPGRADE_MAIN_TABLE); err != nil {
 			return fmt.Errorf("unable to create ha workload upgrade table, error: %v", err)
-		} else if _, err := db.db.Exec(HA_WORKLOAD_ADD_IF_NOT_PRESENT); err != nil {
+		} else if _, err := db.db.Exec(db.stmt(HA_WORKLOAD_ADD_IF_NOT_PRESENT)); err != nil {
 			return fmt.Errorf("unable to create ha workload add if not present function, error: %v", err)
 		}
 
-		glog.V(3).Infof("Postgresql primary partition database tables exist.")
+		glog.V(3).Infof("%v primary partition database tables exist.", db.dialect.Name)
 
 		// Migrate the database tables if necessary. Extract the current schema version from the version table,
 		// and then run each version's migration SQL to bring the database up to the current version supported
@@ -127,33 +155,33 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 		if err := db.db.QueryRow(VERSION_QUERY).Scan(&dbVersion, &description, &timestamp); err != nil {
 			return errors.New(fmt.Sprintf("error scanning row for current version, error: %v", err))
 		} else {
-			glog.V(3).Infof("Postgresql database tables are at version %v, %v, as of %v.", dbVersion, description, timestamp)
+			glog.V(3).Infof("%v database tables are at version %v, %v, as of %v.", db.dialect.Name, dbVersion, description, timestamp)
 		}
 
 		if dbVersion < HIGHEST_DATABASE_VERSION {
-			glog.V(3).Infof("Postgresql database tables upgrading from version %v to %v.", dbVersion, HIGHEST_DATABASE_VERSION)
+			glog.V(3).Infof("%v database tables upgrading from version %v to %v.", db.dialect.Name, dbVersion, HIGHEST_DATABASE_VERSION)
 
 			// Each new database version has it's own key in the migration SQL map.
 			for v := dbVersion + 1; v <= HIGHEST_DATABASE_VERSION; v++ {
 
 				// Run each SQL statement in the array of SQL statements for the current version.
 				for si := 0; si < len(migrationSQL[v].sql); si++ {
-					if _, err := db.db.Exec(migrationSQL[v].sql[si]); err != nil {
+					if _, err := db.db.Exec(db.stmt(migrationSQL[v].sql[si])); err != nil {
 						return errors.New(fmt.Sprintf("unable to run SQL migration statement version %v, index %v, statement %v, error: %v", v, si, migrationSQL[v].sql[si], err))
 					}
 				}
-				glog.V(3).Infof("Postgresql database tables upgraded for version %v, %v", v, migrationSQL[v].description)
+				glog.V(3).Infof("%v database tables upgraded for version %v, %v", db.dialect.Name, v, migrationSQL[v].description)
 
 				if _, err := db.db.Exec(VERSION_UPDATE, v, migrationSQL[v].description); err != nil {
 					return errors.New(fmt.Sprintf("unable to create version table, error: %v", err))
 				} else {
-					glog.V(3).Infof("Postgresql database tables upgraded to version %v, %v", v, migrationSQL[v].description)
+					glog.V(3).Infof("%v database tables upgraded to version %v, %v", db.dialect.Name, v, migrationSQL[v].description)
 				}
 			}
-			glog.V(3).Infof("Finished upgrading postgresql database tables. The version is now %v", HIGHEST_DATABASE_VERSION)
+			glog.V(3).Infof("Finished upgrading %v database tables. The version is now %v", db.dialect.Name, HIGHEST_DATABASE_VERSION)
 		}
 
-		glog.V(3).Infof("Postgresql database tables initialized.")
+		glog.V(3).Infof("%v database tables initialized.", db.dialect.Name)
 
 	}
 	return nil
@@ -116,7 +116,7 @@ func (db *AgbotPostgresqlDB) findUnownedPartition(timeout uint64) (string, error
 		}
 		defer tx.Rollback()
 
-		if err := tx.QueryRow(PARTITION_CLAIM_UNOWNED_BY_FUNCTION, db.identity, timeout).Scan(&id, &rowowner); err != nil && err != sql.ErrNoRows {
+		if err := tx.QueryRow(db.stmt(PARTITION_CLAIM_UNOWNED_BY_FUNCTION), db.identity, timeout).Scan(&id, &rowowner); err != nil && err != sql.ErrNoRows {
 			return "", errors.New(fmt.Sprintf("unable to claim stale, error: %v", err))
 		} else if err == nil {
 			// Nothing to do, we claimed a previously unowned row.
@@ -221,7 +221,7 @@ func (db *AgbotPostgresqlDB) HeartbeatPartition() error {
 func (db *AgbotPostgresqlDB) GetHeartbeat() (uint64, error) {
 
 	var hb float64
-	if err := db.db.QueryRow(PARTITION_GET_HEARTBEAT, db.PrimaryPartition()).Scan(&hb); err != nil {
+	if err := db.db.QueryRow(db.stmt(PARTITION_GET_HEARTBEAT), db.PrimaryPartition()).Scan(&hb); err != nil {
 		return 0, errors.New(fmt.Sprintf("error scanning partition %v heartbeat result, error: %v", db.PrimaryPartition(), err))
 	} else {
 		return uint64(hb), nil
@@ -274,13 +274,17 @@ func (db *AgbotPostgresqlDB) MovePartition(timeout uint64) (bool, error) {
 			return false, err
 		} else if _, err := tx.Exec(db.GetSecretPartitionTableDropPattern(fromPartition)); err != nil {
 			return false, err
+		} else if err := db.moveRolloutPartition(tx, fromPartition); err != nil {
+			return false, err
+		} else if err := db.moveDeferredCancellationPartition(tx, fromPartition); err != nil {
+			return false, err
 		} else if _, err := tx.Exec(PARTITION_DELETE, fromPartition); err != nil {
 			return false, err
 		} else {
 			if err := tx.Commit(); err != nil {
 				return false, errors.New(fmt.Sprintf("unable to commit transaction for moving agreements, error: %v", err))
 			}
-			glog.V(3).Infof("AgreementBot %v moved agreements, workload usage and secrets from partition %v to %v", db.identity, fromPartition, db.PrimaryPartition())
+			glog.V(3).Infof("AgreementBot %v moved agreements, workload usage, secrets, rollouts and deferred cancellations from partition %v to %v", db.identity, fromPartition, db.PrimaryPartition())
 		}
 	}
 	// We found a partition and moved all the records.
@@ -0,0 +1,192 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"strings"
+)
+
+// Constants for the SQL statements that are used to work with the staged rollouts of deployment policies. Rollouts are
+// partitioned by agbot instances in the same way as the workload usages, there is a main table (called rollouts) that defines
+// the schema and a table for each partition (called rollouts_<partition_name>) which inherits from the main table. The
+// rollouts of a partition are moved with its agreements and workload usages when the partition is taken over by another
+// agbot. The moved rollouts can have the same policy name as a rollout in the primary partition, the rollout manager merges
+// them.
+
+// rollouts schema:
+// policy_name: The name of the deployment policy that is being rolled out.
+// partition:   The agbot partition that this rollout lives in.
+// rollout:     The rollout object which is a JSON blob. The blob schema is defined by the Rollout struct in the persistence package.
+// updated:     A timestamp to record last updated time.
+//
+
+const ROLLOUT_CREATE_MAIN_TABLE = `CREATE TABLE IF NOT EXISTS rollouts (
+	policy_name text NOT NULL,
+	partition text NOT NULL,
+	rollout jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp
+);`
+const ROLLOUT_CREATE_PARTITION_TABLE = `CREATE TABLE IF NOT EXISTS "rollouts_ (
+	CHECK ( partition = 'partition_name' )
+) INHERITS (rollouts);`
+const ROLLOUT_CREATE_PARTITION_INDEX = `CREATE INDEX IF NOT EXISTS "policy_index_on_rollouts_ ON "rollouts_ (policy_name);`
+
+// Please note that the following SQL statement has a different syntax where the table name is specified. Note the use of
+// single quotes instead of double quotes that are used in all the other SQL.
+const ROLLOUT_PARTITION_TABLE_EXISTS = `SELECT to_regclass('rollouts_');`
+
+const ROLLOUT_TABLE_NAME_ROOT = `rollouts_`
+const ROLLOUT_PARTITION_FILLIN = `partition_name`
+
+const ALL_ROLLOUT_QUERY = `SELECT rollout FROM "rollouts_;`
+
+const ROLLOUT_INSERT = `INSERT INTO "rollouts_ (policy_name, partition, rollout) VALUES ($1, $2, $3);`
+const ROLLOUT_DELETE = `DELETE FROM "rollouts_ WHERE policy_name = $1;`
+
+const ROLLOUT_MOVE = `WITH moved_rows AS (
+    DELETE FROM "rollouts_ a
+    RETURNING a.policy_name, a.rollout
+)
+INSERT INTO "rollouts_ (policy_name, partition, rollout) SELECT policy_name, 'partition_name', rollout FROM moved_rows;
+`
+
+const ROLLOUT_DROP_PARTITION = `DROP TABLE "rollouts_;`
+
+func (db *AgbotPostgresqlDB) GetRolloutPartitionTableName(partition string) string {
+	return ROLLOUT_TABLE_NAME_ROOT + partition + `"`
+}
+
+func (db *AgbotPostgresqlDB) GetPrimaryRolloutPartitionTableCreate() string {
+	sql := strings.Replace(db.stmt(ROLLOUT_CREATE_PARTITION_TABLE), ROLLOUT_TABLE_NAME_ROOT, db.GetRolloutPartitionTableName(db.PrimaryPartition()), 1)
+	sql = strings.Replace(sql, ROLLOUT_PARTITION_FILLIN, db.PrimaryPartition(), 1)
+	return sql
+}
+
+func (db *AgbotPostgresqlDB) GetPrimaryRolloutPartitionTableIndexCreate() string {
+	sql := strings.Replace(ROLLOUT_CREATE_PARTITION_INDEX, ROLLOUT_TABLE_NAME_ROOT, db.GetRolloutPartitionTableName(db.PrimaryPartition()), 2)
+	return sql
+}
+
+func (db *AgbotPostgresqlDB) GetRolloutPartitionTableDrop(partition string) string {
+	sql := strings.Replace(ROLLOUT_DROP_PARTITION, ROLLOUT_TABLE_NAME_ROOT, db.GetRolloutPartitionTableName(partition), 1)
+	return sql
+}
+
+// The SQL template used by this function is slightly different than the others and therefore does it's own calculation
+// of how the table partition is substituted into the SQL. The difference is in the required use of single quotes.
+func (db *AgbotPostgresqlDB) GetRolloutPartitionTableExists(partition string) string {
+	sql := strings.Replace(db.stmt(ROLLOUT_PARTITION_TABLE_EXISTS), ROLLOUT_TABLE_NAME_ROOT, ROLLOUT_TABLE_NAME_ROOT+partition, 1)
+	return sql
+}
+
+// The partition table name replacement scheme used in this function is slightly different from the others above.
+func (db *AgbotPostgresqlDB) GetRolloutPartitionMove(fromPartition string, toPartition string) string {
+	sql := strings.Replace(db.stmt(ROLLOUT_MOVE), ROLLOUT_TABLE_NAME_ROOT, db.GetRolloutPartitionTableName(toPartition), 2)
+	sql = strings.Replace(sql, db.GetRolloutPartitionTableName(toPartition), db.GetRolloutPartitionTableName(fromPartition), 1)
+	sql = strings.Replace(sql, ROLLOUT_PARTITION_FILLIN, toPartition, 1)
+	return sql
+}
+
+// Move the rollouts of a partition into the primary partition and drop the partition's table. This runs in the transaction
+// of the partition move. Partitions of agbots that did not have staged rollouts do not have a rollouts table.
+func (db *AgbotPostgresqlDB) moveRolloutPartition(tx *sql.Tx, fromPartition string) error {
+	var tableName []byte
+	// This query always retuns a row in the result set. The returned table name is empty if the table does not exist.
+	if err := tx.QueryRow(db.GetRolloutPartitionTableExists(fromPartition)).Scan(&tableName); err != nil {
+		return errors.New(fmt.Sprintf("error scanning result for rollout partition %v table check, error: %v", fromPartition, err))
+	} else if string(tableName) == "" {
+		return nil
+	} else if _, err := tx.Exec(db.GetRolloutPartitionMove(fromPartition, db.PrimaryPartition())); err != nil {
+		return err
+	} else if _, err := tx.Exec(db.GetRolloutPartitionTableDrop(fromPartition)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) FindRollouts() ([]persistence.Rollout, error) {
+	rollouts := make([]persistence.Rollout, 0, 10)
+
+	for _, currentPartition := range db.AllPartitions() {
+
+		sqlStr := strings.Replace(ALL_ROLLOUT_QUERY, ROLLOUT_TABLE_NAME_ROOT, db.GetRolloutPartitionTableName(currentPartition), 1)
+		rows, err := db.db.Query(sqlStr)
+		if err != nil && db.isMissingTable(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.New(fmt.Sprintf("error querying for rollouts, error: %v", err))
+		}
+
+		// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+		defer rows.Close()
+		for rows.Next() {
+			rBytes := make([]byte, 0, 2048)
+			var r persistence.Rollout
+			if err := rows.Scan(&rBytes); err != nil {
+				return nil, errors.New(fmt.Sprintf("error scanning row: %v", err))
+			} else if err := json.Unmarshal(rBytes, &r); err != nil {
+				return nil, errors.New(fmt.Sprintf("error demarshalling row: %v, error: %v", string(rBytes), err))
+			} else {
+				rollouts = append(rollouts, r)
+			}
+		}
+
+		// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+		if err = rows.Err(); err != nil {
+			return nil, errors.New(fmt.Sprintf("error iterating: %v", err))
+		}
+	}
+
+	return rollouts, nil
+}
+
+// Replace the rollouts of the policy in all partitions with the given rollout, which is written to the primary partition.
+func (db *AgbotPostgresqlDB) SaveRollout(r *persistence.Rollout) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := db.deleteRollout(tx, r.PolicyName); err != nil {
+		return err
+	}
+
+	sqlStr := strings.Replace(ROLLOUT_INSERT, ROLLOUT_TABLE_NAME_ROOT, db.GetRolloutPartitionTableName(db.PrimaryPartition()), 1)
+	if rm, err := json.Marshal(r); err != nil {
+		return err
+	} else if _, err := tx.Exec(sqlStr, r.PolicyName, db.PrimaryPartition(), rm); err != nil {
+		return err
+	} else if err := tx.Commit(); err != nil {
+		return err
+	}
+	glog.V(5).Infof("Succeeded writing rollout record %v", r)
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) DeleteRollout(policyName string) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := db.deleteRollout(tx, policyName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *AgbotPostgresqlDB) deleteRollout(tx *sql.Tx, policyName string) error {
+	for _, currentPartition := range db.AllPartitions() {
+		sqlStr := strings.Replace(ROLLOUT_DELETE, ROLLOUT_TABLE_NAME_ROOT, db.GetRolloutPartitionTableName(currentPartition), 1)
+		if _, err := tx.Exec(sqlStr, policyName); err != nil {
+			return errors.New(fmt.Sprintf("error deleting rollout of policy %v in partition %v, error: %v", policyName, currentPartition, err))
+		}
+	}
+	return nil
+}
@@ -120,7 +120,7 @@ const SEARCH_SESSIONS_RESET_CHANGED_SINCE_FOR_POLICY = `UPDATE search_sessions
 func (db *AgbotPostgresqlDB) ObtainSearchSession(policyName string) (string, uint64, error) {
 	var ss sql.NullInt64
 	var cs sql.NullInt64
-	if err := db.db.QueryRow(SEARCH_SESSIONS_UPDATE_SESSION_BY_FUNCTION, policyName, db.identity).Scan(&ss, &cs); err != nil {
+	if err := db.db.QueryRow(db.stmt(SEARCH_SESSIONS_UPDATE_SESSION_BY_FUNCTION), policyName, db.identity).Scan(&ss, &cs); err != nil {
 		return "", 0, errors.New(fmt.Sprintf("error obtaining %v search session, error: %v", policyName, err))
 	} else if !ss.Valid {
 		return "", 0, errors.New(fmt.Sprintf("returned search session for %v is not a valid integer, error: %v", policyName, err))
@@ -139,7 +139,7 @@ func (db *AgbotPostgresqlDB) ObtainSearchSession(policyName string) (string, uin
 func (db *AgbotPostgresqlDB) UpdateSearchSessionChangedSince(currentChangedSince uint64, newChangedSince uint64, policyName string) (bool, error) {
 	var se sql.NullBool
 	glog.V(3).Infof("AgreementBot updating changedSince from %v to %v for %v search session", time.Unix(int64(currentChangedSince), 0).Format(cutil.ExchangeTimeFormat), time.Unix(int64(newChangedSince), 0).Format(cutil.ExchangeTimeFormat), policyName)
-	if err := db.db.QueryRow(SEARCH_SESSIONS_UPDATE_CHANGED_SINCE, currentChangedSince, newChangedSince, db.identity, policyName).Scan(&se); err != nil {
+	if err := db.db.QueryRow(db.stmt(SEARCH_SESSIONS_UPDATE_CHANGED_SINCE), currentChangedSince, newChangedSince, db.identity, policyName).Scan(&se); err != nil {
 		return false, errors.New(fmt.Sprintf("error updating %v search session changedSince, error: %v", policyName, err))
 	} else if !se.Valid {
 		return false, errors.New(fmt.Sprintf("returned search session state for %v is not a valid boolean, error: %v", policyName, err))
@@ -150,7 +150,7 @@ func (db *AgbotPostgresqlDB) UpdateSearchSessionChangedSince(currentChangedSince
 
 // Update all search session with a new changed Since to account for possible lost search results when an agbot restarts.
 func (db *AgbotPostgresqlDB) ResetAllChangedSince(newChangedSince uint64) error {
-	if _, err := db.db.Exec(SEARCH_SESSIONS_RESET_CHANGEDSINCE_BY_FUNCTION, newChangedSince, db.identity); err != nil {
+	if _, err := db.db.Exec(db.stmt(SEARCH_SESSIONS_RESET_CHANGEDSINCE_BY_FUNCTION), newChangedSince, db.identity); err != nil {
 		return errors.New(fmt.Sprintf("error resetting changed since in all search sessions, error: %v", err))
 	}
 	return nil
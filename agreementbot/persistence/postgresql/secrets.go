@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
 	"strings"
 )
 
@@ -92,6 +93,9 @@ const SECRET_PATTERNS_TO_UPDATE = `SELECT DISTINCT pattern_org, pattern_name FRO
 const SECRET_POLICIES_TO_UPDATE_REMOVED_SECRET = `SELECT DISTINCT policy_org, policy_name FROM "secrets_policy_ WHERE secret_org = $1 AND secret_name = $2 AND (last_update_check < $3 OR secret_exists);`
 const SECRET_PATTERNS_TO_UPDATE_REMOVED_SECRET = `SELECT DISTINCT pattern_org, pattern_name FROM "secrets_pattern_ WHERE secret_org = $1 AND secret_name = $2 AND (last_update_check < $3 OR secret_exists);`
 
+const SECRET_ALL_POLICY = `SELECT secret_org, secret_name, policy_org, policy_name, secret_exists, last_update_check FROM "secrets_policy_;`
+const SECRET_ALL_PATTERN = `SELECT secret_org, secret_name, pattern_org, pattern_name, secret_exists, last_update_check FROM "secrets_pattern_;`
+
 const SECRET_DISTINCT_POLICIES = `SELECT DISTINCT policy_name FROM "secrets_policy_ WHERE policy_org = $1;`
 const SECRET_DISTINCT_PATTERNS = `SELECT DISTINCT pattern_name FROM "secrets_pattern_ WHERE pattern_org = $1;`
 
@@ -108,13 +112,13 @@ func (db *AgbotPostgresqlDB) GetSecretPartitionTableNamePattern(partition string
 }
 
 func (db *AgbotPostgresqlDB) GetPrimarySecretPartitionTableCreatePolicy() string {
-	sql := strings.Replace(SECRET_CREATE_PARTITION_TABLE_POLICY, SECRET_TABLE_NAME_ROOT_POLICY, db.GetSecretPartitionTableNamePolicy(db.PrimaryPartition()), 1)
+	sql := strings.Replace(db.stmt(SECRET_CREATE_PARTITION_TABLE_POLICY), SECRET_TABLE_NAME_ROOT_POLICY, db.GetSecretPartitionTableNamePolicy(db.PrimaryPartition()), 1)
 	sql = strings.Replace(sql, SECRET_PARTITION_FILLIN, db.PrimaryPartition(), 1)
 	return sql
 }
 
 func (db *AgbotPostgresqlDB) GetPrimarySecretPartitionTableCreatePattern() string {
-	sql := strings.Replace(SECRET_CREATE_PARTITION_TABLE_PATTERN, SECRET_TABLE_NAME_ROOT_PATTERN, db.GetSecretPartitionTableNamePattern(db.PrimaryPartition()), 1)
+	sql := strings.Replace(db.stmt(SECRET_CREATE_PARTITION_TABLE_PATTERN), SECRET_TABLE_NAME_ROOT_PATTERN, db.GetSecretPartitionTableNamePattern(db.PrimaryPartition()), 1)
 	sql = strings.Replace(sql, SECRET_PARTITION_FILLIN, db.PrimaryPartition(), 1)
 	return sql
 }
@@ -210,6 +214,16 @@ func (db *AgbotPostgresqlDB) GetUniquePatternsQuery() string {
 	return sql
 }
 
+func (db *AgbotPostgresqlDB) GetAllSecretsQueryPolicy() string {
+	sql := strings.Replace(SECRET_ALL_POLICY, SECRET_TABLE_NAME_ROOT_POLICY, db.GetSecretPartitionTableNamePolicy(db.PrimaryPartition()), 1)
+	return sql
+}
+
+func (db *AgbotPostgresqlDB) GetAllSecretsQueryPattern() string {
+	sql := strings.Replace(SECRET_ALL_PATTERN, SECRET_TABLE_NAME_ROOT_PATTERN, db.GetSecretPartitionTableNamePattern(db.PrimaryPartition()), 1)
+	return sql
+}
+
 func (db *AgbotPostgresqlDB) GetDeletePolicy() string {
 	sql := strings.Replace(SECRET_DELETE_BY_POLICY, SECRET_TABLE_NAME_ROOT_POLICY, db.GetSecretPartitionTableNamePolicy(db.PrimaryPartition()), 1)
 	return sql
@@ -232,7 +246,7 @@ func (db *AgbotPostgresqlDB) GetDeleteSecretPattern() string {
 
 // The partition table name replacement scheme used in this function is slightly different from the others above.
 func (db *AgbotPostgresqlDB) GetSecretPartitionMovePattern(fromPartition string, toPartition string) string {
-	sql := strings.Replace(SECRET_MOVE_PATTERN, SECRET_TABLE_NAME_ROOT_PATTERN, db.GetSecretPartitionTableNamePattern(toPartition), 2)
+	sql := strings.Replace(db.stmt(SECRET_MOVE_PATTERN), SECRET_TABLE_NAME_ROOT_PATTERN, db.GetSecretPartitionTableNamePattern(toPartition), 2)
 	sql = strings.Replace(sql, db.GetSecretPartitionTableNamePattern(toPartition), db.GetSecretPartitionTableNamePattern(fromPartition), 1)
 	sql = strings.Replace(sql, SECRET_PARTITION_FILLIN, toPartition, 1)
 	return sql
@@ -240,7 +254,7 @@ func (db *AgbotPostgresqlDB) GetSecretPartitionMovePattern(fromPartition string,
 
 // The partition table name replacement scheme used in this function is slightly different from the others above.
 func (db *AgbotPostgresqlDB) GetSecretPartitionMovePolicy(fromPartition string, toPartition string) string {
-	sql := strings.Replace(SECRET_MOVE_POLICY, SECRET_TABLE_NAME_ROOT_POLICY, db.GetSecretPartitionTableNamePolicy(toPartition), 2)
+	sql := strings.Replace(db.stmt(SECRET_MOVE_POLICY), SECRET_TABLE_NAME_ROOT_POLICY, db.GetSecretPartitionTableNamePolicy(toPartition), 2)
 	sql = strings.Replace(sql, db.GetSecretPartitionTableNamePolicy(toPartition), db.GetSecretPartitionTableNamePolicy(fromPartition), 1)
 	sql = strings.Replace(sql, SECRET_PARTITION_FILLIN, toPartition, 1)
 	return sql
@@ -518,3 +532,35 @@ func (db *AgbotPostgresqlDB) AddManagedPatternSecret(secretOrg, secretName, patt
 
 	return nil
 }
+
+// Returns all the policy and pattern secret records in the primary partition.
+func (db *AgbotPostgresqlDB) FindManagedSecrets() ([]persistence.ManagedSecret, error) {
+	secrets := make([]persistence.ManagedSecret, 0, 10)
+	if err := db.findManagedSecrets(db.GetAllSecretsQueryPolicy(), false, &secrets); err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying policy secrets: %v", err))
+	} else if err := db.findManagedSecrets(db.GetAllSecretsQueryPattern(), true, &secrets); err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying pattern secrets: %v", err))
+	}
+	return secrets, nil
+}
+
+func (db *AgbotPostgresqlDB) findManagedSecrets(sqlString string, pattern bool, secrets *[]persistence.ManagedSecret) error {
+
+	rows, err := db.db.Query(sqlString)
+	if err != nil {
+		return err
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+	for rows.Next() {
+		s := persistence.ManagedSecret{Pattern: pattern}
+		if err := rows.Scan(&s.SecretOrg, &s.SecretName, &s.DeploymentOrg, &s.DeploymentName, &s.SecretExists, &s.LastUpdateCheck); err != nil {
+			return errors.New(fmt.Sprintf("error scanning secret result set row: %v", err))
+		}
+		*secrets = append(*secrets, s)
+	}
+
+	// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+	return rows.Err()
+}
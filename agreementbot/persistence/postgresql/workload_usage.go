@@ -99,7 +99,7 @@ func (db *AgbotPostgresqlDB) GetWorkloadUsagePartitionTableName(partition string
 }
 
 func (db *AgbotPostgresqlDB) GetPrimaryWorkloadUsagePartitionTableCreate() string {
-	sql := strings.Replace(WORKLOAD_USAGE_CREATE_PARTITION_TABLE, WORKLOAD_USAGE_TABLE_NAME_ROOT, db.GetWorkloadUsagePartitionTableName(db.PrimaryPartition()), 1)
+	sql := strings.Replace(db.stmt(WORKLOAD_USAGE_CREATE_PARTITION_TABLE), WORKLOAD_USAGE_TABLE_NAME_ROOT, db.GetWorkloadUsagePartitionTableName(db.PrimaryPartition()), 1)
 	sql = strings.Replace(sql, WORKLOAD_USAGE_PARTITION_FILLIN, db.PrimaryPartition(), 1)
 	return sql
 }
@@ -122,13 +122,13 @@ func (db *AgbotPostgresqlDB) GetWorkloadUsagePartitionUsageTableCount(partition
 // The SQL template used by this function is slightly different than the others and therefore does it's own calculation
 // of how the table partition is substituted into the SQL. The difference is in the required use of single quotes.
 func (db *AgbotPostgresqlDB) GetWorkloadUsagePartitionTableExists(partition string) string {
-	sql := strings.Replace(WORKLOAD_USAGE_PARTITION_TABLE_EXISTS, WORKLOAD_USAGE_TABLE_NAME_ROOT, WORKLOAD_USAGE_TABLE_NAME_ROOT+partition, 1)
+	sql := strings.Replace(db.stmt(WORKLOAD_USAGE_PARTITION_TABLE_EXISTS), WORKLOAD_USAGE_TABLE_NAME_ROOT, WORKLOAD_USAGE_TABLE_NAME_ROOT+partition, 1)
 	return sql
 }
 
 // The partition table name replacement scheme used in this function is slightly different from the others above.
 func (db *AgbotPostgresqlDB) GetWorkloadUsagePartitionMove(fromPartition string, toPartition string) string {
-	sql := strings.Replace(WORKLOAD_USAGE_MOVE, WORKLOAD_USAGE_TABLE_NAME_ROOT, db.GetWorkloadUsagePartitionTableName(toPartition), 2)
+	sql := strings.Replace(db.stmt(WORKLOAD_USAGE_MOVE), WORKLOAD_USAGE_TABLE_NAME_ROOT, db.GetWorkloadUsagePartitionTableName(toPartition), 2)
 	sql = strings.Replace(sql, db.GetWorkloadUsagePartitionTableName(toPartition), db.GetWorkloadUsagePartitionTableName(fromPartition), 1)
 	sql = strings.Replace(sql, WORKLOAD_USAGE_PARTITION_FILLIN, toPartition, 1)
 	return sql
@@ -137,7 +137,7 @@ func (db *AgbotPostgresqlDB) GetWorkloadUsagePartitionMove(fromPartition string,
 // The partition table name replacement scheme used in this function is slightly different from the others above.
 func (db *AgbotPostgresqlDB) GetWorkloadUsagesCount(partition string) (int64, error) {
 	var num int64
-	if err := db.db.QueryRow(db.GetWorkloadUsagePartitionUsageTableCount(partition)).Scan(&num); err != nil && err != sql.ErrNoRows && !strings.Contains(err.Error(), "not exist") {
+	if err := db.db.QueryRow(db.GetWorkloadUsagePartitionUsageTableCount(partition)).Scan(&num); err != nil && err != sql.ErrNoRows && !db.isMissingTable(err) {
 		return 0, errors.New(fmt.Sprintf("error scanning result for workload usage count in partition %v, error: %v", partition, err))
 	} else {
 		return num, nil
@@ -162,9 +162,9 @@ func (db *AgbotPostgresqlDB) internalFindSingleWorkloadUsageByDeviceAndPolicyNam
 			qerr = tx.QueryRow(sqlStr, deviceid, policyName).Scan(&wuBytes)
 		}
 
-		if qerr != nil && qerr != sql.ErrNoRows && !strings.Contains(qerr.Error(), "not exist") {
+		if qerr != nil && qerr != sql.ErrNoRows && !db.isMissingTable(qerr) {
 			return nil, "", errors.New(fmt.Sprintf("error scanning row for workload usage for device id %v and policy name %v, error: %v", deviceid, policyName, qerr))
-		} else if qerr == sql.ErrNoRows || (qerr != nil && strings.Contains(qerr.Error(), "not exist")) {
+		} else if qerr == sql.ErrNoRows || (qerr != nil && db.isMissingTable(qerr)) {
 			continue
 		}
 
@@ -193,7 +193,7 @@ func (db *AgbotPostgresqlDB) FindWorkloadUsages(filters []persistence.WUFilter)
 		// in memory workload usage object).
 		sqlStr := strings.Replace(ALL_WORKLOAD_USAGE_QUERY, WORKLOAD_USAGE_TABLE_NAME_ROOT, db.GetWorkloadUsagePartitionTableName(currentPartition), 1)
 		rows, err := db.db.Query(sqlStr)
-		if err != nil && strings.Contains(err.Error(), "not exist") {
+		if err != nil && db.isMissingTable(err) {
 			continue
 		} else if err != nil {
 			return nil, errors.New(fmt.Sprintf("error querying for workload usages, error: %v", err))
@@ -244,6 +244,17 @@ func (db *AgbotPostgresqlDB) NewWorkloadUsage(deviceId string, policy string, po
 	}
 }
 
+// Write a workload usage from another agbot database as it is, into the primary partition.
+func (db *AgbotPostgresqlDB) ImportWorkloadUsage(wu *persistence.WorkloadUsage) error {
+	if existing, partition, err := db.internalFindSingleWorkloadUsageByDeviceAndPolicyName(nil, wu.DeviceId, wu.PolicyName); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("Workload usage record for device %v and policy name %v already exists in partition %v.", wu.DeviceId, wu.PolicyName, partition)
+	} else {
+		return db.insertWorkloadUsage(nil, wu)
+	}
+}
+
 func (db *AgbotPostgresqlDB) UpdatePendingUpgrade(deviceid string, policyName string) (*persistence.WorkloadUsage, error) {
 	return persistence.UpdatePendingUpgrade(db, deviceid, policyName)
 }
@@ -21,18 +21,27 @@ func Register(name string, db AgbotDatabase) {
 }
 
 // Initialize the underlying Agbot database depending on what is configured. If the bolt DB is configured, it is used. Next,
-// the postgresql config is checked and used if configured. If nothing is configured, an error is returned.
+// the postgresql config is checked and used if configured, and then the sqlite config. If nothing is configured, an error
+// is returned.
 func InitDatabase(cfg *config.HorizonConfig) (AgbotDatabase, error) {
 
-	if cfg.IsBoltDBConfigured() {
-		dbObj := DatabaseProviders["bolt"]
+	if name := ConfiguredDatabase(cfg); name != "" {
+		dbObj := DatabaseProviders[name]
 		return dbObj, dbObj.Initialize(cfg)
+	}
+	return nil, errors.New(fmt.Sprintf("none of bolt DB, Postgresql DB or SQLite DB is configured correctly."))
 
-	} else if cfg.IsPostgresqlConfigured() {
-		dbObj := DatabaseProviders["postgresql"]
-		return dbObj, dbObj.Initialize(cfg)
+}
 
+// Returns the name of the DB implementation that InitDatabase uses for the config, or an empty string if nothing is
+// configured. The database is not opened.
+func ConfiguredDatabase(cfg *config.HorizonConfig) string {
+	if cfg.IsBoltDBConfigured() {
+		return "bolt"
+	} else if cfg.IsPostgresqlConfigured() {
+		return "postgresql"
+	} else if cfg.IsSqliteConfigured() {
+		return "sqlite"
 	}
-	return nil, errors.New(fmt.Sprintf("neither bolt DB nor Postgresql DB is configured correctly."))
-
+	return ""
 }
@@ -0,0 +1,52 @@
+package persistence
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/policy"
+)
+
+// The state of the staged rollout of a service upgrade for a deployment policy. The rollout manager in the agbot moves the
+// rollout forward, the database keeps it across agbot restarts. Saving a rollout replaces the rollout of the same deployment
+// policy.
+type Rollout struct {
+	PolicyName     string                  `json:"policyName"`
+	Config         policy.Rollout          `json:"config"`
+	TargetPriority int                     `json:"targetPriority"` // the workload priority the nodes are upgraded to
+	State          string                  `json:"state"`          // one of the rollout states of the rollout manager
+	Wave           int                     `json:"wave"`           // the current wave, 0 until the first wave starts
+	StartTime      uint64                  `json:"startTime"`
+	NextWaveTime   uint64                  `json:"nextWaveTime,omitempty"` // when the next wave can start, 0 while the current wave is in progress
+	HaltReason     string                  `json:"haltReason,omitempty"`
+	Nodes          map[string]*RolloutNode `json:"nodes"` // keyed by device id
+}
+
+func (r Rollout) String() string {
+	return fmt.Sprintf("PolicyName: %v, Config: %v, TargetPriority: %v, State: %v, Wave: %v, StartTime: %v, NextWaveTime: %v, HaltReason: %v, Nodes: %v",
+		r.PolicyName, r.Config, r.TargetPriority, r.State, r.Wave, r.StartTime, r.NextWaveTime, r.HaltReason, len(r.Nodes))
+}
+
+func (r *Rollout) DeepCopy() *Rollout {
+	newRollout := *r
+	newRollout.Nodes = make(map[string]*RolloutNode, len(r.Nodes))
+	for id, node := range r.Nodes {
+		newNode := *node
+		newRollout.Nodes[id] = &newNode
+	}
+	return &newRollout
+}
+
+type RolloutNode struct {
+	DeviceId         string `json:"deviceId"`
+	AgreementId      string `json:"agreementId"`           // the agreement that was in place when the rollout started
+	Protocol         string `json:"protocol,omitempty"`    // the agreement protocol in use with the node
+	PreviousPriority int    `json:"previousPriority"`      // the workload priority the node was running before the rollout
+	State            string `json:"state"`                 // one of the node states of the rollout manager
+	Wave             int    `json:"wave,omitempty"`        // the wave in which the node was upgraded
+	UpgradeTime      uint64 `json:"upgradeTime,omitempty"` // the time the upgrade of the node was started
+	Reason           string `json:"reason,omitempty"`      // why the node failed or could not be rolled back
+}
+
+func (n RolloutNode) String() string {
+	return fmt.Sprintf("DeviceId: %v, AgreementId: %v, PreviousPriority: %v, State: %v, Wave: %v, UpgradeTime: %v, Reason: %v",
+		n.DeviceId, n.AgreementId, n.PreviousPriority, n.State, n.Wave, n.UpgradeTime, n.Reason)
+}
@@ -0,0 +1,21 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+// A managed secret records that a deployment policy or pattern with active agreements uses a secret, along with the last time
+// the agbot checked the secret for updates. The deployment is a pattern when Pattern is true.
+type ManagedSecret struct {
+	SecretOrg       string `json:"secretOrg"`
+	SecretName      string `json:"secretName"`
+	DeploymentOrg   string `json:"deploymentOrg"`
+	DeploymentName  string `json:"deploymentName"`
+	Pattern         bool   `json:"pattern"`
+	SecretExists    bool   `json:"secretExists"`
+	LastUpdateCheck int64  `json:"lastUpdateCheck"`
+}
+
+func (m ManagedSecret) String() string {
+	return fmt.Sprintf("Secret: %s/%s, Deployment: %s/%s, Pattern: %v, SecretExists: %v, LastUpdateCheck: %v", m.SecretOrg, m.SecretName, m.DeploymentOrg, m.DeploymentName, m.Pattern, m.SecretExists, m.LastUpdateCheck)
+}
@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/agreementbot/persistence/postgresql"
+	"github.com/open-horizon/anax/config"
+	_ "modernc.org/sqlite"
+)
+
+// This function registers an uninitialized agbot DB instance with the DB plugin registry. The plugin's Initialize
+// method is used to configure the object.
+func init() {
+	persistence.Register("sqlite", new(AgbotSqliteDB))
+}
+
+// The SQLite database uses the tables, the partitioning scheme and the code of the postgresql implementation. Each agbot
+// instance "owns" 1 partition in the database, and each partition has its own tables. SQLite has no table inheritance,
+// so the partition tables are standalone tables with the schema of the main tables. SQLite also has no stored procedures,
+// the statements that call them are replaced by plain statements. The database file can be shared by agbots on the same
+// host, the immediate transaction locking serializes their updates in the same way the table locks do in postgresql.
+type AgbotSqliteDB struct {
+	postgresql.AgbotPostgresqlDB
+}
+
+// This function is called by the anax main to allow the configured database a chance to initialize itself.
+// This function is called every time the agbot starts, so it has to handle the following cases:
+// - Nothing exists in the database
+// - The database contains structures with schema that are not at the latest version
+// - The database is completely up to date WRT the schemas
+func (db *AgbotSqliteDB) Initialize(cfg *config.HorizonConfig) error {
+
+	glog.V(1).Infof("Opening SQLite database: %v", cfg.AgreementBot.Sqlite.File)
+
+	if sqdb, err := sql.Open("sqlite", cfg.AgreementBot.Sqlite.MakeConnectionString()); err != nil {
+		return errors.New(fmt.Sprintf("unable to open SQLite database, error: %v", err))
+	} else if err := sqdb.Ping(); err != nil {
+		return errors.New(fmt.Sprintf("unable to ping SQLite database, error: %v", err))
+	} else {
+		return db.AgbotPostgresqlDB.InitializeDatabase(sqdb, sqliteDialect, cfg)
+	}
+}
+
+// The postgresql statements that SQLite cannot run, and the statements that replace them.
+var sqliteDialect = postgresql.Dialect{
+	Name:         "SQLite",
+	MissingTable: "no such table",
+	Statements: map[string]string{
+
+		// The tables are created with the latest schema, so there is nothing to migrate in a new database.
+		postgresql.VERSION_INSERT: fmt.Sprintf(`INSERT INTO version (id, ver, description) SELECT 1, %v, 'initial tables' WHERE NOT EXISTS (SELECT 1 FROM version WHERE id = 1);`, postgresql.HIGHEST_DATABASE_VERSION),
+
+		// Search sessions.
+		postgresql.SEARCH_SESSIONS_UPDATE_SESSION: "",
+		postgresql.SEARCH_SESSIONS_UPDATE_SESSION_BY_FUNCTION: `INSERT INTO search_sessions (policyName, changedSince, sessionToken, sessionEnded, restartChangedSince, updatingAgbot, updated)
+	VALUES ($1, 0, 1999999998, false, 0, $2, current_timestamp)
+	ON CONFLICT (policyName) DO UPDATE SET
+		changedSince = CASE WHEN restartChangedSince != 0 THEN restartChangedSince ELSE changedSince END,
+		restartChangedSince = 0,
+		sessionToken = CASE WHEN sessionToken + 1 > 2000000000 THEN 1 ELSE sessionToken + 1 END,
+		sessionEnded = false, updatingAgbot = $2, updated = current_timestamp
+	WHERE sessionEnded = true;
+SELECT sessionToken, changedSince FROM search_sessions WHERE policyName = $1;`,
+		postgresql.SEARCH_SESSIONS_UPDATE_CHANGED_SINCE: `UPDATE search_sessions
+	SET changedSince = $2, sessionEnded = true, updatingAgbot = $3, updated = current_timestamp
+	WHERE changedSince = $1 AND sessionEnded = false AND policyName = $4
+	RETURNING false;`,
+		postgresql.SEARCH_SESSIONS_RESET_CHANGED_SINCE: "",
+		postgresql.SEARCH_SESSIONS_RESET_CHANGEDSINCE_BY_FUNCTION: `UPDATE search_sessions
+	SET restartChangedSince = CASE WHEN sessionEnded = false THEN $1 ELSE restartChangedSince END,
+		changedSince = CASE WHEN sessionEnded = true THEN $1 ELSE changedSince END,
+		updatingAgbot = $2, updated = current_timestamp;`,
+
+		// Partitions.
+		postgresql.PARTITION_CREATE_MAIN_TABLE: `CREATE TABLE IF NOT EXISTS partitions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner text,
+	heartbeat timestamp with time zone
+);`,
+		postgresql.PARTITION_GET_HEARTBEAT:          `SELECT CAST(strftime('%s', heartbeat) AS REAL) FROM partitions WHERE id = $1;`,
+		postgresql.PARTITION_CLAIM_UNOWNED_FUNCTION: "",
+		postgresql.PARTITION_CLAIM_UNOWNED_BY_FUNCTION: `UPDATE partitions SET owner = $1, heartbeat = current_timestamp
+	WHERE id = (
+		SELECT id FROM partitions
+			WHERE
+				(owner IS NULL AND heartbeat IS NULL)
+				OR
+				(owner IS NOT NULL AND strftime('%s', 'now') - strftime('%s', heartbeat) > $2)
+			LIMIT 1
+		)
+	RETURNING id, owner;`,
+		postgresql.AGREEMENT_PARTITIONS: `SELECT substr(name, 12) FROM sqlite_master WHERE type = 'table' AND name LIKE 'agreements\_%' ESCAPE '\';`,
+
+		// Partition tables. The first occurrence of the table name root is the partition table name, in the move statements
+		// the first occurrence is the table the rows are moved from and the second is the table they are moved to.
+		postgresql.AGREEMENT_CREATE_PARTITION_TABLE: `CREATE TABLE IF NOT EXISTS "agreements_ (
+	agreement_id text NOT NULL,
+	protocol text NOT NULL,
+	partition text NOT NULL,
+	agreement jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	CHECK ( partition = 'partition_name' )
+);`,
+		postgresql.AGREEMENT_PARTITION_TABLE_EXISTS: `SELECT (SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'agreements_');`,
+		postgresql.AGREEMENT_MOVE: `WITH moved_rows AS (
+    SELECT a.agreement_id, a.protocol, a.agreement FROM "agreements_ a
+)
+INSERT INTO "agreements_ (agreement_id, protocol, partition, agreement) SELECT agreement_id, protocol, 'partition_name', agreement FROM moved_rows;
+`,
+		postgresql.WORKLOAD_USAGE_CREATE_PARTITION_TABLE: `CREATE TABLE IF NOT EXISTS "workload_usages_ (
+	device_id text NOT NULL,
+	policy_name text NOT NULL,
+	partition text NOT NULL,
+	workload_usage jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	CHECK ( partition = 'partition_name' )
+);`,
+		postgresql.WORKLOAD_USAGE_PARTITION_TABLE_EXISTS: `SELECT (SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'workload_usages_');`,
+		postgresql.WORKLOAD_USAGE_MOVE: `WITH moved_rows AS (
+    SELECT a.device_id, a.policy_name, a.workload_usage FROM "workload_usages_ a
+)
+INSERT INTO "workload_usages_ (device_id, policy_name, partition, workload_usage) SELECT device_id, policy_name, 'partition_name', workload_usage FROM moved_rows;
+`,
+		postgresql.ROLLOUT_CREATE_PARTITION_TABLE: `CREATE TABLE IF NOT EXISTS "rollouts_ (
+	policy_name text NOT NULL,
+	partition text NOT NULL,
+	rollout jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	CHECK ( partition = 'partition_name' )
+);`,
+		postgresql.ROLLOUT_PARTITION_TABLE_EXISTS: `SELECT (SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'rollouts_');`,
+		postgresql.ROLLOUT_MOVE: `WITH moved_rows AS (
+    SELECT a.policy_name, a.rollout FROM "rollouts_ a
+)
+INSERT INTO "rollouts_ (policy_name, partition, rollout) SELECT policy_name, 'partition_name', rollout FROM moved_rows;
+`,
+		postgresql.DEFERRED_CANCELLATION_CREATE_PARTITION_TABLE: `CREATE TABLE IF NOT EXISTS "deferred_cancellations_ (
+	agreement_id text NOT NULL,
+	partition text NOT NULL,
+	deferred_cancellation jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	CHECK ( partition = 'partition_name' )
+);`,
+		postgresql.DEFERRED_CANCELLATION_PARTITION_TABLE_EXISTS: `SELECT (SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'deferred_cancellations_');`,
+		postgresql.DEFERRED_CANCELLATION_MOVE: `WITH moved_rows AS (
+    SELECT a.agreement_id, a.deferred_cancellation FROM "deferred_cancellations_ a
+)
+INSERT INTO "deferred_cancellations_ (agreement_id, partition, deferred_cancellation) SELECT agreement_id, 'partition_name', deferred_cancellation FROM moved_rows;
+`,
+		postgresql.SECRET_CREATE_PARTITION_TABLE_POLICY: `CREATE TABLE IF NOT EXISTS "secrets_policy_ (
+	secret_org text NOT NULL,
+	secret_name text NOT NULL,
+	policy_org text NOT NULL,
+	policy_name text NOT NULL,
+	secret_exists boolean NOT NULL,
+	last_update_check int NOT NULL,
+	partition text NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	CHECK ( partition = 'partition_name' ),
+	PRIMARY KEY (secret_org, secret_name, policy_org, policy_name)
+);`,
+		postgresql.SECRET_CREATE_PARTITION_TABLE_PATTERN: `CREATE TABLE IF NOT EXISTS "secrets_pattern_ (
+	secret_org text NOT NULL,
+	secret_name text NOT NULL,
+	pattern_org text NOT NULL,
+	pattern_name text NOT NULL,
+	secret_exists boolean NOT NULL,
+	last_update_check int NOT NULL,
+	partition text NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	CHECK ( partition = 'partition_name' ),
+	PRIMARY KEY (secret_org, secret_name, pattern_org, pattern_name)
+);`,
+		postgresql.SECRET_MOVE_POLICY: `WITH moved_rows AS (
+    SELECT a.secret_org, a.secret_name, a.policy_org, a.policy_name, a.last_update_check, a.secret_exists FROM "secrets_policy_ a
+)
+INSERT INTO "secrets_policy_ (secret_org, secret_name, policy_org, policy_name, last_update_check, secret_exists, partition) SELECT secret_org, secret_name, policy_org, policy_name, last_update_check, secret_exists, 'partition_name' FROM moved_rows WHERE secret_org <> policy_org ON CONFLICT DO NOTHING;
+`,
+		postgresql.SECRET_MOVE_PATTERN: `WITH moved_rows AS (
+    SELECT a.secret_org, a.secret_name, a.pattern_org, a.pattern_name, a.last_update_check, a.secret_exists FROM "secrets_pattern_ a
+)
+INSERT INTO "secrets_pattern_ (secret_org, secret_name, pattern_org, pattern_name, last_update_check, secret_exists, partition) SELECT secret_org, secret_name, pattern_org, pattern_name, last_update_check, secret_exists, 'partition_name' FROM moved_rows WHERE secret_org <> pattern_org ON CONFLICT DO NOTHING;
+`,
+
+		// HA groups.
+		postgresql.HA_GROUP_ADD_IF_NOT_PRESENT: "",
+		postgresql.HA_GROUP_ADD_IF_NOT_PRESENT_BY_FUNCTION: `INSERT INTO ha_group_updates (group_name, org_id, node_id, nmp_id)
+	SELECT $1, $2, $3, $4 WHERE NOT EXISTS (SELECT node_id FROM ha_group_updates WHERE group_name = $1 AND org_id = $2);
+SELECT node_id, nmp_id FROM ha_group_updates WHERE group_name = $1 AND org_id = $2;`,
+		postgresql.HA_WORKLOAD_ADD_IF_NOT_PRESENT: "",
+		postgresql.HA_WORKLOAD_ADD_IF_NOT_PRESENT_BY_FUNCTION: `INSERT INTO ha_workload_upgrade (group_name, org_id, policy_name, node_id)
+	SELECT $1, $2, $3, $4 WHERE NOT EXISTS (SELECT node_id FROM ha_workload_upgrade WHERE group_name = $1 AND org_id = $2 AND policy_name = $3);
+SELECT node_id FROM ha_workload_upgrade WHERE group_name = $1 AND org_id = $2 AND policy_name = $3;`,
+	},
+}
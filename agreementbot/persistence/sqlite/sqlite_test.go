@@ -0,0 +1,366 @@
+//go:build unit
+// +build unit
+
+package sqlite
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+	"os"
+	"path"
+	"testing"
+)
+
+// Start an agbot database on the database file in the directory.
+func newTestDB(t *testing.T, dir string) *AgbotSqliteDB {
+	cfg := &config.HorizonConfig{AgreementBot: config.AGConfig{Sqlite: config.SqliteConfig{File: path.Join(dir, "agbot.db")}}}
+	db := new(AgbotSqliteDB)
+	if err := db.Initialize(cfg); err != nil {
+		t.Fatalf("unable to initialize the database, error: %v", err)
+	}
+	return db
+}
+
+func Test_Agreements(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotsqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := newTestDB(t, dir)
+	defer db.Close()
+
+	if err := db.AgreementAttempt("ag1", "myorg", "myorg/node1", "device", "pol1", "", "", "", "Basic", "", []string{"svc1"}, policy.NodeHealth{}, 0, 0); err != nil {
+		t.Fatalf("unable to create agreement, error: %v", err)
+	} else if err := db.AgreementAttempt("ag2", "myorg", "myorg/node2", "device", "pol1", "", "", "", "Basic", "", []string{"svc1"}, policy.NodeHealth{}, 0, 0); err != nil {
+		t.Fatalf("unable to create agreement, error: %v", err)
+	}
+
+	if ag, err := db.AgreementMade("ag1", "counterparty", "sig", "Basic", "", "", ""); err != nil {
+		t.Errorf("unable to update agreement, error: %v", err)
+	} else if ag.CounterPartyAddress != "counterparty" {
+		t.Errorf("the agreement was not updated: %v", ag)
+	}
+
+	if ag, err := db.FindSingleAgreementByAgreementId("ag1", "Basic", []persistence.AFilter{}); err != nil {
+		t.Errorf("unable to find agreement, error: %v", err)
+	} else if ag == nil || ag.CounterPartyAddress != "counterparty" {
+		t.Errorf("the updated agreement was not saved: %v", ag)
+	}
+
+	if _, err := db.ArchiveAgreement("ag2", "Basic", 1, "test"); err != nil {
+		t.Errorf("unable to archive agreement, error: %v", err)
+	} else if active, archived, err := db.GetAgreementCount(db.PrimaryPartition()); err != nil {
+		t.Errorf("unable to count agreements, error: %v", err)
+	} else if active != 1 || archived != 1 {
+		t.Errorf("expected 1 active and 1 archived agreement, got %v and %v", active, archived)
+	}
+
+	if ags, err := db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter()}, "Basic"); err != nil {
+		t.Errorf("unable to find agreements, error: %v", err)
+	} else if len(ags) != 1 || ags[0].CurrentAgreementId != "ag1" {
+		t.Errorf("expected agreement ag1, got %v", ags)
+	}
+
+	if err := db.DeleteAgreement("ag2", "Basic"); err != nil {
+		t.Errorf("unable to delete agreement, error: %v", err)
+	} else if ag, err := db.FindSingleAgreementByAgreementId("ag2", "Basic", []persistence.AFilter{}); err != nil {
+		t.Errorf("unable to find agreement, error: %v", err)
+	} else if ag != nil {
+		t.Errorf("the agreement should be deleted: %v", ag)
+	}
+}
+
+func Test_MovePartition(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotsqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two agbots share the database file, each of them owns a partition.
+	db1 := newTestDB(t, dir)
+	defer db1.Close()
+	db2 := newTestDB(t, dir)
+	defer db2.Close()
+
+	if db1.PrimaryPartition() == db2.PrimaryPartition() {
+		t.Fatalf("the agbots should own different partitions, both own %v", db1.PrimaryPartition())
+	} else if owner, err := db2.GetPartitionOwner(db1.PrimaryPartition()); err != nil || owner == "NO OWNER" {
+		t.Errorf("partition %v should be owned by the first agbot, owner %v, error: %v", db1.PrimaryPartition(), owner, err)
+	}
+
+	if err := db1.AgreementAttempt("ag1", "myorg", "myorg/node1", "device", "pol1", "", "", "", "Basic", "", []string{"svc1"}, policy.NodeHealth{}, 0, 0); err != nil {
+		t.Fatalf("unable to create agreement, error: %v", err)
+	} else if err := db1.NewWorkloadUsage("myorg/node1", "", "pol1", 1, 60, 60, false, "ag1"); err != nil {
+		t.Fatalf("unable to create workload usage, error: %v", err)
+	} else if err := db1.AddManagedPolicySecret("otherorg", "secret1", "myorg", "pol1", true, 10); err != nil {
+		t.Fatalf("unable to add secret, error: %v", err)
+	}
+
+	if partitions, err := db2.FindPartitions(); err != nil {
+		t.Errorf("unable to find partitions, error: %v", err)
+	} else if len(partitions) != 2 {
+		t.Errorf("expected the partitions of both agbots, got %v", partitions)
+	}
+
+	// Nothing can be moved while the first agbot owns its partition.
+	if moved, err := db2.MovePartition(60); err != nil || moved {
+		t.Errorf("no partition should be moved, moved %v, error: %v", moved, err)
+	}
+
+	// After the first agbot quiesces, its records are moved to the second agbot.
+	if err := db1.QuiescePartition(); err != nil {
+		t.Fatalf("unable to quiesce partition, error: %v", err)
+	} else if moved, err := db2.MovePartition(60); err != nil || !moved {
+		t.Fatalf("the quiesced partition should be moved, moved %v, error: %v", moved, err)
+	}
+
+	if ag, err := db2.FindSingleAgreementByAgreementId("ag1", "Basic", []persistence.AFilter{}); err != nil || ag == nil {
+		t.Errorf("the agreement should be moved, agreement %v, error: %v", ag, err)
+	} else if wu, err := db2.FindSingleWorkloadUsageByDeviceAndPolicyName("myorg/node1", "pol1"); err != nil || wu == nil {
+		t.Errorf("the workload usage should be moved, workload usage %v, error: %v", wu, err)
+	} else if names, err := db2.GetManagedPolicySecretNames("", ""); err != nil || len(names) != 1 || names[0] != "otherorg/secret1" {
+		t.Errorf("the secret should be moved, secrets %v, error: %v", names, err)
+	} else if _, _, err := db2.GetAgreementCount(db1.PrimaryPartition()); err == nil {
+		t.Errorf("the tables of the old partition should be dropped")
+	}
+
+	if _, err := db2.GetPartitionOwner(db1.PrimaryPartition()); err == nil {
+		t.Errorf("the moved partition %v should be removed", db1.PrimaryPartition())
+	}
+
+	// A stale partition is claimed by a new agbot.
+	if err := db2.HeartbeatPartition(); err != nil {
+		t.Errorf("unable to heartbeat, error: %v", err)
+	} else if hb, err := db2.GetHeartbeat(); err != nil || hb == 0 {
+		t.Errorf("the heartbeat should be set, heartbeat %v, error: %v", hb, err)
+	} else if claimed, err := db1.ClaimPartition(60); err != nil {
+		t.Errorf("unable to claim partition, error: %v", err)
+	} else if claimed == db2.PrimaryPartition() {
+		t.Errorf("partition %v was heartbeated and should not be stale", claimed)
+	}
+}
+
+func Test_SearchSessions(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotsqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := newTestDB(t, dir)
+	defer db.Close()
+
+	// A new session starts just before the token rolls over.
+	token, cs, err := db.ObtainSearchSession("myorg/pol1")
+	if err != nil {
+		t.Fatalf("unable to obtain search session, error: %v", err)
+	} else if token != "1999999998" || cs != 0 {
+		t.Errorf("unexpected new session %v, changedSince %v", token, cs)
+	}
+
+	// The session is reused until it is ended.
+	if token, _, err := db.ObtainSearchSession("myorg/pol1"); err != nil || token != "1999999998" {
+		t.Errorf("the session should be reused, got %v, error: %v", token, err)
+	}
+
+	if ended, err := db.UpdateSearchSessionChangedSince(0, 100, "myorg/pol1"); err != nil || ended {
+		t.Errorf("the session should be ended by this call, ended %v, error: %v", ended, err)
+	} else if _, err := db.UpdateSearchSessionChangedSince(0, 200, "myorg/pol1"); err == nil {
+		t.Errorf("the session should already be ended")
+	}
+
+	if token, cs, err := db.ObtainSearchSession("myorg/pol1"); err != nil || token != "1999999999" || cs != 100 {
+		t.Errorf("expected session 1999999999 changedSince 100, got %v and %v, error: %v", token, cs, err)
+	}
+
+	// A restart changedSince is used by the next session, and the token rolls over.
+	if err := db.ResetPolicyChangedSince("myorg/pol1", 50); err != nil {
+		t.Errorf("unable to reset changedSince, error: %v", err)
+	} else if _, err := db.UpdateSearchSessionChangedSince(100, 300, "myorg/pol1"); err != nil {
+		t.Errorf("unable to end the session, error: %v", err)
+	} else if token, cs, err := db.ObtainSearchSession("myorg/pol1"); err != nil || token != "2000000000" || cs != 50 {
+		t.Errorf("expected session 2000000000 changedSince 50, got %v and %v, error: %v", token, cs, err)
+	} else if _, err := db.UpdateSearchSessionChangedSince(50, 400, "myorg/pol1"); err != nil {
+		t.Errorf("unable to end the session, error: %v", err)
+	} else if token, _, err := db.ObtainSearchSession("myorg/pol1"); err != nil || token != "1" {
+		t.Errorf("the session token should roll over, got %v, error: %v", token, err)
+	}
+
+	if err := db.ResetAllChangedSince(10); err != nil {
+		t.Errorf("unable to reset all changedSince, error: %v", err)
+	} else if err := db.DumpSearchSessions(); err != nil {
+		t.Errorf("unable to dump search sessions, error: %v", err)
+	}
+}
+
+func Test_HAGroups(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotsqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := newTestDB(t, dir)
+	defer db.Close()
+
+	// The first node of the group to ask is the one that upgrades.
+	if n, err := db.CheckIfGroupPresentAndUpdateHATable(persistence.UpgradingHAGroupNode{GroupName: "g1", OrgId: "myorg", NodeId: "node1", NMPName: "nmp1"}); err != nil || n.NodeId != "node1" {
+		t.Errorf("node1 should be upgrading, got %v, error: %v", n, err)
+	} else if n, err := db.CheckIfGroupPresentAndUpdateHATable(persistence.UpgradingHAGroupNode{GroupName: "g1", OrgId: "myorg", NodeId: "node2", NMPName: "nmp1"}); err != nil || n.NodeId != "node1" {
+		t.Errorf("node1 should still be upgrading, got %v, error: %v", n, err)
+	}
+
+	if node, err := db.InsertHAUpgradingWorkloadForGroupAndPolicy("myorg", "g1", "pol1", "node1"); err != nil || node != "node1" {
+		t.Errorf("node1 should be upgrading pol1, got %v, error: %v", node, err)
+	} else if node, err := db.InsertHAUpgradingWorkloadForGroupAndPolicy("myorg", "g1", "pol1", "node2"); err != nil || node != "node1" {
+		t.Errorf("node1 should still be upgrading pol1, got %v, error: %v", node, err)
+	} else if wls, err := db.ListHAUpgradingWorkloadsByGroupName("myorg", "g1"); err != nil || len(wls) != 1 {
+		t.Errorf("expected 1 upgrading workload, got %v, error: %v", wls, err)
+	}
+
+	if err := db.DeleteHAUpgradeNodeByGroup("myorg", "g1"); err != nil {
+		t.Errorf("unable to delete group, error: %v", err)
+	} else if n, err := db.ListUpgradingNodeInGroup("myorg", "g1"); err != nil || n != nil {
+		t.Errorf("no node should be upgrading, got %v, error: %v", n, err)
+	}
+}
+
+// The import functions are the postgresql implementation, they are used to migrate the records of another database.
+func Test_Import(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotsqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := newTestDB(t, dir)
+	defer db.Close()
+
+	ag := persistence.Agreement{CurrentAgreementId: "ag1", Org: "myorg", DeviceId: "myorg/node1", PolicyName: "pol1", AgreementProtocol: "Basic", CounterPartyAddress: "counterparty", AgreementTimedout: 100}
+	if err := db.ImportAgreement(&ag, "Basic"); err != nil {
+		t.Fatalf("unable to import agreement, error: %v", err)
+	} else if imported, err := db.FindSingleAgreementByAgreementId("ag1", "Basic", []persistence.AFilter{}); err != nil || imported == nil {
+		t.Errorf("agreement ag1 should be imported, agreement %v, error: %v", imported, err)
+	} else if imported.CounterPartyAddress != "counterparty" || imported.AgreementTimedout != 100 {
+		t.Errorf("agreement ag1 was not imported with its state: %v", imported)
+	} else if err := db.ImportAgreement(&ag, "Basic"); err == nil {
+		t.Errorf("agreement ag1 should not be imported twice")
+	}
+
+	wu := persistence.WorkloadUsage{DeviceId: "myorg/node1", PolicyName: "pol1", Priority: 1, RetryCount: 2, CurrentAgreementId: "ag1"}
+	if err := db.ImportWorkloadUsage(&wu); err != nil {
+		t.Fatalf("unable to import workload usage, error: %v", err)
+	} else if imported, err := db.FindSingleWorkloadUsageByDeviceAndPolicyName("myorg/node1", "pol1"); err != nil || imported == nil {
+		t.Errorf("the workload usage should be imported, workload usage %v, error: %v", imported, err)
+	} else if imported.RetryCount != 2 || imported.CurrentAgreementId != "ag1" {
+		t.Errorf("the workload usage was not imported with its state: %v", imported)
+	} else if err := db.ImportWorkloadUsage(&wu); err == nil {
+		t.Errorf("the workload usage should not be imported twice")
+	}
+}
+
+func Test_Rollouts(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotsqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db1 := newTestDB(t, dir)
+	defer db1.Close()
+	db2 := newTestDB(t, dir)
+	defer db2.Close()
+
+	node := &persistence.RolloutNode{DeviceId: "myorg/node1", AgreementId: "ag1", Protocol: "Basic", PreviousPriority: 2, State: "pending"}
+	r := persistence.Rollout{PolicyName: "myorg/pol1", TargetPriority: 1, State: "active", Nodes: map[string]*persistence.RolloutNode{node.DeviceId: node}}
+	if err := db1.SaveRollout(&r); err != nil {
+		t.Fatalf("unable to save rollout, error: %v", err)
+	}
+
+	// Saving the rollout of the same policy replaces it.
+	r.Wave = 1
+	if err := db1.SaveRollout(&r); err != nil {
+		t.Fatalf("unable to save rollout, error: %v", err)
+	} else if rollouts, err := db1.FindRollouts(); err != nil || len(rollouts) != 1 {
+		t.Errorf("expected 1 rollout, got %v, error: %v", rollouts, err)
+	} else if rollouts[0].Wave != 1 || rollouts[0].Nodes["myorg/node1"].Protocol != "Basic" {
+		t.Errorf("the rollout was not saved with its state: %v", rollouts[0])
+	}
+
+	// The rollouts of a moved partition are added to the rollouts of the primary partition, even for the same policy.
+	if err := db2.SaveRollout(&persistence.Rollout{PolicyName: "myorg/pol1", State: "active"}); err != nil {
+		t.Fatalf("unable to save rollout, error: %v", err)
+	} else if err := db1.QuiescePartition(); err != nil {
+		t.Fatalf("unable to quiesce partition, error: %v", err)
+	} else if moved, err := db2.MovePartition(60); err != nil || !moved {
+		t.Fatalf("the quiesced partition should be moved, moved %v, error: %v", moved, err)
+	} else if rollouts, err := db2.FindRollouts(); err != nil || len(rollouts) != 2 {
+		t.Errorf("expected the rollouts of both agbots, got %v, error: %v", rollouts, err)
+	}
+
+	if err := db2.SaveRollout(&r); err != nil {
+		t.Errorf("unable to save rollout, error: %v", err)
+	} else if rollouts, err := db2.FindRollouts(); err != nil || len(rollouts) != 1 {
+		t.Errorf("the saved rollout should replace both rollouts, got %v, error: %v", rollouts, err)
+	}
+
+	if err := db2.DeleteRollout("myorg/pol1"); err != nil {
+		t.Errorf("unable to delete rollout, error: %v", err)
+	} else if rollouts, err := db2.FindRollouts(); err != nil || len(rollouts) != 0 {
+		t.Errorf("the rollout should be deleted, got %v, error: %v", rollouts, err)
+	}
+}
+
+func Test_DeferredCancellations(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "agbotsqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db1 := newTestDB(t, dir)
+	defer db1.Close()
+	db2 := newTestDB(t, dir)
+	defer db2.Close()
+
+	d := persistence.DeferredCancellation{AgreementId: "ag1", Protocol: "Basic", DeviceId: "myorg/node1", PolicyName: "myorg/pol1", Reason: "policy changed", DeferredTime: 100}
+	if err := db1.SaveDeferredCancellation(&d); err != nil {
+		t.Fatalf("unable to save deferred cancellation, error: %v", err)
+	}
+
+	// Saving the deferred cancellation of the same agreement replaces it.
+	d.NextCheckTime = 200
+	if err := db1.SaveDeferredCancellation(&d); err != nil {
+		t.Fatalf("unable to save deferred cancellation, error: %v", err)
+	} else if deferred, err := db1.FindDeferredCancellations(); err != nil || len(deferred) != 1 || deferred[0] != d {
+		t.Errorf("expected deferred cancellation %v, got %v, error: %v", d, deferred, err)
+	}
+
+	// The deferred cancellations of a moved partition are moved with its agreements.
+	if err := db2.SaveDeferredCancellation(&persistence.DeferredCancellation{AgreementId: "ag2", Protocol: "Basic"}); err != nil {
+		t.Fatalf("unable to save deferred cancellation, error: %v", err)
+	} else if err := db1.QuiescePartition(); err != nil {
+		t.Fatalf("unable to quiesce partition, error: %v", err)
+	} else if moved, err := db2.MovePartition(60); err != nil || !moved {
+		t.Fatalf("the quiesced partition should be moved, moved %v, error: %v", moved, err)
+	} else if deferred, err := db2.FindDeferredCancellations(); err != nil || len(deferred) != 2 {
+		t.Errorf("expected the deferred cancellations of both agbots, got %v, error: %v", deferred, err)
+	}
+
+	if err := db2.DeleteDeferredCancellation("ag1"); err != nil {
+		t.Errorf("unable to delete deferred cancellation, error: %v", err)
+	} else if deferred, err := db2.FindDeferredCancellations(); err != nil || len(deferred) != 1 || deferred[0].AgreementId != "ag2" {
+		t.Errorf("only the deferred cancellation of ag2 should be left, got %v, error: %v", deferred, err)
+	}
+}
@@ -0,0 +1,321 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/compcheck"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"golang.org/x/text/message"
+)
+
+// The results of a policy simulation for a node.
+const (
+	SIMULATION_GAIN = "gain" // the node will form an agreement with the proposed policy
+	SIMULATION_KEEP = "keep" // the node has (or would have) an agreement with the current policy and will keep it
+	SIMULATION_LOSE = "lose" // the node has (or would have) an agreement with the current policy and will lose it
+	SIMULATION_NONE = "none" // the node is not compatible with either the current or the proposed policy
+)
+
+// The input body of the /deploycheck/policysimulation API.
+type PolicySimulationInput struct {
+	BusinessPolId  string                         `json:"business_policy_id,omitempty"` // the existing deployment policy (org/name) that is being changed, omit for a new policy
+	BusinessPolicy *businesspolicy.BusinessPolicy `json:"business_policy"`              // the proposed deployment policy
+	NodeOrg        string                         `json:"node_org,omitempty"`           // the org of the nodes to simulate, defaults to the policy's org
+	Nodes          []string                       `json:"nodes,omitempty"`              // restrict the simulation to these nodes (org/id)
+}
+
+func (p PolicySimulationInput) String() string {
+	return fmt.Sprintf("BusinessPolId: %v, BusinessPolicy: %v, NodeOrg: %v, Nodes: %v", p.BusinessPolId, p.BusinessPolicy, p.NodeOrg, p.Nodes)
+}
+
+// The simulation result for a single node. The reasons are the compcheck reasons for the current and proposed policy.
+type NodeSimulationResult struct {
+	NodeId         string            `json:"node_id"`
+	Result         string            `json:"result"`
+	HasAgreement   bool              `json:"has_agreement"`
+	CurrentReason  map[string]string `json:"current_reason,omitempty"`
+	ProposedReason map[string]string `json:"proposed_reason,omitempty"`
+}
+
+// The output of the /deploycheck/policysimulation API. Nodes that are not affected by the change are only listed
+// when the API is called with long=1, but they are always counted in the summary.
+type PolicySimulationOutput struct {
+	Summary map[string]int         `json:"summary"`
+	Gain    []NodeSimulationResult `json:"gain"`
+	Keep    []NodeSimulationResult `json:"keep"`
+	Lose    []NodeSimulationResult `json:"lose"`
+	None    []NodeSimulationResult `json:"none,omitempty"`
+	Skipped map[string]string      `json:"skipped,omitempty"` // nodes that could not be simulated and why, keyed by node id
+}
+
+func NewPolicySimulationOutput() *PolicySimulationOutput {
+	return &PolicySimulationOutput{
+		Summary: map[string]int{SIMULATION_GAIN: 0, SIMULATION_KEEP: 0, SIMULATION_LOSE: 0, SIMULATION_NONE: 0},
+		Gain:    []NodeSimulationResult{},
+		Keep:    []NodeSimulationResult{},
+		Lose:    []NodeSimulationResult{},
+		Skipped: map[string]string{},
+	}
+}
+
+// Add a node result to the output.
+func (p *PolicySimulationOutput) AddResult(res NodeSimulationResult, long bool) {
+	p.Summary[res.Result] += 1
+	switch res.Result {
+	case SIMULATION_GAIN:
+		p.Gain = append(p.Gain, res)
+	case SIMULATION_KEEP:
+		p.Keep = append(p.Keep, res)
+	case SIMULATION_LOSE:
+		p.Lose = append(p.Lose, res)
+	case SIMULATION_NONE:
+		if long {
+			p.None = append(p.None, res)
+		}
+	}
+}
+
+// Determine what happens to a node when the current policy is replaced by the proposed policy. A node that already has
+// an agreement for the current policy is treated as compatible with it, even if it would not be compatible today.
+func classifySimulatedNode(currentCompatible bool, hasAgreement bool, proposedCompatible bool) string {
+	current := currentCompatible || hasAgreement
+	if current && proposedCompatible {
+		return SIMULATION_KEEP
+	} else if proposedCompatible {
+		return SIMULATION_GAIN
+	} else if current {
+		return SIMULATION_LOSE
+	}
+	return SIMULATION_NONE
+}
+
+// This function simulates the effect of publishing a deployment policy, or a change to an existing deployment policy.
+func (a *SecureAPI) policySimulation(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	// swagger:operation GET /deploycheck/policysimulation policySimulation
+	//
+	// Simulate a deployment policy change.
+	//
+	// This API checks every node in the org against the proposed deployment policy and, if business_policy_id is given, against the current version of that policy. It returns the nodes that would gain, keep or lose an agreement with the reasons from the compatibility check. Nothing is published to the exchange.
+	//
+	// ---
+	// consumes:
+	//  - application/json
+	// produces:
+	//  - application/json
+	// parameters:
+	//  - name: long
+	//    in: query
+	//    type: bool
+	//    required: false
+	//    description: "Also list the nodes that are not compatible with either policy."
+	//  - name: business_policy_id
+	//    in: body
+	//    type: string
+	//    required: false
+	//    description: "The exchange id of the deployment policy being changed. Omit it to simulate a new deployment policy."
+	//  - name: business_policy
+	//    in: body
+	//    required: true
+	//    description: "The proposed deployment policy."
+	//    schema:
+	//     "$ref": "#/definitions/BusinessPolicy"
+	//  - name: node_org
+	//    in: body
+	//    type: string
+	//    required: false
+	//    description: "The organization of the nodes. The default is the organization of the deployment policy."
+	//  - name: nodes
+	//    in: body
+	//    type: array
+	//    required: false
+	//    description: "Restrict the simulation to these nodes."
+	// responses:
+	//  '200':
+	//    description: "Success"
+	//    schema:
+	//     type: PolicySimulationOutput
+	//  '400':
+	//    description: "Failure - No input found"
+	//    schema:
+	//     type: string
+	//  '401':
+	//    description: "Failure - Failed to authenticate"
+	//    schema:
+	//     type: string
+	//  '500':
+	//    description: "Failure - Error"
+	//    schema:
+	//      type: string
+	case "GET":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("/deploycheck/policysimulation called.")))
+
+		if user_ec, exUser, msgPrinter, ok := a.processExchangeCred("/deploycheck/policysimulation", UserTypeCred, w, r); ok {
+			body, _ := io.ReadAll(r.Body)
+			if len(body) == 0 {
+				glog.Errorf(APIlogString(fmt.Sprintf("No input found.")))
+				writeResponse(w, msgPrinter.Sprintf("No input found."), http.StatusBadRequest)
+			} else if input, err := a.decodePolicySimulationBody(body, msgPrinter); err != nil {
+				writeResponse(w, err.Error(), http.StatusBadRequest)
+			} else {
+				long := r.URL.Query().Get("long") != ""
+				output, err := a.simulatePolicy(user_ec, exUser, input, long, msgPrinter)
+				a.writeCompCheckResponse(w, output, err, msgPrinter)
+			}
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Verify the input body from the /deploycheck/policysimulation api.
+func (a *SecureAPI) decodePolicySimulationBody(body []byte, msgPrinter *message.Printer) (*PolicySimulationInput, error) {
+
+	var input PolicySimulationInput
+	if err := json.Unmarshal(body, &input); err != nil {
+		glog.Errorf(APIlogString(fmt.Sprintf("Input body couldn't be deserialized to PolicySimulationInput object. %v", err)))
+		return nil, fmt.Errorf(msgPrinter.Sprintf("Input body couldn't be deserialized to PolicySimulationInput object. %v", err))
+	} else if input.BusinessPolicy == nil {
+		return nil, fmt.Errorf(msgPrinter.Sprintf("The proposed deployment policy must be specified in business_policy."))
+	} else if err := input.BusinessPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf(msgPrinter.Sprintf("Failed to validate the proposed deployment policy. %v", err))
+	} else if input.BusinessPolId != "" && exchange.GetOrg(input.BusinessPolId) == "" {
+		return nil, fmt.Errorf(msgPrinter.Sprintf("The business_policy_id %v must be in the format org/name.", input.BusinessPolId))
+	}
+	return &input, nil
+}
+
+// Run the compatibility check for every node against the current and the proposed policy and classify the nodes.
+func (a *SecureAPI) simulatePolicy(ec exchange.ExchangeContext, exUser string, input *PolicySimulationInput, long bool, msgPrinter *message.Printer) (*PolicySimulationOutput, error) {
+
+	// Get the current version of the policy from the exchange.
+	var currentPol *businesspolicy.BusinessPolicy
+	if input.BusinessPolId != "" {
+		if pols, err := exchange.GetBusinessPolicies(ec, exchange.GetOrg(input.BusinessPolId), exchange.GetId(input.BusinessPolId)); err != nil {
+			return nil, compcheck.NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Failed to get deployment policy %v from the exchange. %v", input.BusinessPolId, err)), compcheck.COMPCHECK_EXCHANGE_ERROR)
+		} else if exPol, ok := pols[input.BusinessPolId]; !ok {
+			return nil, compcheck.NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Deployment policy %v not found in the exchange.", input.BusinessPolId)), compcheck.COMPCHECK_INPUT_ERROR)
+		} else {
+			pol := exPol.GetBusinessPolicy()
+			currentPol = &pol
+		}
+	}
+
+	nodeOrg := input.NodeOrg
+	if nodeOrg == "" && input.BusinessPolId != "" {
+		nodeOrg = exchange.GetOrg(input.BusinessPolId)
+	} else if nodeOrg == "" {
+		nodeOrg = exchange.GetOrg(ec.GetExchangeId())
+	}
+
+	nodes, err := exchange.GetExchangeOrgDevices(ec.GetHTTPFactory(), nodeOrg, ec.GetExchangeId(), ec.GetExchangeToken(), ec.GetExchangeURL())
+	if err != nil {
+		return nil, compcheck.NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Failed to get nodes from the exchange. %v", err)), compcheck.COMPCHECK_EXCHANGE_ERROR)
+	}
+
+	// The nodes that currently have an agreement with this agbot for the policy.
+	agNodes := make(map[string]bool)
+	if input.BusinessPolId != "" {
+		for _, agp := range policy.AllAgreementProtocols() {
+			if ags, err := a.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), persistence.PolAFilter(input.BusinessPolId)}, agp); err != nil {
+				return nil, fmt.Errorf(msgPrinter.Sprintf("Failed to get agreements from the database. %v", err))
+			} else {
+				for _, ag := range ags {
+					if ag.AgreementTimedout == 0 {
+						agNodes[ag.DeviceId] = true
+					}
+				}
+			}
+		}
+	}
+
+	nodeIds := make([]string, 0, len(nodes))
+	for nodeId := range nodes {
+		if len(input.Nodes) == 0 || cutil.SliceContains(input.Nodes, nodeId) {
+			nodeIds = append(nodeIds, nodeId)
+		}
+	}
+	sort.Strings(nodeIds)
+
+	// Both policies are checked against every node with the same service definitions and service policies, so they are
+	// read from the exchange once for the whole simulation. Each node is read once for both checks.
+	checker := compcheck.NewDeployChecker(ec)
+
+	output := NewPolicySimulationOutput()
+	for _, id := range input.Nodes {
+		if _, ok := nodes[id]; !ok {
+			output.Skipped[id] = msgPrinter.Sprintf("Node not found in the exchange.")
+		}
+	}
+
+	for _, nodeId := range nodeIds {
+		if nodes[nodeId].Pattern != "" {
+			output.Skipped[nodeId] = msgPrinter.Sprintf("Node is registered with pattern %v.", nodes[nodeId].Pattern)
+			continue
+		}
+
+		res := NodeSimulationResult{NodeId: nodeId, HasAgreement: agNodes[nodeId]}
+
+		currentCompatible := false
+		if currentPol != nil {
+			if compatible, reason, err := a.simulateDeployment(ec, checker, exUser, nodeId, currentPol, msgPrinter); err != nil {
+				output.Skipped[nodeId] = err.Error()
+				continue
+			} else {
+				currentCompatible = compatible
+				res.CurrentReason = reason
+			}
+		}
+
+		if compatible, reason, err := a.simulateDeployment(ec, checker, exUser, nodeId, input.BusinessPolicy, msgPrinter); err != nil {
+			output.Skipped[nodeId] = err.Error()
+			continue
+		} else {
+			res.ProposedReason = reason
+			res.Result = classifySimulatedNode(currentCompatible, res.HasAgreement, compatible)
+		}
+
+		output.AddResult(res, long)
+	}
+
+	glog.V(3).Infof(APIlogString(fmt.Sprintf("policy simulation for %v in org %v: %v", input.BusinessPolId, nodeOrg, output.Summary)))
+	return output, nil
+}
+
+// Check if the node is compatible with the given deployment policy, including the bound secrets.
+func (a *SecureAPI) simulateDeployment(ec exchange.ExchangeContext, checker *compcheck.DeployChecker, exUser string, nodeId string, pol *businesspolicy.BusinessPolicy, msgPrinter *message.Printer) (bool, map[string]string, error) {
+
+	ccInput := compcheck.CompCheck{NodeId: nodeId, BusinessPolicy: pol}
+	output, err := checker.DeployCompatible("", &ccInput, false, msgPrinter)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if output.Compatible && output.Input != nil && len(output.Input.NeededSB) != 0 {
+		if ok, msg, err := a.verifySecretNames(ec, exUser, output.Input.NeededSB, output.Input.NodeOrg, msgPrinter); err != nil {
+			return false, nil, err
+		} else if !ok {
+			output.Compatible = false
+			if output.Reason == nil {
+				output.Reason = make(map[string]string)
+			}
+			output.Reason["general"] = msg
+		}
+	}
+
+	return output.Compatible, output.Reason, nil
+}
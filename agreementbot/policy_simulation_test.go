@@ -0,0 +1,53 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"testing"
+)
+
+func Test_classifySimulatedNode(t *testing.T) {
+
+	tests := []struct {
+		current   bool
+		agreement bool
+		proposed  bool
+		result    string
+	}{
+		{false, false, true, SIMULATION_GAIN},
+		{true, false, true, SIMULATION_KEEP},
+		{false, true, true, SIMULATION_KEEP},
+		{true, true, false, SIMULATION_LOSE},
+		{false, true, false, SIMULATION_LOSE},
+		{true, false, false, SIMULATION_LOSE},
+		{false, false, false, SIMULATION_NONE},
+	}
+
+	for _, test := range tests {
+		if res := classifySimulatedNode(test.current, test.agreement, test.proposed); res != test.result {
+			t.Errorf("current: %v, agreement: %v, proposed: %v should be %v but is %v", test.current, test.agreement, test.proposed, test.result, res)
+		}
+	}
+}
+
+func Test_PolicySimulationOutput(t *testing.T) {
+
+	output := NewPolicySimulationOutput()
+	output.AddResult(NodeSimulationResult{NodeId: "org/n1", Result: SIMULATION_GAIN}, false)
+	output.AddResult(NodeSimulationResult{NodeId: "org/n2", Result: SIMULATION_LOSE}, false)
+	output.AddResult(NodeSimulationResult{NodeId: "org/n3", Result: SIMULATION_NONE}, false)
+
+	if len(output.Gain) != 1 || len(output.Lose) != 1 || len(output.Keep) != 0 {
+		t.Errorf("wrong node lists in output: %v", output)
+	} else if len(output.None) != 0 {
+		t.Errorf("unaffected nodes should only be listed in long output: %v", output)
+	} else if output.Summary[SIMULATION_NONE] != 1 || output.Summary[SIMULATION_KEEP] != 0 {
+		t.Errorf("wrong summary in output: %v", output.Summary)
+	}
+
+	output.AddResult(NodeSimulationResult{NodeId: "org/n4", Result: SIMULATION_NONE}, true)
+	if len(output.None) != 1 || output.Summary[SIMULATION_NONE] != 2 {
+		t.Errorf("unaffected nodes should be listed in long output: %v", output)
+	}
+}
@@ -24,6 +24,9 @@ type PrioritizedWorkQueue struct {
 
 	bufferSize uint64 // The (rough) maximum queue depth that should not be exceeded without blocking. This is immutable once constructed.
 
+	enqueueTimeHigh []time.Time // The time each entry in the high buffer was queued, used to measure queue latency.
+	enqueueTimeLow  []time.Time // The time each entry in the low buffer was queued, used to measure queue latency.
+
 	queueHistory *PrioritizedWorkQueueHistory // Stats records from the recent past.
 }
 
@@ -122,12 +125,14 @@ func (n *PrioritizedWorkQueue) RemoveHighPriorityBufferHead() {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
 	n.workQueueBufferHigh = n.workQueueBufferHigh[1:]
+	n.enqueueTimeHigh = observeDequeue(n.enqueueTimeHigh, HIGH_PRIORITY, len(n.workQueueBufferHigh))
 }
 
 func (n *PrioritizedWorkQueue) AddToHighPriorityBuffer(w *AgreementWork) {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
 	n.workQueueBufferHigh = append(n.workQueueBufferHigh, w)
+	n.enqueueTimeHigh = observeEnqueue(n.enqueueTimeHigh, HIGH_PRIORITY, len(n.workQueueBufferHigh))
 }
 
 func (n *PrioritizedWorkQueue) LowPriorityBufferLen() int {
@@ -146,12 +151,33 @@ func (n *PrioritizedWorkQueue) RemoveLowPriorityBufferHead() {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
 	n.workQueueBufferLow = n.workQueueBufferLow[1:]
+	n.enqueueTimeLow = observeDequeue(n.enqueueTimeLow, LOW_PRIORITY, len(n.workQueueBufferLow))
 }
 
 func (n *PrioritizedWorkQueue) AddToLowPriorityBuffer(w *AgreementWork) {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
 	n.workQueueBufferLow = append(n.workQueueBufferLow, w)
+	n.enqueueTimeLow = observeEnqueue(n.enqueueTimeLow, LOW_PRIORITY, len(n.workQueueBufferLow))
+}
+
+// Record the enqueue time of a new buffer entry and update the queue metrics. Returns the updated enqueue times.
+func observeEnqueue(times []time.Time, priority string, depth int) []time.Time {
+	agbotWorkQueueDepth.Set(float64(depth), priority)
+	agbotWorkQueueQueued.Inc(priority)
+	return append(times, time.Now())
+}
+
+// Record how long the buffer head waited to be dispatched and update the queue metrics. Returns the updated
+// enqueue times.
+func observeDequeue(times []time.Time, priority string, depth int) []time.Time {
+	agbotWorkQueueDepth.Set(float64(depth), priority)
+	agbotWorkQueueDispatched.Inc(priority)
+	if len(times) == 0 {
+		return times
+	}
+	agbotWorkQueueWait.Observe(time.Since(times[0]).Seconds(), priority)
+	return times[1:]
 }
 
 const HIGH_PRIORITY = "high"
@@ -0,0 +1,470 @@
+package agreementbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The staged rollout of a service upgrade for a deployment policy. When a deployment policy with a rollout section gets
+// a new, higher priority service version, the agreements that would have been cancelled by the policy change handler are
+// handed to the rollout manager instead. The rollout governor then upgrades those nodes in waves, using the same
+// workload usage mechanism that the HA group upgrades use: the workload usage record is removed and the agreement is
+// cancelled, so the next agreement is made with the highest priority service version.
+//
+// Nodes waiting for their wave have rollback checking disabled in their workload usage record, which keeps them on their
+// current service version if their agreement is cancelled for some other reason. When too many nodes in a wave fail,
+// the rollout halts. If rollback is enabled, the upgraded nodes have their workload usage record set back to the
+// priority they were running before the rollout (with rollback checking disabled) and their agreement is cancelled.
+//
+// The rollout state is kept in the agbot database, partitioned like the workload usages of the nodes. The rollout manager
+// reads the rollouts from the database each time it governs them, so a rollout continues after the agbot restarts, and
+// the rollouts of a partition taken over from another agbot are picked up along with its agreements.
+
+// States of a staged rollout.
+const ROLLOUT_ACTIVE = "active"
+const ROLLOUT_HALTED = "halted"
+const ROLLOUT_COMPLETE = "complete"
+
+// States of a node in a staged rollout.
+const ROLLOUT_NODE_PENDING = "pending"
+const ROLLOUT_NODE_UPGRADING = "upgrading"
+const ROLLOUT_NODE_UPGRADED = "upgraded"
+const ROLLOUT_NODE_FAILED = "failed"
+const ROLLOUT_NODE_ROLLED_BACK = "rolledBack"
+
+const GOVERN_ROLLOUTS = "AgBotGovernRollouts"
+
+type RolloutNode = persistence.RolloutNode
+
+type PolicyRollout struct {
+	persistence.Rollout
+	pol *policy.Policy // the deployment policy being rolled out
+}
+
+func NewPolicyRollout(pol *policy.Policy, targetPriority int, now uint64) *PolicyRollout {
+	return &PolicyRollout{
+		Rollout: persistence.Rollout{
+			PolicyName:     pol.Header.Name,
+			Config:         *pol.Rollout,
+			TargetPriority: targetPriority,
+			State:          ROLLOUT_ACTIVE,
+			StartTime:      now,
+			Nodes:          make(map[string]*RolloutNode),
+		},
+		pol: pol,
+	}
+}
+
+func (r *PolicyRollout) DeepCopy() *PolicyRollout {
+	return &PolicyRollout{Rollout: *r.Rollout.DeepCopy(), pol: r.pol}
+}
+
+// Add the nodes of another rollout of the same policy that are not in this rollout. This happens when the rollouts of a
+// partition taken over from another agbot are moved into this agbot's partition.
+func (r *PolicyRollout) merge(other *PolicyRollout) {
+	for id, node := range other.Nodes {
+		if _, ok := r.Nodes[id]; !ok {
+			r.Nodes[id] = node
+		}
+	}
+}
+
+// Return the nodes in the given state, sorted by device id so that the waves are predictable.
+func (r *PolicyRollout) nodesInState(state string) []*RolloutNode {
+	nodes := make([]*RolloutNode, 0)
+	for _, node := range r.Nodes {
+		if node.State == state {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].DeviceId < nodes[j].DeviceId })
+	return nodes
+}
+
+// Return the number of failed and still upgrading nodes in the given wave, and the size of the wave.
+func (r *PolicyRollout) waveCounts(wave int) (int, int, int) {
+	failed, upgrading, size := 0, 0, 0
+	for _, node := range r.Nodes {
+		if node.Wave != wave {
+			continue
+		}
+		size++
+		if node.State == ROLLOUT_NODE_FAILED {
+			failed++
+		} else if node.State == ROLLOUT_NODE_UPGRADING {
+			upgrading++
+		}
+	}
+	return failed, upgrading, size
+}
+
+func (r *PolicyRollout) halt(reason string) {
+	glog.Warningf(rolloutLogString(fmt.Sprintf("halting rollout of %v, %v", r.PolicyName, reason)))
+	r.State = ROLLOUT_HALTED
+	r.HaltReason = reason
+}
+
+// Move the rollout forward once the current wave is done. The rollout halts as soon as the failed nodes in the current
+// wave exceed the failure threshold, even if other nodes in the wave are still upgrading.
+func (r *PolicyRollout) evaluateWave(now uint64) {
+	if r.State != ROLLOUT_ACTIVE || r.Wave == 0 || r.NextWaveTime != 0 {
+		return
+	}
+
+	failed, upgrading, size := r.waveCounts(r.Wave)
+	if r.Config.FailureThresholdExceeded(failed, size) {
+		r.halt(fmt.Sprintf("%v of %v nodes failed in wave %v", failed, size, r.Wave))
+	} else if upgrading == 0 {
+		if len(r.nodesInState(ROLLOUT_NODE_PENDING)) == 0 {
+			glog.V(3).Infof(rolloutLogString(fmt.Sprintf("rollout of %v is complete", r.PolicyName)))
+			r.State = ROLLOUT_COMPLETE
+		} else {
+			r.NextWaveTime = now + uint64(r.Config.PauseS)
+		}
+	}
+}
+
+// Start the next wave if it is time to do so, returning the nodes in the new wave. The first wave starts right away.
+func (r *PolicyRollout) startWave(h rolloutNodeHandler, now uint64) []*RolloutNode {
+	if r.State != ROLLOUT_ACTIVE || (r.Wave != 0 && (r.NextWaveTime == 0 || now < r.NextWaveTime)) {
+		return nil
+	}
+
+	// Nodes whose maintenance window is closed wait for a later wave.
+	pending := []*RolloutNode{}
+	for _, node := range r.nodesInState(ROLLOUT_NODE_PENDING) {
+		if h.canUpgradeRolloutNode(r, node) {
+			pending = append(pending, node)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	size := r.Config.GetWaveSize(len(r.Nodes))
+	if size > len(pending) {
+		size = len(pending)
+	}
+
+	r.Wave++
+	r.NextWaveTime = 0
+	for _, node := range pending[:size] {
+		node.State = ROLLOUT_NODE_UPGRADING
+		node.Wave = r.Wave
+		node.UpgradeTime = now
+	}
+
+	glog.V(3).Infof(rolloutLogString(fmt.Sprintf("starting wave %v of rollout %v with %v nodes", r.Wave, r.PolicyName, size)))
+	return pending[:size]
+}
+
+// Return the service version that a node goes back to when the rollout is rolled back, or nil if there isn't one.
+func (r *PolicyRollout) rollbackWorkload(node *RolloutNode) *policy.Workload {
+	if node.PreviousPriority == 0 || node.PreviousPriority == r.TargetPriority || r.pol == nil {
+		return nil
+	}
+	return policy.GetWorkloadWithPriority(r.pol.Workloads, node.PreviousPriority)
+}
+
+// The functions that act on the nodes in a rollout. They are implemented by the agbot worker.
+type rolloutNodeHandler interface {
+	rolloutPolicy(policyName string) *policy.Policy
+	canUpgradeRolloutNode(r *PolicyRollout, node *RolloutNode) bool
+	upgradeRolloutNode(r *PolicyRollout, node *RolloutNode) error
+	checkRolloutNode(r *PolicyRollout, node *RolloutNode) (string, string)
+	rollbackRolloutNode(r *PolicyRollout, node *RolloutNode, prevWorkload *policy.Workload) error
+}
+
+func (r *PolicyRollout) govern(h rolloutNodeHandler, now uint64) {
+
+	if r.State == ROLLOUT_ACTIVE {
+		timeout := uint64(r.Config.GetSuccessTimeoutS())
+		for _, node := range r.nodesInState(ROLLOUT_NODE_UPGRADING) {
+			if state, reason := h.checkRolloutNode(r, node); state != ROLLOUT_NODE_UPGRADING {
+				node.State = state
+				node.Reason = reason
+			} else if now-node.UpgradeTime > timeout {
+				node.State = ROLLOUT_NODE_FAILED
+				node.Reason = fmt.Sprintf("node did not reach the %v state within %v seconds", r.Config.GetSuccessCriteria(), timeout)
+			}
+			if node.State != ROLLOUT_NODE_UPGRADING {
+				glog.V(3).Infof(rolloutLogString(fmt.Sprintf("rollout of %v, node %v is %v %v", r.PolicyName, node.DeviceId, node.State, node.Reason)))
+			}
+		}
+
+		r.evaluateWave(now)
+
+		for _, node := range r.startWave(h, now) {
+			if err := h.upgradeRolloutNode(r, node); err != nil {
+				node.State = ROLLOUT_NODE_FAILED
+				node.Reason = fmt.Sprintf("unable to start upgrade, error: %v", err)
+				glog.Errorf(rolloutLogString(fmt.Sprintf("rollout of %v, node %v %v", r.PolicyName, node.DeviceId, node.Reason)))
+			}
+		}
+	}
+
+	// Roll back every node that was touched by the rollout. Nodes that can't be rolled back yet, for example
+	// because they are between agreements, are tried again the next time through.
+	if r.State == ROLLOUT_HALTED && r.Config.Rollback {
+		for _, node := range r.Nodes {
+			if node.State == ROLLOUT_NODE_PENDING || node.State == ROLLOUT_NODE_ROLLED_BACK {
+				continue
+			} else if prevWorkload := r.rollbackWorkload(node); prevWorkload == nil {
+				node.Reason = "no previous service version to roll back to"
+			} else if err := h.rollbackRolloutNode(r, node, prevWorkload); err != nil {
+				node.Reason = fmt.Sprintf("unable to roll back, error: %v", err)
+				glog.Warningf(rolloutLogString(fmt.Sprintf("rollout of %v, node %v %v", r.PolicyName, node.DeviceId, node.Reason)))
+			} else {
+				glog.V(3).Infof(rolloutLogString(fmt.Sprintf("rollout of %v, node %v rolled back to priority %v", r.PolicyName, node.DeviceId, node.PreviousPriority)))
+				node.State = ROLLOUT_NODE_ROLLED_BACK
+				node.Reason = ""
+			}
+		}
+	}
+}
+
+// The rollout manager moves the staged rollouts forward. The rollouts are kept in the database, the manager serializes
+// the updates that this agbot makes to them.
+type RolloutManager struct {
+	rolloutsLock sync.Mutex
+	db           persistence.AgbotDatabase
+}
+
+func NewRolloutManager(db persistence.AgbotDatabase) *RolloutManager {
+	return &RolloutManager{
+		db: db,
+	}
+}
+
+// Read the rollouts from the database, keyed by deployment policy name. When there is more than one rollout for a policy,
+// the nodes of the older rollouts are merged into the newest one and the merged rollout is saved. The deployment policies
+// of the rollouts are not set.
+func (rm *RolloutManager) load() (map[string]*PolicyRollout, error) {
+	found, err := rm.db.FindRollouts()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read rollouts, error: %v", err))
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].StartTime > found[j].StartTime })
+
+	rollouts := make(map[string]*PolicyRollout, len(found))
+	merged := make(map[string]bool)
+	for ix := range found {
+		r := &PolicyRollout{Rollout: found[ix]}
+		if r.Nodes == nil {
+			r.Nodes = make(map[string]*RolloutNode)
+		}
+		if newest, ok := rollouts[r.PolicyName]; ok {
+			newest.merge(r)
+			merged[r.PolicyName] = true
+		} else {
+			rollouts[r.PolicyName] = r
+		}
+	}
+
+	for name := range merged {
+		glog.V(3).Infof(rolloutLogString(fmt.Sprintf("merged the rollouts of %v", name)))
+		if err := rm.db.SaveRollout(&rollouts[name].Rollout); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to save merged rollout of %v, error: %v", name, err))
+		}
+	}
+	return rollouts, nil
+}
+
+// Add nodes to the rollout of a changed deployment policy. If the policy is already being rolled out to the same target
+// service version with the same rollout settings, the nodes are added to that rollout. Otherwise a new rollout replaces
+// any previous one for the policy.
+func (rm *RolloutManager) AddRollout(pol *policy.Policy, targetPriority int, nodes []RolloutNode) error {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	rollouts, err := rm.load()
+	if err != nil {
+		return err
+	}
+
+	r, ok := rollouts[pol.Header.Name]
+	if !ok || r.State == ROLLOUT_COMPLETE || r.TargetPriority != targetPriority || !r.Config.IsSame(pol.Rollout) {
+		r = NewPolicyRollout(pol, targetPriority, uint64(time.Now().Unix()))
+		glog.V(3).Infof(rolloutLogString(fmt.Sprintf("starting rollout of %v to priority %v, %v", r.PolicyName, targetPriority, r.Config)))
+	}
+
+	for ix := range nodes {
+		if _, ok := r.Nodes[nodes[ix].DeviceId]; !ok {
+			node := nodes[ix]
+			r.Nodes[node.DeviceId] = &node
+		}
+	}
+
+	if err := rm.db.SaveRollout(&r.Rollout); err != nil {
+		return errors.New(fmt.Sprintf("unable to save rollout of %v, error: %v", r.PolicyName, err))
+	}
+	return nil
+}
+
+func (rm *RolloutManager) DeleteRollout(policyName string) error {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	if err := rm.db.DeleteRollout(policyName); err != nil {
+		return errors.New(fmt.Sprintf("unable to delete rollout of %v, error: %v", policyName, err))
+	}
+	return nil
+}
+
+// Return the rollouts, keyed by deployment policy name.
+func (rm *RolloutManager) GetRollouts() (map[string]*PolicyRollout, error) {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	return rm.load()
+}
+
+// Move each rollout forward and save the rollouts that changed.
+func (rm *RolloutManager) Govern(h rolloutNodeHandler, now uint64) {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	rollouts, err := rm.load()
+	if err != nil {
+		glog.Errorf(rolloutLogString(err))
+		return
+	}
+
+	for _, r := range rollouts {
+		r.pol = h.rolloutPolicy(r.PolicyName)
+		before := r.Rollout.DeepCopy()
+		r.govern(h, now)
+		if reflect.DeepEqual(before, &r.Rollout) {
+			continue
+		} else if err := rm.db.SaveRollout(&r.Rollout); err != nil {
+			glog.Errorf(rolloutLogString(fmt.Sprintf("unable to save rollout of %v, error: %v", r.PolicyName, err)))
+		}
+	}
+}
+
+// The rollout governor subworker.
+func (w *AgreementBotWorker) GovernRollouts() int {
+	if rolloutManager != nil {
+		rolloutManager.Govern(w, uint64(time.Now().Unix()))
+	}
+	return 0
+}
+
+// Return the deployment policy that is being rolled out, or nil if the agbot no longer has it.
+func (w *AgreementBotWorker) rolloutPolicy(policyName string) *policy.Policy {
+	return w.pm.GetPolicy(exchange.GetOrg(policyName), policyName)
+}
+
+// Return the agreements with the node under the given policy that are not being cancelled.
+func (w *AgreementBotWorker) activeRolloutAgreements(r *PolicyRollout, node *RolloutNode) ([]persistence.Agreement, error) {
+	NotTimedOut := func() persistence.AFilter {
+		return func(a persistence.Agreement) bool { return a.AgreementTimedout == 0 }
+	}
+	return w.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), persistence.DevPolAFilter(node.DeviceId, r.PolicyName), NotTimedOut()}, node.Protocol)
+}
+
+// A node can only be upgraded while the maintenance windows of the node and the policy are open.
+func (w *AgreementBotWorker) canUpgradeRolloutNode(r *PolicyRollout, node *RolloutNode) bool {
+	return InMaintenanceWindow(w, w.pm, node.DeviceId, r.PolicyName, time.Now())
+}
+
+// Remove the workload usage record so that the node picks up the highest priority service version, then cancel the
+// node's agreement.
+func (w *AgreementBotWorker) upgradeRolloutNode(r *PolicyRollout, node *RolloutNode) error {
+	if wlu, err := w.db.FindSingleWorkloadUsageByDeviceAndPolicyName(node.DeviceId, r.PolicyName); err != nil {
+		return err
+	} else if wlu != nil {
+		if err := w.db.DeleteWorkloadUsage(node.DeviceId, r.PolicyName); err != nil {
+			return err
+		}
+	}
+
+	agreements, err := w.activeRolloutAgreements(r, node)
+	if err != nil {
+		return err
+	}
+	for ix := range agreements {
+		w.TerminateAgreement(&agreements[ix], w.consumerPH.Get(agreements[ix].AgreementProtocol).GetTerminationCode(TERM_REASON_POLICY_CHANGED))
+	}
+	return nil
+}
+
+// Check if an upgrading node has met the rollout success criteria. A node fails when its workload usage record shows
+// that it ended up at a different priority than the target, which happens when the workload rollback retries kick in.
+func (w *AgreementBotWorker) checkRolloutNode(r *PolicyRollout, node *RolloutNode) (string, string) {
+	if wlu, err := w.db.FindSingleWorkloadUsageByDeviceAndPolicyName(node.DeviceId, r.PolicyName); err != nil {
+		glog.Errorf(rolloutLogString(fmt.Sprintf("error getting workload usage for %v using policy %v, error: %v", node.DeviceId, r.PolicyName, err)))
+		return ROLLOUT_NODE_UPGRADING, ""
+	} else if wlu != nil && wlu.Priority != r.TargetPriority {
+		return ROLLOUT_NODE_FAILED, fmt.Sprintf("node is running workload priority %v instead of %v", wlu.Priority, r.TargetPriority)
+	}
+
+	agreements, err := w.activeRolloutAgreements(r, node)
+	if err != nil {
+		glog.Errorf(rolloutLogString(fmt.Sprintf("error getting agreements for %v using policy %v, error: %v", node.DeviceId, r.PolicyName, err)))
+		return ROLLOUT_NODE_UPGRADING, ""
+	}
+
+	for _, ag := range agreements {
+		if ag.AgreementInceptionTime < node.UpgradeTime || ag.AgreementFinalizedTime == 0 {
+			continue
+		} else if r.Config.GetSuccessCriteria() == policy.ROLLOUT_SUCCESS_FINALIZED {
+			return ROLLOUT_NODE_UPGRADED, ""
+		} else if running, err := w.WorkloadRunningOnDevice(node.DeviceId, ag.CurrentAgreementId); err != nil {
+			glog.Errorf(rolloutLogString(fmt.Sprintf("error getting service status for %v, error: %v", node.DeviceId, err)))
+		} else if running {
+			return ROLLOUT_NODE_UPGRADED, ""
+		}
+	}
+	return ROLLOUT_NODE_UPGRADING, ""
+}
+
+// Set the node's workload usage record back to the previous priority with rollback checking disabled, so that it stays
+// there, and cancel the node's agreement if it is not already running that priority. The rollback termination reason
+// keeps the workload usage record in place when the agreement is cancelled.
+func (w *AgreementBotWorker) rollbackRolloutNode(r *PolicyRollout, node *RolloutNode, prevWorkload *policy.Workload) error {
+	agreements, err := w.activeRolloutAgreements(r, node)
+	if err != nil {
+		return err
+	}
+
+	prio := prevWorkload.Priority
+	wlu, err := w.db.FindSingleWorkloadUsageByDeviceAndPolicyName(node.DeviceId, r.PolicyName)
+	if err != nil {
+		return err
+	} else if wlu != nil && wlu.Priority == prio.PriorityValue {
+		// The node is already back at the previous priority, just make sure it stays there.
+		_, err := w.db.DisableRollbackChecking(node.DeviceId, r.PolicyName)
+		return err
+	} else if wlu != nil {
+		if _, err := w.db.UpdatePriority(node.DeviceId, r.PolicyName, prio.PriorityValue, prio.RetryDurationS, prio.VerifiedDurationS, wlu.CurrentAgreementId); err != nil {
+			return err
+		}
+	} else if len(agreements) == 0 {
+		return errors.New("waiting for node to make an agreement")
+	} else if err := w.db.NewWorkloadUsage(node.DeviceId, agreements[0].Policy, r.PolicyName, prio.PriorityValue, prio.RetryDurationS, prio.VerifiedDurationS, false, agreements[0].CurrentAgreementId); err != nil {
+		return err
+	}
+
+	if _, err := w.db.DisableRollbackChecking(node.DeviceId, r.PolicyName); err != nil {
+		return err
+	}
+
+	for ix := range agreements {
+		w.TerminateAgreement(&agreements[ix], w.consumerPH.Get(agreements[ix].AgreementProtocol).GetTerminationCode(TERM_REASON_ROLLOUT_ROLLBACK))
+	}
+	return nil
+}
+
+var rolloutLogString = func(v interface{}) string {
+	return fmt.Sprintf("Rollout Manager: %v", v)
+}
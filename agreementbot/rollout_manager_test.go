@@ -0,0 +1,295 @@
+//go:build unit
+// +build unit
+
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/agreementbot/persistence/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+	"os"
+	"testing"
+)
+
+// A node handler that records the upgrades and rollbacks, and reports the node states it is told to.
+type testRolloutHandler struct {
+	pol        *policy.Policy
+	upgraded   []string
+	rolledBack []string
+	states     map[string]string
+	closed     map[string]bool
+}
+
+func (h *testRolloutHandler) rolloutPolicy(policyName string) *policy.Policy {
+	return h.pol
+}
+
+func (h *testRolloutHandler) canUpgradeRolloutNode(r *PolicyRollout, node *RolloutNode) bool {
+	return !h.closed[node.DeviceId]
+}
+
+func (h *testRolloutHandler) upgradeRolloutNode(r *PolicyRollout, node *RolloutNode) error {
+	h.upgraded = append(h.upgraded, node.DeviceId)
+	return nil
+}
+
+func (h *testRolloutHandler) checkRolloutNode(r *PolicyRollout, node *RolloutNode) (string, string) {
+	if state, ok := h.states[node.DeviceId]; ok {
+		return state, ""
+	}
+	return ROLLOUT_NODE_UPGRADING, ""
+}
+
+func (h *testRolloutHandler) rollbackRolloutNode(r *PolicyRollout, node *RolloutNode, prevWorkload *policy.Workload) error {
+	h.rolledBack = append(h.rolledBack, node.DeviceId)
+	return nil
+}
+
+func getTestRolloutPolicy(rollout *policy.Rollout) *policy.Policy {
+	pol := policy.Policy_Factory("myorg/bp1")
+	for _, prio := range []int{1, 2} {
+		wl := policy.Workload_Factory("svc", "myorg", fmt.Sprintf("1.%v.0", 2-prio), "amd64")
+		wl.Priority = *policy.Workload_Priority_Factory(prio, 1, 60, 0)
+		pol.Add_Workload(wl)
+	}
+	pol.Rollout = rollout
+	return pol
+}
+
+// Start a rollout manager on a bolt database in the directory.
+func newTestRolloutManager(t *testing.T, dir string) (*RolloutManager, *bolt.AgbotBoltDB) {
+	db := new(bolt.AgbotBoltDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: dir}}); err != nil {
+		t.Fatalf("unable to initialize the database, error: %v", err)
+	}
+	return NewRolloutManager(db), db
+}
+
+func getTestRollout(t *testing.T, rm *RolloutManager, policyName string) *PolicyRollout {
+	rollouts, err := rm.GetRollouts()
+	if err != nil {
+		t.Fatalf("unable to get rollouts, error: %v", err)
+	}
+	return rollouts[policyName]
+}
+
+func getTestRolloutNodes(count int) []RolloutNode {
+	nodes := make([]RolloutNode, 0)
+	for i := 0; i < count; i++ {
+		nodes = append(nodes, RolloutNode{DeviceId: fmt.Sprintf("myorg/n%v", i), PreviousPriority: 2, State: ROLLOUT_NODE_PENDING})
+	}
+	return nodes
+}
+
+func Test_Rollout_Waves(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "rollout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rm, db := newTestRolloutManager(t, dir)
+	defer db.Close()
+	if err := rm.AddRollout(getTestRolloutPolicy(&policy.Rollout{BatchSize: 2, PauseS: 60, SuccessTimeoutS: 100}), 1, getTestRolloutNodes(5)); err != nil {
+		t.Fatalf("unable to add rollout, error: %v", err)
+	}
+
+	h := &testRolloutHandler{states: map[string]string{}}
+	now := uint64(1000)
+
+	// The first wave starts right away.
+	rm.Govern(h, now)
+	if len(h.upgraded) != 2 || h.upgraded[0] != "myorg/n0" || h.upgraded[1] != "myorg/n1" {
+		t.Errorf("wrong nodes upgraded in first wave: %v", h.upgraded)
+	}
+
+	// Nothing happens while the wave is in progress.
+	rm.Govern(h, now+10)
+	if len(h.upgraded) != 2 {
+		t.Errorf("no more nodes should be upgraded while the wave is in progress: %v", h.upgraded)
+	}
+
+	// The next wave waits for the pause after the nodes are upgraded.
+	h.states["myorg/n0"] = ROLLOUT_NODE_UPGRADED
+	h.states["myorg/n1"] = ROLLOUT_NODE_UPGRADED
+	rm.Govern(h, now+20)
+	if len(h.upgraded) != 2 {
+		t.Errorf("no more nodes should be upgraded during the pause: %v", h.upgraded)
+	} else if r := getTestRollout(t, rm, "myorg/bp1"); r.NextWaveTime != now+80 {
+		t.Errorf("next wave time should be %v but is %v", now+80, r.NextWaveTime)
+	}
+
+	rm.Govern(h, now+80)
+	if len(h.upgraded) != 4 {
+		t.Errorf("second wave should have started: %v", h.upgraded)
+	}
+
+	h.states["myorg/n2"] = ROLLOUT_NODE_UPGRADED
+	h.states["myorg/n3"] = ROLLOUT_NODE_UPGRADED
+	rm.Govern(h, now+90)
+	rm.Govern(h, now+150)
+	h.states["myorg/n4"] = ROLLOUT_NODE_UPGRADED
+	rm.Govern(h, now+160)
+
+	if r := getTestRollout(t, rm, "myorg/bp1"); r.State != ROLLOUT_COMPLETE || r.Wave != 3 {
+		t.Errorf("rollout should be complete after 3 waves: %v", r)
+	} else if len(h.rolledBack) != 0 {
+		t.Errorf("no nodes should be rolled back: %v", h.rolledBack)
+	}
+}
+
+func Test_Rollout_HaltAndRollback(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "rollout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rm, db := newTestRolloutManager(t, dir)
+	defer db.Close()
+	pol := getTestRolloutPolicy(&policy.Rollout{BatchPercentage: 50, SuccessTimeoutS: 100, MaxFailurePercentage: 40, Rollback: true})
+	if err := rm.AddRollout(pol, 1, getTestRolloutNodes(4)); err != nil {
+		t.Fatalf("unable to add rollout, error: %v", err)
+	}
+
+	h := &testRolloutHandler{pol: pol, states: map[string]string{}}
+	now := uint64(1000)
+
+	rm.Govern(h, now)
+	if len(h.upgraded) != 2 {
+		t.Errorf("wrong nodes upgraded in first wave: %v", h.upgraded)
+	}
+
+	// One failure out of two is over the threshold, the second node times out.
+	h.states["myorg/n0"] = ROLLOUT_NODE_UPGRADED
+	rm.Govern(h, now+101)
+
+	r := getTestRollout(t, rm, "myorg/bp1")
+	if r.State != ROLLOUT_HALTED || r.HaltReason == "" {
+		t.Errorf("rollout should be halted: %v", r)
+	} else if r.Nodes["myorg/n1"].State != ROLLOUT_NODE_ROLLED_BACK || r.Nodes["myorg/n0"].State != ROLLOUT_NODE_ROLLED_BACK {
+		t.Errorf("upgraded nodes should be rolled back: %v %v", r.Nodes["myorg/n0"], r.Nodes["myorg/n1"])
+	} else if r.Nodes["myorg/n2"].State != ROLLOUT_NODE_PENDING || len(h.upgraded) != 2 || len(h.rolledBack) != 2 {
+		t.Errorf("pending nodes should not be touched, upgraded: %v, rolled back: %v", h.upgraded, h.rolledBack)
+	}
+
+	// The same change is merged into the halted rollout, a new rollout setting starts a new rollout.
+	if err := rm.AddRollout(pol, 1, getTestRolloutNodes(5)); err != nil {
+		t.Fatalf("unable to add rollout, error: %v", err)
+	}
+	if r := getTestRollout(t, rm, "myorg/bp1"); r.State != ROLLOUT_HALTED || len(r.Nodes) != 5 {
+		t.Errorf("node should have been added to the halted rollout: %v", r)
+	}
+
+	if err := rm.AddRollout(getTestRolloutPolicy(&policy.Rollout{BatchSize: 1}), 1, getTestRolloutNodes(1)); err != nil {
+		t.Fatalf("unable to add rollout, error: %v", err)
+	}
+	if r := getTestRollout(t, rm, "myorg/bp1"); r.State != ROLLOUT_ACTIVE || len(r.Nodes) != 1 {
+		t.Errorf("a new rollout should have been started: %v", r)
+	}
+
+	if err := rm.DeleteRollout("myorg/bp1"); err != nil {
+		t.Errorf("unable to delete rollout, error: %v", err)
+	} else if rollouts, err := rm.GetRollouts(); err != nil || len(rollouts) != 0 {
+		t.Errorf("rollout should have been deleted: %v, error: %v", rollouts, err)
+	}
+}
+
+func Test_Rollout_NoPreviousVersion(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "rollout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rm, db := newTestRolloutManager(t, dir)
+	defer db.Close()
+	nodes := getTestRolloutNodes(1)
+	nodes[0].PreviousPriority = 0
+	if err := rm.AddRollout(getTestRolloutPolicy(&policy.Rollout{BatchSize: 1, Rollback: true}), 1, nodes); err != nil {
+		t.Fatalf("unable to add rollout, error: %v", err)
+	}
+
+	h := &testRolloutHandler{states: map[string]string{"myorg/n0": ROLLOUT_NODE_FAILED}}
+	rm.Govern(h, 1000)
+	rm.Govern(h, 1010)
+
+	if r := getTestRollout(t, rm, "myorg/bp1"); r.State != ROLLOUT_HALTED {
+		t.Errorf("rollout should be halted: %v", r)
+	} else if n := r.Nodes["myorg/n0"]; n.State != ROLLOUT_NODE_FAILED || n.Reason == "" || len(h.rolledBack) != 0 {
+		t.Errorf("node without a previous version should not be rolled back: %v", n)
+	}
+}
+
+func Test_Rollout_MaintenanceWindowClosed(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "rollout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rm, db := newTestRolloutManager(t, dir)
+	defer db.Close()
+	if err := rm.AddRollout(getTestRolloutPolicy(&policy.Rollout{BatchSize: 2}), 1, getTestRolloutNodes(3)); err != nil {
+		t.Fatalf("unable to add rollout, error: %v", err)
+	}
+
+	// Nodes with a closed maintenance window are skipped until it opens.
+	h := &testRolloutHandler{states: map[string]string{}, closed: map[string]bool{"myorg/n0": true, "myorg/n1": true, "myorg/n2": true}}
+	rm.Govern(h, 1000)
+	if len(h.upgraded) != 0 {
+		t.Errorf("no nodes should be upgraded while their windows are closed: %v", h.upgraded)
+	} else if r := getTestRollout(t, rm, "myorg/bp1"); r.Wave != 0 {
+		t.Errorf("the first wave should not have started: %v", r)
+	}
+
+	delete(h.closed, "myorg/n1")
+	rm.Govern(h, 1010)
+	if len(h.upgraded) != 1 || h.upgraded[0] != "myorg/n1" {
+		t.Errorf("only the node with an open window should be upgraded: %v", h.upgraded)
+	} else if r := getTestRollout(t, rm, "myorg/bp1"); r.Nodes["myorg/n0"].State != ROLLOUT_NODE_PENDING {
+		t.Errorf("the node with a closed window should still be pending: %v", r.Nodes["myorg/n0"])
+	}
+}
+
+func Test_Rollout_Restart(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "rollout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rm, db := newTestRolloutManager(t, dir)
+	pol := getTestRolloutPolicy(&policy.Rollout{BatchSize: 1, SuccessTimeoutS: 100, Rollback: true})
+	if err := rm.AddRollout(pol, 1, getTestRolloutNodes(3)); err != nil {
+		t.Fatalf("unable to add rollout, error: %v", err)
+	}
+
+	h := &testRolloutHandler{pol: pol, states: map[string]string{"myorg/n0": ROLLOUT_NODE_UPGRADED}}
+	rm.Govern(h, 1000)
+	rm.Govern(h, 1010)
+	db.Close()
+
+	// After a restart, the rollout continues where it was and the policy is found again for the rollback.
+	rm, db = newTestRolloutManager(t, dir)
+	defer db.Close()
+
+	if r := getTestRollout(t, rm, "myorg/bp1"); r == nil || r.Wave != 2 || r.Nodes["myorg/n0"].State != ROLLOUT_NODE_UPGRADED || r.Nodes["myorg/n1"].State != ROLLOUT_NODE_UPGRADING {
+		t.Fatalf("the rollout should be restored in its second wave: %v", r)
+	}
+
+	h.states["myorg/n1"] = ROLLOUT_NODE_FAILED
+	rm.Govern(h, 1020)
+	rm.Govern(h, 1030)
+	if r := getTestRollout(t, rm, "myorg/bp1"); r.State != ROLLOUT_HALTED {
+		t.Errorf("rollout should be halted: %v", r)
+	} else if len(h.rolledBack) != 2 || r.Nodes["myorg/n0"].State != ROLLOUT_NODE_ROLLED_BACK {
+		t.Errorf("upgraded nodes should be rolled back: %v, %v", h.rolledBack, r.Nodes["myorg/n0"])
+	}
+}
@@ -0,0 +1,248 @@
+package local
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// The local secrets provider uses envelope encryption. Each secret is encrypted with its own randomly generated
+// data key, and the data key is encrypted (wrapped) with a master key from the master key ring. Rotating the master
+// key only requires the data keys to be re-wrapped, the secrets themselves are not re-encrypted.
+
+const KEY_SIZE = 32 // AES-256
+
+// A master key and the time it was created.
+type MasterKey struct {
+	Key          []byte `json:"key"`
+	CreationTime int64  `json:"created_time"`
+}
+
+// The master key ring is stored in a file on the agbot's file system. It holds the current master key and any
+// previous master keys that might still be wrapping a data key.
+type MasterKeyRing struct {
+	Current string               `json:"current"`
+	Keys    map[string]MasterKey `json:"keys"`
+}
+
+func (k MasterKeyRing) String() string {
+	ids := make([]string, 0, len(k.Keys))
+	for id := range k.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return fmt.Sprintf("Current: %v, Keys: %v", k.Current, ids)
+}
+
+// Create a key ring with a single, newly generated master key.
+func NewMasterKeyRing() (*MasterKeyRing, error) {
+	kr := &MasterKeyRing{
+		Keys: make(map[string]MasterKey),
+	}
+	if _, err := kr.AddKey(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Generate a new master key and make it the current key. Returns the id of the new key.
+func (k *MasterKeyRing) AddKey() (string, error) {
+	key, err := newRandomKey()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	id := fmt.Sprintf("%v", now.UnixNano())
+	k.Keys[id] = MasterKey{Key: key, CreationTime: now.Unix()}
+	k.Current = id
+	return id, nil
+}
+
+// Return the current master key and its id.
+func (k *MasterKeyRing) CurrentKey() (string, []byte, error) {
+	if mk, ok := k.Keys[k.Current]; !ok {
+		return "", nil, errors.New(fmt.Sprintf("current master key %v is not in the key ring", k.Current))
+	} else {
+		return k.Current, mk.Key, nil
+	}
+}
+
+// Returns the age of the current master key.
+func (k *MasterKeyRing) CurrentKeyAge() time.Duration {
+	if mk, ok := k.Keys[k.Current]; ok {
+		return time.Since(time.Unix(mk.CreationTime, 0))
+	}
+	return 0
+}
+
+func (k *MasterKeyRing) Validate() error {
+	if len(k.Keys) == 0 {
+		return errors.New("the key ring does not contain any master keys")
+	} else if _, _, err := k.CurrentKey(); err != nil {
+		return err
+	}
+	for id, mk := range k.Keys {
+		if len(mk.Key) != KEY_SIZE {
+			return errors.New(fmt.Sprintf("master key %v has length %v, must be %v", id, len(mk.Key), KEY_SIZE))
+		}
+	}
+	return nil
+}
+
+// Read the key ring from the file system. If the file does not exist, a new key ring is created and saved.
+func LoadMasterKeyRing(fileName string) (*MasterKeyRing, error) {
+	if bytes, err := os.ReadFile(fileName); os.IsNotExist(err) {
+		kr, err := NewMasterKeyRing()
+		if err != nil {
+			return nil, err
+		}
+		return kr, kr.Save(fileName)
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read master key file %v, error: %v", fileName, err))
+	} else {
+		kr := new(MasterKeyRing)
+		if err := json.Unmarshal(bytes, kr); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to parse master key file %v, error: %v", fileName, err))
+		} else if err := kr.Validate(); err != nil {
+			return nil, errors.New(fmt.Sprintf("master key file %v is not valid, error: %v", fileName, err))
+		}
+		return kr, nil
+	}
+}
+
+// Write the key ring to the file system. The file is written to a temporary file first and then renamed, so that
+// a failure part way through never leaves a partially written key ring behind.
+func (k *MasterKeyRing) Save(fileName string) error {
+	bytes, err := json.Marshal(k)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal master key ring, error: %v", err))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return errors.New(fmt.Sprintf("unable to create directory for master key file %v, error: %v", fileName, err))
+	}
+
+	tmpFile := fileName + ".tmp"
+	if err := os.WriteFile(tmpFile, bytes, 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to write master key file %v, error: %v", tmpFile, err))
+	} else if err := os.Rename(tmpFile, fileName); err != nil {
+		return errors.New(fmt.Sprintf("unable to rename %v to %v, error: %v", tmpFile, fileName, err))
+	}
+	return nil
+}
+
+// Encrypt plaintext with a new data key, and wrap the data key with the current master key.
+func (k *MasterKeyRing) Seal(plaintext []byte) (keyId string, wrappedKey []byte, ciphertext []byte, err error) {
+	keyId, masterKey, err := k.CurrentKey()
+	if err != nil {
+		return
+	}
+
+	dataKey, err := newRandomKey()
+	if err != nil {
+		return
+	}
+
+	if ciphertext, err = encrypt(dataKey, plaintext); err != nil {
+		return
+	}
+	wrappedKey, err = encrypt(masterKey, dataKey)
+	return
+}
+
+// Unwrap the data key with the master key it was wrapped with, and decrypt the ciphertext.
+func (k *MasterKeyRing) Open(keyId string, wrappedKey []byte, ciphertext []byte) ([]byte, error) {
+	dataKey, err := k.unwrap(keyId, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(dataKey, ciphertext)
+}
+
+// Re-wrap a data key with the current master key. Returns the id of the current master key and the new wrapped key.
+func (k *MasterKeyRing) Rewrap(keyId string, wrappedKey []byte) (string, []byte, error) {
+	dataKey, err := k.unwrap(keyId, wrappedKey)
+	if err != nil {
+		return "", nil, err
+	}
+	currentId, masterKey, err := k.CurrentKey()
+	if err != nil {
+		return "", nil, err
+	}
+	newWrappedKey, err := encrypt(masterKey, dataKey)
+	return currentId, newWrappedKey, err
+}
+
+// Remove all keys except the current one.
+func (k *MasterKeyRing) RemovePreviousKeys() {
+	for id := range k.Keys {
+		if id != k.Current {
+			delete(k.Keys, id)
+		}
+	}
+}
+
+func (k *MasterKeyRing) unwrap(keyId string, wrappedKey []byte) ([]byte, error) {
+	mk, ok := k.Keys[keyId]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("master key %v is not in the key ring", keyId))
+	}
+	return decrypt(mk.Key, wrappedKey)
+}
+
+func newRandomKey() ([]byte, error) {
+	key := make([]byte, KEY_SIZE)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to generate key, error: %v", err))
+	}
+	return key, nil
+}
+
+// AES-GCM encryption, the nonce is prepended to the returned ciphertext.
+func encrypt(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to generate nonce, error: %v", err))
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	if plaintext, err := gcm.Open(nil, nonce, sealed, nil); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to decrypt, error: %v", err))
+	} else {
+		return plaintext, nil
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create cipher, error: %v", err))
+	}
+	if gcm, err := cipher.NewGCM(block); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create GCM, error: %v", err))
+	} else {
+		return gcm, nil
+	}
+}
@@ -0,0 +1,118 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+)
+
+// This function is called by the anax main to allow the plugin a chance to initialize itself.
+// This function is called every time the agbot starts. The secrets database is opened (or created) and the
+// master key ring is loaded (or generated) here.
+func (ls *AgbotLocalSecrets) Initialize(cfg *config.HorizonConfig) (err error) {
+
+	glog.V(1).Infof(localPluginLogString("Initializing the local secrets plugin."))
+
+	ls.cfg = cfg
+	if ls.authenticate == nil {
+		ls.authenticate = ls.exchangeAuthenticator
+	}
+
+	dbPath := cfg.AgreementBot.LocalSecrets.DBPath
+	if err := os.MkdirAll(dbPath, 0700); err != nil {
+		return errors.New(fmt.Sprintf("unable to create local secrets directory %v, error: %v", dbPath, err))
+	}
+
+	dbName := path.Join(dbPath, LOCAL_SECRETS_DATABASE_NAME)
+	if ls.db, err = bolt.Open(dbName, 0600, &bolt.Options{Timeout: 10 * time.Second}); err != nil {
+		return errors.New(fmt.Sprintf("unable to open local secrets database %v, error: %v", dbName, err))
+	}
+
+	if ls.keyRing, err = LoadMasterKeyRing(cfg.GetLocalSecretsMasterKeyFile()); err != nil {
+		ls.db.Close()
+		ls.db = nil
+		return err
+	}
+
+	glog.V(1).Infof(localPluginLogString(fmt.Sprintf("Initialized the local secrets plugin: %v", ls)))
+
+	return nil
+}
+
+// There is no external secrets manager to log in to, the plugin is ready as soon as the database is open.
+func (ls *AgbotLocalSecrets) Login() error {
+	if ls.db == nil {
+		return errors.New("local secrets database is not open")
+	}
+	ls.dbInteraction()
+	return nil
+}
+
+// Called periodically by the agbot. This is where the master key is rotated when it reaches the configured age.
+func (ls *AgbotLocalSecrets) Renew() error {
+
+	interval := ls.cfg.AgreementBot.LocalSecrets.KeyRotationIntervalH
+	if interval <= 0 {
+		return nil
+	}
+
+	ls.keyRingLock.RLock()
+	age := ls.keyRing.CurrentKeyAge()
+	ls.keyRingLock.RUnlock()
+
+	if age >= time.Duration(interval)*time.Hour {
+		glog.V(3).Infof(localPluginLogString(fmt.Sprintf("master key is %v old, rotating it", age)))
+		if err := ls.RotateMasterKey(); err != nil {
+			return errors.New(fmt.Sprintf("agbot unable to rotate local secrets master key, error: %v", err))
+		}
+	}
+	return nil
+}
+
+func (ls *AgbotLocalSecrets) IsReady() bool {
+	return ls.db != nil && ls.keyRing != nil
+}
+
+func (ls *AgbotLocalSecrets) Close() {
+	if ls.db != nil {
+		ls.db.Close()
+		ls.db = nil
+	}
+	glog.V(2).Infof("Closed local secrets implementation")
+}
+
+func (ls *AgbotLocalSecrets) GetLastVaultStatus() uint64 {
+	return ls.lastDBInteraction
+}
+
+// Verify the user's credentials by retrieving the user from the exchange. The user is in the form org/user.
+func (ls *AgbotLocalSecrets) exchangeAuthenticator(user, token string) (string, string, bool, error) {
+
+	var resp interface{}
+	resp = new(exchange.GetUsersResponse)
+	targetURL := fmt.Sprintf("%vorgs/%v/users/%v", ls.cfg.AgreementBot.ExchangeURL, exchange.GetOrg(user), exchange.GetId(user))
+
+	if err := exchange.InvokeExchangeRetryOnTransportError(ls.cfg.Collaborators.HTTPClientFactory, "GET", targetURL, user, token, nil, &resp); err != nil {
+		return "", "", false, err
+	}
+
+	// The response should contain exactly one user, keyed by org/user.
+	users, _ := resp.(*exchange.GetUsersResponse)
+	for key, def := range users.Users {
+		orgAndUser := strings.Split(key, "/")
+		if len(orgAndUser) != 2 {
+			return "", "", false, errors.New(fmt.Sprintf("exchange user %v is not in the correct format, should be org/username", key))
+		}
+		return orgAndUser[1], orgAndUser[0], def.Admin, nil
+	}
+
+	return "", "", false, errors.New(fmt.Sprintf("user %v not found in the exchange", user))
+}
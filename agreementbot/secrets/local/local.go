@@ -0,0 +1,567 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/secrets"
+	"github.com/open-horizon/anax/config"
+)
+
+// This function registers an uninitialized agbot secrets implementation with the secrets plugin registry. The plugin's Initialize
+// method is used to configure the object.
+func init() {
+	secrets.Register("local", new(AgbotLocalSecrets))
+}
+
+const LOCAL_SECRETS_DATABASE_NAME = "agbotsecrets.db"
+const SECRETS_BUCKET = "secrets"
+
+// The kinds of access that a user can request for a secret.
+const (
+	ACCESS_LIST  = "list"
+	ACCESS_READ  = "read"
+	ACCESS_WRITE = "write"
+)
+
+// A function that verifies the user's credentials with the exchange. It returns the exchange user name (without the org),
+// the user's org, and whether or not the user is an org admin.
+type Authenticator func(user, token string) (exUser string, userOrg string, admin bool, err error)
+
+// The fields in this object are initialized in the Initialize method in this package.
+type AgbotLocalSecrets struct {
+	db                *bolt.DB
+	cfg               *config.HorizonConfig
+	keyRing           *MasterKeyRing
+	keyRingLock       sync.RWMutex // Protects the key ring, which changes when the master key is rotated.
+	authenticate      Authenticator
+	lastDBInteraction uint64
+}
+
+func (ls *AgbotLocalSecrets) String() string {
+	return fmt.Sprintf("DB: %v, KeyRing: %v", ls.cfg.AgreementBot.LocalSecrets.DBPath, ls.keyRing)
+}
+
+// The record stored in the database for each secret. The secret details are encrypted with a data key which is
+// wrapped by the master key identified by KeyId.
+type secretRecord struct {
+	KeyId        string `json:"key_id"`
+	WrappedKey   []byte `json:"wrapped_key"`
+	Ciphertext   []byte `json:"ciphertext"`
+	CreationTime int64  `json:"created_time"`
+	UpdateTime   int64  `json:"updated_time"`
+}
+
+// Secrets are stored in the database under a key of the form <org>/<path>, where path is one of:
+// <name>, user/<user>/<name>, node/<node>/<name> or user/<user>/node/<node>/<name>.
+func secretKey(org, path string) string {
+	return org + "/" + strings.Trim(path, "/")
+}
+
+// Returns the path of the secret within the org for the given owner and name.
+func secretPath(secretUser, secretNode, secretName string) string {
+	if secretUser != "" && secretNode != "" {
+		return fmt.Sprintf("user/%s/node/%s/%s", secretUser, secretNode, secretName)
+	} else if secretUser != "" {
+		return fmt.Sprintf("user/%s/%s", secretUser, secretName)
+	} else if secretNode != "" {
+		return fmt.Sprintf("node/%s/%s", secretNode, secretName)
+	}
+	return secretName
+}
+
+// Returns true if the secret path is owned by a user, i.e. it is a user or user node secret.
+func isUserPath(path string) bool {
+	return strings.HasPrefix(path, "user/")
+}
+
+// Returns true if the secret path belongs to the given user.
+func isOwnedBy(path, exUser string) bool {
+	prefix := "user/" + exUser
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// Available to all users within the org
+func (ls *AgbotLocalSecrets) ListOrgSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("list secret %v in org %v", path, org)))
+	return ls.listSecret(user, token, org, path)
+}
+
+// Available to all users within the org
+func (ls *AgbotLocalSecrets) ListOrgUserSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("list secret %v in org %v as user %v", path, org, user)))
+	return ls.listSecret(user, token, org, path)
+}
+
+// Available to all users in the org
+func (ls *AgbotLocalSecrets) ListOrgNodeSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("list secret %v in org %v", path, org)))
+	return ls.listSecret(user, token, org, path)
+}
+
+// Available to admins and the user that owns the secret
+func (ls *AgbotLocalSecrets) ListUserNodeSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("list secret %v in org %v as user %v", path, org, user)))
+	return ls.listSecret(user, token, org, path)
+}
+
+// Check that the secret at the specified path exists.
+func (ls *AgbotLocalSecrets) listSecret(user, token, org, path string) error {
+
+	if _, err := ls.authorize(user, token, org, path, ACCESS_LIST); err != nil {
+		return err
+	}
+
+	if rec, err := ls.getRecord(org, path); err != nil {
+		return err
+	} else if rec == nil {
+		return &secrets.NoSecretFound{Response: notFoundResponse(org, path), SecretPath: path}
+	}
+
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("done listing %s.", path)))
+	return nil
+}
+
+// List all secrets in the specified org.
+func (ls *AgbotLocalSecrets) ListAllSecrets(user, token, org, path string) ([]string, error) {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("list all secrets in %v", org)))
+	return ls.listSecrets(user, token, org, path, true)
+}
+
+// List all org-level secrets at a specified path.
+func (ls *AgbotLocalSecrets) ListOrgSecrets(user, token, org, path string) ([]string, error) {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("list secrets in %v", org)))
+	return ls.listSecrets(user, token, org, path, false)
+}
+
+// List all user-level secrets at a specified path. The user/<user> prefix is removed from the names.
+func (ls *AgbotLocalSecrets) ListOrgUserSecrets(user, token, org, path string) ([]string, error) {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("listing secrets for user %v in %v", user, org)))
+	return ls.listSecrets(user, token, org, path, false)
+}
+
+// List all org-level node secrets at a specified path. The node/<node> prefix is removed from the names.
+func (ls *AgbotLocalSecrets) ListOrgNodeSecrets(user, token, org, node, path string) ([]string, error) {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("listing secrets for node %v in %v", node, org)))
+	return ls.listSecrets(user, token, org, path, false)
+}
+
+// List all user-level node secrets at a specified path. The user/<user>/node/<node> prefix is removed from the names.
+func (ls *AgbotLocalSecrets) ListUserNodeSecrets(user, token, org, node, path string) ([]string, error) {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("listing secrets for node %v in %v as user %v", node, org, user)))
+	return ls.listSecrets(user, token, org, path, false)
+}
+
+// List the secrets under the specified path. When the path is empty, user and node secrets are only included if
+// allSecrets is true. Secret names are returned relative to the path, and only secrets visible to the user are returned.
+func (ls *AgbotLocalSecrets) listSecrets(user, token, org, path string, allSecrets bool) ([]string, error) {
+
+	exUser, err := ls.authorize(user, token, org, path, ACCESS_LIST)
+	if err != nil {
+		return nil, err
+	}
+	admin := exUser == ""
+	if !admin {
+		// The user has been authorized, but might not be an admin. Find out so that other user's secrets can be filtered.
+		_, admin, _ = ls.isAdmin(user, token)
+	}
+
+	path = strings.Trim(path, "/")
+	prefix := org + "/"
+	if path != "" {
+		prefix += path + "/"
+	}
+
+	secretList := make([]string, 0)
+	err = ls.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SECRETS_BUCKET))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, _ = c.Next() {
+			name := strings.TrimPrefix(string(k), prefix)
+			fullPath := strings.TrimPrefix(string(k), org+"/")
+			if path == "" && !allSecrets && (strings.HasPrefix(name, "user/") || strings.HasPrefix(name, "node/")) {
+				continue
+			} else if !admin && isUserPath(fullPath) && !isOwnedBy(fullPath, exUser) {
+				continue
+			}
+			secretList = append(secretList, name)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, &secrets.SecretsProviderUnavailable{ProviderError: err}
+	}
+	ls.dbInteraction()
+
+	if len(secretList) == 0 {
+		return nil, &secrets.NoSecretFound{Response: notFoundResponse(org, path), SecretPath: path}
+	}
+
+	sort.Strings(secretList)
+	return secretList, nil
+}
+
+// Available only to org admin users
+func (ls *AgbotLocalSecrets) CreateOrgSecret(user, token, org, path string, data secrets.SecretDetails) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("creating secret %s in org %s", path, org)))
+	return ls.createSecret(user, token, org, path, data)
+}
+
+// Available to the user that owns the secret and org admins
+func (ls *AgbotLocalSecrets) CreateOrgUserSecret(user, token, org, path string, data secrets.SecretDetails) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("creating secret %s in org %s", path, org)))
+	return ls.createSecret(user, token, org, path, data)
+}
+
+// Available only to org admins
+func (ls *AgbotLocalSecrets) CreateOrgNodeSecret(user, token, org, path string, data secrets.SecretDetails) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("creating secret %s in org %s", path, org)))
+	return ls.createSecret(user, token, org, path, data)
+}
+
+// Available to the user that owns the secret and org admins
+func (ls *AgbotLocalSecrets) CreateUserNodeSecret(user, token, org, path string, data secrets.SecretDetails) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("creating secret %s in org %s", path, org)))
+	return ls.createSecret(user, token, org, path, data)
+}
+
+// Encrypt the secret details and store them. An existing secret is replaced, keeping its creation time.
+func (ls *AgbotLocalSecrets) createSecret(user, token, org, path string, data secrets.SecretDetails) error {
+
+	if strings.Trim(path, "/") == "" {
+		return &secrets.BadRequest{ResponseCode: http.StatusBadRequest, Response: map[string][]string{"errors": {"Secret name must not be an empty string"}}, HttpMethod: http.MethodPost, SecretPath: path}
+	}
+
+	if _, err := ls.authorize(user, token, org, path, ACCESS_WRITE); err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return &secrets.BadRequest{ResponseCode: http.StatusBadRequest, Response: map[string][]string{"errors": {err.Error()}}, HttpMethod: http.MethodPost, SecretPath: path, RequestBody: &data}
+	}
+
+	// Hold the key ring until the record is saved, so that a master key rotation can't remove the key the data key
+	// is wrapped with before the record is there to be re-wrapped.
+	ls.keyRingLock.RLock()
+	defer ls.keyRingLock.RUnlock()
+
+	keyId, wrappedKey, ciphertext, err := ls.keyRing.Seal(plaintext)
+	if err != nil {
+		return &secrets.SecretsProviderUnavailable{ProviderError: err}
+	}
+
+	now := time.Now().Unix()
+	key := secretKey(org, path)
+	err = ls.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(SECRETS_BUCKET))
+		if err != nil {
+			return err
+		}
+
+		rec := secretRecord{KeyId: keyId, WrappedKey: wrappedKey, Ciphertext: ciphertext, CreationTime: now, UpdateTime: now}
+		if existing := b.Get([]byte(key)); existing != nil {
+			var old secretRecord
+			if err := json.Unmarshal(existing, &old); err == nil {
+				rec.CreationTime = old.CreationTime
+			}
+		}
+
+		if serial, err := json.Marshal(rec); err != nil {
+			return err
+		} else {
+			return b.Put([]byte(key), serial)
+		}
+	})
+
+	if err != nil {
+		return &secrets.SecretsProviderUnavailable{ProviderError: err}
+	}
+	ls.dbInteraction()
+
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("done creating %s.", path)))
+	return nil
+}
+
+// Available only to org admin users
+func (ls *AgbotLocalSecrets) DeleteOrgSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("delete secret %s in org %s", path, org)))
+	return ls.deleteSecret(user, token, org, path)
+}
+
+// Available to the user that owns the secret and org admins
+func (ls *AgbotLocalSecrets) DeleteOrgUserSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("delete secret %s in org %s", path, org)))
+	return ls.deleteSecret(user, token, org, path)
+}
+
+// Available only to org admin users
+func (ls *AgbotLocalSecrets) DeleteOrgNodeSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("delete secret %s in org %s", path, org)))
+	return ls.deleteSecret(user, token, org, path)
+}
+
+// Available to the user that owns the secret and org admins
+func (ls *AgbotLocalSecrets) DeleteUserNodeSecret(user, token, org, path string) error {
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("delete secret %s in org %s", path, org)))
+	return ls.deleteSecret(user, token, org, path)
+}
+
+func (ls *AgbotLocalSecrets) deleteSecret(user, token, org, path string) error {
+
+	if _, err := ls.authorize(user, token, org, path, ACCESS_WRITE); err != nil {
+		return err
+	}
+
+	found := false
+	key := secretKey(org, path)
+	err := ls.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SECRETS_BUCKET))
+		if b == nil || b.Get([]byte(key)) == nil {
+			return nil
+		}
+		found = true
+		return b.Delete([]byte(key))
+	})
+
+	if err != nil {
+		return &secrets.SecretsProviderUnavailable{ProviderError: err}
+	}
+	ls.dbInteraction()
+
+	if !found {
+		return &secrets.NoSecretFound{Response: notFoundResponse(org, path), SecretPath: path}
+	}
+
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("done deleting %s.", path)))
+	return nil
+}
+
+// Decrypt and return the details of a secret. The agbot's own credentials are allowed to read any secret, which is how
+// secrets are obtained when they are sent to nodes.
+func (ls *AgbotLocalSecrets) GetSecretDetails(user, token, org, secretUser, secretNode, secretName string) (res secrets.SecretDetails, err error) {
+
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("extract secret details for %s in org %s as user %s", secretName, org, secretUser)))
+
+	if err = checkSecretInput(org, secretName); err != nil {
+		return
+	}
+
+	path := secretPath(secretUser, secretNode, secretName)
+	if _, err = ls.authorize(user, token, org, path, ACCESS_READ); err != nil {
+		return
+	}
+
+	rec, err := ls.getRecord(org, path)
+	if err != nil {
+		return
+	} else if rec == nil {
+		err = &secrets.NoSecretFound{Response: notFoundResponse(org, path), SecretPath: path}
+		return
+	}
+
+	ls.keyRingLock.RLock()
+	plaintext, derr := ls.keyRing.Open(rec.KeyId, rec.WrappedKey, rec.Ciphertext)
+	ls.keyRingLock.RUnlock()
+	if derr != nil {
+		err = &secrets.InvalidResponse{ReadError: derr, HttpMethod: http.MethodGet, SecretPath: path}
+		return
+	}
+
+	if uerr := json.Unmarshal(plaintext, &res); uerr != nil {
+		err = &secrets.InvalidResponse{ParseError: uerr, Response: []byte("********"), HttpMethod: http.MethodGet, SecretPath: path}
+		return
+	}
+
+	glog.V(3).Infof(localPluginLogString("done extracting secret details"))
+	return
+}
+
+// Retrieve the metadata for a secret.
+func (ls *AgbotLocalSecrets) GetSecretMetadata(secretOrg, secretUser, secretNode, secretName string) (res secrets.SecretMetadata, err error) {
+
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("extract secret metadata for %s in org %s as user %s", secretName, secretOrg, secretUser)))
+
+	if err = checkSecretInput(secretOrg, secretName); err != nil {
+		return
+	}
+
+	path := secretPath(secretUser, secretNode, secretName)
+	rec, err := ls.getRecord(secretOrg, path)
+	if err != nil {
+		return
+	} else if rec == nil {
+		err = &secrets.NoSecretFound{Response: notFoundResponse(secretOrg, path), SecretPath: path}
+		return
+	}
+
+	res.CreationTime = rec.CreationTime
+	res.UpdateTime = rec.UpdateTime
+
+	glog.V(5).Infof(localPluginLogString(fmt.Sprintf("Metadata: %v", res)))
+	return
+}
+
+// Rotate the master key. A new master key is generated and saved, then the data key of every secret is re-wrapped
+// with it. Previous master keys are removed from the key ring once no secret is using them.
+func (ls *AgbotLocalSecrets) RotateMasterKey() error {
+
+	ls.keyRingLock.Lock()
+	defer ls.keyRingLock.Unlock()
+
+	keyFile := ls.cfg.GetLocalSecretsMasterKeyFile()
+
+	// Save the key ring with the new key before any data key is wrapped with it.
+	newKeyId, err := ls.keyRing.AddKey()
+	if err != nil {
+		return err
+	} else if err := ls.keyRing.Save(keyFile); err != nil {
+		delete(ls.keyRing.Keys, newKeyId)
+		return err
+	}
+
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("rotating master key to %v", newKeyId)))
+
+	count := 0
+	err = ls.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SECRETS_BUCKET))
+		if b == nil {
+			return nil
+		}
+
+		updates := make(map[string][]byte)
+		if err := b.ForEach(func(k, v []byte) error {
+			var rec secretRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return errors.New(fmt.Sprintf("unable to demarshal secret %v, error: %v", string(k), err))
+			} else if rec.KeyId == newKeyId {
+				return nil
+			} else if rec.KeyId, rec.WrappedKey, err = ls.keyRing.Rewrap(rec.KeyId, rec.WrappedKey); err != nil {
+				return errors.New(fmt.Sprintf("unable to re-wrap data key of secret %v, error: %v", string(k), err))
+			} else if serial, err := json.Marshal(rec); err != nil {
+				return err
+			} else {
+				updates[string(k)] = serial
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for k, v := range updates {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		count = len(updates)
+		return nil
+	})
+
+	// If re-wrapping failed, the transaction was rolled back so the previous keys are still needed.
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to rotate master key, error: %v", err))
+	}
+
+	ls.keyRing.RemovePreviousKeys()
+	if err := ls.keyRing.Save(keyFile); err != nil {
+		return err
+	}
+
+	glog.V(3).Infof(localPluginLogString(fmt.Sprintf("rotated master key to %v, re-wrapped %v data keys", newKeyId, count)))
+	return nil
+}
+
+// Read the record for the secret at the given path, returns nil if the secret does not exist.
+func (ls *AgbotLocalSecrets) getRecord(org, path string) (*secretRecord, error) {
+	var rec *secretRecord
+	key := secretKey(org, path)
+	err := ls.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SECRETS_BUCKET))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(key)); v != nil {
+			rec = new(secretRecord)
+			return json.Unmarshal(v, rec)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, &secrets.SecretsProviderUnavailable{ProviderError: err}
+	}
+	ls.dbInteraction()
+	return rec, nil
+}
+
+// Verify that the user can access the secret at the given path. The agbot itself can list and read all secrets.
+// Org admins can do anything within their org. Other users can manage their own user secrets and list the org and
+// node level secrets. On success, the exchange user name is returned, or an empty string for the agbot.
+func (ls *AgbotLocalSecrets) authorize(user, token, org, path, access string) (string, error) {
+
+	if user == ls.cfg.AgreementBot.ExchangeId && token == ls.cfg.AgreementBot.ExchangeToken {
+		if access == ACCESS_WRITE {
+			return "", &secrets.PermissionDenied{Response: deniedResponse(user, access, path), HttpMethod: access, SecretPath: path, ExchangeUser: user}
+		}
+		return "", nil
+	}
+
+	exUser, userOrg, admin, err := ls.authenticate(user, token)
+	if err != nil {
+		return "", &secrets.Unauthenticated{LoginError: err, ExchangeUser: user}
+	}
+
+	path = strings.Trim(path, "/")
+	if userOrg != org {
+		return "", &secrets.PermissionDenied{Response: deniedResponse(exUser, access, path), HttpMethod: access, SecretPath: path, ExchangeUser: exUser}
+	} else if admin || isOwnedBy(path, exUser) {
+		return exUser, nil
+	} else if access == ACCESS_LIST && (!isUserPath(path) || path == "user") {
+		return exUser, nil
+	}
+
+	return "", &secrets.PermissionDenied{Response: deniedResponse(exUser, access, path), HttpMethod: access, SecretPath: path, ExchangeUser: exUser}
+}
+
+func (ls *AgbotLocalSecrets) isAdmin(user, token string) (string, bool, error) {
+	exUser, _, admin, err := ls.authenticate(user, token)
+	return exUser, admin, err
+}
+
+func (ls *AgbotLocalSecrets) dbInteraction() {
+	ls.lastDBInteraction = uint64(time.Now().Unix())
+}
+
+func checkSecretInput(org, secretName string) error {
+	if org == "" {
+		return &secrets.BadRequest{ResponseCode: http.StatusBadRequest, Response: map[string][]string{"errors": {"Organization name must not be an empty string"}}}
+	} else if secretName == "" {
+		return &secrets.BadRequest{ResponseCode: http.StatusBadRequest, Response: map[string][]string{"errors": {"Secret name must not be an empty string"}}}
+	}
+	return nil
+}
+
+func notFoundResponse(org, path string) map[string][]string {
+	return map[string][]string{"errors": {fmt.Sprintf("secret %v not found in org %v", path, org)}}
+}
+
+func deniedResponse(user, access, path string) map[string][]string {
+	return map[string][]string{"errors": {fmt.Sprintf("user %v does not have %v access to %v", user, access, path)}}
+}
+
+// Log string prefix api
+var localPluginLogString = func(v interface{}) string {
+	return fmt.Sprintf("Local Secrets Plugin: %v", v)
+}
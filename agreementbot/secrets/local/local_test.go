@@ -0,0 +1,202 @@
+//go:build unit
+// +build unit
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/open-horizon/anax/agreementbot/secrets"
+	"github.com/open-horizon/anax/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Users known to the stub authenticator, keyed by org/user. The token is always "pw".
+var testUsers = map[string]bool{
+	"myorg/admin": true,
+	"myorg/bob":   false,
+	"myorg/alice": false,
+	"other/admin": true,
+}
+
+func stubAuthenticator(user, token string) (string, string, bool, error) {
+	if admin, ok := testUsers[user]; !ok || token != "pw" {
+		return "", "", false, errors.New("bad credentials")
+	} else {
+		return path.Base(user), path.Dir(user), admin, nil
+	}
+}
+
+func newTestSecrets(t *testing.T, dir string) *AgbotLocalSecrets {
+	cfg := &config.HorizonConfig{
+		AgreementBot: config.AGConfig{
+			ExchangeId:    "myorg/agbot",
+			ExchangeToken: "agbotpw",
+			LocalSecrets:  config.LocalSecretsConfig{DBPath: dir},
+		},
+	}
+
+	ls := &AgbotLocalSecrets{authenticate: stubAuthenticator}
+	assert.Nil(t, ls.Initialize(cfg))
+	assert.Nil(t, ls.Login())
+	assert.True(t, ls.IsReady())
+	return ls
+}
+
+func Test_LocalSecrets_CRUD(t *testing.T) {
+	dir := t.TempDir()
+	ls := newTestSecrets(t, dir)
+	defer ls.Close()
+
+	details := secrets.SecretDetails{Key: "user", Value: "secret"}
+	assert.Nil(t, ls.CreateOrgSecret("myorg/admin", "pw", "myorg", "db/password", details))
+	assert.Nil(t, ls.CreateOrgNodeSecret("myorg/admin", "pw", "myorg", "node/n1/token", details))
+	assert.Nil(t, ls.CreateOrgUserSecret("myorg/bob", "pw", "myorg", "user/bob/s1", details))
+	assert.Nil(t, ls.CreateUserNodeSecret("myorg/bob", "pw", "myorg", "user/bob/node/n1/s2", details))
+
+	// The secret is not stored in the clear.
+	raw, err := os.ReadFile(path.Join(dir, LOCAL_SECRETS_DATABASE_NAME))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(raw), "secret\"")
+
+	assert.Nil(t, ls.ListOrgSecret("myorg/bob", "pw", "myorg", "db/password"))
+	assert.IsType(t, &secrets.NoSecretFound{}, ls.ListOrgSecret("myorg/bob", "pw", "myorg", "nothere"))
+
+	names, err := ls.ListOrgSecrets("myorg/bob", "pw", "myorg", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db/password"}, names)
+
+	names, err = ls.ListOrgUserSecrets("myorg/bob", "pw", "myorg", "user/bob")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node/n1/s2", "s1"}, names)
+
+	names, err = ls.ListOrgNodeSecrets("myorg/bob", "pw", "myorg", "n1", "node/n1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"token"}, names)
+
+	names, err = ls.ListUserNodeSecrets("myorg/bob", "pw", "myorg", "n1", "user/bob/node/n1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"s2"}, names)
+
+	names, err = ls.ListAllSecrets("myorg/admin", "pw", "myorg", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db/password", "node/n1/token", "user/bob/node/n1/s2", "user/bob/s1"}, names)
+
+	// The agbot reads the secret details with its own credentials.
+	res, err := ls.GetSecretDetails("myorg/agbot", "agbotpw", "myorg", "bob", "n1", "s2")
+	assert.Nil(t, err)
+	assert.Equal(t, details, res)
+
+	md, err := ls.GetSecretMetadata("myorg", "", "", "db/password")
+	assert.Nil(t, err)
+	assert.NotZero(t, md.CreationTime)
+	assert.Equal(t, md.CreationTime, md.UpdateTime)
+
+	assert.Nil(t, ls.DeleteOrgUserSecret("myorg/bob", "pw", "myorg", "user/bob/s1"))
+	assert.IsType(t, &secrets.NoSecretFound{}, ls.DeleteOrgUserSecret("myorg/bob", "pw", "myorg", "user/bob/s1"))
+	_, err = ls.GetSecretMetadata("myorg", "bob", "", "s1")
+	assert.IsType(t, &secrets.NoSecretFound{}, err)
+}
+
+func Test_LocalSecrets_ACL(t *testing.T) {
+	ls := newTestSecrets(t, t.TempDir())
+	defer ls.Close()
+
+	details := secrets.SecretDetails{Key: "k", Value: "v"}
+
+	// Non-admins can't create org or node secrets, or secrets owned by another user.
+	assert.IsType(t, &secrets.PermissionDenied{}, ls.CreateOrgSecret("myorg/bob", "pw", "myorg", "s", details))
+	assert.IsType(t, &secrets.PermissionDenied{}, ls.CreateOrgNodeSecret("myorg/bob", "pw", "myorg", "node/n1/s", details))
+	assert.IsType(t, &secrets.PermissionDenied{}, ls.CreateOrgUserSecret("myorg/bob", "pw", "myorg", "user/alice/s", details))
+
+	// Users from another org are denied, even if they are admins there.
+	assert.IsType(t, &secrets.PermissionDenied{}, ls.CreateOrgSecret("other/admin", "pw", "myorg", "s", details))
+
+	// Bad credentials are reported as unauthenticated.
+	assert.IsType(t, &secrets.Unauthenticated{}, ls.ListOrgSecret("myorg/bob", "bad", "myorg", "s"))
+
+	// The agbot can read, but not write.
+	assert.IsType(t, &secrets.PermissionDenied{}, ls.CreateOrgSecret("myorg/agbot", "agbotpw", "myorg", "s", details))
+
+	// Admins can manage user secrets, which are hidden from other users.
+	assert.Nil(t, ls.CreateOrgUserSecret("myorg/admin", "pw", "myorg", "user/alice/s", details))
+	assert.IsType(t, &secrets.PermissionDenied{}, ls.ListOrgUserSecret("myorg/bob", "pw", "myorg", "user/alice/s"))
+	_, err := ls.GetSecretDetails("myorg/bob", "pw", "myorg", "alice", "", "s")
+	assert.IsType(t, &secrets.PermissionDenied{}, err)
+	_, err = ls.ListAllSecrets("myorg/bob", "pw", "myorg", "")
+	assert.IsType(t, &secrets.NoSecretFound{}, err)
+
+	res, err := ls.GetSecretDetails("myorg/alice", "pw", "myorg", "alice", "", "s")
+	assert.Nil(t, err)
+	assert.Equal(t, details, res)
+}
+
+func Test_LocalSecrets_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	ls := newTestSecrets(t, dir)
+
+	details := secrets.SecretDetails{Key: "k", Value: "v"}
+	assert.Nil(t, ls.CreateOrgSecret("myorg/admin", "pw", "myorg", "s", details))
+	oldKey := ls.keyRing.Current
+
+	// Rotation is not due yet.
+	ls.cfg.AgreementBot.LocalSecrets.KeyRotationIntervalH = 1
+	assert.Nil(t, ls.Renew())
+	assert.Equal(t, oldKey, ls.keyRing.Current)
+
+	assert.Nil(t, ls.RotateMasterKey())
+	assert.NotEqual(t, oldKey, ls.keyRing.Current)
+	assert.Equal(t, 1, len(ls.keyRing.Keys))
+
+	rec, err := ls.getRecord("myorg", "s")
+	assert.Nil(t, err)
+	assert.Equal(t, ls.keyRing.Current, rec.KeyId)
+
+	// The rotated key ring is persisted, so the secret can still be read after a restart.
+	ls.Close()
+	ls = newTestSecrets(t, dir)
+	defer ls.Close()
+	res, err := ls.GetSecretDetails("myorg/agbot", "agbotpw", "myorg", "", "", "s")
+	assert.Nil(t, err)
+	assert.Equal(t, details, res)
+}
+
+func Test_LocalSecrets_ConcurrentRotation(t *testing.T) {
+	dir := t.TempDir()
+	ls := newTestSecrets(t, dir)
+	defer ls.Close()
+
+	// Secrets created while the master key is rotated must be readable afterwards.
+	details := secrets.SecretDetails{Key: "k", Value: "v"}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.Nil(t, ls.CreateOrgSecret("myorg/admin", "pw", "myorg", fmt.Sprintf("s%v-%v", i, j), details))
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			assert.Nil(t, ls.RotateMasterKey())
+		}
+	}()
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 100; j++ {
+			res, err := ls.GetSecretDetails("myorg/agbot", "agbotpw", "myorg", "", "", fmt.Sprintf("s%v-%v", i, j))
+			assert.Nil(t, err)
+			assert.Equal(t, details, res)
+		}
+	}
+}
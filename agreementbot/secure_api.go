@@ -190,6 +190,7 @@ func (a *SecureAPI) listen() {
 		router.HandleFunc("/deploycheck/userinputcompatible", a.userinput_compatible).Methods("GET", "OPTIONS")
 		router.HandleFunc("/deploycheck/deploycompatible", a.deploy_compatible).Methods("GET", "OPTIONS")
 		router.HandleFunc("/deploycheck/secretbindingcompatible", a.secretbinding_compatible).Methods("GET", "OPTIONS")
+		router.HandleFunc("/deploycheck/policysimulation", a.policySimulation).Methods("GET", "OPTIONS")
 		router.HandleFunc("/compatibility/constraints/node/{policyType}", a.policyCompatibleNodeList).Methods("GET", "OPTIONS")
 		router.HandleFunc("/compatibility/patterns/node", a.patternCompatibleNodeList).Methods("GET", "OPTIONS")
 		router.HandleFunc("/org/{org}/secrets/user/{user}", a.userSecrets).Methods("LIST", "OPTIONS")
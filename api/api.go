@@ -9,6 +9,7 @@ import (
 	"github.com/open-horizon/anax/apicommon"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
@@ -62,6 +63,8 @@ func NewAPIListener(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *pol
 		listener.EC = worker.NewExchangeContext(fmt.Sprintf("%v/%v", pDevice.Org, pDevice.Id), pDevice.Token, cfg.Edge.ExchangeURL, cfg.GetCSSURL(), cfg.Edge.AgbotURL, cfg.Collaborators.HTTPClientFactory)
 	}
 
+	metrics.DefaultRegistry.RegisterCollector("agent", listener.collectMetrics)
+
 	listener.listen(cfg)
 	return listener
 }
@@ -82,17 +85,22 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/service/config", a.serviceconfig).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/configstate", a.service_configstate).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/policy", a.servicepolicy).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/{instance}/log", a.servicelog).Methods("GET", "OPTIONS")
 
 	// Connectivity and blockchain status info
 	router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 	router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
 
+	// Prometheus/OpenMetrics compatible metrics
+	router.HandleFunc("/metrics", a.metrics).Methods("GET", "OPTIONS")
+
 	// Used by the Registration UI to obtain a random token string
 	router.HandleFunc("/token/random", tokenRandom).Methods("GET", "OPTIONS")
 
 	// Used to configure a node to participate in the Horizon platform
 	router.HandleFunc("/node", a.node).Methods("GET", "HEAD", "POST", "PATCH", "DELETE", "OPTIONS")
 	router.HandleFunc("/node/configstate", a.nodeconfigstate).Methods("GET", "HEAD", "PUT", "OPTIONS")
+	router.HandleFunc("/node/diagnostics", a.nodediagnostics).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/node/policy", a.nodepolicy).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
 	router.HandleFunc("/node/userinput", a.nodeuserinput).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
 
@@ -104,6 +112,12 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/eventlog/all", a.eventlog).Methods("GET", "OPTIONS")
 	// delete all eventlogs from previous registrations
 	router.HandleFunc("/eventlog/prune", a.eventlog).Methods("DELETE", "OPTIONS")
+	// stream the eventlogs for current registration as they are saved
+	router.HandleFunc("/eventlog/stream", a.eventlogStream).Methods("GET", "OPTIONS")
+	// get the eventlog retention policy and statistics
+	router.HandleFunc("/eventlog/retention", a.eventlogRetention).Methods("GET", "OPTIONS")
+	// remove the eventlogs that exceed the retention policy and request compaction of the db
+	router.HandleFunc("/eventlog/retention/compact", a.eventlogRetention).Methods("POST", "OPTIONS")
 	//get the active surface errors for this node
 	router.HandleFunc("/eventlog/surface", a.surface).Methods("GET", "OPTIONS")
 
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -81,6 +83,100 @@ func (a *API) eventlog(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// stream the eventlogs for current registration as they are saved.
+func (a *API) eventlogStream(w http.ResponseWriter, r *http.Request) {
+
+	resource := "eventlog/stream"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		// get message printer with the language passed in from the header
+		lan := r.Header.Get("Accept-Language")
+		if lan == "" {
+			lan = i18n.DEFAULT_LANGUAGE
+		}
+		msgPrinter := i18n.GetMessagePrinterWithLocale(lan)
+
+		if err := r.ParseForm(); err != nil {
+			errorHandler(NewAPIUserInputError(msgPrinter.Sprintf("Error parsing the selections %v. %v", r.Form, err), "selection"))
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errorHandler(NewSystemError(msgPrinter.Sprintf("Streaming is not supported for %v", resource)))
+			return
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v. Language: %v", r.Method, resource, r.Form, lan)))
+
+		// Validate the input before the response is started, so that errors can still be returned with an http code.
+		if _, err := persistence.ConvertToSelectors(r.Form); err != nil {
+			errorHandler(NewAPIUserInputError(msgPrinter.Sprintf("Error converting the selections into Selectors: %v", err), "selection"))
+			return
+		}
+		lastId := r.Header.Get("Last-Event-ID")
+		if _, err := strconv.ParseUint(lastId, 10, 64); lastId != "" && err != nil {
+			errorHandler(NewAPIUserInputError(msgPrinter.Sprintf("The last event id %v is not a valid event log record id.", lastId), "Last-Event-ID"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		if err := StreamEventLogsForOutput(a.db, w, flusher.Flush, r.Context().Done(), r.Form, lastId, msgPrinter); err != nil {
+			glog.V(3).Infof(apiLogString(fmt.Sprintf("Ended %v stream, error %v", resource, err)))
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// get the event log retention policy and statistics, or remove the records that exceed the policy and request
+// compaction of the db at the next restart.
+func (a *API) eventlogRetention(w http.ResponseWriter, r *http.Request) {
+
+	resource := "eventlog/retention"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	// get message printer with the language passed in from the header
+	lan := r.Header.Get("Accept-Language")
+	if lan == "" {
+		lan = i18n.DEFAULT_LANGUAGE
+	}
+	msgPrinter := i18n.GetMessagePrinterWithLocale(lan)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := GetEventLogRetentionForOutput(a.db, a.Config.Edge.EventLogRetention); err != nil {
+			errorHandler(NewSystemError(msgPrinter.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := CompactEventLogs(a.db, a.Config.Edge.EventLogRetention, msgPrinter); err != nil {
+			errorHandler(NewSystemError(msgPrinter.Sprintf("Error compacting the event logs, error %v", err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) surface(w http.ResponseWriter, r *http.Request) {
 	resource := "eventlog/surface"
 	errorHandler := GetHTTPErrorHandler(w)
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/metrics"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"net/http"
+)
+
+// Metrics computed from the agent's database each time the /metrics API is called.
+var (
+	agentAgreements = metrics.DefaultRegistry.NewGaugeVec("anax_agreements",
+		"Number of agreements on this node by state.", "state")
+	agentServiceInstances = metrics.DefaultRegistry.NewGaugeVec("anax_service_instances",
+		"Number of dependent service instances on this node by state.", "state")
+)
+
+// The states reported for agreements and service instances.
+const (
+	METRIC_STATE_PROPOSED    = "proposed"
+	METRIC_STATE_ACCEPTED    = "accepted"
+	METRIC_STATE_FINALIZED   = "finalized"
+	METRIC_STATE_EXECUTING   = "execution_started"
+	METRIC_STATE_TERMINATING = "terminating"
+	METRIC_STATE_ARCHIVED    = "archived"
+	METRIC_STATE_STARTING    = "starting"
+	METRIC_STATE_RUNNING     = "running"
+	METRIC_STATE_FAILED      = "failed"
+	METRIC_STATE_CLEANUP     = "cleanup"
+)
+
+func (a *API) metrics(w http.ResponseWriter, r *http.Request) {
+	metrics.DefaultRegistry.ServeHTTP(w, r)
+}
+
+// Returns the state of an agreement as reported in the metrics.
+func agreementMetricState(ag *persistence.EstablishedAgreement) string {
+	if ag.Archived {
+		return METRIC_STATE_ARCHIVED
+	} else if ag.AgreementTerminatedTime != 0 {
+		return METRIC_STATE_TERMINATING
+	} else if ag.AgreementExecutionStartTime != 0 {
+		return METRIC_STATE_EXECUTING
+	} else if ag.AgreementFinalizedTime != 0 {
+		return METRIC_STATE_FINALIZED
+	} else if ag.AgreementAcceptedTime != 0 {
+		return METRIC_STATE_ACCEPTED
+	}
+	return METRIC_STATE_PROPOSED
+}
+
+// Returns the state of a dependent service instance as reported in the metrics.
+func serviceInstanceMetricState(msi *persistence.MicroserviceInstance) string {
+	if msi.Archived {
+		return METRIC_STATE_ARCHIVED
+	} else if msi.CleanupStartTime != 0 {
+		return METRIC_STATE_CLEANUP
+	} else if msi.ExecutionFailureCode != 0 {
+		return METRIC_STATE_FAILED
+	} else if msi.ExecutionStartTime != 0 {
+		return METRIC_STATE_RUNNING
+	}
+	return METRIC_STATE_STARTING
+}
+
+// Called by the metrics registry just before the metrics are written out.
+func (a *API) collectMetrics() {
+
+	if agreements, err := persistence.FindEstablishedAgreementsAllProtocols(a.db, policy.AllAgreementProtocols(), []persistence.EAFilter{}); err != nil {
+		glog.Errorf(apiLogString(fmt.Sprintf("unable to read agreements for metrics, error %v", err)))
+	} else {
+		agentAgreements.Reset()
+		for _, ag := range agreements {
+			agentAgreements.Add(1, agreementMetricState(&ag))
+		}
+	}
+
+	if msInstances, err := persistence.FindMicroserviceInstances(a.db, []persistence.MIFilter{persistence.AllMIFilter()}); err != nil {
+		glog.Errorf(apiLogString(fmt.Sprintf("unable to read service instances for metrics, error %v", err)))
+	} else {
+		agentServiceInstances.Reset()
+		for _, msi := range msInstances {
+			agentServiceInstances.Add(1, serviceInstanceMetricState(&msi))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -168,6 +169,57 @@ func (a *API) node(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Collect the diagnostic bundle of the node. The bundle is returned as a gzipped tar archive, or uploaded to the MMS so that
+// it can be downloaded without access to the node.
+func (a *API) nodediagnostics(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/diagnostics"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if err := r.ParseForm(); err != nil {
+			errorHandler(NewAPIUserInputError(fmt.Sprintf("Error parsing the diagnostics options %v. %v", r.Form, err), "options"))
+		} else if opts, err := NewDiagnosticsOptions(r.Form); err != nil {
+			errorHandler(err)
+		} else {
+			// the bundle is created before the response is written, so that an error can still be returned.
+			var buf bytes.Buffer
+			if manifest, err := CreateDiagnosticBundle(a.pm, a.db, a.Config, a.statusInfo(), opts, &buf); err != nil {
+				errorHandler(NewSystemError(fmt.Sprintf("Error creating the diagnostic bundle, error %v", err)))
+			} else {
+				w.Header().Set("Content-Type", "application/gzip")
+				w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v\"", diagnosticBundleFileName(manifest)))
+				w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+				w.WriteHeader(http.StatusOK)
+				w.Write(buf.Bytes())
+			}
+		}
+
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if err := r.ParseForm(); err != nil {
+			errorHandler(NewAPIUserInputError(fmt.Sprintf("Error parsing the diagnostics options %v. %v", r.Form, err), "options"))
+		} else if opts, err := NewDiagnosticsOptions(r.Form); err != nil {
+			errorHandler(err)
+		} else if out, err := UploadDiagnosticBundle(a.pm, a.db, a.Config, a.statusInfo(), opts); err != nil {
+			errorHandler(err)
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) nodeconfigstate(w http.ResponseWriter, r *http.Request) {
 
 	resource := "node/configstate"
@@ -53,6 +53,42 @@ func (a *API) service(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Get the logs of a container of a service instance. The logs are streamed when they are followed.
+func (a *API) servicelog(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/log"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	_, errWritten := a.existingDeviceOrError(w)
+	if errWritten {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		instance := mux.Vars(r)["instance"]
+
+		if err := r.ParseForm(); err != nil {
+			errorhandler(NewAPIUserInputError(fmt.Sprintf("Error parsing the log options %v. %v", r.Form, err), "options"))
+			return
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v for service instance %v with options %v", r.Method, resource, instance, r.Form)))
+
+		if opts, err := NewServiceLogOptions(r.Form); err != nil {
+			errorhandler(err)
+		} else {
+			StreamServiceLogs(errorhandler, r.Context(), a.db, a.Config, instance, opts, w)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 // For working with a node's representation of a service, including the policy and input variables of the service.
 func (a *API) serviceconfig(w http.ResponseWriter, r *http.Request) {
 
@@ -10,22 +10,7 @@ import (
 func (a *API) status(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		// get the cert and config versions for the local db
-		cert_version := ""
-		config_version := ""
-		pDevice, err := persistence.FindExchangeDevice(a.db)
-		if err == nil && pDevice != nil {
-			sw_version := pDevice.SoftwareVersions
-			if sw_version != nil {
-				cert_version, _ = sw_version[persistence.CERT_VERSION]
-				config_version, _ = sw_version[persistence.CONFIG_VERSION]
-			}
-		}
-
-		info := apicommon.NewInfo(a.GetHTTPFactory(), a.GetExchangeURL(), a.GetCSSURL(),
-			a.GetExchangeId(), a.GetExchangeToken(), cert_version, config_version)
-
-		writeResponse(w, info, http.StatusOK)
+		writeResponse(w, a.statusInfo(), http.StatusOK)
 	case "OPTIONS":
 		w.Header().Set("Allow", "GET, OPTIONS")
 		w.WriteHeader(http.StatusOK)
@@ -46,3 +31,21 @@ func (a *API) workerstatus(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+// Returns the status of the agent, its configuration and its connectivity to the management hub.
+func (a *API) statusInfo() *apicommon.Info {
+	// get the cert and config versions for the local db
+	cert_version := ""
+	config_version := ""
+	pDevice, err := persistence.FindExchangeDevice(a.db)
+	if err == nil && pDevice != nil {
+		sw_version := pDevice.SoftwareVersions
+		if sw_version != nil {
+			cert_version, _ = sw_version[persistence.CERT_VERSION]
+			config_version, _ = sw_version[persistence.CONFIG_VERSION]
+		}
+	}
+
+	return apicommon.NewInfo(a.GetHTTPFactory(), a.GetExchangeURL(), a.GetCSSURL(),
+		a.GetExchangeId(), a.GetExchangeToken(), cert_version, config_version)
+}
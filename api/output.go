@@ -75,6 +75,7 @@ type AllServices struct {
 	Config      []MicroserviceConfig                            `json:"config"`      // the service configurations
 	Instances   map[string][]*MicroserviceInstanceOutput        `json:"instances"`   // the microservice instances that are running
 	Definitions map[string][]persistence.MicroserviceDefinition `json:"definitions"` // the definitions of services from the exchange
+	ImagePulls  []persistence.ImagePull                         `json:"image_pulls"` // the pulls of service images, in progress or finished in the last day
 }
 
 func NewServiceOutput() *AllServices {
@@ -82,10 +83,25 @@ func NewServiceOutput() *AllServices {
 		Config:      make([]MicroserviceConfig, 0, 10),
 		Instances:   make(map[string][]*MicroserviceInstanceOutput, 0),
 		Definitions: make(map[string][]persistence.MicroserviceDefinition, 0),
+		ImagePulls:  make([]persistence.ImagePull, 0),
 	}
 }
 
 // Functions and types that plug into the go sorting feature
+type ImagePullsByStartTime []persistence.ImagePull
+
+func (s ImagePullsByStartTime) Len() int {
+	return len(s)
+}
+
+func (s ImagePullsByStartTime) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s ImagePullsByStartTime) Less(i, j int) bool {
+	return s[i].StartTime < s[j].StartTime
+}
+
 type EstablishedAgreementsByAgreementCreationTime []persistence.EstablishedAgreement
 
 func (s EstablishedAgreementsByAgreementCreationTime) Len() int {
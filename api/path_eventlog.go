@@ -1,15 +1,24 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/persistence"
 	"golang.org/x/text/message"
+	"io"
 	"sort"
+	"strconv"
+	"time"
 )
 
+// The number of seconds between the comments sent on an idle event log stream. A client that went away is noticed when
+// the write fails.
+const EVENTLOG_STREAM_KEEPALIVE_S = 30
+
 // This API returns the event logs saved on the db.
 func FindEventLogsForOutput(db *bolt.DB, all_logs bool, selections map[string][]string, msgPrinter *message.Printer) ([]persistence.EventLog, error) {
 
@@ -32,6 +41,93 @@ func FindEventLogsForOutput(db *bolt.DB, all_logs bool, selections map[string][]
 	}
 }
 
+// This API streams the event logs that match the selections to the output as server-sent events, as they are saved
+// on the db. It returns when the done channel is closed or a write fails. If lastId is set, the matching event logs
+// saved after that record are sent first, so that a client that reconnects does not miss any records.
+func StreamEventLogsForOutput(db *bolt.DB, out io.Writer, flush func(), done <-chan struct{}, selections map[string][]string, lastId string, msgPrinter *message.Printer) error {
+
+	glog.V(5).Infof(apiLogString(fmt.Sprintf("Streaming event logs. The selectors are: %v, last event id: %v.", selections, lastId)))
+
+	s, err := persistence.ConvertToSelectors(selections)
+	if err != nil {
+		return fmt.Errorf(msgPrinter.Sprintf("Error converting the selections into Selectors: %v", err))
+	}
+
+	// The highest record id sent from the db. The live records up to this id were already sent.
+	lastReplayed := uint64(0)
+	if lastId != "" {
+		if lastReplayed, err = strconv.ParseUint(lastId, 10, 64); err != nil {
+			return fmt.Errorf(msgPrinter.Sprintf("The last event id %v is not a valid event log record id.", lastId))
+		}
+	}
+
+	// Subscribe before reading the saved records so that a record saved in between is not missed.
+	sub := eventlog.Subscribe(s, msgPrinter)
+	defer eventlog.Unsubscribe(sub)
+
+	if lastId != "" {
+		replaySelectors := make(map[string][]persistence.Selector, len(s)+1)
+		for attr, sels := range s {
+			replaySelectors[attr] = sels
+		}
+		replaySelectors["record_id"] = append(append([]persistence.Selector{}, s["record_id"]...), persistence.Selector{Op: ">", MatchValue: lastReplayed})
+
+		eventLogs, err := eventlog.GetEventLogs(db, false, replaySelectors, msgPrinter)
+		if err != nil {
+			return err
+		}
+		sort.Sort(EventLogByRecordId(eventLogs))
+		for _, el := range eventLogs {
+			if err := writeEventLogEvent(out, el); err != nil {
+				return err
+			}
+			lastReplayed, _ = strconv.ParseUint(el.Id, 10, 64)
+		}
+	}
+	flush()
+
+	keepalive := time.NewTicker(EVENTLOG_STREAM_KEEPALIVE_S * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return nil
+		case el, ok := <-sub.Events:
+			if !ok {
+				return nil
+			}
+			// Skip the records that were already sent from the db.
+			if id, _ := strconv.ParseUint(el.Id, 10, 64); id <= lastReplayed {
+				continue
+			}
+			if dropped := sub.Dropped(); dropped > 0 {
+				_, err = fmt.Fprintf(out, ": %v event log records were dropped\n\n", dropped)
+			}
+			if err == nil {
+				err = writeEventLogEvent(out, el)
+			}
+		case <-keepalive.C:
+			_, err = fmt.Fprint(out, ": keepalive\n\n")
+		}
+		if err != nil {
+			return err
+		}
+		flush()
+	}
+}
+
+// Write an event log record as a server-sent event. The record id is the event id.
+func writeEventLogEvent(out io.Writer, el persistence.EventLog) error {
+	serial, err := json.Marshal(el)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "id: %v\nevent: eventlog\ndata: %s\n\n", el.Id, serial)
+	return err
+}
+
 // This API deletes the selected event logs saved on the db.
 func DeleteEventLogs(db *bolt.DB, prune bool, selections map[string][]string, msgPrinter *message.Printer) (int, error) {
 	s := map[string][]persistence.Selector{}
@@ -61,6 +157,48 @@ func DeleteEventLogs(db *bolt.DB, prune bool, selections map[string][]string, ms
 	return count, err
 }
 
+type EventLogRetentionOutput struct {
+	Policy          persistence.EventLogRetentionPolicy `json:"policy"`
+	Stats           persistence.EventLogStats           `json:"stats"`
+	Removed         int                                 `json:"removed"`
+	RestartRequired bool                                `json:"restart_required"` // the db file is only compacted when the agent restarts
+	Message         string                              `json:"message,omitempty"`
+}
+
+// This API returns the event log retention policy from the config and the current size of the event log.
+func GetEventLogRetentionForOutput(db *bolt.DB, retention config.EventLogRetentionConfig) (*EventLogRetentionOutput, error) {
+	stats, err := persistence.GetEventLogStats(db)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLogRetentionOutput{Policy: eventlog.GetRetentionPolicy(retention), Stats: *stats}, nil
+}
+
+// This API removes the event logs that exceed the retention policy right away, instead of waiting for the next
+// retention check, and requests compaction of the db file. The compaction is done the next time the agent starts,
+// which the output says, the db file does not get smaller until then.
+func CompactEventLogs(db *bolt.DB, retention config.EventLogRetentionConfig, msgPrinter *message.Printer) (*EventLogRetentionOutput, error) {
+	policy := eventlog.GetRetentionPolicy(retention)
+	removed, err := persistence.PruneEventLogs(db, policy, uint64(time.Now().Unix()))
+	if err != nil {
+		return nil, err
+	}
+	glog.V(3).Infof(apiLogString(fmt.Sprintf("Removed %v event log records that exceed retention policy %v.", removed, policy)))
+
+	if err := persistence.RequestCompaction(db); err != nil {
+		return nil, err
+	}
+
+	out, err := GetEventLogRetentionForOutput(db, retention)
+	if err != nil {
+		return nil, err
+	}
+	out.Removed = removed
+	out.RestartRequired = true
+	out.Message = msgPrinter.Sprintf("Removed %v event logs. The agent database is not compacted until the agent is restarted, restart the agent to reclaim %v bytes.", removed, out.Stats.DBFreeSize)
+	return out, nil
+}
+
 func FindSurfaceLogsForOutput(db *bolt.DB, msgPrinter *message.Printer) ([]persistence.SurfaceError, error) {
 	outputLogs := make([]persistence.SurfaceError, 0)
 	surfaceLogs, err := persistence.FindSurfaceErrors(db)
@@ -4,13 +4,18 @@
 package api
 
 import (
+	"bytes"
 	"flag"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/stretchr/testify/assert"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -116,3 +121,112 @@ func Test_FindEventLogsForOutput(t *testing.T) {
 	}
 
 }
+
+// A writer that can be read while the stream is writing to it.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func Test_StreamEventLogsForOutput(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	msgPrinter := i18n.GetMessagePrinterWithLocale("en")
+
+	for _, severity := range []string{persistence.SEVERITY_ERROR, persistence.SEVERITY_INFO, persistence.SEVERITY_ERROR} {
+		if err := eventlog.LogNodeEvent(db, severity, persistence.NewMessageMeta("node event."), persistence.EC_START_NODE_CONFIG_REG, "node1", "myorg", "", persistence.CONFIGSTATE_CONFIGURING); err != nil {
+			t.Errorf("error saving event log: %v", err)
+		}
+	}
+
+	// Stream the error records, starting after the first record.
+	out := new(syncBuffer)
+	done := make(chan struct{})
+	ended := make(chan error)
+	go func() {
+		ended <- StreamEventLogsForOutput(db, out, func() {}, done, map[string][]string{"severity": {"error"}}, "1", msgPrinter)
+	}()
+
+	waitFor := func(s string) bool {
+		for i := 0; i < 100; i++ {
+			if strings.Contains(out.String(), s) {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+
+	if !waitFor("id: 3\n") {
+		t.Errorf("the saved error record after the last event id should have been sent: %v", out.String())
+	}
+
+	eventlog.LogNodeEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("node event."), persistence.EC_START_NODE_CONFIG_REG, "node1", "myorg", "", persistence.CONFIGSTATE_CONFIGURING)
+	eventlog.LogNodeEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta("node %v failed.", "node1"), persistence.EC_START_NODE_CONFIG_REG, "node1", "myorg", "", persistence.CONFIGSTATE_CONFIGURING)
+
+	if !waitFor("id: 5\n") {
+		t.Errorf("the new error record should have been streamed: %v", out.String())
+	}
+
+	close(done)
+	assert.Nil(t, <-ended, "the stream should end without error")
+
+	output := out.String()
+	assert.Equal(t, 2, strings.Count(output, "event: eventlog"), "only the matching records should be sent")
+	assert.False(t, strings.Contains(output, "id: 1\n") || strings.Contains(output, "id: 4\n"), "the first record and the info record should not be sent")
+	assert.True(t, strings.Contains(output, `"message":"node node1 failed."`), "the message should be translated")
+
+	// Invalid input is rejected before anything is streamed.
+	if err := StreamEventLogsForOutput(db, out, func() {}, done, map[string][]string{}, "abc", msgPrinter); err == nil {
+		t.Errorf("an invalid last event id should be rejected")
+	}
+}
+
+func Test_CompactEventLogs(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	msgPrinter := i18n.GetMessagePrinterWithLocale("en")
+
+	for i := 0; i < 5; i++ {
+		if err := eventlog.LogNodeEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("node event."), persistence.EC_START_NODE_CONFIG_REG, "node1", "myorg", "", persistence.CONFIGSTATE_CONFIGURING); err != nil {
+			t.Errorf("error saving event log: %v", err)
+		}
+	}
+
+	// The records over the limit are removed right away, the db file is only compacted when the agent restarts.
+	out, err := CompactEventLogs(db, config.EventLogRetentionConfig{MaxCount: 2}, msgPrinter)
+	assert.Nil(t, err, "Compacting the event logs should not return an error.")
+	assert.Equal(t, 3, out.Removed, "Wrong number of event logs removed.")
+	assert.Equal(t, 2, out.Stats.Count, "Wrong number of event logs kept.")
+	assert.True(t, out.Stats.CompactionRequested, "The compaction should be requested.")
+	assert.True(t, out.RestartRequired, "The output should say that a restart is required.")
+	assert.Contains(t, out.Message, "restart the agent", "The message should say that a restart is required.")
+
+	// the GET output does not ask for a restart
+	out, err = GetEventLogRetentionForOutput(db, config.EventLogRetentionConfig{MaxCount: 2})
+	assert.Nil(t, err, "Getting the event log retention should not return an error.")
+	assert.False(t, out.RestartRequired, "The GET output should not say that a restart is required.")
+	assert.Equal(t, "", out.Message, "The GET output should not have a message.")
+}
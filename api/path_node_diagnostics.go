@@ -0,0 +1,426 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/apicommon"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/resource"
+	"github.com/open-horizon/anax/version"
+	"github.com/open-horizon/anax/worker"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The MMS object type of the diagnostic bundles uploaded by the agents.
+const DIAGNOSTICS_OBJECT_TYPE = "agent_diagnostics"
+
+// The default number of lines from the end of the logs of each service container that are added to a diagnostic bundle.
+const DIAGNOSTICS_LOG_LINES_DEFAULT = 1000
+
+// The options of a diagnostic bundle, from the query parameters of the request.
+type DiagnosticsOptions struct {
+	LogLines int64 // the number of lines from the end of the logs of each service container, no logs when it is 0
+}
+
+// Returns the diagnostics options in the query parameters.
+func NewDiagnosticsOptions(form url.Values) (*DiagnosticsOptions, error) {
+	opts := &DiagnosticsOptions{LogLines: DIAGNOSTICS_LOG_LINES_DEFAULT}
+
+	if lines := form.Get("log_lines"); lines != "" {
+		var err error
+		if opts.LogLines, err = strconv.ParseInt(lines, 10, 64); err != nil || opts.LogLines < 0 {
+			return nil, NewAPIUserInputError(fmt.Sprintf("the log_lines %v is not a positive number of lines", lines), "log_lines")
+		}
+	}
+	return opts, nil
+}
+
+// The summary of a diagnostic bundle. It is the last file in the archive, so that it can list the other files.
+type DiagnosticsManifest struct {
+	NodeId       string            `json:"node_id"`
+	Org          string            `json:"organization"`
+	NodeType     string            `json:"node_type"`
+	AgentVersion string            `json:"agent_version"`
+	CreatedTime  uint64            `json:"created_time"`
+	Files        []string          `json:"files"`  // the files in the archive
+	Errors       map[string]string `json:"errors"` // the files that could not be collected, and why
+}
+
+// The MMS object that a diagnostic bundle was uploaded to.
+type DiagnosticsUpload struct {
+	Org        string              `json:"organization"`
+	ObjectType string              `json:"object_type"`
+	ObjectId   string              `json:"object_id"`
+	Size       int                 `json:"size"`
+	Manifest   DiagnosticsManifest `json:"manifest"`
+}
+
+// A diagnostic bundle is a gzipped tar archive. All the files are in a directory named after the node and the creation time,
+// so that bundles from several nodes can be extracted in the same place.
+type diagnosticBundle struct {
+	gw       *gzip.Writer
+	tw       *tar.Writer
+	dir      string
+	created  time.Time
+	manifest DiagnosticsManifest
+}
+
+func newDiagnosticBundle(out io.Writer, dev *persistence.ExchangeDevice, created time.Time) *diagnosticBundle {
+	manifest := DiagnosticsManifest{
+		AgentVersion: version.HORIZON_VERSION,
+		CreatedTime:  uint64(created.Unix()),
+		Files:        make([]string, 0, 20),
+		Errors:       make(map[string]string),
+	}
+	if dev != nil {
+		manifest.NodeId = dev.Id
+		manifest.Org = dev.Org
+		manifest.NodeType = dev.GetNodeType()
+	}
+
+	gw := gzip.NewWriter(out)
+	return &diagnosticBundle{
+		gw:       gw,
+		tw:       tar.NewWriter(gw),
+		dir:      diagnosticBundleDir(&manifest),
+		created:  created,
+		manifest: manifest,
+	}
+}
+
+func (b *diagnosticBundle) addFile(name string, data []byte) error {
+	if err := b.writeFile(name, data); err != nil {
+		return err
+	}
+	b.manifest.Files = append(b.manifest.Files, name)
+	return nil
+}
+
+func (b *diagnosticBundle) writeFile(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    fmt.Sprintf("%v/%v", b.dir, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.created,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return errors.New(fmt.Sprintf("unable to add %v to the diagnostic bundle, error %v", name, err))
+	} else if _, err := b.tw.Write(data); err != nil {
+		return errors.New(fmt.Sprintf("unable to add %v to the diagnostic bundle, error %v", name, err))
+	}
+	return nil
+}
+
+// Add the output of the collector as a JSON file. A collector that fails is recorded in the manifest, the other files are
+// still collected.
+func (b *diagnosticBundle) addJSON(name string, collector func() (interface{}, error)) error {
+	if obj, err := collector(); err != nil {
+		b.addError(name, err)
+	} else if data, err := json.MarshalIndent(obj, "", "  "); err != nil {
+		b.addError(name, err)
+	} else {
+		return b.addFile(name, data)
+	}
+	return nil
+}
+
+func (b *diagnosticBundle) addError(name string, err error) {
+	glog.Warningf(apiLogString(fmt.Sprintf("Unable to collect %v for the diagnostic bundle, error %v", name, err)))
+	b.manifest.Errors[name] = err.Error()
+}
+
+// Write the manifest and end the archive.
+func (b *diagnosticBundle) close() error {
+	b.manifest.Files = append(b.manifest.Files, "manifest.json")
+	if data, err := json.MarshalIndent(b.manifest, "", "  "); err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal the diagnostics manifest, error %v", err))
+	} else if err := b.writeFile("manifest.json", data); err != nil {
+		return err
+	} else if err := b.tw.Close(); err != nil {
+		return errors.New(fmt.Sprintf("unable to close the diagnostic bundle, error %v", err))
+	}
+	return b.gw.Close()
+}
+
+// Write a diagnostic bundle of the node to the output. The bundle contains the node, its status, its agreements, its
+// services, its event logs, the status of the agent's workers, the agent config with its credentials censored, and the
+// inspect output without the environment values and the recent logs of the service containers. The status is collected
+// by the caller because it needs the exchange context of the agent.
+func CreateDiagnosticBundle(pm *policy.PolicyManager, db *bolt.DB, cfg *config.HorizonConfig, status *apicommon.Info, opts *DiagnosticsOptions, out io.Writer) (*DiagnosticsManifest, error) {
+
+	dev, err := persistence.FindExchangeDevice(db)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read the node, error %v", err))
+	}
+
+	b := newDiagnosticBundle(out, dev, time.Now())
+
+	msgPrinter := i18n.GetMessagePrinter()
+
+	collectors := []struct {
+		name      string
+		collector func() (interface{}, error)
+	}{
+		{"node.json", func() (interface{}, error) { return FindHorizonDeviceForOutput(db) }},
+		{"status.json", func() (interface{}, error) { return status, nil }},
+		{"workers.json", func() (interface{}, error) { return worker.GetWorkerStatusManager(), nil }},
+		{"agreements.json", func() (interface{}, error) { return FindAgreementsForOutput(db) }},
+		{"services.json", func() (interface{}, error) { return FindServicesForOutput(pm, db, cfg) }},
+		{"eventlogs.json", func() (interface{}, error) {
+			return FindEventLogsForOutput(db, false, map[string][]string{}, msgPrinter)
+		}},
+		{"config.json", func() (interface{}, error) { return censoredAgentConfig(cfg), nil }},
+	}
+	for _, c := range collectors {
+		if err := b.addJSON(c.name, c.collector); err != nil {
+			return nil, err
+		}
+	}
+
+	// the ESS config is only set while the ESS is running.
+	if resource.IsFileSyncServiceStarted() {
+		if err := b.addJSON("ess_config.json", func() (interface{}, error) { return resource.CensoredESSConfig(), nil }); err != nil {
+			return nil, err
+		}
+	}
+
+	if dev != nil && dev.GetNodeType() == persistence.DEVICE_TYPE_CLUSTER {
+		err = addClusterServiceLogs(b, db, opts)
+	} else {
+		err = addServiceContainers(b, cfg, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.close(); err != nil {
+		return nil, err
+	}
+
+	glog.V(3).Infof(apiLogString(fmt.Sprintf("Created diagnostic bundle %v with %v files and %v errors", b.dir, len(b.manifest.Files), len(b.manifest.Errors))))
+	return &b.manifest, nil
+}
+
+// Create a diagnostic bundle and send it to the CSS through the embedded ESS, in the org of the node, so that it can be
+// downloaded from the MMS.
+func UploadDiagnosticBundle(pm *policy.PolicyManager, db *bolt.DB, cfg *config.HorizonConfig, status *apicommon.Info, opts *DiagnosticsOptions) (*DiagnosticsUpload, error) {
+
+	if !resource.IsFileSyncServiceStarted() {
+		return nil, NewBadRequestError("the diagnostic bundle can only be uploaded when the node is registered and the file sync service is running")
+	}
+
+	var buf bytes.Buffer
+	manifest, err := CreateDiagnosticBundle(pm, db, cfg, status, opts, &buf)
+	if err != nil {
+		return nil, NewSystemError(err.Error())
+	}
+
+	upload := &DiagnosticsUpload{
+		Org:        manifest.Org,
+		ObjectType: DIAGNOSTICS_OBJECT_TYPE,
+		ObjectId:   diagnosticBundleDir(manifest),
+		Size:       buf.Len(),
+		Manifest:   *manifest,
+	}
+	if err := resource.SendObjectToCSS(upload.Org, upload.ObjectType, upload.ObjectId, buf.Bytes()); err != nil {
+		return nil, NewSystemError(err.Error())
+	}
+	return upload, nil
+}
+
+// The directory of the files in a diagnostic bundle, diagnostics-<node id>-<creation time>.
+func diagnosticBundleDir(manifest *DiagnosticsManifest) string {
+	created := time.Unix(int64(manifest.CreatedTime), 0).UTC().Format("20060102T150405Z")
+	if manifest.NodeId == "" {
+		return fmt.Sprintf("diagnostics-%v", created)
+	}
+	return fmt.Sprintf("diagnostics-%v-%v", manifest.NodeId, created)
+}
+
+// The name of the archive of a diagnostic bundle, it is the name of the directory in the archive.
+func diagnosticBundleFileName(manifest *DiagnosticsManifest) string {
+	return fmt.Sprintf("%v.tar.gz", diagnosticBundleDir(manifest))
+}
+
+// Returns the agent config without the credentials it contains. Only the config of the agent is returned, the agbot config is
+// not used by the agent.
+func censoredAgentConfig(cfg *config.HorizonConfig) interface{} {
+	edge := cfg.Edge
+
+	// the headers of the event sinks are often credentials, only their names are kept.
+	edge.EventSinks = make([]config.EventSinkConfig, 0, len(cfg.Edge.EventSinks))
+	for _, sink := range cfg.Edge.EventSinks {
+		headers := make(map[string]string, len(sink.Headers))
+		for h, v := range sink.Headers {
+			if v != "" {
+				v = "<...>"
+			}
+			headers[h] = v
+		}
+		sink.Headers = headers
+		sink.Address = censoredURL(sink.Address)
+		edge.EventSinks = append(edge.EventSinks, sink)
+	}
+
+	// the registry mirrors can contain the credentials of the mirror.
+	edge.RegistryMirrors = make([]config.RegistryMirrorConfig, 0, len(cfg.Edge.RegistryMirrors))
+	for _, rm := range cfg.Edge.RegistryMirrors {
+		mirrors := make([]string, 0, len(rm.Mirrors))
+		for _, m := range rm.Mirrors {
+			mirrors = append(mirrors, censoredURL(m))
+		}
+		rm.Mirrors = mirrors
+		edge.RegistryMirrors = append(edge.RegistryMirrors, rm)
+	}
+
+	return map[string]interface{}{
+		"Edge":         edge,
+		"ArchSynonyms": cfg.ArchSynonyms,
+	}
+}
+
+// Returns the URL, or the image name prefix of a registry, without the user info, such as user:password@, and without the
+// values of the query parameters, which are often tokens.
+func censoredURL(u string) string {
+	scheme, rest := "", u
+	if i := strings.Index(u, "://"); i >= 0 {
+		scheme, rest = u[:i+3], u[i+3:]
+	}
+
+	host := rest
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		host = rest[:i]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		rest = "<...>@" + rest[i+1:]
+	}
+
+	if i := strings.Index(rest, "?"); i >= 0 {
+		params := strings.Split(rest[i+1:], "&")
+		for j, p := range params {
+			if k := strings.Index(p, "="); k >= 0 && k < len(p)-1 {
+				params[j] = p[:k+1] + "<...>"
+			}
+		}
+		rest = rest[:i+1] + strings.Join(params, "&")
+	}
+	return scheme + rest
+}
+
+// Remove the values of the environment variables from the inspect output of a container, they contain the secrets and the
+// credentials that are passed to the service. Only the names of the variables are kept.
+func censorContainerEnv(c *docker.Container) {
+	if c == nil || c.Config == nil {
+		return
+	}
+	for i, env := range c.Config.Env {
+		if k := strings.Index(env, "="); k >= 0 && k < len(env)-1 {
+			c.Config.Env[i] = env[:k+1] + "<...>"
+		}
+	}
+}
+
+// Add the inspect output and the recent logs of the containers that the agent started for the services on a device.
+func addServiceContainers(b *diagnosticBundle, cfg *config.HorizonConfig, opts *DiagnosticsOptions) error {
+	client, err := docker.NewClient(cfg.Edge.DockerEndpoint)
+	if err != nil {
+		b.addError("containers", errors.New(fmt.Sprintf("unable to create docker client from %v, error %v", cfg.Edge.DockerEndpoint, err)))
+		return nil
+	}
+
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		b.addError("containers", errors.New(fmt.Sprintf("unable to list docker containers from %v, error %v", cfg.Edge.DockerEndpoint, err)))
+		return nil
+	}
+
+	names := make([]string, 0, len(containers))
+	for _, c := range containers {
+		if _, ok := c.Labels[container.LABEL_PREFIX+".agreement_id"]; ok && len(c.Names) > 0 {
+			names = append(names, c.Names[0][1:])
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := b.addJSON(fmt.Sprintf("containers/%v.json", name), func() (interface{}, error) {
+			c, err := client.InspectContainer(name)
+			censorContainerEnv(c)
+			return c, err
+		}); err != nil {
+			return err
+		}
+
+		if opts.LogLines == 0 {
+			continue
+		}
+		var logs bytes.Buffer
+		logOpts := docker.LogsOptions{
+			Container:    name,
+			OutputStream: &logs,
+			ErrorStream:  &logs,
+			Stdout:       true,
+			Stderr:       true,
+			Timestamps:   true,
+			Tail:         strconv.FormatInt(opts.LogLines, 10),
+		}
+		if err := client.Logs(logOpts); err != nil {
+			b.addError(fmt.Sprintf("logs/%v.log", name), err)
+		} else if err := b.addFile(fmt.Sprintf("logs/%v.log", name), logs.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add the recent logs of the operator pods of the services on a cluster.
+func addClusterServiceLogs(b *diagnosticBundle, db *bolt.DB, opts *DiagnosticsOptions) error {
+	if opts.LogLines == 0 {
+		return nil
+	}
+
+	ags, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter()})
+	if err != nil {
+		b.addError("logs", errors.New(fmt.Sprintf("unable to read the agreements, error %v", err)))
+		return nil
+	}
+
+	for _, ag := range ags {
+		name := fmt.Sprintf("logs/%v-operator.log", ag.CurrentAgreementId)
+		if ag.AgreementExecutionStartTime == 0 {
+			continue
+		} else if msdef, err := persistence.FindMicroserviceDefWithKey(db, ag.ServiceDefId); err != nil {
+			b.addError(name, errors.New(fmt.Sprintf("unable to read the service definition of agreement %v, error %v", ag.CurrentAgreementId, err)))
+		} else if msdef == nil {
+			b.addError(name, errors.New(fmt.Sprintf("the service definition of agreement %v is not on the node", ag.CurrentAgreementId)))
+		} else if logs, err := openClusterServiceLogs(context.Background(), db, msdef, &ServiceLogOptions{Tail: opts.LogLines, Timestamps: true}); err != nil {
+			b.addError(name, err)
+		} else {
+			data, err := io.ReadAll(logs)
+			logs.Close()
+			if err != nil {
+				b.addError(name, err)
+			} else if err := b.addFile(name, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/apicommon"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_NewDiagnosticsOptions(t *testing.T) {
+
+	opts, err := NewDiagnosticsOptions(url.Values{})
+	assert.Nil(t, err, "the options should be valid")
+	assert.Equal(t, int64(DIAGNOSTICS_LOG_LINES_DEFAULT), opts.LogLines, "wrong default log lines")
+
+	opts, err = NewDiagnosticsOptions(url.Values{"log_lines": []string{"0"}})
+	assert.Nil(t, err, "the options should be valid")
+	assert.Equal(t, int64(0), opts.LogLines, "wrong log lines")
+
+	_, err = NewDiagnosticsOptions(url.Values{"log_lines": []string{"-5"}})
+	assert.NotNil(t, err, "a negative number of lines should not be valid")
+}
+
+func Test_CreateDiagnosticBundle(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	dev, err := persistence.SaveNewExchangeDevice(db, "mynode", "token", "mynode", persistence.DEVICE_TYPE_DEVICE, "myorg", "", persistence.CONFIGSTATE_CONFIGURED, persistence.SoftwareVersion{})
+	if err != nil {
+		t.Errorf("failed to save the node, error %v", err)
+	}
+
+	cfg := &config.HorizonConfig{
+		Edge: config.Config{
+			DockerEndpoint:  "unix:///no/such/docker.sock",
+			EventSinks:      []config.EventSinkConfig{{Type: "webhook", Address: "https://user:pw@logs.example.com/in?token=tk1", Headers: map[string]string{"Authorization": "Bearer secret"}}},
+			RegistryMirrors: []config.RegistryMirrorConfig{{Prefix: "docker.io", Mirrors: []string{"mirroruser:mirrorpw@cache.example.com:5000"}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	manifest, err := CreateDiagnosticBundle(policy.PolicyManager_Factory(false, false), db, cfg, &apicommon.Info{}, &DiagnosticsOptions{LogLines: 10}, &buf)
+	assert.Nil(t, err, "the bundle should be created")
+	assert.Equal(t, dev.Id, manifest.NodeId, "wrong node in the manifest")
+
+	// the containers cannot be listed without docker, the other files are still collected.
+	assert.Contains(t, manifest.Errors, "containers", "the missing docker should be an error")
+
+	gr, err := gzip.NewReader(&buf)
+	if !assert.Nil(t, err, "the bundle should be gzipped") {
+		return
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if !assert.Nil(t, err, "the bundle should be a tar archive") {
+			return
+		}
+		assert.True(t, strings.HasPrefix(hdr.Name, diagnosticBundleDir(manifest)+"/"), "the file %v should be in the bundle directory", hdr.Name)
+		data, _ := io.ReadAll(tr)
+		files[strings.TrimPrefix(hdr.Name, diagnosticBundleDir(manifest)+"/")] = string(data)
+	}
+
+	for _, name := range []string{"node.json", "status.json", "workers.json", "agreements.json", "services.json", "eventlogs.json", "config.json", "manifest.json"} {
+		assert.Contains(t, files, name, "the bundle should contain %v", name)
+		assert.Contains(t, manifest.Files, name, "the manifest should list %v", name)
+	}
+
+	// the event sink credentials are censored.
+	assert.NotContains(t, files["config.json"], "Bearer secret", "the event sink header should be censored")
+	assert.Contains(t, files["config.json"], "Authorization", "the event sink header name should be kept")
+	assert.Equal(t, "Bearer secret", cfg.Edge.EventSinks[0].Headers["Authorization"], "the agent config should not be changed")
+	for _, secret := range []string{"user:pw", "tk1", "mirrorpw"} {
+		assert.NotContains(t, files["config.json"], secret, "the credentials in the URLs should be censored")
+	}
+	assert.Contains(t, files["config.json"], "cache.example.com:5000", "the mirror should be kept")
+	assert.Equal(t, "mirroruser:mirrorpw@cache.example.com:5000", cfg.Edge.RegistryMirrors[0].Mirrors[0], "the agent config should not be changed")
+
+	var archived DiagnosticsManifest
+	assert.Nil(t, json.Unmarshal([]byte(files["manifest.json"]), &archived), "the manifest should be json")
+	assert.Equal(t, *manifest, archived, "the archived manifest should be the returned manifest")
+}
+
+func Test_censoredURL(t *testing.T) {
+
+	assert.Equal(t, "https://logs.example.com/in", censoredURL("https://logs.example.com/in"), "a URL without credentials should not be changed")
+	assert.Equal(t, "https://<...>@logs.example.com/in", censoredURL("https://user:pw@logs.example.com/in"), "the user info should be censored")
+	assert.Equal(t, "https://logs.example.com/in?token=<...>&debug", censoredURL("https://logs.example.com/in?token=tk1&debug"), "the query values should be censored")
+	assert.Equal(t, "<...>@cache.example.com:5000/quay", censoredURL("user:pw@cache.example.com:5000/quay"), "the user info of a mirror should be censored")
+	assert.Equal(t, "/var/run/events.sock", censoredURL("/var/run/events.sock"), "a path should not be changed")
+}
+
+func Test_censorContainerEnv(t *testing.T) {
+
+	c := &docker.Container{Config: &docker.Config{Env: []string{"HZN_AGREEMENTID=ag1", "DB_PASSWORD=secret", "EMPTY="}}}
+	censorContainerEnv(c)
+	assert.Equal(t, []string{"HZN_AGREEMENTID=<...>", "DB_PASSWORD=<...>", "EMPTY="}, c.Config.Env, "only the names of the variables should be kept")
+
+	censorContainerEnv(&docker.Container{})
+	censorContainerEnv(nil)
+}
@@ -80,6 +80,14 @@ func FindServicesForOutput(pm *policy.PolicyManager,
 	sort.Sort(MicroserviceDefById(wrap.Definitions[activeKey]))
 	sort.Sort(MicroserviceDefByUpgradeStartTime(wrap.Definitions[archivedKey]))
 
+	// Add the image pulls, so that a slow pull can be told from a stalled one.
+	pulls, err := persistence.FindImagePulls(db, []persistence.IPFilter{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read image pulls, error %v", err))
+	}
+	wrap.ImagePulls = pulls
+	sort.Sort(ImagePullsByStartTime(wrap.ImagePulls))
+
 	// Add the service config sub-object to the output
 	cfg, err := FindServiceConfigForOutput(pm, db)
 	if err != nil {
@@ -301,7 +301,7 @@ func CreateService(service *Service,
 		service.Name = &msdef.Name
 	}
 	msdef.RequestedArch = *service.Arch
-	msdef.UpgradeVersionRange = vExp.Get_expression()
+	msdef.UpgradeVersionRange = vExp.Get_shorthand_expression()
 	if service.AutoUpgrade != nil {
 		msdef.AutoUpgrade = *service.AutoUpgrade
 	}
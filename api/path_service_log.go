@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/abstractprotocol"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/kube_operator"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The options of the logs of a service container, from the query parameters of the request.
+type ServiceLogOptions struct {
+	Container  string // the name of the container in the deployment of a native service, or in the pod of a cluster service
+	Pod        string // cluster services only, a pod in the namespace of the service instead of the operator pod
+	Tail       int64  // the number of lines from the end of the logs, all the lines when it is 0
+	SinceS     int64  // only the logs of the last number of seconds, all the logs when it is 0
+	Follow     bool   // stream the logs as they are written, until the client closes the connection
+	Timestamps bool   // prefix each line with its timestamp
+}
+
+// Returns the log options in the query parameters.
+func NewServiceLogOptions(form url.Values) (*ServiceLogOptions, error) {
+	opts := &ServiceLogOptions{
+		Container: form.Get("container"),
+		Pod:       form.Get("pod"),
+	}
+
+	var err error
+	if tail := form.Get("tail"); tail != "" {
+		if opts.Tail, err = strconv.ParseInt(tail, 10, 64); err != nil || opts.Tail < 0 {
+			return nil, NewAPIUserInputError(fmt.Sprintf("the tail %v is not a positive number of lines", tail), "tail")
+		}
+	}
+	if since := form.Get("since"); since != "" {
+		if opts.SinceS, err = strconv.ParseInt(since, 10, 64); err != nil || opts.SinceS < 0 {
+			return nil, NewAPIUserInputError(fmt.Sprintf("the since %v is not a positive number of seconds", since), "since")
+		}
+	}
+	if follow := form.Get("follow"); follow != "" {
+		if opts.Follow, err = strconv.ParseBool(follow); err != nil {
+			return nil, NewAPIUserInputError(fmt.Sprintf("the follow %v is not true or false", follow), "follow")
+		}
+	}
+	if timestamps := form.Get("timestamps"); timestamps != "" {
+		if opts.Timestamps, err = strconv.ParseBool(timestamps); err != nil {
+			return nil, NewAPIUserInputError(fmt.Sprintf("the timestamps %v is not true or false", timestamps), "timestamps")
+		}
+	}
+	return opts, nil
+}
+
+// A writer that flushes the response after each write, so that followed logs are sent as they are written.
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if f.flusher != nil {
+		f.flusher.Flush()
+	}
+	return n, err
+}
+
+// Write the stdout and stderr of a container of the service instance with the given key, the agreement id for a top level
+// service, to the response. The logs of a native service come from its docker container, the logs of a cluster service come
+// from the operator pod of the service, or from another pod in its namespace. Errors found before the logs are written are
+// reported with the error handler, the response is ended when the logs end or, when the logs are followed, when the client
+// closes the connection.
+func StreamServiceLogs(errorHandler ErrorHandler, ctx context.Context, db *bolt.DB, cfg *config.HorizonConfig, instanceKey string, opts *ServiceLogOptions, w http.ResponseWriter) {
+
+	// the instance of a top level service is its agreement.
+	msinst, err := persistence.GetMicroserviceInstIWithKey(db, instanceKey)
+	if err != nil {
+		errorHandler(NewSystemError(fmt.Sprintf("unable to read service instance %v, error %v", instanceKey, err)))
+		return
+	} else if msinst == nil || msinst.IsArchived() {
+		errorHandler(NewNotFoundError(fmt.Sprintf("service instance %v is not running on the node", instanceKey), "instance"))
+		return
+	}
+
+	msdef, err := persistence.FindMicroserviceDefWithKey(db, msinst.GetServiceDefId())
+	if err != nil {
+		errorHandler(NewSystemError(fmt.Sprintf("unable to read the definition of service instance %v, error %v", instanceKey, err)))
+		return
+	} else if msdef == nil {
+		errorHandler(NewNotFoundError(fmt.Sprintf("the definition of service instance %v is not on the node", instanceKey), "instance"))
+		return
+	}
+
+	dev, err := persistence.FindExchangeDevice(db)
+	if err != nil {
+		errorHandler(NewSystemError(fmt.Sprintf("unable to read the node, error %v", err)))
+		return
+	}
+
+	var logs io.ReadCloser
+	var containerId string
+	if dev != nil && dev.GetNodeType() == persistence.DEVICE_TYPE_CLUSTER {
+		if logs, err = openClusterServiceLogs(ctx, db, msdef, opts); err != nil {
+			errorHandler(err)
+			return
+		}
+		defer logs.Close()
+	} else {
+		deployment, _ := msdef.GetDeployment()
+		if deployment == "" {
+			errorHandler(NewBadRequestError(fmt.Sprintf("service instance %v does not have a native deployment", instanceKey)))
+			return
+		}
+		if containerId, err = findNativeServiceContainer(cfg, msinst.GetKey(), deployment, opts); err != nil {
+			errorHandler(err)
+			return
+		}
+	}
+
+	glog.V(5).Infof(apiLogString(fmt.Sprintf("Writing logs of container %v of service instance %v with options %v", opts.Container, instanceKey, *opts)))
+
+	flusher, _ := w.(http.Flusher)
+	out := flushWriter{w: w, flusher: flusher}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if logs != nil {
+		_, err = io.Copy(out, logs)
+	} else {
+		err = writeNativeServiceLogs(ctx, cfg, containerId, opts, out)
+	}
+	if err != nil && ctx.Err() == nil {
+		glog.Errorf(apiLogString(fmt.Sprintf("Unable to write the logs of service instance %v, error %v", instanceKey, err)))
+	}
+}
+
+// Check that the container is in the native deployment and that it exists on the node, and return the id of its docker
+// container. When the container is not set, the deployment must have only one container.
+func findNativeServiceContainer(cfg *config.HorizonConfig, instanceKey string, deployment string, opts *ServiceLogOptions) (string, error) {
+	if opts.Pod != "" {
+		return "", NewAPIUserInputError("the pod can only be set for the services of a cluster node", "pod")
+	}
+
+	deploymentDesc, err := containermessage.GetNativeDeployment(deployment)
+	if err != nil {
+		return "", NewSystemError(fmt.Sprintf("unable to read the deployment of service instance %v, error %v", instanceKey, err))
+	}
+
+	if opts.Container == "" {
+		if len(deploymentDesc.Services) != 1 {
+			names := make([]string, 0, len(deploymentDesc.Services))
+			for name := range deploymentDesc.Services {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return "", NewAPIUserInputError(fmt.Sprintf("service instance %v has more than one container %v, the container must be set", instanceKey, names), "container")
+		}
+		for name := range deploymentDesc.Services {
+			opts.Container = name
+		}
+	} else if _, ok := deploymentDesc.Services[opts.Container]; !ok {
+		return "", NewNotFoundError(fmt.Sprintf("container %v is not in the deployment of service instance %v", opts.Container, instanceKey), "container")
+	}
+
+	client, err := docker.NewClient(cfg.Edge.DockerEndpoint)
+	if err != nil {
+		return "", NewSystemError(fmt.Sprintf("unable to create docker client from %v, error %v", cfg.Edge.DockerEndpoint, err))
+	}
+
+	labelFilter := fmt.Sprintf("%v.service_name=%v", container.LABEL_PREFIX, opts.Container)
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true, Filters: map[string][]string{"label": []string{labelFilter}}})
+	if err != nil {
+		return "", NewSystemError(fmt.Sprintf("unable to list the containers of service instance %v, error %v", instanceKey, err))
+	} else if containerId := selectNativeServiceContainer(containers, instanceKey, opts.Container, deploymentDesc); containerId == "" {
+		return "", NewNotFoundError(fmt.Sprintf("container %v of service instance %v is not on the node", opts.Container, instanceKey), "container")
+	} else {
+		return containerId, nil
+	}
+}
+
+// Returns the id of the docker container of the service instance, or an empty string when it is not in the containers. The
+// agent labels the containers of an instance with the container name in the deployment and with the agreement id, which is
+// the instance key. A singleton container is shared by the instances that use it, so it is labeled with its variation
+// instead of an agreement id.
+func selectNativeServiceContainer(containers []docker.APIContainers, instanceKey string, containerName string, deploymentDesc *containermessage.DeploymentDescription) string {
+	singleton := deploymentDesc.ServicePattern.IsShared("singleton", containerName)
+	for _, c := range containers {
+		if c.Labels[container.LABEL_PREFIX+".service_name"] != containerName {
+			continue
+		} else if singleton && c.Labels[container.LABEL_PREFIX+".service_pattern.shared"] == "singleton" && c.Labels[container.LABEL_PREFIX+".variation"] == deploymentDesc.Services[containerName].VariationLabel {
+			return c.ID
+		} else if !singleton && c.Labels[container.LABEL_PREFIX+".agreement_id"] == instanceKey {
+			return c.ID
+		}
+	}
+	return ""
+}
+
+func writeNativeServiceLogs(ctx context.Context, cfg *config.HorizonConfig, containerId string, opts *ServiceLogOptions, out io.Writer) error {
+	client, err := docker.NewClient(cfg.Edge.DockerEndpoint)
+	if err != nil {
+		return err
+	}
+
+	logOpts := docker.LogsOptions{
+		Context:      ctx,
+		Container:    containerId,
+		OutputStream: out,
+		ErrorStream:  out,
+		Stdout:       true,
+		Stderr:       true,
+		Follow:       opts.Follow,
+		Timestamps:   opts.Timestamps,
+		Tail:         "all",
+	}
+	if opts.Tail > 0 {
+		logOpts.Tail = strconv.FormatInt(opts.Tail, 10)
+	}
+	if opts.SinceS > 0 {
+		logOpts.Since = time.Now().Unix() - opts.SinceS
+	}
+	return client.Logs(logOpts)
+}
+
+// Open the logs of the operator pod of a cluster service, or of another pod in the namespace of the service. The namespace
+// is the one requested in the agreement of the service, or the namespace of the operator.
+func openClusterServiceLogs(ctx context.Context, db *bolt.DB, msdef *persistence.MicroserviceDefinition, opts *ServiceLogOptions) (io.ReadCloser, error) {
+	kd, err := persistence.GetKubeDeployment(msdef.ClusterDeployment)
+	if msdef.ClusterDeployment == "" || err != nil {
+		return nil, NewBadRequestError(fmt.Sprintf("service %v/%v does not have an operator deployment", msdef.Org, msdef.SpecRef))
+	}
+
+	ags, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.ServiceDefEAFilter(msdef.Id)})
+	if err != nil {
+		return nil, NewSystemError(fmt.Sprintf("unable to read the agreement of service %v/%v, error %v", msdef.Org, msdef.SpecRef, err))
+	} else if len(ags) < 1 {
+		return nil, NewNotFoundError(fmt.Sprintf("service %v/%v does not have an agreement", msdef.Org, msdef.SpecRef), "instance")
+	}
+
+	reqNamespace, err := clusterNamespaceFromAgreement(&ags[0])
+	if err != nil {
+		return nil, NewSystemError(err.Error())
+	}
+
+	client, err := kube_operator.NewKubeClient()
+	if err != nil {
+		return nil, NewSystemError(fmt.Sprintf("unable to create the kube client, error %v", err))
+	}
+
+	logs, err := client.Logs(ctx, kd.OperatorYamlArchive, kd.Metadata, ags[0].CurrentAgreementId, reqNamespace, kube_operator.LogOptions{
+		Pod:          opts.Pod,
+		Container:    opts.Container,
+		TailLines:    opts.Tail,
+		SinceSeconds: opts.SinceS,
+		Follow:       opts.Follow,
+		Timestamps:   opts.Timestamps,
+	})
+	if err != nil {
+		return nil, NewSystemError(fmt.Sprintf("unable to get the logs of service %v/%v, error %v", msdef.Org, msdef.SpecRef, err))
+	}
+	return logs, nil
+}
+
+// Returns the cluster namespace that the agreement requested for the service.
+func clusterNamespaceFromAgreement(ag *persistence.EstablishedAgreement) (string, error) {
+	if proposal, err := abstractprotocol.DemarshalProposal(ag.Proposal); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to demarshal the proposal of agreement %v, error %v", ag.CurrentAgreementId, err))
+	} else if tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to demarshal the TsAndCs of agreement %v, error %v", ag.CurrentAgreementId, err))
+	} else {
+		return tcPolicy.ClusterNamespace, nil
+	}
+}
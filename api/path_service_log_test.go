@@ -0,0 +1,94 @@
+//go:build unit
+// +build unit
+
+package api
+
+import (
+	"context"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func Test_NewServiceLogOptions(t *testing.T) {
+
+	form := url.Values{}
+	form.Set("container", "netspeed")
+	form.Set("tail", "100")
+	form.Set("since", "3600")
+	form.Set("follow", "true")
+
+	opts, err := NewServiceLogOptions(form)
+	assert.Nil(t, err, "the options should be valid")
+	assert.Equal(t, ServiceLogOptions{Container: "netspeed", Tail: 100, SinceS: 3600, Follow: true}, *opts, "wrong options")
+
+	// the default is all the logs, without following them.
+	opts, err = NewServiceLogOptions(url.Values{})
+	assert.Nil(t, err, "the options should be valid")
+	assert.Equal(t, ServiceLogOptions{}, *opts, "wrong default options")
+
+	for name, value := range map[string]string{"tail": "-1", "since": "an hour", "follow": "maybe", "timestamps": "2"} {
+		form := url.Values{}
+		form.Set(name, value)
+		_, err := NewServiceLogOptions(form)
+		if assert.NotNil(t, err, "the %v option %v should not be valid", name, value) {
+			assert.Equal(t, name, err.(*APIUserInputError).Input, "wrong input in the error")
+		}
+	}
+}
+
+func Test_StreamServiceLogs_not_found(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	var myError error
+	errorhandler := GetPassThroughErrorHandler(&myError)
+
+	w := httptest.NewRecorder()
+	StreamServiceLogs(errorhandler, context.Background(), db, &config.HorizonConfig{}, "noinstance", &ServiceLogOptions{}, w)
+
+	if assert.NotNil(t, myError, "the instance should not be found") {
+		assert.IsType(t, &NotFoundError{}, myError, "wrong type of error")
+	}
+	assert.Empty(t, w.Body.String(), "no logs should be written")
+}
+
+func Test_selectNativeServiceContainer(t *testing.T) {
+
+	labels := func(serviceName string, agreementId string, variation string, singleton bool) map[string]string {
+		l := map[string]string{container.LABEL_PREFIX + ".service_name": serviceName, container.LABEL_PREFIX + ".variation": variation}
+		if singleton {
+			l[container.LABEL_PREFIX+".service_pattern.shared"] = "singleton"
+		} else {
+			l[container.LABEL_PREFIX+".agreement_id"] = agreementId
+		}
+		return l
+	}
+
+	containers := []docker.APIContainers{
+		{ID: "c1", Labels: labels("netspeed", "ag1", "", false)},
+		{ID: "c2", Labels: labels("netspeed", "ag2", "", false)},
+		{ID: "c3", Labels: labels("gps", "", "v1", true)},
+		{ID: "c4", Labels: labels("gps", "", "v2", true)},
+	}
+
+	deploymentDesc := &containermessage.DeploymentDescription{Services: map[string]*containermessage.Service{"netspeed": {}, "gps": {VariationLabel: "v2"}}}
+	assert.Equal(t, "c2", selectNativeServiceContainer(containers, "ag2", "netspeed", deploymentDesc), "the container of the agreement should be found")
+	assert.Equal(t, "", selectNativeServiceContainer(containers, "ag3", "netspeed", deploymentDesc), "there is no container for the agreement")
+	assert.Equal(t, "", selectNativeServiceContainer(containers, "ag1", "gps", deploymentDesc), "the gps containers are singletons")
+
+	// a singleton container is shared, it is found by its variation
+	deploymentDesc.ServicePattern.Shared = map[string][]string{"singleton": {"gps"}}
+	assert.Equal(t, "c4", selectNativeServiceContainer(containers, "ag1", "gps", deploymentDesc), "the singleton container of the variation should be found")
+	deploymentDesc.Services["gps"].VariationLabel = "v3"
+	assert.Equal(t, "", selectNativeServiceContainer(containers, "ag1", "gps", deploymentDesc), "there is no singleton container for the variation")
+}
@@ -553,6 +553,7 @@ const AB_CANCEL_FORCED_UPGRADE = 207
 const AB_CANCEL_NODE_HEARTBEAT = 208
 const AB_CANCEL_AG_MISSING = 209
 const AB_CANCEL_UPDATE_REJECTED = 210
+const AB_CANCEL_ROLLOUT_ROLLBACK = 211
 
 // const AB_CANCEL_BC_WRITE_FAILED       = 208  // xd0
 
@@ -588,9 +589,10 @@ func DecodeReasonCode(code uint64) string {
 		AB_USER_REQUESTED:          "agreement bot user requested",
 		AB_CANCEL_FORCED_UPGRADE:   "agreement bot user requested service upgrade",
 		// AB_CANCEL_BC_WRITE_FAILED:   "agreement bot agreement write failed"}
-		AB_CANCEL_NODE_HEARTBEAT:  "agreement bot detected node heartbeat stopped",
-		AB_CANCEL_AG_MISSING:      "agreement bot detected agreement missing from node",
-		AB_CANCEL_UPDATE_REJECTED: "agreement update rejected by node"}
+		AB_CANCEL_NODE_HEARTBEAT:   "agreement bot detected node heartbeat stopped",
+		AB_CANCEL_AG_MISSING:       "agreement bot detected agreement missing from node",
+		AB_CANCEL_UPDATE_REJECTED:  "agreement update rejected by node",
+		AB_CANCEL_ROLLOUT_ROLLBACK: "agreement bot rolled back a halted service rollout"}
 
 	if reasonString, ok := codeMeanings[code]; !ok {
 		return "unknown reason code, device might be downlevel"
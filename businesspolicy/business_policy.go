@@ -15,18 +15,19 @@ const DEFAULT_MAX_AGREEMENT = 0
 // BusinessPolicy the business policy
 // swagger:model
 type BusinessPolicy struct {
-	Owner         string                              `json:"owner,omitempty"`
-	Label         string                              `json:"label"`
-	Description   string                              `json:"description"`
-	Service       ServiceRef                          `json:"service"`
-	Properties    externalpolicy.PropertyList         `json:"properties,omitempty"`
-	Constraints   externalpolicy.ConstraintExpression `json:"constraints,omitempty"`
-	UserInput     []policy.UserInput                  `json:"userInput,omitempty"`
-	SecretBinding []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"` // The secret binding from service secret names to secret manager secret names.
+	Owner              string                              `json:"owner,omitempty"`
+	Label              string                              `json:"label"`
+	Description        string                              `json:"description"`
+	Service            ServiceRef                          `json:"service"`
+	Properties         externalpolicy.PropertyList         `json:"properties,omitempty"`
+	Constraints        externalpolicy.ConstraintExpression `json:"constraints,omitempty"`
+	UserInput          []policy.UserInput                  `json:"userInput,omitempty"`
+	SecretBinding      []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"`      // The secret binding from service secret names to secret manager secret names.
+	MaintenanceWindows exchangecommon.MaintenanceWindows   `json:"maintenanceWindows,omitempty"` // When non-urgent workload changes can be made on the nodes, at any time when omitted.
 }
 
 func (w BusinessPolicy) String() string {
-	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Service: %v, Properties: %v, Constraints: %v, UserInput: %v, SecretBinding: %v",
+	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Service: %v, Properties: %v, Constraints: %v, UserInput: %v, SecretBinding: %v, MaintenanceWindows: %v",
 		w.Owner,
 		w.Label,
 		w.Description,
@@ -34,7 +35,8 @@ func (w BusinessPolicy) String() string {
 		w.Properties,
 		w.Constraints,
 		w.UserInput,
-		w.SecretBinding)
+		w.SecretBinding,
+		w.MaintenanceWindows)
 }
 
 type ServiceRef struct {
@@ -44,16 +46,18 @@ type ServiceRef struct {
 	ClusterNamespace string           `json:"clusterNamespace,omitempty"` // the namespace ths service will be deployed to.
 	ServiceVersions  []WorkloadChoice `json:"serviceVersions,omitempty"`  // a list of service version for rollback
 	NodeH            NodeHealth       `json:"nodeHealth"`                 // policy for determining when a node's health is violating its agreements
+	Rollout          *policy.Rollout  `json:"rollout,omitempty"`          // how service upgrades are rolled out to the nodes, all at once when omitted
 }
 
 func (w ServiceRef) String() string {
-	return fmt.Sprintf("Name: %v, Org: %v, Arch: %v, ClusterNamespace: %v, ServiceVersions: %v, NodeH: %v",
+	return fmt.Sprintf("Name: %v, Org: %v, Arch: %v, ClusterNamespace: %v, ServiceVersions: %v, NodeH: %v, Rollout: %v",
 		w.Name,
 		w.Org,
 		w.Arch,
 		w.ClusterNamespace,
 		w.ServiceVersions,
-		w.NodeH)
+		w.NodeH,
+		w.Rollout)
 }
 
 func (w ServiceRef) Validate() error {
@@ -73,10 +77,46 @@ func (w ServiceRef) Validate() error {
 			}
 		}
 	}
+
+	if w.Rollout != nil {
+		return w.validateRollout()
+	}
 	return nil
 
 }
 
+func (w ServiceRef) validateRollout() error {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	r := w.Rollout
+	if r.BatchSize < 0 || r.BatchPercentage < 0 || r.BatchPercentage > 100 {
+		return fmt.Errorf(msgPrinter.Sprintf("rollout batchSize cannot be negative and batchPercentage must be between 0 and 100"))
+	} else if (r.BatchSize == 0) == (r.BatchPercentage == 0) {
+		return fmt.Errorf(msgPrinter.Sprintf("exactly one of rollout batchSize or batchPercentage must be set"))
+	} else if r.PauseS < 0 || r.SuccessTimeoutS < 0 {
+		return fmt.Errorf(msgPrinter.Sprintf("rollout pauseS and successTimeoutS cannot be negative"))
+	} else if r.MaxFailurePercentage < 0 || r.MaxFailurePercentage > 100 {
+		return fmt.Errorf(msgPrinter.Sprintf("rollout maxFailurePercentage must be between 0 and 100"))
+	} else if r.SuccessCriteria != "" && r.SuccessCriteria != policy.ROLLOUT_SUCCESS_FINALIZED && r.SuccessCriteria != policy.ROLLOUT_SUCCESS_EXECUTING {
+		return fmt.Errorf(msgPrinter.Sprintf("rollout successCriteria must be %v or %v", policy.ROLLOUT_SUCCESS_FINALIZED, policy.ROLLOUT_SUCCESS_EXECUTING))
+	}
+
+	// Rolling back means going back to a lower priority service version, so there must be more than one.
+	if r.Rollback {
+		prioritized := 0
+		for _, wc := range w.ServiceVersions {
+			if wc.Priority.PriorityValue != 0 {
+				prioritized++
+			}
+		}
+		if prioritized < 2 {
+			return fmt.Errorf(msgPrinter.Sprintf("rollout rollback requires at least 2 service versions with a priority_value"))
+		}
+	}
+	return nil
+}
+
 type WorkloadPriority struct {
 	PriorityValue     int `json:"priority_value,omitempty"`     // The priority of the workload
 	Retries           int `json:"retries,omitempty"`            // The number of retries before giving up and moving to the next priority
@@ -161,6 +201,10 @@ func (b *BusinessPolicy) Validate() error {
 		}
 	}
 
+	if err := b.MaintenanceWindows.Validate(); err != nil {
+		return err
+	}
+
 	// Validate the Constraints expression by invoking the plugins.
 	if b != nil && len(b.Constraints) != 0 {
 		_, err := b.Constraints.Validate()
@@ -217,6 +261,12 @@ func (b *BusinessPolicy) GenPolicyFromBusinessPolicy(policyName string) (*policy
 	// node health
 	ConvertNodeHealth(service.NodeH, pol)
 
+	// staged rollout of service upgrades
+	pol.Rollout = service.Rollout.DeepCopy()
+
+	// when non-urgent workload changes can be made on the nodes
+	pol.MaintenanceWindows = b.MaintenanceWindows.DeepCopy()
+
 	pol.MaxAgreements = DEFAULT_MAX_AGREEMENT
 
 	// add default agreement protocol
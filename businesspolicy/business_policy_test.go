@@ -321,3 +321,59 @@ func Test_GenPolicyFromBusinessPolicy_Complicated(t *testing.T) {
 		t.Errorf("Second user input variable value for service cpu should be val2 but got %v.", pPolicy.UserInput[0].Inputs[1].Value)
 	}
 }
+
+// staged rollout settings
+func Test_Validate_Rollout(t *testing.T) {
+
+	wlc1 := WorkloadChoice{
+		Version:  "1.0.0",
+		Priority: WorkloadPriority{PriorityValue: 2, Retries: 1, RetryDurationS: 60},
+	}
+	wlc2 := WorkloadChoice{
+		Version:  "1.1.0",
+		Priority: WorkloadPriority{PriorityValue: 1, Retries: 1, RetryDurationS: 60},
+	}
+
+	bPolicy := BusinessPolicy{
+		Label: "my business policy",
+		Service: ServiceRef{
+			Name:            "cpu",
+			Org:             "mycomp",
+			Arch:            "amd64",
+			ServiceVersions: []WorkloadChoice{wlc1, wlc2},
+			Rollout:         &policy.Rollout{BatchPercentage: 10, PauseS: 300, MaxFailurePercentage: 20, Rollback: true},
+		},
+	}
+
+	if err := bPolicy.Validate(); err != nil {
+		t.Errorf("Validate should have not have returned error but got: %v", err)
+	} else if pPolicy, err := bPolicy.GenPolicyFromBusinessPolicy("mypolicy"); err != nil {
+		t.Errorf("GenPolicyFromBusinessPolicy should have not have returned error but got: %v", err)
+	} else if !pPolicy.Rollout.IsSame(bPolicy.Service.Rollout) || pPolicy.Rollout == bPolicy.Service.Rollout {
+		t.Errorf("Rollout for policy is wrong: %v", pPolicy.Rollout)
+	}
+
+	badRollouts := []policy.Rollout{
+		{},
+		{BatchSize: 5, BatchPercentage: 10},
+		{BatchPercentage: 101},
+		{BatchSize: -1},
+		{BatchSize: 5, PauseS: -1},
+		{BatchSize: 5, MaxFailurePercentage: 150},
+		{BatchSize: 5, SuccessCriteria: "running"},
+	}
+	for _, r := range badRollouts {
+		ro := r
+		bPolicy.Service.Rollout = &ro
+		if err := bPolicy.Validate(); err == nil {
+			t.Errorf("Validate should have returned error for rollout %v", r)
+		}
+	}
+
+	// Rollback needs a lower priority version to go back to.
+	bPolicy.Service.Rollout = &policy.Rollout{BatchSize: 5, Rollback: true}
+	bPolicy.Service.ServiceVersions = []WorkloadChoice{{Version: "1.1.0"}}
+	if err := bPolicy.Validate(); err == nil {
+		t.Errorf("Validate should have returned error for rollback with a single service version")
+	}
+}
@@ -0,0 +1,40 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/agreementbot"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/i18n"
+	"os"
+	"strings"
+)
+
+// Explain why the node does or does not have an agreement for the deployment policy or pattern.
+func Explain(node string, pol string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	nodeParts := strings.Split(node, "/")
+	if len(nodeParts) != 2 || nodeParts[0] == "" || nodeParts[1] == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The node must be in the form org/id, but is %v.", node))
+	}
+	polParts := strings.Split(pol, "/")
+	if len(polParts) != 2 || polParts[0] == "" || polParts[1] == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The deployment policy or pattern must be in the form org/name, but is %v.", pol))
+	}
+
+	// set env to call agbot url
+	if err := os.Setenv("HORIZON_URL", cliutils.GetAgbotUrlBase()); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("unable to set env var 'HORIZON_URL', error %v", err))
+	}
+
+	var explanation agreementbot.AgreementExplanation
+	cliutils.HorizonGet(fmt.Sprintf("explain/%v/%v/%v/%v", nodeParts[0], nodeParts[1], polParts[0], polParts[1]), []int{200}, &explanation, false)
+
+	jsonBytes, err := json.MarshalIndent(explanation, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn agbot explain' output: %v", err))
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
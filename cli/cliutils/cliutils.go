@@ -345,6 +345,28 @@ func PullDockerImage(client *dockerclient.Client, domain, path, tag string) (dig
 
 }
 
+// LocalDockerImageDigest returns the repo digest of an image in the local image store, without pushing or pulling the image.
+// The image only has a repo digest for its repository if it was pushed to or pulled from the repository.
+func LocalDockerImageDigest(client *dockerclient.Client, domain, path, tag string) (string, error) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	imageName := cutil.FormDockerImageName(domain, path, tag, "")
+	image, err := client.InspectImage(imageName)
+	if err != nil {
+		return "", errors.New(msgPrinter.Sprintf("could not inspect local image %v: %v", imageName, err))
+	}
+
+	repository := cutil.NormalizeImageRepository(imageName)
+	for _, rDigest := range image.RepoDigests {
+		if cutil.NormalizeImageRepository(rDigest) == repository {
+			_, _, _, digest := cutil.ParseDockerImagePath(rDigest)
+			Verbose(msgPrinter.Sprintf("Using digest %v of local image %v", digest, imageName))
+			return digest, nil
+		}
+	}
+	return "", errors.New(msgPrinter.Sprintf("local image %v has no digest for its repository, push it to or pull it from the repository first", imageName))
+}
+
 // Get the image digest so that it can be set into the published service definition. The digest will be in
 // the stdout from the docker pull/push that was done previously, or it can be retrieved from the image itself.
 func retrieveDigest(client *dockerclient.Client, buf bytes.Buffer, repository string, imageName string) (digest string) {
@@ -2157,7 +2179,7 @@ func getString(v interface{}) string {
 
 // This function is used in the service publish command to pull the docker image.
 // It  the image name with the digest.
-func GetNewDockerImageName(image string, dontTouchImage bool, pullImage bool) string {
+func GetNewDockerImageName(image string, dontTouchImage bool, pullImage bool, localDigest bool) string {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -2178,6 +2200,10 @@ func GetNewDockerImageName(image string, dontTouchImage bool, pullImage bool) st
 				if digest, err = PullDockerImage(client, domain, path, tag); err != nil {
 					Fatal(CLI_GENERAL_ERROR, msgPrinter.Sprintf("Docker pull failure: %v", err))
 				}
+			} else if localDigest {
+				if digest, err = LocalDockerImageDigest(client, domain, path, tag); err != nil {
+					Fatal(CLI_GENERAL_ERROR, err.Error())
+				}
 			} else {
 				digest = PushDockerImage(client, domain, path, tag) // this will error out if the push fails or can't get the digest
 			}
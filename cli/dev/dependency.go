@@ -278,7 +278,7 @@ func ValidateDependencies(directory string, userInputs *common.UserInputFile, us
 				} else if vRange, err := semanticversion.Version_Expression_Factory(rs.VersionRange); err != nil {
 					return errors.New(msgPrinter.Sprintf("dependency validation failed, dependency %v has an invalid version %v, error: %v", fileInfo.Name(), rs.Version, err))
 				} else if inRange, err := vRange.Is_within_range(dDef.Version); err != nil {
-					return errors.New(msgPrinter.Sprintf("dependency validation failed, unable to verify version range %v is within required range %v, error: %v", dDef.Version, vRange.Get_expression(), err))
+					return errors.New(msgPrinter.Sprintf("dependency validation failed, unable to verify version range %v is within required range %v, error: %v", dDef.Version, vRange.Get_shorthand_expression(), err))
 				} else if inRange {
 					found = true
 					break
@@ -1,11 +1,14 @@
 package eventlog
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
+	"golang.org/x/text/language"
 	"net/http"
 	"regexp"
 	"strings"
@@ -106,6 +109,40 @@ func Prune(force bool) {
 	}
 }
 
+// Display the event log retention policy of the agent and the current size of the event log.
+func Retention() {
+	var retention api.EventLogRetentionOutput
+	cliutils.HorizonGet("eventlog/retention", []int{200}, &retention, false)
+
+	jsonBytes, err := cliutils.DisplayAsJson(retention)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog retention' output: %v", err))
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
+
+// Remove the event logs that exceed the retention policy now and request compaction of the agent database, which is
+// done when the agent is restarted.
+func Compact(force bool) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if !force {
+		cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to remove the event logs that exceed the retention policy and compact the agent database the next time the agent is restarted?"))
+	}
+
+	_, resp, _ := cliutils.HorizonPutPost(http.MethodPost, "eventlog/retention/compact", []int{200}, nil, true)
+
+	var retention api.EventLogRetentionOutput
+	if err := json.Unmarshal([]byte(resp), &retention); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal 'hzn eventlog compact' output: %v", err))
+	}
+
+	fmt.Println(msgPrinter.Sprintf("Removed %v event log entries that exceed the retention policy, %v entries remain.", retention.Removed, retention.Stats.Count))
+	if retention.RestartRequired {
+		fmt.Println(msgPrinter.Sprintf("The agent database is not compacted until the agent is restarted. Restart the agent to reclaim %v bytes.", retention.Stats.DBFreeSize))
+	}
+}
+
 func List(all bool, detail bool, selections []string, tailing bool) {
 
 	// format the eventlog api string
@@ -128,41 +165,7 @@ func List(all bool, detail bool, selections []string, tailing bool) {
 		cliutils.HorizonGet(url_s, []int{200}, &apiOutput, false)
 
 		//output
-
-		if detail {
-			long_output := make([]EventLog, len(apiOutput))
-			for i, v := range apiOutput {
-				long_output[i].Id = v.Id
-				long_output[i].Timestamp = cliutils.ConvertTime(v.Timestamp)
-				long_output[i].Severity = v.Severity
-				long_output[i].Message = v.Message
-				long_output[i].EventCode = v.EventCode
-				long_output[i].SourceType = v.SourceType
-				long_output[i].Source = v.Source
-			}
-
-			jsonBytes, err := cliutils.DisplayAsJson(long_output)
-			if err != nil {
-				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog list' output: %v", err))
-			}
-			if len(jsonBytes) > 3 {
-				fmt.Printf("%s", jsonBytes[2:len(jsonBytes)-2])
-			}
-		} else {
-			short_output := make([]string, len(apiOutput))
-			for i, v := range apiOutput {
-				t := time.Unix(int64(v.Timestamp), 0)
-				short_output[i] = fmt.Sprintf("%v:   %v", t.Format("2006-01-02 15:04:05"), v.Message)
-			}
-			jsonBytes, err := cliutils.DisplayAsJson(short_output)
-			if err != nil {
-				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog list' output: %v", err))
-			}
-
-			if len(jsonBytes) > 3 {
-				fmt.Printf("%s", jsonBytes[2:len(jsonBytes)-2])
-			}
-		}
+		displayEventLogs(apiOutput, detail)
 
 		if tailing {
 			// selection contraints for most recent records
@@ -193,6 +196,142 @@ func List(all bool, detail bool, selections []string, tailing bool) {
 	}
 }
 
+// Display the event logs that match the selections, then stream new event logs from the agent as they are saved. If the
+// connection to the agent is lost, the stream is reopened from the last record that was displayed.
+func Follow(detail bool, selections []string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	url_s := "eventlog"
+	sel := ""
+	if len(selections) > 0 {
+		if s, err := getSelectionString(selections); err != nil {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", err)
+		} else {
+			sel = s
+			url_s = fmt.Sprintf("%v?%v", url_s, sel)
+		}
+	}
+
+	apiOutput := make([]persistence.EventLogRaw, 0)
+	cliutils.HorizonGet(url_s, []int{200}, &apiOutput, false)
+	displayEventLogs(apiOutput, detail)
+
+	lastId := ""
+	if len(apiOutput) > 0 {
+		lastId = apiOutput[len(apiOutput)-1].Id
+	}
+
+	url_s = fmt.Sprintf("%v/eventlog/stream", cliutils.GetHorizonUrlBase())
+	if sel != "" {
+		url_s = fmt.Sprintf("%v?%v", url_s, sel)
+	}
+
+	for {
+		var err error
+		if lastId, err = followStream(url_s, lastId, detail); err != nil {
+			cliutils.Verbose(msgPrinter.Sprintf("The event log stream ended, reconnecting: %v", err))
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// Read the server-sent events from the event log stream and display each event log. Returns the id of the last event log
+// displayed when the stream ends.
+func followStream(url_s string, lastId string, detail bool) (string, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	apiMsg := http.MethodGet + " " + url_s
+	cliutils.Verbose(apiMsg)
+
+	req, err := http.NewRequest(http.MethodGet, url_s, nil)
+	if err != nil {
+		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("%s new request failed: %v", apiMsg, err))
+	}
+	req.Header.Add("Accept", "text/event-stream")
+	if lastId != "" {
+		req.Header.Add("Last-Event-ID", lastId)
+	}
+	localeTag, err := i18n.GetLocale()
+	if err != nil {
+		localeTag = language.English
+	}
+	req.Header.Add("Accept-Language", localeTag.String())
+
+	// No timeout, the stream stays open until the agent ends it.
+	resp, err := cliutils.GetHTTPClient(0).Do(req)
+	if err != nil {
+		return lastId, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("The Horizon agent does not support event log streaming, use the --tail flag instead."))
+	} else if resp.StatusCode != http.StatusOK {
+		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code from %s: %d, %s", apiMsg, resp.StatusCode, cliutils.GetRespBodyAsString(resp.Body)))
+	}
+
+	// Each event is a group of "field: value" lines that ends with an empty line. Lines that start with a colon are comments.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	data := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		} else if line == "" && data != "" {
+			var el persistence.EventLogRaw
+			if err := json.Unmarshal([]byte(data), &el); err != nil {
+				cliutils.Warning(msgPrinter.Sprintf("Unable to unmarshal event log %v: %v", data, err))
+			} else {
+				displayEventLogs([]persistence.EventLogRaw{el}, detail)
+				lastId = el.Id
+			}
+			data = ""
+		}
+	}
+	return lastId, scanner.Err()
+}
+
+// Display the event logs, in full when detail is true, otherwise just the time and message of each one.
+func displayEventLogs(eventLogs []persistence.EventLogRaw, detail bool) {
+	if detail {
+		long_output := make([]EventLog, len(eventLogs))
+		for i, v := range eventLogs {
+			long_output[i].Id = v.Id
+			long_output[i].Timestamp = cliutils.ConvertTime(v.Timestamp)
+			long_output[i].Severity = v.Severity
+			long_output[i].Message = v.Message
+			long_output[i].EventCode = v.EventCode
+			long_output[i].SourceType = v.SourceType
+			long_output[i].Source = v.Source
+		}
+
+		jsonBytes, err := cliutils.DisplayAsJson(long_output)
+		if err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog list' output: %v", err))
+		}
+		if len(jsonBytes) > 3 {
+			fmt.Printf("%s", jsonBytes[2:len(jsonBytes)-2])
+		}
+	} else {
+		short_output := make([]string, len(eventLogs))
+		for i, v := range eventLogs {
+			t := time.Unix(int64(v.Timestamp), 0)
+			short_output[i] = fmt.Sprintf("%v:   %v", t.Format("2006-01-02 15:04:05"), v.Message)
+		}
+		jsonBytes, err := cliutils.DisplayAsJson(short_output)
+		if err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog list' output: %v", err))
+		}
+
+		if len(jsonBytes) > 3 {
+			fmt.Printf("%s", jsonBytes[2:len(jsonBytes)-2])
+		}
+	}
+}
+
 func ListSurfaced(long bool) {
 	apiOutput := make([]persistence.SurfaceError, 0)
 	cliutils.HorizonGet("eventlog/surface", []int{200}, &apiOutput, false)
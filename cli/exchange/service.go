@@ -15,6 +15,7 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangecommon"
 	"github.com/open-horizon/anax/externalpolicy"
+	_ "github.com/open-horizon/anax/externalpolicy/json_language"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
@@ -129,7 +130,7 @@ func ServiceList(credOrg, userPw, service string, namesOnly bool, filePath strin
 }
 
 // ServicePublish signs the MS def and puts it in the exchange
-func ServicePublish(org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath string, dontTouchImage bool, pullImage bool, registryTokens []string, overwrite bool, servicePolicyFilePath string, public string) {
+func ServicePublish(org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath string, dontTouchImage bool, pullImage bool, localDigest bool, registryTokens []string, overwrite bool, servicePolicyFilePath string, public string) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -138,6 +139,8 @@ func ServicePublish(org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath strin
 	}
 	if dontTouchImage && pullImage {
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Flags -I and -P are mutually exclusive."))
+	} else if localDigest && (dontTouchImage || pullImage) {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Flag --local-digest cannot be specified with -I or -P."))
 	}
 	cliutils.SetWhetherUsingApiKey(userPw)
 
@@ -174,7 +177,7 @@ func ServicePublish(org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath strin
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Error validating the input service: %v", err))
 	}
 
-	SignAndPublish(&svcFile, org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath, dontTouchImage, pullImage, registryTokens, !overwrite)
+	SignAndPublish(&svcFile, org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath, dontTouchImage, pullImage, localDigest, registryTokens, !overwrite)
 
 	// create service policy if servicePolicyFilePath is defined
 	if servicePolicyFilePath != "" {
@@ -188,7 +191,7 @@ func ServicePublish(org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath strin
 }
 
 // Sign and publish the service definition. This is a function that is reusable across different hzn commands.
-func SignAndPublish(sf *common.ServiceFile, org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath string, dontTouchImage bool, pullImage bool, registryTokens []string, promptForOverwrite bool) {
+func SignAndPublish(sf *common.ServiceFile, org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath string, dontTouchImage bool, pullImage bool, localDigest bool, registryTokens []string, promptForOverwrite bool) {
 
 	//check for ExchangeUrl early on
 	var exchUrl = cliutils.GetExchangeUrl()
@@ -203,8 +206,8 @@ func SignAndPublish(sf *common.ServiceFile, org, userPw, jsonFilePath, keyFilePa
 	var usedPubKeyBytes_cluster []byte
 	usedPubKeyName := ""
 	usedPubKeyName_cluster := ""
-	svcInput.Deployment, svcInput.DeploymentSignature, usedPubKeyBytes, usedPubKeyName = SignDeployment(sf.Deployment, sf.DeploymentSignature, baseDir, false, keyFilePath, pubKeyFilePath, dontTouchImage, pullImage)
-	svcInput.ClusterDeployment, svcInput.ClusterDeploymentSignature, usedPubKeyBytes_cluster, usedPubKeyName_cluster = SignDeployment(sf.ClusterDeployment, sf.ClusterDeploymentSignature, baseDir, true, keyFilePath, pubKeyFilePath, dontTouchImage, pullImage)
+	svcInput.Deployment, svcInput.DeploymentSignature, usedPubKeyBytes, usedPubKeyName = SignDeployment(sf.Deployment, sf.DeploymentSignature, baseDir, false, keyFilePath, pubKeyFilePath, dontTouchImage, pullImage, localDigest)
+	svcInput.ClusterDeployment, svcInput.ClusterDeploymentSignature, usedPubKeyBytes_cluster, usedPubKeyName_cluster = SignDeployment(sf.ClusterDeployment, sf.ClusterDeploymentSignature, baseDir, true, keyFilePath, pubKeyFilePath, dontTouchImage, pullImage, localDigest)
 
 	// Create or update resource in the exchange
 	exchId := cutil.FormExchangeIdForService(svcInput.URL, svcInput.Version, svcInput.Arch)
@@ -298,7 +301,7 @@ func SignAndPublish(sf *common.ServiceFile, org, userPw, jsonFilePath, keyFilePa
 
 // The function signs the given deployment if it is not empty abd not already signed. It returns the deployment, its signature
 // and the public key whose matching private was used for signing the deployment.
-func SignDeployment(deployment interface{}, deploymentSignature string, baseDir string, isCluster bool, keyFilePath string, pubKeyFilePath string, dontTouchImage bool, pullImage bool) (string, string, []byte, string) {
+func SignDeployment(deployment interface{}, deploymentSignature string, baseDir string, isCluster bool, keyFilePath string, pubKeyFilePath string, dontTouchImage bool, pullImage bool, localDigest bool) (string, string, []byte, string) {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -335,6 +338,7 @@ func SignDeployment(deployment interface{}, deploymentSignature string, baseDir
 		ctx.Add("currentDir", baseDir)
 		ctx.Add("dontTouchImage", dontTouchImage)
 		ctx.Add("pullImage", pullImage)
+		ctx.Add("localDigest", localDigest)
 
 		// Allow the right plugin to sign the deployment configuration.
 		depStr, sig, err := plugin_registry.DeploymentConfigPlugins.SignByOne(dep, newPrivKeyToStore, ctx)
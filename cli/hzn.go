@@ -155,6 +155,9 @@ Environment Variables:
 	agbotCacheServedOrg := agbotCacheCmd.Command("servedorg | sorg", msgPrinter.Sprintf("List served pattern orgs and deployment policy orgs.")).Alias("sorg").Alias("servedorg")
 	agbotCacheServedOrgList := agbotCacheServedOrg.Command("list | ls", msgPrinter.Sprintf("Display served pattern orgs and deployment policy orgs.")).Alias("ls").Alias("list")
 
+	agbotExplainCmd := agbotCmd.Command("explain", msgPrinter.Sprintf("Explain why an edge node does or does not have an agreement for a deployment policy or pattern. The explanation includes the last node search, the compatibility check, the last agreement the node rejected, the workload usage retries and the upgrades that are held back."))
+	agbotExplainNode := agbotExplainCmd.Flag("node", msgPrinter.Sprintf("The edge node, in the form org/id.")).Short('n').Required().String()
+	agbotExplainPolicy := agbotExplainCmd.Flag("policy", msgPrinter.Sprintf("The deployment policy or pattern, in the form org/name.")).Short('p').Required().String()
 	agbotListCmd := agbotCmd.Command("list | ls", msgPrinter.Sprintf("Display general information about this Horizon agbot node.")).Alias("ls").Alias("list")
 	agbotPolicyCmd := agbotCmd.Command("policy | pol", msgPrinter.Sprintf("List the policies this Horizon agreement bot hosts.")).Alias("pol").Alias("policy")
 	agbotPolicyListCmd := agbotPolicyCmd.Command("list | ls", msgPrinter.Sprintf("List policies this Horizon agreement bot hosts.")).Alias("ls").Alias("list")
@@ -284,6 +287,7 @@ Environment Variables:
 	eventlogCmd := app.Command("eventlog | ev", msgPrinter.Sprintf("List the event logs for the current or all registrations.")).Alias("ev").Alias("eventlog")
 	eventlogListCmd := eventlogCmd.Command("list | ls", msgPrinter.Sprintf("List the event logs for the current or all registrations.")).Alias("ls").Alias("list")
 	listTail := eventlogListCmd.Flag("tail", msgPrinter.Sprintf("Continuously polls the event log to display the most recent records, similar to tail -F behavior.")).Short('f').Bool()
+	listFollow := eventlogListCmd.Flag("follow", msgPrinter.Sprintf("Display new event log records as the agent saves them, without polling. The records are streamed from the agent until the command is interrupted.")).Bool()
 	listAllEventlogs := eventlogListCmd.Flag("all", msgPrinter.Sprintf("List all the event logs including the previous registrations.")).Short('a').Bool()
 	listDetailedEventlogs := eventlogListCmd.Flag("long", msgPrinter.Sprintf("List event logs with details.")).Short('l').Bool()
 	listSelectedEventlogs := eventlogListCmd.Flag("select", msgPrinter.Sprintf("Selection string. This flag can be repeated which means 'AND'. Each flag should be in the format of attribute=value, attribute~value, \"attribute>value\" or \"attribute<value\", where '~' means contains. The common attribute names are timestamp, time_since (unit is hours), severity, message, event_code, source_type, agreement_id, service_url etc. Use the '-l' flag to see all the attribute names.")).Short('s').Strings()
@@ -292,6 +296,9 @@ Environment Variables:
 	deleteEventLogsForce := eventlogDeleteCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	eventlogPruneCmd := eventlogCmd.Command("prune | pr", msgPrinter.Sprintf("Delete the all event logs from previous registrations.")).Alias("pr").Alias("prune")
 	pruneEventLogsForce := eventlogPruneCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
+	eventlogRetentionCmd := eventlogCmd.Command("retention | ret", msgPrinter.Sprintf("Display the event log retention policy of the agent and the current size of the event log.")).Alias("ret").Alias("retention")
+	eventlogCompactCmd := eventlogCmd.Command("compact", msgPrinter.Sprintf("Delete the event logs that exceed the retention policy now, and compact the agent database the next time the agent is restarted."))
+	compactEventLogsForce := eventlogCompactCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	surfaceErrorsEventlogs := eventlogCmd.Command("surface | sf", msgPrinter.Sprintf("List all the active errors that will be shared with the Exchange if the node is online.")).Alias("sf").Alias("surface")
 	surfaceErrorsEventlogsLong := surfaceErrorsEventlogs.Flag("long", msgPrinter.Sprintf("List the full event logs of the surface errors.")).Short('l').Bool()
 
@@ -537,6 +544,7 @@ Environment Variables:
 	exSvcPubPubKeyFile := exServicePublishCmd.Flag("public-key-file", msgPrinter.Sprintf("(DEPRECATED) The path of public key file (that corresponds to the private key) that should be stored with the service, to be used by the Horizon Agent to verify the signature. If this flag is not specified, the public key will be calculated from the private key.")).Short('K').ExistingFile()
 	exSvcPubDontTouchImage := exServicePublishCmd.Flag("dont-change-image-tag", msgPrinter.Sprintf("The image paths in the deployment field have regular tags and should not be changed to sha256 digest values. The image will not get automatically uploaded to the repository. This should only be used during development when testing new versions often.")).Short('I').Bool()
 	exSvcPubPullImage := exServicePublishCmd.Flag("pull-image", msgPrinter.Sprintf("Use the image from the image repository. It will pull the image from the image repository and overwrite the local image if exists. This flag is mutually exclusive with -I.")).Short('P').Bool()
+	exSvcPubLocalDigest := exServicePublishCmd.Flag("local-digest", msgPrinter.Sprintf("Pin the images in the deployment field to the digests of the images in the local image store, instead of pushing the images to the image repository. The local images must have been pushed to or pulled from the image repository. This flag is mutually exclusive with -I and -P.")).Bool()
 	exSvcRegistryTokens := exServicePublishCmd.Flag("registry-token", msgPrinter.Sprintf("Docker registry domain and auth that should be stored with the service, to enable the Horizon edge node to access the service's docker images. This flag can be repeated, and each flag should be in the format: registry:user:token")).Short('r').Strings()
 	exSvcOverwrite := exServicePublishCmd.Flag("overwrite", msgPrinter.Sprintf("Overwrite the existing version if the service exists in the Exchange. It will skip the 'do you want to overwrite' prompt.")).Short('O').Bool()
 	exSvcPolicyFile := exServicePublishCmd.Flag("service-policy-file", msgPrinter.Sprintf("The path of the service policy JSON file to be used for the service to be published. This flag is optional")).Short('p').String()
@@ -687,6 +695,10 @@ Environment Variables:
 
 	nodeCmd := app.Command("node", msgPrinter.Sprintf("List and manage general information about this Horizon edge node."))
 	nodeListCmd := nodeCmd.Command("list | ls", msgPrinter.Sprintf("Display general information about this Horizon edge node.")).Alias("list").Alias("ls")
+	nodeDiagnosticsCmd := nodeCmd.Command("diagnostics | diag", msgPrinter.Sprintf("Collect a diagnostic bundle of this Horizon edge node. The bundle is a gzipped tar archive with the node status, agreements, services, event logs, agent worker status, agent config without credentials, and the inspect output and recent logs of the service containers.")).Alias("diag").Alias("diagnostics")
+	nodeDiagnosticsFile := nodeDiagnosticsCmd.Flag("file", msgPrinter.Sprintf("The file that the diagnostic bundle is written to. If omitted, the bundle is saved in the current directory in a file named diagnostics-<time>.tar.gz. Mutually exclusive with --upload.")).Short('f').String()
+	nodeDiagnosticsUpload := nodeDiagnosticsCmd.Flag("upload", msgPrinter.Sprintf("Upload the diagnostic bundle to the Model Management Service in the organization of the node, with the object type agent_diagnostics, instead of saving it in a file. The node must be registered.")).Bool()
+	nodeDiagnosticsLogLines := nodeDiagnosticsCmd.Flag("log-lines", msgPrinter.Sprintf("The number of lines from the end of the logs of each service container that are added to the bundle. Use 0 to leave out the logs.")).Default("1000").Int()
 
 	nodeManagementCmd := app.Command("nodemanagement | nm", msgPrinter.Sprintf("List and manage manifests and agent files for node management.")).Alias("nm").Alias("nodemanagement")
 	nmOrg := nodeManagementCmd.Flag("org", msgPrinter.Sprintf("The Horizon organization ID. If not specified, HZN_ORG_ID will be used as a default.")).Short('o').String()
@@ -1240,7 +1252,7 @@ Environment Variables:
 	case exServiceListCmd.FullCommand():
 		exchange.ServiceList(*exOrg, credToUse, *exService, !*exServiceLong, *exSvcOpYamlFilePath, *exSvcOpYamlForce)
 	case exServicePublishCmd.FullCommand():
-		exchange.ServicePublish(*exOrg, *exUserPw, *exSvcJsonFile, *exSvcPrivKeyFile, *exSvcPubPubKeyFile, *exSvcPubDontTouchImage, *exSvcPubPullImage, *exSvcRegistryTokens, *exSvcOverwrite, *exSvcPolicyFile, *exSvcPublic)
+		exchange.ServicePublish(*exOrg, *exUserPw, *exSvcJsonFile, *exSvcPrivKeyFile, *exSvcPubPubKeyFile, *exSvcPubDontTouchImage, *exSvcPubPullImage, *exSvcPubLocalDigest, *exSvcRegistryTokens, *exSvcOverwrite, *exSvcPolicyFile, *exSvcPublic)
 	case exServiceVerifyCmd.FullCommand():
 		exchange.ServiceVerify(*exOrg, credToUse, *exVerService, *exSvcPubKeyFile)
 	case exSvcDelCmd.FullCommand():
@@ -1291,6 +1303,8 @@ Environment Variables:
 		key.Remove(*keyDelName)
 	case nodeListCmd.FullCommand():
 		node.List()
+	case nodeDiagnosticsCmd.FullCommand():
+		node.Diagnostics(*nodeDiagnosticsFile, *nodeDiagnosticsUpload, *nodeDiagnosticsLogLines)
 	case policyListCmd.FullCommand():
 		policy.List()
 	case policyNewCmd.FullCommand():
@@ -1344,11 +1358,22 @@ Environment Variables:
 	case statusCmd.FullCommand():
 		status.DisplayStatus(*statusLong, false)
 	case eventlogListCmd.FullCommand():
-		eventlog.List(*listAllEventlogs, *listDetailedEventlogs, *listSelectedEventlogs, *listTail)
+		if *listFollow {
+			if *listTail || *listAllEventlogs {
+				cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The --follow flag cannot be used with the --tail or --all flags."))
+			}
+			eventlog.Follow(*listDetailedEventlogs, *listSelectedEventlogs)
+		} else {
+			eventlog.List(*listAllEventlogs, *listDetailedEventlogs, *listSelectedEventlogs, *listTail)
+		}
 	case eventlogDeleteCmd.FullCommand():
 		eventlog.Delete(*deleteSelectedEventlogs, *deleteEventLogsForce)
 	case eventlogPruneCmd.FullCommand():
 		eventlog.Prune(*pruneEventLogsForce)
+	case eventlogRetentionCmd.FullCommand():
+		eventlog.Retention()
+	case eventlogCompactCmd.FullCommand():
+		eventlog.Compact(*compactEventLogsForce)
 	case surfaceErrorsEventlogs.FullCommand():
 		eventlog.ListSurfaced(*surfaceErrorsEventlogsLong)
 	case devServiceNewCmd.FullCommand():
@@ -1373,6 +1398,8 @@ Environment Variables:
 		agreementbot.AgreementCancel(*agbotCancelAgreementId, *agbotCancelAllAgreements)
 	case agbotListCmd.FullCommand():
 		agreementbot.List()
+	case agbotExplainCmd.FullCommand():
+		agreementbot.Explain(*agbotExplainNode, *agbotExplainPolicy)
 	case agbotPolicyListCmd.FullCommand():
 		agreementbot.PolicyList(*agbotPolicyOrg, *agbotPolicyName)
 	case utilSignCmd.FullCommand():
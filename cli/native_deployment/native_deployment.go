@@ -3,6 +3,7 @@ package native_deployment
 import (
 	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	dockerclient "github.com/fsouza/go-dockerclient"
@@ -40,7 +41,7 @@ func (p *NativeDeploymentConfigPlugin) Sign(dep map[string]interface{}, privKey
 
 	// Since the deployment config has been validated as ours, we can assume it is structured correctly.
 	services := dep["services"].(map[string]interface{})
-	var dontTouchImage, pullImage, ok bool
+	var dontTouchImage, pullImage, localDigest, ok bool
 	dontTouchImage, ok = (ctx.Get("dontTouchImage")).(bool)
 	if !ok {
 		dontTouchImage = false
@@ -49,12 +50,16 @@ func (p *NativeDeploymentConfigPlugin) Sign(dep map[string]interface{}, privKey
 	if !ok {
 		pullImage = false
 	}
+	localDigest, ok = (ctx.Get("localDigest")).(bool)
+	if !ok {
+		localDigest = false
+	}
 
 	for _, svc := range services {
 		service := svc.(map[string]interface{})
 		image := service["image"].(string)
 
-		newImage := cliutils.GetNewDockerImageName(image, dontTouchImage, pullImage)
+		newImage := cliutils.GetNewDockerImageName(image, dontTouchImage, pullImage, localDigest)
 		if newImage != image {
 			msgPrinter.Printf("Using '%s' in 'deployment' field instead of '%s'", newImage, image)
 			msgPrinter.Println()
@@ -173,7 +178,7 @@ func (p *NativeDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{
 }
 
 // This can't be a const because a map literal isn't a const in go
-var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1, "tmpfs": 1, "network": 1, "entrypoint": 1, "max_memory_mb": 1, "max_cpus": 1, "log_driver": 1, "secrets": 1, "pid": 1, "user": 1, "sysctls": 1, "ipc": 1}
+var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1, "tmpfs": 1, "network": 1, "entrypoint": 1, "max_memory_mb": 1, "max_cpus": 1, "log_driver": 1, "secrets": 1, "pid": 1, "user": 1, "sysctls": 1, "ipc": 1, "healthcheck": 1, "restart_policy": 1, "ulimits": 1, "pids_limit": 1, "cap_drop": 1, "read_only": 1, "shm_size_mb": 1, "memory_reservation_mb": 1, "max_memory_swap_mb": 1, "image_signature": 1}
 
 // CheckDeploymentService verifies it has the required 'image' key, and checks for keys we don't recognize.
 // For now it only prints a warning for unrecognized keys, in case we recently added a key to anax and haven't updated hzn yet.
@@ -192,6 +197,18 @@ func CheckDeploymentService(svcName string, depSvc map[string]interface{}) error
 			cliutils.Warning(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has unrecognized field '%s'. See https://github.com/open-horizon/anax/blob/master/doc/deployment_string.md", svcName, k))
 		}
 
+		// Check that the health check can be passed to the container runtime.
+		if k == "healthcheck" {
+			var hc containermessage.HealthCheck
+			if bytes, err := json.Marshal(depSvc[k]); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed healthcheck value %v, error %v", svcName, depSvc[k], err))
+			} else if err := json.Unmarshal(bytes, &hc); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed healthcheck value %v, error %v", svcName, string(bytes), err))
+			} else if _, err := hc.DockerHealthConfig(); err != nil {
+				return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid healthcheck, error %v", svcName, err))
+			}
+		}
+
 		// Check for the use of the default agent API port, which will cause a port conflict at runtime.
 		if k == "ports" {
 			// Marshal and unmarshal the ports deployment config so that we can reuse typed APIs for parsing the host port
@@ -211,6 +228,27 @@ func CheckDeploymentService(svcName string, depSvc map[string]interface{}) error
 			}
 		}
 	}
+
+	// Check that the restart policy and the resource limits can be passed to the container runtime.
+	var svc containermessage.Service
+	if bytes, err := json.Marshal(depSvc); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' is malformed, error %v", svcName, err))
+	} else if err := json.Unmarshal(bytes, &svc); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' is malformed, error %v", svcName, err))
+	} else if err := svc.SetHostConfigLimits(&dockerclient.HostConfig{Memory: svc.MaxMemoryMb * 1024 * 1024}); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has invalid resource limits, error %v", svcName, err))
+	}
+
+	// Check that the image signature is encoded the way the agent expects, the signature itself is verified by the agent.
+	if svc.ImageSignature != nil {
+		if svc.ImageSignature.Payload == "" || svc.ImageSignature.Signature == "" {
+			return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an image_signature without a payload or signature", svcName))
+		} else if _, err := base64.StdEncoding.DecodeString(svc.ImageSignature.Payload); err != nil {
+			return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an image_signature payload that is not base64 encoded, error %v", svcName, err))
+		} else if _, err := base64.StdEncoding.DecodeString(svc.ImageSignature.Signature); err != nil {
+			return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an image_signature signature that is not base64 encoded, error %v", svcName, err))
+		}
+	}
 	return nil
 }
 
@@ -9,7 +9,10 @@ import (
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/version"
+	"net/http"
+	"os"
 	"strings"
+	"time"
 )
 
 type Configstate struct {
@@ -139,3 +142,45 @@ func Env(org, userPw, exchUrl, cssUrl, agbotUrl string) {
 	msgPrinter.Printf("HZN_AGBOT_URL: %s", agbotUrl)
 	msgPrinter.Println()
 }
+
+// Collect the diagnostic bundle of the node from the agent. The bundle is saved in a file, or uploaded to the MMS so that it
+// can be downloaded from the management hub without access to the node.
+func Diagnostics(outputFile string, upload bool, logLines int) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if upload && outputFile != "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("-f and --upload are mutually exclusive."))
+	} else if logLines < 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("--log-lines must not be negative."))
+	}
+
+	url_s := fmt.Sprintf("node/diagnostics?log_lines=%v", logLines)
+
+	if upload {
+		_, resp, _ := cliutils.HorizonPutPost(http.MethodPost, url_s, []int{200}, "", true)
+
+		out := api.DiagnosticsUpload{}
+		if err := json.Unmarshal([]byte(resp), &out); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal the response %v: %v", resp, err))
+		}
+		for name, err := range out.Manifest.Errors {
+			cliutils.Warning(msgPrinter.Sprintf("%v was not collected: %v", name, err))
+		}
+		msgPrinter.Printf("The diagnostic bundle of node %v/%v was uploaded to the Model Management Service as object type %v, id %v. Download it with 'hzn mms object download -t %v -i %v'.", out.Org, out.Manifest.NodeId, out.ObjectType, out.ObjectId, out.ObjectType, out.ObjectId)
+		msgPrinter.Println()
+		return
+	}
+
+	var bundle string
+	cliutils.HorizonGet(url_s, []int{200}, &bundle, false)
+
+	if outputFile == "" {
+		outputFile = fmt.Sprintf("diagnostics-%v.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	if err := os.WriteFile(outputFile, []byte(bundle), 0600); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("failed to write the diagnostic bundle to %v: %v", outputFile, err))
+	}
+	msgPrinter.Printf("The diagnostic bundle was saved in %v.", outputFile)
+	msgPrinter.Println()
+}
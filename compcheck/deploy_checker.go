@@ -0,0 +1,263 @@
+package compcheck
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"golang.org/x/text/message"
+	"strings"
+)
+
+// A DeployChecker runs the deployment compatibility check for many nodes against the same deployment policies. The nodes,
+// node policies, service definitions, service policies, deployment policies and patterns that it reads from the exchange
+// are kept for the life of the checker, so each of them is read once no matter how many checks use it. The secrets are
+// checked every time. A DeployChecker is meant for a single API call and is not safe for concurrent use.
+type DeployChecker struct {
+	getDeviceHandler          exchange.DeviceHandler
+	nodePolicyHandler         exchange.NodePolicyHandler
+	getBusinessPolicies       exchange.BusinessPoliciesHandler
+	getPatterns               exchange.PatternHandler
+	servicePolicyHandler      exchange.ServicePolicyHandler
+	getServiceHandler         exchange.ServiceHandler
+	serviceDefResolverHandler exchange.ServiceDefResolverHandler
+	getSelectedServices       exchange.SelectedServicesHandler
+	vaultSecretExists         exchange.VaultSecretExistsHandler
+}
+
+func NewDeployChecker(ec exchange.ExchangeContext) *DeployChecker {
+	return newDeployChecker(exchange.GetHTTPDeviceHandler(ec),
+		exchange.GetHTTPNodePolicyHandler(ec),
+		exchange.GetHTTPBusinessPoliciesHandler(ec),
+		exchange.GetHTTPExchangePatternHandler(ec),
+		exchange.GetHTTPServicePolicyHandler(ec),
+		exchange.GetHTTPServiceHandler(ec),
+		exchange.GetHTTPServiceDefResolverHandler(ec),
+		exchange.GetHTTPSelectedServicesHandler(ec),
+		exchange.GetHTTPVaultSecretExistsHandler(ec))
+}
+
+func newDeployChecker(getDeviceHandler exchange.DeviceHandler,
+	nodePolicyHandler exchange.NodePolicyHandler,
+	getBusinessPolicies exchange.BusinessPoliciesHandler,
+	getPatterns exchange.PatternHandler,
+	servicePolicyHandler exchange.ServicePolicyHandler,
+	getServiceHandler exchange.ServiceHandler,
+	serviceDefResolverHandler exchange.ServiceDefResolverHandler,
+	getSelectedServices exchange.SelectedServicesHandler,
+	vaultSecretExists exchange.VaultSecretExistsHandler) *DeployChecker {
+
+	return &DeployChecker{
+		getDeviceHandler:          cachedDeviceHandler(getDeviceHandler),
+		nodePolicyHandler:         cachedNodePolicyHandler(nodePolicyHandler),
+		getBusinessPolicies:       cachedBusinessPoliciesHandler(getBusinessPolicies),
+		getPatterns:               cachedPatternHandler(getPatterns),
+		servicePolicyHandler:      cachedServicePolicyHandler(servicePolicyHandler),
+		getServiceHandler:         cachedServiceHandler(getServiceHandler),
+		serviceDefResolverHandler: cachedServiceDefResolverHandler(serviceDefResolverHandler),
+		getSelectedServices:       cachedSelectedServicesHandler(getSelectedServices),
+		vaultSecretExists:         vaultSecretExists,
+	}
+}
+
+// The same check as DeployCompatible, with the exchange resources read by earlier checks.
+func (d *DeployChecker) DeployCompatible(agbotUrl string, ccInput *CompCheck, checkAllSvcs bool, msgPrinter *message.Printer) (*CompCheckOutput, error) {
+	return deployCompatible(d.getDeviceHandler, d.nodePolicyHandler, d.getBusinessPolicies, d.getPatterns, d.servicePolicyHandler, d.getServiceHandler, d.serviceDefResolverHandler, d.getSelectedServices, d.vaultSecretExists, agbotUrl, ccInput, checkAllSvcs, msgPrinter)
+}
+
+// The cache key of an exchange resource.
+func cacheKey(parts ...string) string {
+	return strings.Join(parts, "|")
+}
+
+// The cached results are copied before they are returned, so that a check cannot change the result seen by the next one.
+// Errors are not cached.
+
+func copyServiceDefs(defs map[string]exchange.ServiceDefinition) map[string]exchange.ServiceDefinition {
+	if defs == nil {
+		return nil
+	}
+	c := make(map[string]exchange.ServiceDefinition, len(defs))
+	for id, def := range defs {
+		c[id] = def
+	}
+	return c
+}
+
+func cachedDeviceHandler(h exchange.DeviceHandler) exchange.DeviceHandler {
+	cache := map[string]*exchange.Device{}
+	return func(id string, token string) (*exchange.Device, error) {
+		key := cacheKey(id, token)
+		dev, ok := cache[key]
+		if !ok {
+			var err error
+			if dev, err = h(id, token); err != nil {
+				return nil, err
+			}
+			cache[key] = dev
+		}
+		if dev == nil {
+			return nil, nil
+		}
+		c := *dev
+		return &c, nil
+	}
+}
+
+func cachedNodePolicyHandler(h exchange.NodePolicyHandler) exchange.NodePolicyHandler {
+	cache := map[string]*exchange.ExchangeNodePolicy{}
+	return func(deviceId string) (*exchange.ExchangeNodePolicy, error) {
+		pol, ok := cache[deviceId]
+		if !ok {
+			var err error
+			if pol, err = h(deviceId); err != nil {
+				return nil, err
+			}
+			cache[deviceId] = pol
+		}
+		if pol == nil {
+			return nil, nil
+		}
+		c := *pol
+		return &c, nil
+	}
+}
+
+func cachedBusinessPoliciesHandler(h exchange.BusinessPoliciesHandler) exchange.BusinessPoliciesHandler {
+	cache := map[string]map[string]exchange.ExchangeBusinessPolicy{}
+	return func(org string, policy_id string) (map[string]exchange.ExchangeBusinessPolicy, error) {
+		key := cacheKey(org, policy_id)
+		pols, ok := cache[key]
+		if !ok {
+			var err error
+			if pols, err = h(org, policy_id); err != nil {
+				return nil, err
+			}
+			cache[key] = pols
+		}
+		c := make(map[string]exchange.ExchangeBusinessPolicy, len(pols))
+		for id, pol := range pols {
+			c[id] = pol
+		}
+		return c, nil
+	}
+}
+
+func cachedPatternHandler(h exchange.PatternHandler) exchange.PatternHandler {
+	cache := map[string]map[string]exchange.Pattern{}
+	return func(org string, pattern string) (map[string]exchange.Pattern, error) {
+		key := cacheKey(org, pattern)
+		pats, ok := cache[key]
+		if !ok {
+			var err error
+			if pats, err = h(org, pattern); err != nil {
+				return nil, err
+			}
+			cache[key] = pats
+		}
+		c := make(map[string]exchange.Pattern, len(pats))
+		for id, pat := range pats {
+			c[id] = pat
+		}
+		return c, nil
+	}
+}
+
+func cachedServicePolicyHandler(h exchange.ServicePolicyHandler) exchange.ServicePolicyHandler {
+	type result struct {
+		pol *exchange.ExchangeServicePolicy
+		id  string
+	}
+	cache := map[string]result{}
+	return func(sUrl string, sOrg string, sVersion string, sArch string) (*exchange.ExchangeServicePolicy, string, error) {
+		key := cacheKey(sUrl, sOrg, sVersion, sArch)
+		res, ok := cache[key]
+		if !ok {
+			pol, id, err := h(sUrl, sOrg, sVersion, sArch)
+			if err != nil {
+				return nil, "", err
+			}
+			res = result{pol: pol, id: id}
+			cache[key] = res
+		}
+		if res.pol == nil {
+			return nil, res.id, nil
+		}
+		pol := *res.pol
+		return &pol, res.id, nil
+	}
+}
+
+func cachedServiceHandler(h exchange.ServiceHandler) exchange.ServiceHandler {
+	type result struct {
+		def *exchange.ServiceDefinition
+		id  string
+	}
+	cache := map[string]result{}
+	return func(wUrl string, wOrg string, wVersion string, wArch string) (*exchange.ServiceDefinition, string, error) {
+		key := cacheKey(wUrl, wOrg, wVersion, wArch)
+		res, ok := cache[key]
+		if !ok {
+			def, id, err := h(wUrl, wOrg, wVersion, wArch)
+			if err != nil {
+				return nil, "", err
+			}
+			res = result{def: def, id: id}
+			cache[key] = res
+		}
+		if res.def == nil {
+			return nil, res.id, nil
+		}
+		def := *res.def
+		return &def, res.id, nil
+	}
+}
+
+func cachedServiceDefResolverHandler(h exchange.ServiceDefResolverHandler) exchange.ServiceDefResolverHandler {
+	type result struct {
+		apiSpecs *policy.APISpecList
+		depDefs  map[string]exchange.ServiceDefinition
+		topDef   *exchange.ServiceDefinition
+		topId    string
+	}
+	cache := map[string]result{}
+	return func(wUrl string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, map[string]exchange.ServiceDefinition, *exchange.ServiceDefinition, string, error) {
+		key := cacheKey(wUrl, wOrg, wVersion, wArch)
+		res, ok := cache[key]
+		if !ok {
+			apiSpecs, depDefs, topDef, topId, err := h(wUrl, wOrg, wVersion, wArch)
+			if err != nil {
+				return nil, nil, nil, "", err
+			}
+			res = result{apiSpecs: apiSpecs, depDefs: depDefs, topDef: topDef, topId: topId}
+			cache[key] = res
+		}
+
+		var apiSpecs *policy.APISpecList
+		if res.apiSpecs != nil {
+			specs := make(policy.APISpecList, len(*res.apiSpecs))
+			copy(specs, *res.apiSpecs)
+			apiSpecs = &specs
+		}
+		var topDef *exchange.ServiceDefinition
+		if res.topDef != nil {
+			def := *res.topDef
+			topDef = &def
+		}
+		return apiSpecs, copyServiceDefs(res.depDefs), topDef, res.topId, nil
+	}
+}
+
+func cachedSelectedServicesHandler(h exchange.SelectedServicesHandler) exchange.SelectedServicesHandler {
+	cache := map[string]map[string]exchange.ServiceDefinition{}
+	return func(wUrl string, wOrg string, wVersion string, wArch string) (map[string]exchange.ServiceDefinition, error) {
+		key := cacheKey(wUrl, wOrg, wVersion, wArch)
+		defs, ok := cache[key]
+		if !ok {
+			var err error
+			if defs, err = h(wUrl, wOrg, wVersion, wArch); err != nil {
+				return nil, err
+			}
+			cache[key] = defs
+		}
+		return copyServiceDefs(defs), nil
+	}
+}
@@ -0,0 +1,75 @@
+//go:build unit
+// +build unit
+
+package compcheck
+
+import (
+	"errors"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+func Test_cachedServiceDefResolverHandler(t *testing.T) {
+
+	calls := 0
+	fail := true
+	handler := cachedServiceDefResolverHandler(func(wUrl string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, map[string]exchange.ServiceDefinition, *exchange.ServiceDefinition, string, error) {
+		calls += 1
+		if fail {
+			return nil, nil, nil, "", errors.New("exchange unavailable")
+		}
+		depDefs := map[string]exchange.ServiceDefinition{"myorg/dep_1.0.0_amd64": {URL: "dep"}}
+		return &policy.APISpecList{}, depDefs, &exchange.ServiceDefinition{URL: wUrl}, "myorg/" + wUrl, nil
+	})
+
+	// errors are not cached
+	if _, _, _, _, err := handler("svc1", "myorg", "1.0.0", "amd64"); err == nil {
+		t.Errorf("the error should be returned")
+	}
+	fail = false
+
+	for i := 0; i < 2; i++ {
+		if _, depDefs, topDef, topId, err := handler("svc1", "myorg", "1.0.0", "amd64"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if topDef.URL != "svc1" || topId != "myorg/svc1" || len(depDefs) != 1 {
+			t.Errorf("wrong service definitions %v %v %v", topDef, topId, depDefs)
+		} else {
+			// a change made by the caller is not seen by the next caller
+			topDef.URL = "changed"
+			delete(depDefs, "myorg/dep_1.0.0_amd64")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("the service should be read from the exchange twice, but was read %v times", calls)
+	}
+
+	if _, _, topDef, _, err := handler("svc2", "myorg", "1.0.0", "amd64"); err != nil || topDef.URL != "svc2" {
+		t.Errorf("wrong service definition %v, error: %v", topDef, err)
+	} else if calls != 3 {
+		t.Errorf("a different service should be read from the exchange, but it was read %v times", calls)
+	}
+}
+
+func Test_cachedServicePolicyHandler(t *testing.T) {
+
+	calls := 0
+	handler := cachedServicePolicyHandler(func(sUrl string, sOrg string, sVersion string, sArch string) (*exchange.ExchangeServicePolicy, string, error) {
+		calls += 1
+		if sUrl == "nopolicy" {
+			return nil, "", nil
+		}
+		return &exchange.ExchangeServicePolicy{}, "myorg/" + sUrl, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if pol, id, err := handler("svc1", "myorg", "1.0.0", "amd64"); err != nil || pol == nil || id != "myorg/svc1" {
+			t.Errorf("wrong service policy %v %v, error: %v", pol, id, err)
+		} else if pol, _, err := handler("nopolicy", "myorg", "1.0.0", "amd64"); err != nil || pol != nil {
+			t.Errorf("there should be no service policy, but got %v, error: %v", pol, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("each service policy should be read from the exchange once, but they were read %v times", calls)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangecommon"
 	"github.com/open-horizon/anax/externalpolicy"
+	_ "github.com/open-horizon/anax/externalpolicy/json_language"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/policy"
@@ -872,6 +873,34 @@ func Test_CheckPolicyCompatiblility(t *testing.T) {
 		t.Errorf("The producerPolicy should not have 3 properties but got %v", len(producerPolicy.Properties))
 	}
 
+	// not compatible, the reason explains the negated node constraint
+	extPol_Deploy_Not := createExternalPolicy(map[string]string{"prop4": "some value"}, []string{"NOT (prop2 == val2 AND prop1 exists)"})
+	_, intNPol2, err := GetNodePolicy(getNodePolicyHandler(*extPol, *extPol_Deploy_Not, *extPol_Manage), "myorg/mynode", msgPrinter)
+	if err != nil {
+		t.Errorf("GetNodePolicy should have returned nil error but got: %v", err)
+	}
+	if compatible, reason, _, _, err := CheckPolicyCompatiblility(intNPol2, intBPol, mergedSPol, "", msgPrinter); err != nil {
+		t.Errorf("CheckPolicyCompatiblility should have returned nil error but got: %v", err)
+	} else if compatible {
+		t.Errorf("CheckPolicyCompatiblility should have returned not compatible but not")
+	} else if !strings.Contains(reason, "satisfy the negated requirement NOT (prop2==val2 AND prop1 exists)") {
+		t.Errorf("The reason should explain the negated constraint but got: %v", reason)
+	}
+
+	// not compatible, the reason explains the JSON node constraint
+	extPol_Deploy_JSON := createExternalPolicy(map[string]string{"prop4": "some value"}, []string{`{"or": [{"property": "prop1", "op": "startswith", "value": "x"}, {"property": "prop2", "op": "not exists"}]}`})
+	_, intNPol3, err := GetNodePolicy(getNodePolicyHandler(*extPol, *extPol_Deploy_JSON, *extPol_Manage), "myorg/mynode", msgPrinter)
+	if err != nil {
+		t.Errorf("GetNodePolicy should have returned nil error but got: %v", err)
+	}
+	if compatible, reason, _, _, err := CheckPolicyCompatiblility(intNPol3, intBPol, mergedSPol, "", msgPrinter); err != nil {
+		t.Errorf("CheckPolicyCompatiblility should have returned nil error but got: %v", err)
+	} else if compatible {
+		t.Errorf("CheckPolicyCompatiblility should have returned not compatible but not")
+	} else if !strings.Contains(reason, "The required properties prop1 startswith x, prop2 not exists were not found") {
+		t.Errorf("The reason should explain the JSON constraint but got: %v", reason)
+	}
+
 	// error cases
 	if _, _, _, _, err := CheckPolicyCompatiblility(nil, intBPol, mergedSPol, "arm64", msgPrinter); err == nil {
 		t.Errorf("CheckPolicyCompatiblility should not have returned nil error")
@@ -76,6 +76,16 @@ type Config struct {
 	K8sCRInstallTimeoutS             int64     // The number of seconds to wait for the custom resouce to install successfully before it is considered a failure
 	SecretsManagerFilePath           string    // The filepath for the secrets manager to store secrets in the agent filesystem
 	NodeMgmtWorkDirectory            string    // The filepath for the node management policy updates to use
+	AdmissionPolicyFile              string    // The node-local policy file that restricts what service containers can ask for. No restrictions by default
+	VerifyImageSignatures            bool      // Refuse to start services whose images do not have a valid image signature. The default is false
+	RequireImageDigests              bool      // Refuse to start services whose images are not pinned by digest. The default is false
+
+	EventLogRetention EventLogRetentionConfig // The limits on the event log records kept in the agent's database
+	EventSinks        []EventSinkConfig       // The external collectors that the agent's event logs are forwarded to
+	ImageLifecycle    ImageLifecycleConfig    // The removal of unused service images and the pre-loading of images on the node
+	RegistryMirrors   []RegistryMirrorConfig  // The mirrors that service images are pulled from before their own registry
+	ImagePull         ImagePullConfig         // The concurrency, retries and stall detection of service image pulls
+	DynamicProperties []DynamicPropertyConfig // The node properties that are computed on the node and pushed to the node policy
 
 	// these Ids could be provided in config or discovered after startup by the system
 	BlockchainAccountId        string
@@ -87,55 +97,57 @@ type AGConfig struct {
 	TxLostDelayTolerationSeconds  int
 	AgreementWorkers              int
 	DBPath                        string
-	Postgresql                    PostgresqlConfig // The Postgresql config if it is being used
-	PartitionStale                uint64           // Number of seconds to wait before declaring a partition to be stale (i.e. the previous owner has unexpectedly terminated).
-	ProtocolTimeoutS              uint64           // Number of seconds to wait before declaring proposal response is lost
-	AgreementTimeoutS             uint64           // Number of seconds to wait before declaring agreement not finalized in blockchain
-	ProtocolTimeoutScaleFactor    float64          // Time to wait before declaring a proposal response is lost. Expressed as a scaling factor of the max heartbeat interval for a given node
-	AgreementTimeoutScaleFactor   float64          // Time to wait before declaring an agreement did not finalize. Expressed as a scaling factor of the max heartbeat interval for a given node
-	NoDataIntervalS               uint64           // default should be 15 mins == 15*60 == 900. Ignored if the policy has data verification disabled.
-	ActiveAgreementsURL           string           // This field is used when policy files indicate they want data verification but they dont specify a URL
-	ActiveAgreementsUser          string           // This is the userid the agbot uses to authenticate to the data verifivcation API
-	ActiveAgreementsPW            string           // This is the password for the ActiveAgreementsUser
-	PolicyPath                    string           // The directory where policy files are kept, default /etc/provider-tremor/policy/
-	NewContractIntervalS          uint64           // default should be 1
-	ProcessGovernanceIntervalS    uint64           // How long the gov sleeps before general gov checks (new payloads, interval payments, etc).
-	IgnoreContractWithAttribs     string           // A comma seperated list of contract attributes. If set, the contracts that contain one or more of the attributes will be ignored. The default is "ethereum_account".
-	ExchangeURL                   string           // The URL of the Horizon exchange. If not configured, the exchange will not be used.
-	ExchangeHeartbeat             int              // Seconds between heartbeats to the exchange
-	ExchangeId                    string           // The id of the agbot, not the userid of the exchange user. Must be org qualified.
-	ExchangeToken                 string           // The agbot's authentication token
-	DVPrefix                      string           // When looking for agreement ids in the data verification API response, look for agreement ids with this prefix.
-	ActiveDeviceTimeoutS          int              // The amount of time a device can go without heartbeating and still be considered active for the purposes of search
-	ExchangeMessageTTL            int              // The number of seconds the exchange will keep this message before automatically deleting it
-	ExchangeMessageTTLScaleFactor float64          // Scale factor for thee time the exchange will keep this ,essage before automatically deleting it. Scaled relativee to the max heeartbeat interval
-	MessageKeyPath                string           // The path to the location of messaging keys
-	MessageKeyCheck               int              // The interval (in seconds) indicating how often the agbot checks its own object in the exchange to ensure that the message key is still available.
-	DefaultWorkloadPW             string           // The default workload password if none is specified in the policy file
-	APIListen                     string           // Host and port for the API to listen on
-	SecureAPIListenHost           string           // The host for the secure API to listen on
-	SecureAPIListenPort           string           // The port for the secure API to listen on
-	SecureAPIServerCert           string           // The path to the certificate file for the secure api
-	SecureAPIServerKey            string           // The path to the server key file for the secure api
-	PurgeArchivedAgreementHours   int              // Number of hours to leave an archived agreement in the database before automatically deleting it
-	CheckUpdatedPolicyS           int              // The number of seconds to wait between checks for an updated policy file. Zero means auto checking is turned off.
-	CSSURL                        string           // The URL used to access the CSS.
-	CSSSSLCert                    string           // The path to the client side SSL certificate for the CSS.
-	MMSGarbageCollectionInterval  int64            // The amount of time to wait between MMS object cache garbage collection scans.
-	AgreementBatchSize            uint64           // The number of nodes that the agbot will process in a batch.
-	AgreementQueueSize            uint64           // The agreement bot work queue max size.
-	MessageQueueScale             float64          // Scaling factor applied to the AgreementQueueSize when determining how deep to keep the queues.
-	QueueHistorySize              int              // The number of statistics records to retain in the prioritized queue history.
-	ErrRescanS                    uint64           // The number of seconds between rescan if error occurs from last rescan
-	FullRescanS                   uint64           // The number of seconds between policy scans when there have been no changes reported by the exchange.
-	MaxExchangeChanges            int              // The maximum number of exchange changes to request on a given call the exchange /changes API.
-	RetryLookBackWindow           uint64           // The time window (in seconds) used by the agbot to look backward in time for node changes when node agreements are retried.
-	PolicySearchOrder             bool             // When true, search policies from most recently changed to least recently changed.
-	Vault                         VaultConfig      // The hashicorp vault config to connect to and fetch secrets from.
-	SecretsUpdateCheckInterval    int              // The number of seconds between checks for updated secrets. Default is 60
-	SecretsUpdateCheckMaxInterval int              // As the runtime increases the SecretsUpdateCheckInterval, this value is the maximum that value can attain.
-	SecretsUpdateCheckIncrement   int              // The number of seconds to increment the SecretsUpdateCheckInterval when its time to increase the poll interval.
-	CSSDestinationBatchSize       int              // The max number of destination updates to send to CSS in a single update.
+	Postgresql                    PostgresqlConfig   // The Postgresql config if it is being used
+	Sqlite                        SqliteConfig       // The SQLite config if it is being used
+	PartitionStale                uint64             // Number of seconds to wait before declaring a partition to be stale (i.e. the previous owner has unexpectedly terminated).
+	ProtocolTimeoutS              uint64             // Number of seconds to wait before declaring proposal response is lost
+	AgreementTimeoutS             uint64             // Number of seconds to wait before declaring agreement not finalized in blockchain
+	ProtocolTimeoutScaleFactor    float64            // Time to wait before declaring a proposal response is lost. Expressed as a scaling factor of the max heartbeat interval for a given node
+	AgreementTimeoutScaleFactor   float64            // Time to wait before declaring an agreement did not finalize. Expressed as a scaling factor of the max heartbeat interval for a given node
+	NoDataIntervalS               uint64             // default should be 15 mins == 15*60 == 900. Ignored if the policy has data verification disabled.
+	ActiveAgreementsURL           string             // This field is used when policy files indicate they want data verification but they dont specify a URL
+	ActiveAgreementsUser          string             // This is the userid the agbot uses to authenticate to the data verifivcation API
+	ActiveAgreementsPW            string             // This is the password for the ActiveAgreementsUser
+	PolicyPath                    string             // The directory where policy files are kept, default /etc/provider-tremor/policy/
+	NewContractIntervalS          uint64             // default should be 1
+	ProcessGovernanceIntervalS    uint64             // How long the gov sleeps before general gov checks (new payloads, interval payments, etc).
+	IgnoreContractWithAttribs     string             // A comma seperated list of contract attributes. If set, the contracts that contain one or more of the attributes will be ignored. The default is "ethereum_account".
+	ExchangeURL                   string             // The URL of the Horizon exchange. If not configured, the exchange will not be used.
+	ExchangeHeartbeat             int                // Seconds between heartbeats to the exchange
+	ExchangeId                    string             // The id of the agbot, not the userid of the exchange user. Must be org qualified.
+	ExchangeToken                 string             // The agbot's authentication token
+	DVPrefix                      string             // When looking for agreement ids in the data verification API response, look for agreement ids with this prefix.
+	ActiveDeviceTimeoutS          int                // The amount of time a device can go without heartbeating and still be considered active for the purposes of search
+	ExchangeMessageTTL            int                // The number of seconds the exchange will keep this message before automatically deleting it
+	ExchangeMessageTTLScaleFactor float64            // Scale factor for thee time the exchange will keep this ,essage before automatically deleting it. Scaled relativee to the max heeartbeat interval
+	MessageKeyPath                string             // The path to the location of messaging keys
+	MessageKeyCheck               int                // The interval (in seconds) indicating how often the agbot checks its own object in the exchange to ensure that the message key is still available.
+	DefaultWorkloadPW             string             // The default workload password if none is specified in the policy file
+	APIListen                     string             // Host and port for the API to listen on
+	SecureAPIListenHost           string             // The host for the secure API to listen on
+	SecureAPIListenPort           string             // The port for the secure API to listen on
+	SecureAPIServerCert           string             // The path to the certificate file for the secure api
+	SecureAPIServerKey            string             // The path to the server key file for the secure api
+	PurgeArchivedAgreementHours   int                // Number of hours to leave an archived agreement in the database before automatically deleting it
+	CheckUpdatedPolicyS           int                // The number of seconds to wait between checks for an updated policy file. Zero means auto checking is turned off.
+	CSSURL                        string             // The URL used to access the CSS.
+	CSSSSLCert                    string             // The path to the client side SSL certificate for the CSS.
+	MMSGarbageCollectionInterval  int64              // The amount of time to wait between MMS object cache garbage collection scans.
+	AgreementBatchSize            uint64             // The number of nodes that the agbot will process in a batch.
+	AgreementQueueSize            uint64             // The agreement bot work queue max size.
+	MessageQueueScale             float64            // Scaling factor applied to the AgreementQueueSize when determining how deep to keep the queues.
+	QueueHistorySize              int                // The number of statistics records to retain in the prioritized queue history.
+	ErrRescanS                    uint64             // The number of seconds between rescan if error occurs from last rescan
+	FullRescanS                   uint64             // The number of seconds between policy scans when there have been no changes reported by the exchange.
+	MaxExchangeChanges            int                // The maximum number of exchange changes to request on a given call the exchange /changes API.
+	RetryLookBackWindow           uint64             // The time window (in seconds) used by the agbot to look backward in time for node changes when node agreements are retried.
+	PolicySearchOrder             bool               // When true, search policies from most recently changed to least recently changed.
+	Vault                         VaultConfig        // The hashicorp vault config to connect to and fetch secrets from.
+	LocalSecrets                  LocalSecretsConfig // The config of the local, encrypted secrets provider used when there is no vault.
+	SecretsUpdateCheckInterval    int                // The number of seconds between checks for updated secrets. Default is 60
+	SecretsUpdateCheckMaxInterval int                // As the runtime increases the SecretsUpdateCheckInterval, this value is the maximum that value can attain.
+	SecretsUpdateCheckIncrement   int                // The number of seconds to increment the SecretsUpdateCheckInterval when its time to increase the poll interval.
+	CSSDestinationBatchSize       int                // The max number of destination updates to send to CSS in a single update.
 }
 
 // Contains the hashicorp vault configuration used within AGConfig.
@@ -144,6 +156,69 @@ type VaultConfig struct {
 	SSLCertPath string // The SSL certificate for the vault.
 }
 
+// Contains the configuration of the local secrets provider used within AGConfig. The secrets are stored encrypted
+// in a bolt database on the agbot's file system, which allows small installations to use secrets without a vault.
+type LocalSecretsConfig struct {
+	DBPath               string // The directory where the secrets database is kept.
+	MasterKeyFile        string // The file holding the master key ring. It is created if it doesnt exist.
+	KeyRotationIntervalH int    // The number of hours between automatic master key rotations. Zero disables automatic rotation.
+}
+
+// The retention limits for the agent's event log. A zero value means there is no limit.
+type EventLogRetentionConfig struct {
+	MaxAgeH        uint64                          // Event log records older than this number of hours are removed.
+	MaxCount       int                             // The maximum number of event log records kept. The oldest records are removed first.
+	SeverityLimits map[string]EventLogLimitsConfig // Additional limits for the records of one severity (info, warning, error or fatal).
+	CheckIntervalS int                             // The number of seconds between retention checks. The default is 3600.
+}
+
+type EventLogLimitsConfig struct {
+	MaxAgeH  uint64
+	MaxCount int
+}
+
+func (e EventLogRetentionConfig) String() string {
+	return fmt.Sprintf("MaxAgeH: %v, MaxCount: %v, SeverityLimits: %v, CheckIntervalS: %v", e.MaxAgeH, e.MaxCount, e.SeverityLimits, e.CheckIntervalS)
+}
+
+// A mirror of the images of a registry, or of a repository path in a registry, such as a local pull-through cache. The images
+// with the prefix are pulled with the prefix replaced by each mirror in turn, then from their own registry unless NoFallback is
+// set. Each mirror must start with a registry host and must not be a mirror in another rule.
+type RegistryMirrorConfig struct {
+	Prefix     string   // The images that are mirrored, a registry such as docker.io or a registry and a path such as quay.io/myorg.
+	Mirrors    []string // The prefixes that replace Prefix in the image names, such as cache.example.com:5000 or cache.example.com:5000/quay/myorg.
+	NoFallback bool     // Do not pull from the image's own registry when the image cannot be pulled from any of the mirrors.
+}
+
+func (r RegistryMirrorConfig) String() string {
+	return fmt.Sprintf("Prefix: %v, Mirrors: %v, NoFallback: %v", r.Prefix, r.Mirrors, r.NoFallback)
+}
+
+// The lifecycle of the service images on the node. Images that are still used by a service are never removed. A zero value
+// means there is no limit.
+type ImageLifecycleConfig struct {
+	UnusedGracePeriodM uint64 // Images pulled by the agent are removed this number of minutes after the last service that used them stopped.
+	DiskBudgetMB       uint64 // The maximum disk space used by the images pulled by the agent. The least recently used images are removed first.
+	PreseedDirectory   string // A directory of image tar files in docker save format that are loaded when their images are not on the node.
+	CheckIntervalS     int    // The number of seconds between image lifecycle checks. The default is 600.
+}
+
+func (i ImageLifecycleConfig) String() string {
+	return fmt.Sprintf("UnusedGracePeriodM: %v, DiskBudgetMB: %v, PreseedDirectory: %v, CheckIntervalS: %v", i.UnusedGracePeriodM, i.DiskBudgetMB, i.PreseedDirectory, i.CheckIntervalS)
+}
+
+type ImagePullConfig struct {
+	MaxConcurrentPulls int    // The maximum number of images pulled at the same time. The default is 1.
+	StartBelowKBps     uint64 // Another image pull is only started while the pulls in progress download less than this many KB per second.
+	MaxDownloadKBps    uint64 // The image pulls on the node download at most this many KB per second together. When 0, there is no limit.
+	RetryDeadlineM     uint64 // Failed image pulls are retried with a backoff for this number of minutes. When 0, a pull is tried 3 times.
+	StallTimeoutS      uint64 // An image pull that makes no progress for this number of seconds is stalled, and it is restarted. The default is 120.
+}
+
+func (i ImagePullConfig) String() string {
+	return fmt.Sprintf("MaxConcurrentPulls: %v, StartBelowKBps: %v, MaxDownloadKBps: %v, RetryDeadlineM: %v, StallTimeoutS: %v", i.MaxConcurrentPulls, i.StartBelowKBps, i.MaxDownloadKBps, i.RetryDeadlineM, i.StallTimeoutS)
+}
+
 func (c *HorizonConfig) GetSecretsMount() string {
 	return HZN_SECRETS_MOUNT
 }
@@ -168,6 +243,10 @@ func (c *HorizonConfig) IsPostgresqlConfigured() bool {
 	return (c.AgreementBot.Postgresql != (PostgresqlConfig{})) && (c.GetPartitionStale() != 0)
 }
 
+func (c *HorizonConfig) IsSqliteConfigured() bool {
+	return c.AgreementBot.Sqlite.File != ""
+}
+
 func (c *HorizonConfig) GetPartitionStale() uint64 {
 	if c.AgreementBot.PartitionStale == 0 {
 		return 60
@@ -180,6 +259,18 @@ func (c *HorizonConfig) IsVaultConfigured() bool {
 	return c.AgreementBot.Vault != VaultConfig{}
 }
 
+func (c *HorizonConfig) IsLocalSecretsConfigured() bool {
+	return c.AgreementBot.LocalSecrets.DBPath != ""
+}
+
+// The master key file defaults to a file in the local secrets DB directory.
+func (c *HorizonConfig) GetLocalSecretsMasterKeyFile() string {
+	if c.AgreementBot.LocalSecrets.MasterKeyFile == "" {
+		return path.Join(c.AgreementBot.LocalSecrets.DBPath, "masterkey.json")
+	}
+	return c.AgreementBot.LocalSecrets.MasterKeyFile
+}
+
 func (c *HorizonConfig) GetSecretsManagerFilePath() string {
 	secPath := c.Edge.SecretsManagerFilePath
 	if secPath == "" {
@@ -471,6 +562,22 @@ func Read(file string) (*HorizonConfig, error) {
 			config.Edge.InitialPollingBuffer = 120
 		}
 
+		if config.Edge.EventLogRetention.CheckIntervalS == 0 {
+			config.Edge.EventLogRetention.CheckIntervalS = EventLogRetentionCheckIntervalS_DEFAULT
+		}
+
+		if config.Edge.ImageLifecycle.CheckIntervalS == 0 {
+			config.Edge.ImageLifecycle.CheckIntervalS = ImageLifecycleCheckIntervalS_DEFAULT
+		}
+
+		if config.Edge.ImagePull.MaxConcurrentPulls <= 0 {
+			config.Edge.ImagePull.MaxConcurrentPulls = ImagePullMaxConcurrentPulls_DEFAULT
+		}
+
+		if config.Edge.ImagePull.StallTimeoutS == 0 {
+			config.Edge.ImagePull.StallTimeoutS = ImagePullStallTimeoutS_DEFAULT
+		}
+
 		// add a slash at the back of the ExchangeUrl
 		if config.Edge.ExchangeURL != "" {
 			config.Edge.ExchangeURL = strings.TrimRight(config.Edge.ExchangeURL, "/") + "/"
@@ -550,6 +657,12 @@ func (con *Config) String() string {
 		", NodeCheckIntervalS: %v"+
 		", FileSyncService: {%v}"+
 		", InitialPollingBuffer: {%v}"+
+		", EventLogRetention: {%v}"+
+		", EventSinks: %v"+
+		", ImageLifecycle: {%v}"+
+		", RegistryMirrors: %v"+
+		", ImagePull: {%v}"+
+		", DynamicProperties: %v"+
 		", BlockchainAccountId: %v"+
 		", BlockchainDirectoryAddress %v",
 		con.ServiceStorage, con.APIListen, con.DBPath, con.DockerEndpoint, con.DockerCredFilePath, con.DefaultCPUSet,
@@ -559,7 +672,7 @@ func (con *Config) String() string {
 		con.ExchangeMessagePollMaxInterval, con.ExchangeMessagePollIncrement, con.UserPublicKeyPath, con.ReportDeviceStatus,
 		con.TrustCertUpdatesFromOrg, con.TrustDockerAuthFromOrg, con.ServiceUpgradeCheckIntervalS, con.MultipleAnaxInstances,
 		con.DefaultServiceRetryCount, con.DefaultServiceRetryDuration, con.NodeCheckIntervalS, con.FileSyncService.String(),
-		con.InitialPollingBuffer, con.BlockchainAccountId, con.BlockchainDirectoryAddress)
+		con.InitialPollingBuffer, con.EventLogRetention.String(), con.EventSinks, con.ImageLifecycle.String(), con.RegistryMirrors, con.ImagePull.String(), con.DynamicProperties, con.BlockchainAccountId, con.BlockchainDirectoryAddress)
 }
 
 func (agc *AGConfig) String() string {
@@ -568,6 +681,7 @@ func (agc *AGConfig) String() string {
 		", AgreementWorkers: %v"+
 		", DBPath: %v"+
 		", Postgresql: {%v}"+
+		", Sqlite: {%v}"+
 		", PartitionStale: %v"+
 		", ProtocolTimeoutS: %v"+
 		", AgreementTimeoutS: %v"+
@@ -608,10 +722,11 @@ func (agc *AGConfig) String() string {
 		", RetryLookBackWindow: %v"+
 		", PolicySearchOrder: %v"+
 		", Vault: {%v}"+
+		", LocalSecrets: {%v}"+
 		", SecretsUpdateCheckInterval: %v"+
 		", SecretsUpdateCheckMaxInterval: %v"+
 		", SecretsUpdateCheckIncrement: %v",
-		agc.TxLostDelayTolerationSeconds, agc.AgreementWorkers, agc.DBPath, agc.Postgresql.String(),
+		agc.TxLostDelayTolerationSeconds, agc.AgreementWorkers, agc.DBPath, agc.Postgresql.String(), agc.Sqlite,
 		agc.PartitionStale, agc.ProtocolTimeoutS, agc.AgreementTimeoutS, agc.NoDataIntervalS, agc.ActiveAgreementsURL,
 		agc.ActiveAgreementsUser, mask, agc.PolicyPath, agc.NewContractIntervalS, agc.ProcessGovernanceIntervalS,
 		agc.IgnoreContractWithAttribs, agc.ExchangeURL, agc.ExchangeHeartbeat, agc.ExchangeId,
@@ -619,9 +734,13 @@ func (agc *AGConfig) String() string {
 		agc.SecureAPIListenHost, agc.SecureAPIListenPort, agc.SecureAPIServerCert, agc.SecureAPIServerKey,
 		agc.PurgeArchivedAgreementHours, agc.CheckUpdatedPolicyS, agc.CSSURL, agc.CSSSSLCert, agc.CSSDestinationBatchSize, agc.AgreementBatchSize,
 		agc.AgreementQueueSize, agc.MessageQueueScale, agc.QueueHistorySize, agc.FullRescanS, agc.ErrRescanS, agc.MaxExchangeChanges,
-		agc.RetryLookBackWindow, agc.PolicySearchOrder, agc.Vault, agc.SecretsUpdateCheckInterval, agc.SecretsUpdateCheckMaxInterval, agc.SecretsUpdateCheckIncrement)
+		agc.RetryLookBackWindow, agc.PolicySearchOrder, agc.Vault, agc.LocalSecrets, agc.SecretsUpdateCheckInterval, agc.SecretsUpdateCheckMaxInterval, agc.SecretsUpdateCheckIncrement)
 }
 
 func (c *VaultConfig) String() string {
 	return fmt.Sprintf("VaultURL: %v,", c.VaultURL)
 }
+
+func (c LocalSecretsConfig) String() string {
+	return fmt.Sprintf("DBPath: %v, MasterKeyFile: %v, KeyRotationIntervalH: %v", c.DBPath, c.MasterKeyFile, c.KeyRotationIntervalH)
+}
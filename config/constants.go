@@ -146,3 +146,36 @@ const SecretsUpdateCheckIncrement_DEFAULT = 30
 
 // Batch destination size to send to CSS
 const AgbotCSSDestinationBatchSize_DEFAULT = 200
+
+// Time between event log retention checks on the agent
+const EventLogRetentionCheckIntervalS_DEFAULT = 3600
+
+// The syslog facility used by event sinks, 3 is daemon
+const EventSinkSyslogFacility_DEFAULT = 3
+
+// The maximum number of event logs sent in one batch by an event sink
+const EventSinkBatchSize_DEFAULT = 100
+
+// Time an event log waits in an event sink batch before it is sent
+const EventSinkFlushIntervalS_DEFAULT = 5
+
+// The number of retries of a failed event sink send
+const EventSinkMaxRetries_DEFAULT = 3
+
+// Time an agbot waits for a lock on the SQLite database
+const AgbotSqliteBusyTimeoutMS_DEFAULT = 5000
+
+// Time between image lifecycle checks on the agent
+const ImageLifecycleCheckIntervalS_DEFAULT = 600
+
+// The number of service images that the agent pulls at the same time
+const ImagePullMaxConcurrentPulls_DEFAULT = 1
+
+// Time without progress after which an image pull is stalled
+const ImagePullStallTimeoutS_DEFAULT = 120
+
+// Time between computations of a dynamic node property
+const DynamicPropertyIntervalS_DEFAULT = 60
+
+// Time a dynamic node property script can run
+const DynamicPropertyTimeoutS_DEFAULT = 10
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Configuration for a node property whose value is computed on the node by a provider. The built-in providers
+// are free_disk, accelerator, battery, file and script. The property is pushed to the node policy on the exchange
+// when it is not in the node policy, or when its value changes enough to cross a threshold.
+type DynamicPropertyConfig struct {
+	Name       string    // The name of the node property.
+	Provider   string    // The provider that computes the value of the property.
+	Path       string    // free_disk: the file system, the default is /. accelerator: a glob of device files. battery: the power supply directory. file: the file holding the value.
+	Command    []string  // script: the command and its arguments. The value is what the command writes to stdout.
+	Type       string    // The type of the value: string, int, float or boolean. The default is the type returned by the provider.
+	IntervalS  int       // The number of seconds between computations of the value. The default is 60.
+	TimeoutS   int       // script: the number of seconds the command can run. The default is 10.
+	Thresholds []float64 // A numerical value is pushed when it moves to another band between these thresholds.
+	MinChange  float64   // A numerical value is pushed when it changes by at least this amount. Not used when Thresholds are set.
+}
+
+func (d DynamicPropertyConfig) String() string {
+	return fmt.Sprintf("Name: %v, Provider: %v, Path: %v, Command: %v, Type: %v, IntervalS: %v, TimeoutS: %v, Thresholds: %v, MinChange: %v",
+		d.Name, d.Provider, d.Path, d.Command, d.Type, d.IntervalS, d.TimeoutS, d.Thresholds, d.MinChange)
+}
+
+func (d DynamicPropertyConfig) GetIntervalS() int {
+	if d.IntervalS <= 0 {
+		return DynamicPropertyIntervalS_DEFAULT
+	}
+	return d.IntervalS
+}
+
+func (d DynamicPropertyConfig) GetTimeoutS() int {
+	if d.TimeoutS <= 0 {
+		return DynamicPropertyTimeoutS_DEFAULT
+	}
+	return d.TimeoutS
+}
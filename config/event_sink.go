@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Configuration for forwarding the agent's event logs to an external collector. The Type selects the sink
+// implementation, the built-in types are syslog, unix and webhook.
+type EventSinkConfig struct {
+	Type           string            // The kind of sink: syslog, unix or webhook.
+	Address        string            // syslog: udp://host:port, tcp://host:port or unix:///dev/log. unix: the path of a stream socket. webhook: the URL to POST to.
+	Severities     []string          // Only forward the event logs with these severities. The default is all severities.
+	SourceTypes    []string          // Only forward the event logs with these source types (agreement, service, node, ...). The default is all source types.
+	Facility       int               // syslog: the facility number. The default is 3 (daemon).
+	BatchSize      int               // webhook: the maximum number of event logs in one POST. The default is 100.
+	FlushIntervalS int               // webhook: the maximum number of seconds an event log waits to be sent. The default is 5.
+	MaxRetries     int               // The number of times to retry a failed send before the event logs are dropped. The default is 3.
+	Headers        map[string]string // webhook: additional HTTP headers, such as Authorization.
+}
+
+func (e EventSinkConfig) String() string {
+	headers := make([]string, 0, len(e.Headers))
+	for h := range e.Headers {
+		headers = append(headers, h)
+	}
+	return fmt.Sprintf("Type: %v, Address: %v, Severities: %v, SourceTypes: %v, Facility: %v, BatchSize: %v, FlushIntervalS: %v, MaxRetries: %v, Headers: %v",
+		e.Type, e.Address, e.Severities, e.SourceTypes, e.Facility, e.BatchSize, e.FlushIntervalS, e.MaxRetries, headers)
+}
+
+func (e EventSinkConfig) GetFacility() int {
+	if e.Facility == 0 {
+		return EventSinkSyslogFacility_DEFAULT
+	}
+	return e.Facility
+}
+
+func (e EventSinkConfig) GetBatchSize() int {
+	if e.BatchSize <= 0 {
+		return EventSinkBatchSize_DEFAULT
+	}
+	return e.BatchSize
+}
+
+func (e EventSinkConfig) GetFlushIntervalS() int {
+	if e.FlushIntervalS <= 0 {
+		return EventSinkFlushIntervalS_DEFAULT
+	}
+	return e.FlushIntervalS
+}
+
+func (e EventSinkConfig) GetMaxRetries() int {
+	if e.MaxRetries <= 0 {
+		return EventSinkMaxRetries_DEFAULT
+	}
+	return e.MaxRetries
+}
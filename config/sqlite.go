@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// The SQLite database is an embedded alternative to Postgresql for small deployments and tests. Several agbots on the
+// same host can share the database file, each of them owns a partition just like they do in Postgresql.
+type SqliteConfig struct {
+	File          string // The path of the database file. It is created if it doesnt exist.
+	BusyTimeoutMS int    // The number of milliseconds to wait for a lock held by another agbot. The default is 5000.
+}
+
+func (s SqliteConfig) GetBusyTimeoutMS() int {
+	if s.BusyTimeoutMS <= 0 {
+		return AgbotSqliteBusyTimeoutMS_DEFAULT
+	}
+	return s.BusyTimeoutMS
+}
+
+// Every transaction takes the write lock when it begins, which serializes the agbots sharing the file in the same
+// way that table locks do in Postgresql. The write ahead log lets the readers run while a transaction is open.
+func (s SqliteConfig) MakeConnectionString() string {
+	return fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_txlock=immediate", url.PathEscape(s.File), s.GetBusyTimeoutMS())
+}
+
+func (s SqliteConfig) String() string {
+	return fmt.Sprintf("File: %v, BusyTimeoutMS: %v", s.File, s.BusyTimeoutMS)
+}
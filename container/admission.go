@@ -0,0 +1,213 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The admission policy is a node-local file, written by the node owner, that restricts what the containers of a deployment
+// can ask for, independent of what the publisher of the service has signed. The policy is unprivileged by default: privileged
+// containers, host networking, host pid/ipc modes and containers without a seccomp or apparmor profile are only admitted when
+// the policy allows them, and capabilities, devices, host bind paths and image registries are only admitted when they match
+// an entry in the allow list of the corresponding rule and no entry in its deny list. The registry of an image is checked
+// before the image is pulled.
+//
+// ex:
+//
+//	{
+//	  "privileged": false,
+//	  "hostNetwork": false,
+//	  "hostPid": false,
+//	  "hostIpc": false,
+//	  "unconfined": false,
+//	  "requireReadOnlyRootfs": true,
+//	  "capabilities": {"allow": ["NET_BIND_SERVICE"]},
+//	  "devices": {"allow": ["/dev/ttyUSB0"]},
+//	  "binds": {"allow": ["/var/data"], "deny": ["/var/data/secret"]},
+//	  "registries": {"allow": ["*"], "deny": ["docker.io"]}
+//	}
+type AdmissionPolicy struct {
+	Privileged            bool          `json:"privileged"`
+	HostNetwork           bool          `json:"hostNetwork"`
+	HostPid               bool          `json:"hostPid"`
+	HostIpc               bool          `json:"hostIpc"`
+	Unconfined            bool          `json:"unconfined"`
+	RequireReadOnlyRootfs bool          `json:"requireReadOnlyRootfs"`
+	Capabilities          AdmissionRule `json:"capabilities"`
+	Devices               AdmissionRule `json:"devices"`
+	Binds                 AdmissionRule `json:"binds"`
+	Registries            AdmissionRule `json:"registries"`
+}
+
+func (p AdmissionPolicy) String() string {
+	return fmt.Sprintf("Privileged: %v, HostNetwork: %v, HostPid: %v, HostIpc: %v, Unconfined: %v, RequireReadOnlyRootfs: %v, Capabilities: {%v}, Devices: {%v}, Binds: {%v}, Registries: {%v}",
+		p.Privileged, p.HostNetwork, p.HostPid, p.HostIpc, p.Unconfined, p.RequireReadOnlyRootfs, p.Capabilities, p.Devices, p.Binds, p.Registries)
+}
+
+// The allow and deny lists of one kind of resource. An allow list entry of "*" matches everything. Device and bind entries
+// are host paths, they also match everything below the path.
+type AdmissionRule struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+func (r AdmissionRule) String() string {
+	return fmt.Sprintf("Allow: %v, Deny: %v", r.Allow, r.Deny)
+}
+
+// Returns true if the value matches an entry in the allow list and no entry in the deny list.
+func (r AdmissionRule) admits(value string, match func(entry string, value string) bool) bool {
+	for _, entry := range r.Deny {
+		if match(entry, value) {
+			return false
+		}
+	}
+	for _, entry := range r.Allow {
+		if entry == "*" || match(entry, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities can be written with or without the CAP_ prefix, in any case.
+func matchCapability(entry string, value string) bool {
+	normalize := func(c string) string {
+		return strings.TrimPrefix(strings.ToUpper(c), "CAP_")
+	}
+	return normalize(entry) == normalize(value)
+}
+
+// A host path matches an entry if it is the entry or it is below the entry.
+func matchPath(entry string, value string) bool {
+	entry = filepath.Clean(entry)
+	value = filepath.Clean(value)
+	return value == entry || entry == "/" || strings.HasPrefix(value, entry+"/")
+}
+
+func matchRegistry(entry string, value string) bool {
+	return strings.EqualFold(entry, value)
+}
+
+// Returns true if the security option turns off the seccomp or apparmor confinement of the container. Docker accepts both
+// seccomp=unconfined and the older seccomp:unconfined form.
+func unconfinedSecurityOpt(opt string) bool {
+	kv := strings.SplitN(strings.Replace(opt, ":", "=", 1), "=", 2)
+	if len(kv) != 2 {
+		return false
+	}
+	key := strings.ToLower(strings.TrimSpace(kv[0]))
+	return (key == "seccomp" || key == "apparmor") && strings.EqualFold(strings.TrimSpace(kv[1]), "unconfined")
+}
+
+// The error returned when a deployment is not admitted by the node's admission policy.
+type AdmissionError struct {
+	ServiceName string
+	Violation   string
+}
+
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("service %v violates the node admission policy: %v", e.ServiceName, e.Violation)
+}
+
+// Read the admission policy from the file. There is no policy, and so everything is admitted, when the file name is empty.
+func LoadAdmissionPolicy(fileName string) (*AdmissionPolicy, error) {
+	if fileName == "" {
+		return nil, nil
+	}
+
+	admissionPolicy := new(AdmissionPolicy)
+	if bytes, err := os.ReadFile(filepath.Clean(fileName)); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read admission policy file %v, error: %v", fileName, err))
+	} else if err := json.Unmarshal(bytes, admissionPolicy); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to demarshal admission policy file %v, error: %v", fileName, err))
+	}
+
+	glog.V(5).Infof("Loaded admission policy %v from %v", admissionPolicy, fileName)
+	return admissionPolicy, nil
+}
+
+// Check the services of the deployment against the admission policy. The binds to host paths below the storage directory
+// of the agreement are binds to the agreement's own files and are always admitted, workloadStorageDir is empty when the
+// storage of the agreement is a docker volume. An *AdmissionError is returned for the first violation that is found.
+func (p *AdmissionPolicy) Check(deployment *containermessage.DeploymentDescription, workloadStorageDir string) error {
+	if p == nil {
+		return nil
+	}
+
+	for serviceName, service := range deployment.Services {
+		violation := func(format string, args ...interface{}) error {
+			return &AdmissionError{ServiceName: serviceName, Violation: fmt.Sprintf(format, args...)}
+		}
+
+		if service.Privileged && !p.Privileged {
+			return violation("privileged containers are not allowed")
+		} else if service.Network == "host" && !p.HostNetwork {
+			return violation("host networking is not allowed")
+		} else if service.PID == "host" && !p.HostPid {
+			return violation("the host pid mode is not allowed")
+		} else if service.Ipc == "host" && !p.HostIpc {
+			return violation("the host ipc mode is not allowed")
+		} else if p.RequireReadOnlyRootfs && !service.ReadOnly {
+			return violation("the root filesystem of the container must be read only")
+		}
+
+		for _, opt := range service.SecurityOpt {
+			if unconfinedSecurityOpt(opt) && !p.Unconfined {
+				return violation("security option %v is not allowed", opt)
+			}
+		}
+
+		for _, c := range service.CapAdd {
+			if !p.Capabilities.admits(c, matchCapability) {
+				return violation("capability %v is not allowed", c)
+			}
+		}
+
+		for _, d := range service.Devices {
+			if hostPath := strings.Split(d, ":")[0]; !p.Devices.admits(hostPath, matchPath) {
+				return violation("device %v is not allowed", hostPath)
+			}
+		}
+
+		for _, b := range service.Binds {
+			// Binds of named volumes do not expose the host filesystem.
+			hostPath := strings.Split(b, ":")[0]
+			if !filepath.IsAbs(hostPath) || (workloadStorageDir != "" && matchPath(workloadStorageDir, hostPath)) {
+				continue
+			} else if !p.Binds.admits(hostPath, matchPath) {
+				return violation("bind of host path %v is not allowed", hostPath)
+			}
+		}
+
+		if err := p.CheckImage(serviceName, service.Image); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check the registry of the image of a service against the admission policy, so that an image from a registry that is not
+// admitted is not pulled. An *AdmissionError is returned when the registry is not admitted.
+func (p *AdmissionPolicy) CheckImage(serviceName string, image string) error {
+	if p == nil {
+		return nil
+	}
+
+	domain, _, _, _ := cutil.ParseDockerImagePath(image)
+	if domain == "" {
+		domain = "docker.io"
+	}
+	if !p.Registries.admits(domain, matchRegistry) {
+		return &AdmissionError{ServiceName: serviceName, Violation: fmt.Sprintf("images from registry %v are not allowed", domain)}
+	}
+	return nil
+}
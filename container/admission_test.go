@@ -0,0 +1,92 @@
+//go:build unit
+// +build unit
+
+package container
+
+import (
+	"github.com/open-horizon/anax/containermessage"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_AdmissionPolicy(t *testing.T) {
+	dir, err := os.MkdirTemp("", "admission-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "admission_policy.json")
+	policyString := `{
+		"capabilities": {"allow": ["NET_BIND_SERVICE"]},
+		"devices": {"allow": ["/dev/bus/usb"]},
+		"binds": {"allow": ["/var/data"], "deny": ["/var/data/secret"]},
+		"registries": {"allow": ["*"], "deny": ["docker.io"]}
+	}`
+	if err := os.WriteFile(file, []byte(policyString), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadAdmissionPolicy(file)
+	if err != nil {
+		t.Fatalf("unable to load admission policy, error: %v", err)
+	}
+
+	admitted := containermessage.Service{
+		Image:       "quay.io/myorg/myservice:1.0.0",
+		CapAdd:      []string{"CAP_NET_BIND_SERVICE"},
+		Devices:     []string{"/dev/bus/usb/001/001:/dev/bus/usb/001/001"},
+		Binds:       []string{"/var/data/in:/data:ro", "myvolume:/volume", "/var/horizon/service_storage/ag1:/service_config:rw"},
+		SecurityOpt: []string{"label=disable", "seccomp=/etc/docker/seccomp.json"},
+	}
+	deployment := &containermessage.DeploymentDescription{Services: map[string]*containermessage.Service{"svc1": &admitted}}
+	if err := p.Check(deployment, "/var/horizon/service_storage/ag1"); err != nil {
+		t.Errorf("service %v should be admitted, error: %v", admitted, err)
+	}
+
+	for _, refused := range []containermessage.Service{
+		{Image: "quay.io/myorg/myservice", Privileged: true},
+		{Image: "quay.io/myorg/myservice", Network: "host"},
+		{Image: "quay.io/myorg/myservice", PID: "host"},
+		{Image: "quay.io/myorg/myservice", Ipc: "host"},
+		{Image: "quay.io/myorg/myservice", CapAdd: []string{"SYS_ADMIN"}},
+		{Image: "quay.io/myorg/myservice", Devices: []string{"/dev/mem:/dev/mem"}},
+		{Image: "quay.io/myorg/myservice", Binds: []string{"/var/data/secret/key:/key:ro"}},
+		{Image: "quay.io/myorg/myservice", Binds: []string{"/etc:/host_etc"}},
+		{Image: "quay.io/myorg/myservice", Binds: []string{"/var/horizon/service_storage/ag2:/other_agreement"}},
+		{Image: "quay.io/myorg/myservice", Binds: []string{"/var/horizon/service_storage:/all_agreements"}},
+		{Image: "quay.io/myorg/myservice", SecurityOpt: []string{"seccomp=unconfined"}},
+		{Image: "quay.io/myorg/myservice", SecurityOpt: []string{"apparmor:unconfined"}},
+		{Image: "myorg/myservice:1.0.0"},
+	} {
+		svc := refused
+		deployment := &containermessage.DeploymentDescription{Services: map[string]*containermessage.Service{"svc1": &svc}}
+		if err := p.Check(deployment, "/var/horizon/service_storage/ag1"); err == nil {
+			t.Errorf("service %v should not be admitted", refused)
+		} else if _, ok := err.(*AdmissionError); !ok {
+			t.Errorf("expected an admission error for service %v, got %v", refused, err)
+		}
+	}
+
+	// The unconfined containers are admitted when the policy allows them.
+	unconfined := containermessage.Service{Image: "quay.io/myorg/myservice", SecurityOpt: []string{"seccomp=unconfined", "apparmor=unconfined"}}
+	p.Unconfined = true
+	if err := p.Check(&containermessage.DeploymentDescription{Services: map[string]*containermessage.Service{"svc1": &unconfined}}, ""); err != nil {
+		t.Errorf("service %v should be admitted, error: %v", unconfined, err)
+	}
+
+	// The registry of an image is checked on its own before the image is pulled.
+	if err := p.CheckImage("svc1", "quay.io/myorg/myservice:1.0.0"); err != nil {
+		t.Errorf("the image should be admitted, error: %v", err)
+	} else if err := p.CheckImage("svc1", "myorg/myservice:1.0.0"); err == nil {
+		t.Errorf("the image from docker.io should not be admitted")
+	}
+
+	// Without a policy file everything is admitted.
+	if p, err := LoadAdmissionPolicy(""); err != nil || p != nil {
+		t.Errorf("there should be no admission policy, policy %v, error: %v", p, err)
+	} else if err := p.Check(deployment, ""); err != nil {
+		t.Errorf("everything should be admitted without a policy, error: %v", err)
+	}
+}
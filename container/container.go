@@ -53,6 +53,7 @@ const (
 	EL_CONT_ERROR_UNMARSHAL_DEPLOY            = "Error Unmarshalling deployment string %v, error: %v"
 	EL_CONT_ERROR_UNMARSHAL_DEPLOY_OVERRIDE   = "Error Unmarshalling deployment override string %v for agreement %v, error: %v"
 	EL_CONT_START_CONTAINER_ERROR             = "Error starting containers: %v"
+	EL_CONT_ADMISSION_POLICY_VIOLATION        = "Containers for %v were not admitted: %v"
 	EL_CONT_START_CONTAINER_ERROR_FOR_AG      = "Error starting containers for agreement %v: %v"
 	EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG    = "Error restarting containers for agreements %v: %v"
 	EL_CONT_CLEAN_OLD_CONTAINER_ERROR         = "Error cleaning up old containers before starting up new containers for %v. Error: %v"
@@ -78,6 +79,7 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_CONT_ERROR_UNMARSHAL_DEPLOY)
 	msgPrinter.Sprintf(EL_CONT_ERROR_UNMARSHAL_DEPLOY_OVERRIDE)
 	msgPrinter.Sprintf(EL_CONT_START_CONTAINER_ERROR)
+	msgPrinter.Sprintf(EL_CONT_ADMISSION_POLICY_VIOLATION)
 	msgPrinter.Sprintf(EL_CONT_START_CONTAINER_ERROR_FOR_AG)
 	msgPrinter.Sprintf(EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG)
 	msgPrinter.Sprintf(EL_CONT_CLEAN_OLD_CONTAINER_ERROR)
@@ -385,6 +387,20 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			serviceConfig.HostConfig.NanoCPUs = int64(service.MaxCPUs * 1000000000)
 		}
 
+		// Set the restart policy and the other resource limits if they are defined in the service config
+		if err := service.SetHostConfigLimits(&serviceConfig.HostConfig); err != nil {
+			return nil, fmt.Errorf("Invalid resource limits for service %v: %v", serviceName, err)
+		}
+
+		// Pass the health check to the container runtime, the container maintenance checks whether it is healthy.
+		if service.Healthcheck != nil {
+			if hc, err := service.Healthcheck.DockerHealthConfig(); err != nil {
+				return nil, fmt.Errorf("Invalid healthcheck for service %v: %v", serviceName, err)
+			} else {
+				serviceConfig.Config.Healthcheck = hc
+			}
+		}
+
 		// Mark each container as infrastructure if the deployment description indicates infrastructure
 		if deployment.Infrastructure {
 			serviceConfig.Config.Labels[LABEL_PREFIX+".infrastructure"] = ""
@@ -1272,6 +1288,18 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 
 	workloadRWStorageDir, useVolume := b.workloadStorageDir(agreementId)
 
+	// The node's admission policy is read for every deployment so that changes to the policy file apply to the next deployment.
+	// Nothing is created for a deployment that is not admitted.
+	admittedStorageDir := workloadRWStorageDir
+	if useVolume {
+		admittedStorageDir = ""
+	}
+	if admissionPolicy, err := LoadAdmissionPolicy(b.Config.Edge.AdmissionPolicyFile); err != nil {
+		return nil, err
+	} else if err := admissionPolicy.Check(deployment, admittedStorageDir); err != nil {
+		return nil, err
+	}
+
 	if !useVolume {
 		cleanedDir := filepath.Clean(workloadRWStorageDir)
 		// create RO workload storage dir if it doesnt already exist
@@ -1348,10 +1376,17 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 	// New network will be created if there is at least one service without 'network:host' mode
 	newNetworkNeeded := false
 	for serviceName, servicePair := range servicePairs {
-		if image, err := b.client.InspectImage(servicePair.serviceConfig.Config.Image); err != nil {
-			return nil, fail(nil, serviceName, fmt.Errorf("Failed to locally inspect image: %v. Please build and tag image locally or pull the image from your docker repository before running this command. Original error: %v", servicePair.serviceConfig.Config.Image, err))
+		imageName := servicePair.serviceConfig.Config.Image
+		if image, localName, err := InspectLocalImage(b.client, imageName, b.Config.Edge.RegistryMirrors); err != nil {
+			return nil, fail(nil, serviceName, fmt.Errorf("Failed to locally inspect image: %v. Please build and tag image locally or pull the image from your docker repository before running this command. Original error: %v", imageName, err))
 		} else if image == nil {
-			return nil, fail(nil, serviceName, fmt.Errorf("Unable to find Docker image: %v", servicePair.serviceConfig.Config.Image))
+			return nil, fail(nil, serviceName, fmt.Errorf("Unable to find Docker image: %v", imageName))
+		} else if err := checkImageDigest(imageName, cutil.OriginalRepoDigests(image.RepoDigests, b.Config.Edge.RegistryMirrors), b.Config.Edge.RequireImageDigests); err != nil {
+			return nil, fail(nil, serviceName, err)
+		} else {
+			// the container is created from the image that was found, which is the image in a registry mirror for an image
+			// pinned by digest that was pulled from the mirror.
+			servicePair.serviceConfig.Config.Image = localName
 		}
 
 		// need to examine original deploymentDescription to determine which containers are "shared" or in other special patterns
@@ -1607,10 +1642,17 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			// Create the docker configuration and launch the containers.
 			// agreementId is the MSSInstanceKey
 			if deploymentConfig, err := b.ResourcesCreate(agreementId, cmd.AgreementLaunchContext.AgreementProtocol, deploymentDesc, cmd.AgreementLaunchContext.ConfigureRaw, *cmd.AgreementLaunchContext.EnvironmentAdditions, ms_children_networks, serviceIdentity, sVer, agreementId); err != nil {
-				eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR,
-					persistence.NewMessageMeta(EL_CONT_START_CONTAINER_ERROR, err.Error()),
-					persistence.EC_ERROR_START_CONTAINER,
-					ags[0])
+				if _, ok := err.(*AdmissionError); ok {
+					eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR,
+						persistence.NewMessageMeta(EL_CONT_ADMISSION_POLICY_VIOLATION, agreementId, err.Error()),
+						persistence.EC_ADMISSION_POLICY_VIOLATION,
+						ags[0])
+				} else {
+					eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR,
+						persistence.NewMessageMeta(EL_CONT_START_CONTAINER_ERROR, err.Error()),
+						persistence.EC_ERROR_START_CONTAINER,
+						ags[0])
+				}
 				glog.Errorf("Error starting containers: %v", err)
 				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, deploymentConfig) // still using deployment here, need it to shutdown containers
 
@@ -1766,14 +1808,21 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 
 		// Get the container started
 		if deployment, err := b.ResourcesCreate(lc.Name, "", deploymentDesc, []byte(""), *lc.EnvironmentAdditions, ms_children_networks, serviceIdentity, sVer, containerName); err != nil {
-			log_str := EL_CONT_START_CONTAINER_ERROR_FOR_AG
-			if lc.IsRetry {
-				log_str = EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG
+			if _, ok := err.(*AdmissionError); ok {
+				eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+					persistence.NewMessageMeta(EL_CONT_ADMISSION_POLICY_VIOLATION, lc.Name, err.Error()),
+					persistence.EC_ADMISSION_POLICY_VIOLATION, "",
+					serviceInfo.URL, serviceInfo.Org, serviceInfo.Version, "", lc.AgreementIds)
+			} else {
+				log_str := EL_CONT_START_CONTAINER_ERROR_FOR_AG
+				if lc.IsRetry {
+					log_str = EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG
+				}
+				eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+					persistence.NewMessageMeta(log_str, fmt.Sprintf("%v", lc.AgreementIds), err.Error()),
+					persistence.EC_ERROR_START_CONTAINER, "",
+					serviceInfo.URL, serviceInfo.Org, serviceInfo.Version, "", lc.AgreementIds)
 			}
-			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
-				persistence.NewMessageMeta(log_str, fmt.Sprintf("%v", lc.AgreementIds), err.Error()),
-				persistence.EC_ERROR_START_CONTAINER, "",
-				serviceInfo.URL, serviceInfo.Org, serviceInfo.Version, "", lc.AgreementIds)
 			glog.Errorf("Error starting containers: %v", err)
 			b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
 
@@ -1824,8 +1873,12 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 
 				for _, name := range serviceNames {
 					if container.Labels[LABEL_PREFIX+".service_name"] == name && container.State == "running" {
-						cMatches = append(cMatches, *container)
-						glog.V(4).Infof("Matching container instance for agreement %v: %v", agreementId, container)
+						if isUnhealthy(container) {
+							glog.Errorf("Container %v for agreement %v is unhealthy: %v", container.Names, agreementId, container.Status)
+						} else {
+							cMatches = append(cMatches, *container)
+							glog.V(4).Infof("Matching container instance for agreement %v: %v", agreementId, container)
+						}
 					}
 				}
 				return nil
@@ -1898,6 +1951,8 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 					if container.Labels[LABEL_PREFIX+".service_name"] == name {
 						if container.State != "running" {
 							glog.Errorf("Service container for %v is not in the running state.", instance_key)
+						} else if isUnhealthy(container) {
+							glog.Errorf("Service container for %v is unhealthy: %v", instance_key, container.Status)
 						} else {
 							cMatches = append(cMatches, *container)
 							glog.V(4).Infof("Matching container instance for service instance %v: %v", instance_key, container)
@@ -2879,6 +2934,45 @@ func (b *ContainerWorker) connectContainers(network *docker.Network, containers
 	return nil
 }
 
+// Inspect the local image with the given name. An image pinned by digest that was pulled from a registry mirror does not have
+// its original name, it is found by its name in the mirror. Returns the image and the name it was found with.
+func InspectLocalImage(client *docker.Client, imageName string, mirrors []config.RegistryMirrorConfig) (*docker.Image, string, error) {
+	image, err := client.InspectImage(imageName)
+	if err != docker.ErrNoSuchImage {
+		return image, imageName, err
+	}
+
+	mirrorNames, _ := cutil.MirroredImageNames(imageName, mirrors)
+	for _, mirrorName := range mirrorNames {
+		if mirrorImage, mirrorErr := client.InspectImage(mirrorName); mirrorErr == nil {
+			glog.V(3).Infof("Found image %v as %v from a registry mirror", imageName, mirrorName)
+			return mirrorImage, mirrorName, nil
+		}
+	}
+	return nil, imageName, err
+}
+
+// Check that the local image that a container will run from is the image that the deployment is pinned to. An image that is
+// not pinned by digest is only allowed when the node does not require digests.
+func checkImageDigest(imageName string, repoDigests []string, requireDigest bool) error {
+	if _, _, _, digest := cutil.ParseDockerImagePath(imageName); digest == "" {
+		if requireDigest {
+			return fmt.Errorf("Image %v is not pinned by digest, only images pinned by digest are allowed on this node", imageName)
+		}
+		return nil
+	} else if !cutil.ImageDigestMatches(imageName, repoDigests) {
+		return fmt.Errorf("Digest mismatch for image %v, the local image has digests %v", imageName, repoDigests)
+	}
+	return nil
+}
+
+// Returns true if the health check of the container has failed. The container runtime only marks a container unhealthy after
+// the configured number of consecutive failed checks, and reports it in the container status, e.g. "Up 5 minutes (unhealthy)".
+// A container without a health check is never unhealthy.
+func isUnhealthy(container *docker.APIContainers) bool {
+	return strings.Contains(container.Status, "(unhealthy)")
+}
+
 func isAnaxNetwork(net *docker.Network, bridgeName string) bool {
 	if _, anaxNet := net.Labels[LABEL_PREFIX+".network"]; anaxNet && net.Name == bridgeName {
 		return true
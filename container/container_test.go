@@ -293,3 +293,30 @@ func createTmpDir() error {
 	}
 	return nil
 }
+
+func Test_isUnhealthy(t *testing.T) {
+	for status, unhealthy := range map[string]bool{
+		"Up 5 minutes":                    false,
+		"Up 5 minutes (healthy)":          false,
+		"Up 5 seconds (health: starting)": false,
+		"Up 5 minutes (unhealthy)":        true,
+	} {
+		if isUnhealthy(&docker.APIContainers{State: "running", Status: status}) != unhealthy {
+			t.Errorf("container with status %v should have unhealthy %v", status, unhealthy)
+		}
+	}
+}
+
+func Test_checkImageDigest(t *testing.T) {
+	repoDigests := []string{"mydomain.com/myservice@sha256:1234"}
+
+	if err := checkImageDigest("mydomain.com/myservice@sha256:1234", repoDigests, true); err != nil {
+		t.Errorf("the image should match its digest, error: %v", err)
+	} else if err := checkImageDigest("mydomain.com/myservice:1.0.0", repoDigests, false); err != nil {
+		t.Errorf("the image does not need a digest, error: %v", err)
+	} else if err := checkImageDigest("mydomain.com/myservice:1.0.0", repoDigests, true); err == nil {
+		t.Errorf("the image should need a digest")
+	} else if err := checkImageDigest("mydomain.com/myservice@sha256:5678", repoDigests, false); err == nil {
+		t.Errorf("the image should not match its digest")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	docker "github.com/fsouza/go-dockerclient"
 	"reflect"
 	"strings"
+	"time"
 )
 
 /*
@@ -171,11 +172,21 @@ type Service struct {
 	MaxCPUs          float32              `json:"max_cpus,omitempty"`
 	LogDriver        string               `json:"log_driver,omitempty"` // Docker's log-driver. Syslog will be used as default driver
 	Secrets          map[string]Secret    `json:"secrets"`
-	SecurityOpt      []string             `json:"security_opt,omitempty"` // Related to SELinux security for podman
-	PID              string               `json:"pid,omitempty"`          // The process id that the container should run in, see docker run --pid
-	User             string               `json:"user,omitempty"`         // The linux user ID (UID format) in which the container should run, see docker run -user
-	Sysctls          map[string]string    `json:"sysctls,omitempty"`      // The namespaced kernel parameters (sysctls) for this container, see docker run --sysctls
-	Ipc              string               `json:"ipc,omitempty"`          // The ipc mode for this container, see docker run --ipc
+	SecurityOpt      []string             `json:"security_opt,omitempty"`          // Related to SELinux security for podman
+	PID              string               `json:"pid,omitempty"`                   // The process id that the container should run in, see docker run --pid
+	User             string               `json:"user,omitempty"`                  // The linux user ID (UID format) in which the container should run, see docker run -user
+	Sysctls          map[string]string    `json:"sysctls,omitempty"`               // The namespaced kernel parameters (sysctls) for this container, see docker run --sysctls
+	Ipc              string               `json:"ipc,omitempty"`                   // The ipc mode for this container, see docker run --ipc
+	Healthcheck      *HealthCheck         `json:"healthcheck,omitempty"`           // The health check for this container, see docker run --health-cmd
+	RestartPolicy    *RestartPolicy       `json:"restart_policy,omitempty"`        // When the container runtime restarts the container, see docker run --restart. Always restart by default
+	Ulimits          []Ulimit             `json:"ulimits,omitempty"`               // The ulimits for this container, see docker run --ulimit
+	PidsLimit        int64                `json:"pids_limit,omitempty"`            // The maximum number of processes in this container, -1 for unlimited, see docker run --pids-limit
+	CapDrop          []string             `json:"cap_drop,omitempty"`              // The linux capabilities to remove from this container, see docker run --cap-drop
+	ReadOnly         bool                 `json:"read_only,omitempty"`             // Mount the root filesystem of this container as read only, see docker run --read-only
+	ShmSizeMb        int64                `json:"shm_size_mb,omitempty"`           // The size of /dev/shm in this container, see docker run --shm-size
+	MemReservationMb int64                `json:"memory_reservation_mb,omitempty"` // The soft memory limit of this container, see docker run --memory-reservation
+	MaxMemSwapMb     int64                `json:"max_memory_swap_mb,omitempty"`    // The maximum memory plus swap this container can use, -1 for unlimited swap, see docker run --memory-swap
+	ImageSignature   *ImageSignature      `json:"image_signature,omitempty"`       // The detached signature of the image digest, checked after the image is pulled
 }
 
 func (s *Service) AddFilesystemBinding(bind string) {
@@ -252,6 +263,182 @@ func (s *Service) AddSpecificPortBinding(b docker.PortBinding) {
 	s.Ports = append(s.Ports, b)
 }
 
+// The health check run by the container runtime inside a service container. The test is the command to run, in the same form as
+// a Dockerfile HEALTHCHECK, e.g. ["CMD", "curl", "-f", "http://localhost/"] or ["CMD-SHELL", "curl -f http://localhost/ || exit 1"].
+// The durations are strings like "30s" or "1m". Fields that are not set use the runtime's defaults.
+type HealthCheck struct {
+	Test        []string `json:"test"`
+	Interval    string   `json:"interval,omitempty"`     // time between checks
+	Timeout     string   `json:"timeout,omitempty"`      // time after which a check is considered to have failed
+	Retries     int      `json:"retries,omitempty"`      // the number of consecutive failed checks before the container is unhealthy
+	StartPeriod string   `json:"start_period,omitempty"` // time for the container to start up, failed checks in this period are not counted
+}
+
+func (h HealthCheck) String() string {
+	return fmt.Sprintf("Test: %v, Interval: %v, Timeout: %v, Retries: %v, StartPeriod: %v", h.Test, h.Interval, h.Timeout, h.Retries, h.StartPeriod)
+}
+
+// Convert the health check into the container runtime's health check config. An error is returned if the health check is
+// not valid.
+func (h HealthCheck) DockerHealthConfig() (*docker.HealthConfig, error) {
+	if len(h.Test) == 0 {
+		return nil, errors.New(fmt.Sprintf("healthcheck test must be specified"))
+	} else if h.Test[0] != "NONE" && h.Test[0] != "CMD" && h.Test[0] != "CMD-SHELL" {
+		return nil, errors.New(fmt.Sprintf("healthcheck test %v must begin with NONE, CMD or CMD-SHELL", h.Test))
+	} else if h.Test[0] != "NONE" && len(h.Test) < 2 {
+		return nil, errors.New(fmt.Sprintf("healthcheck test %v has no command", h.Test))
+	} else if h.Retries < 0 {
+		return nil, errors.New(fmt.Sprintf("healthcheck retries %v must not be negative", h.Retries))
+	}
+
+	hc := &docker.HealthConfig{
+		Test:    h.Test,
+		Retries: h.Retries,
+	}
+
+	for _, d := range []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{{"interval", h.Interval, &hc.Interval}, {"timeout", h.Timeout, &hc.Timeout}, {"start_period", h.StartPeriod, &hc.StartPeriod}} {
+		if d.value == "" {
+			continue
+		} else if duration, err := time.ParseDuration(d.value); err != nil {
+			return nil, errors.New(fmt.Sprintf("healthcheck %v %v is not a valid duration, error: %v", d.name, d.value, err))
+		} else if duration < time.Millisecond {
+			// The container runtimes reject durations below 1 millisecond.
+			return nil, errors.New(fmt.Sprintf("healthcheck %v %v must be at least 1ms", d.name, d.value))
+		} else {
+			*d.field = duration
+		}
+	}
+
+	return hc, nil
+}
+
+// A detached signature of a container image. The payload is the base64 encoded sigstore simple signing document of the image,
+// as created by "cosign generate", which names the image repository and manifest digest. The signature is the base64 encoded
+// RSA-PSS signature of the payload, as created by "hzn util sign", made with a key whose public key is on the node.
+type ImageSignature struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+func (i ImageSignature) String() string {
+	return fmt.Sprintf("Payload: %v, Signature: %v", i.Payload, i.Signature)
+}
+
+// The restart policy of a service container. The name is "no", "on-failure" or "always". With "on-failure", the container
+// is restarted at most MaxRetryCount times, or without limit when it is 0.
+type RestartPolicy struct {
+	Name          string `json:"name"`
+	MaxRetryCount int    `json:"max_retry_count,omitempty"`
+}
+
+func (r RestartPolicy) String() string {
+	return fmt.Sprintf("Name: %v, MaxRetryCount: %v", r.Name, r.MaxRetryCount)
+}
+
+// Convert the restart policy into the container runtime's restart policy. An error is returned if the policy is not valid.
+func (r RestartPolicy) DockerRestartPolicy() (docker.RestartPolicy, error) {
+	switch r.Name {
+	case "no", "always":
+		if r.MaxRetryCount != 0 {
+			return docker.RestartPolicy{}, errors.New(fmt.Sprintf("restart_policy max_retry_count is only allowed with on-failure, not with %v", r.Name))
+		} else if r.Name == "no" {
+			return docker.NeverRestart(), nil
+		}
+		return docker.AlwaysRestart(), nil
+	case "on-failure":
+		if r.MaxRetryCount < 0 {
+			return docker.RestartPolicy{}, errors.New(fmt.Sprintf("restart_policy max_retry_count %v must not be negative", r.MaxRetryCount))
+		}
+		return docker.RestartOnFailure(r.MaxRetryCount), nil
+	default:
+		return docker.RestartPolicy{}, errors.New(fmt.Sprintf("restart_policy name %v must be one of no, on-failure or always", r.Name))
+	}
+}
+
+// A ulimit of a service container, e.g. {"name": "nofile", "soft": 1024, "hard": 2048}. A limit of -1 means unlimited.
+type Ulimit struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
+}
+
+// Set the restart policy and the resource limits of the service in the container runtime's host config, the settings that are
+// not in the service are left as they are. The memory limit of the host config must already be set because the reservation and
+// swap limits are checked against it. An error is returned if one of the settings is not valid.
+func (s *Service) SetHostConfigLimits(hc *docker.HostConfig) error {
+
+	if s.RestartPolicy != nil {
+		if rp, err := s.RestartPolicy.DockerRestartPolicy(); err != nil {
+			return err
+		} else {
+			hc.RestartPolicy = rp
+		}
+	}
+
+	for _, u := range s.Ulimits {
+		if u.Name == "" {
+			return errors.New(fmt.Sprintf("ulimit %v must have a name", u))
+		} else if u.Soft < -1 || u.Hard < -1 {
+			return errors.New(fmt.Sprintf("ulimit %v must not be less than -1", u))
+		} else if u.Hard != -1 && (u.Soft == -1 || u.Soft > u.Hard) {
+			return errors.New(fmt.Sprintf("ulimit %v soft limit must not be greater than the hard limit", u))
+		}
+		hc.Ulimits = append(hc.Ulimits, docker.ULimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+
+	if s.PidsLimit < -1 {
+		return errors.New(fmt.Sprintf("pids_limit %v must be -1 or greater", s.PidsLimit))
+	} else if s.PidsLimit != 0 {
+		pidsLimit := s.PidsLimit
+		hc.PidsLimit = &pidsLimit
+	}
+
+	for _, c := range s.CapDrop {
+		if c == "" {
+			return errors.New(fmt.Sprintf("cap_drop %v must not contain an empty capability", s.CapDrop))
+		}
+	}
+	if len(s.CapDrop) != 0 {
+		hc.CapDrop = s.CapDrop
+	}
+	if s.ReadOnly {
+		hc.ReadonlyRootfs = true
+	}
+
+	if s.ShmSizeMb < 0 {
+		return errors.New(fmt.Sprintf("shm_size_mb %v must not be negative", s.ShmSizeMb))
+	} else if s.ShmSizeMb != 0 {
+		hc.ShmSize = s.ShmSizeMb * 1024 * 1024
+	}
+
+	if s.MemReservationMb < 0 {
+		return errors.New(fmt.Sprintf("memory_reservation_mb %v must not be negative", s.MemReservationMb))
+	} else if hc.Memory != 0 && s.MemReservationMb*1024*1024 > hc.Memory {
+		return errors.New(fmt.Sprintf("memory_reservation_mb %v must not be greater than the memory limit of %v MB", s.MemReservationMb, hc.Memory/(1024*1024)))
+	} else if s.MemReservationMb != 0 {
+		hc.MemoryReservation = s.MemReservationMb * 1024 * 1024
+	}
+
+	if s.MaxMemSwapMb != 0 && s.MaxMemoryMb == 0 {
+		return errors.New(fmt.Sprintf("max_memory_swap_mb %v can only be set together with max_memory_mb", s.MaxMemSwapMb))
+	} else if s.MaxMemSwapMb == -1 {
+		hc.MemorySwap = -1
+	} else if s.MaxMemSwapMb < 0 {
+		return errors.New(fmt.Sprintf("max_memory_swap_mb %v must be -1 or greater", s.MaxMemSwapMb))
+	} else if s.MaxMemSwapMb != 0 {
+		if s.MaxMemSwapMb*1024*1024 < hc.Memory {
+			return errors.New(fmt.Sprintf("max_memory_swap_mb %v must not be less than the memory limit of %v MB", s.MaxMemSwapMb, hc.Memory/(1024*1024)))
+		}
+		hc.MemorySwap = s.MaxMemSwapMb * 1024 * 1024
+	}
+
+	return nil
+}
+
 type Port struct {
 	LocalhostOnly   bool   `json:"localhost_only,omitempty"`
 	PortAndProtocol string `json:"port_and_protocol"`
@@ -6,6 +6,7 @@ package containermessage
 import (
 	docker "github.com/fsouza/go-dockerclient"
 	"testing"
+	"time"
 )
 
 func Test_HasSpecificPortBinding(t *testing.T) {
@@ -179,3 +180,90 @@ func Test_AddSpecificPortBinding(t *testing.T) {
 		t.Errorf("Service should have 2 specific port bindings but not.")
 	}
 }
+
+func Test_HealthCheck(t *testing.T) {
+	hc := HealthCheck{Test: []string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}, Interval: "30s", Timeout: "5s", Retries: 3, StartPeriod: "1m"}
+
+	if dhc, err := hc.DockerHealthConfig(); err != nil {
+		t.Errorf("DockerHealthConfig for %v should not have returned an error: %v", hc, err)
+	} else if dhc.Interval != 30*time.Second || dhc.Timeout != 5*time.Second || dhc.StartPeriod != time.Minute || dhc.Retries != 3 || len(dhc.Test) != 2 {
+		t.Errorf("DockerHealthConfig for %v returned the wrong config: %v", hc, dhc)
+	}
+
+	// Omitted durations use the runtime defaults.
+	hc = HealthCheck{Test: []string{"CMD", "/bin/check"}}
+	if dhc, err := hc.DockerHealthConfig(); err != nil {
+		t.Errorf("DockerHealthConfig for %v should not have returned an error: %v", hc, err)
+	} else if dhc.Interval != 0 || dhc.Timeout != 0 || dhc.StartPeriod != 0 || dhc.Retries != 0 {
+		t.Errorf("DockerHealthConfig for %v returned the wrong config: %v", hc, dhc)
+	}
+
+	for _, invalid := range []HealthCheck{
+		{},
+		{Test: []string{"curl", "-f", "http://localhost/"}},
+		{Test: []string{"CMD"}},
+		{Test: []string{"CMD", "/bin/check"}, Interval: "30"},
+		{Test: []string{"CMD", "/bin/check"}, Timeout: "1us"},
+		{Test: []string{"CMD", "/bin/check"}, Retries: -1},
+	} {
+		if _, err := invalid.DockerHealthConfig(); err == nil {
+			t.Errorf("DockerHealthConfig for %v should have returned an error.", invalid)
+		}
+	}
+}
+
+func Test_SetHostConfigLimits(t *testing.T) {
+	s := Service{
+		RestartPolicy:    &RestartPolicy{Name: "on-failure", MaxRetryCount: 5},
+		Ulimits:          []Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+		PidsLimit:        100,
+		CapDrop:          []string{"ALL"},
+		ReadOnly:         true,
+		ShmSizeMb:        64,
+		MemReservationMb: 128,
+		MaxMemoryMb:      256,
+		MaxMemSwapMb:     512,
+	}
+
+	hc := docker.HostConfig{Memory: 256 * 1024 * 1024, RestartPolicy: docker.AlwaysRestart()}
+	if err := s.SetHostConfigLimits(&hc); err != nil {
+		t.Errorf("SetHostConfigLimits for %v should not have returned an error: %v", s, err)
+	} else if hc.RestartPolicy != docker.RestartOnFailure(5) || len(hc.Ulimits) != 1 || hc.Ulimits[0].Soft != 1024 || hc.PidsLimit == nil || *hc.PidsLimit != 100 {
+		t.Errorf("SetHostConfigLimits for %v returned the wrong host config: %v", s, hc)
+	} else if len(hc.CapDrop) != 1 || !hc.ReadonlyRootfs || hc.ShmSize != 64*1024*1024 || hc.MemoryReservation != 128*1024*1024 || hc.MemorySwap != 512*1024*1024 {
+		t.Errorf("SetHostConfigLimits for %v returned the wrong host config: %v", s, hc)
+	}
+
+	// Settings that are not in the service are left as they are.
+	s = Service{}
+	hc = docker.HostConfig{RestartPolicy: docker.AlwaysRestart()}
+	if err := s.SetHostConfigLimits(&hc); err != nil {
+		t.Errorf("SetHostConfigLimits for %v should not have returned an error: %v", s, err)
+	} else if hc.RestartPolicy != docker.AlwaysRestart() || hc.PidsLimit != nil || hc.MemorySwap != 0 {
+		t.Errorf("SetHostConfigLimits for %v returned the wrong host config: %v", s, hc)
+	}
+
+	for _, invalid := range []Service{
+		{RestartPolicy: &RestartPolicy{Name: "sometimes"}},
+		{RestartPolicy: &RestartPolicy{Name: "always", MaxRetryCount: 3}},
+		{Ulimits: []Ulimit{{Name: "nofile", Soft: 4096, Hard: 1024}}},
+		{PidsLimit: -2},
+		{ShmSizeMb: -1},
+		{MemReservationMb: 512},
+		{MaxMemoryMb: 256, MaxMemSwapMb: 128},
+	} {
+		hc := docker.HostConfig{Memory: 256 * 1024 * 1024}
+		if err := invalid.SetHostConfigLimits(&hc); err == nil {
+			t.Errorf("SetHostConfigLimits for %v should have returned an error.", invalid)
+		}
+	}
+
+	// The swap limit is only valid together with the memory limit of the service, not the default memory limit of the agent.
+	for _, swap := range []int64{512, -1} {
+		s = Service{MaxMemSwapMb: swap}
+		hc = docker.HostConfig{Memory: 256 * 1024 * 1024}
+		if err := s.SetHostConfigLimits(&hc); err == nil {
+			t.Errorf("SetHostConfigLimits for %v without a memory limit should have returned an error.", s)
+		}
+	}
+}
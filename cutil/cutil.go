@@ -436,6 +436,133 @@ func FormDockerImageName(domain, path, tag, digest string) string {
 	return image
 }
 
+// Returns the fully qualified repository of an image name, without the tag or digest, so that the different ways of naming
+// an image in docker hub can be compared. For example busybox:latest and docker.io/library/busybox are the same repository.
+func NormalizeImageRepository(image string) string {
+	domain, path, _, _ := ParseDockerImagePath(image)
+	if domain == "" || domain == "index.docker.io" {
+		domain = "docker.io"
+		if !strings.Contains(path, "/") {
+			path = "library/" + path
+		}
+	}
+	return domain + "/" + path
+}
+
+// Returns true if the image is the same repository and digest as one of the repo digests that the container runtime
+// reports for a local image. The repo digests have the form repository@digest. An image name without a digest never matches.
+func ImageDigestMatches(image string, repoDigests []string) bool {
+	_, _, _, digest := ParseDockerImagePath(image)
+	if digest == "" {
+		return false
+	}
+
+	repository := NormalizeImageRepository(image)
+	for _, repoDigest := range repoDigests {
+		if _, _, _, rDigest := ParseDockerImagePath(repoDigest); rDigest == digest && NormalizeImageRepository(repoDigest) == repository {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the image is one of the local images with the repo tags and repo digests that the container runtime
+// reports. An image named by digest is matched on its digest, any other image on its repository and tag. An image without
+// a tag has the latest tag.
+func ImageNameMatches(image string, repoTags []string, repoDigests []string) bool {
+	_, _, tag, digest := ParseDockerImagePath(image)
+	if digest != "" {
+		return ImageDigestMatches(image, repoDigests)
+	} else if tag == "" {
+		tag = "latest"
+	}
+
+	repository := NormalizeImageRepository(image)
+	for _, repoTag := range repoTags {
+		if _, _, rTag, _ := ParseDockerImagePath(repoTag); rTag == tag && NormalizeImageRepository(repoTag) == repository {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the repository prefix of a registry mirror rule in the form returned by NormalizeImageRepository.
+func normalizeMirrorPrefix(prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "index.docker.io" || strings.HasPrefix(prefix, "index.docker.io/") {
+		prefix = strings.TrimPrefix(prefix, "index.")
+	}
+	return prefix
+}
+
+// Returns the image name with the repository prefix replaced, keeping the tag and digest.
+func replaceImagePrefix(image string, repository string, prefix string, newPrefix string) string {
+	_, _, tag, digest := ParseDockerImagePath(image)
+	name := strings.TrimSuffix(newPrefix, "/") + strings.TrimPrefix(repository, prefix)
+	if tag != "" {
+		name += ":" + tag
+	}
+	if digest != "" {
+		name += "@" + digest
+	}
+	return name
+}
+
+// Returns true if the repository is the prefix or is below the prefix.
+func repositoryHasPrefix(repository string, prefix string) bool {
+	return prefix != "" && (repository == prefix || strings.HasPrefix(repository, prefix+"/"))
+}
+
+// Returns the names of the image in the registry mirrors that apply to it, in the order they should be tried, and whether the
+// image can also be pulled from its own registry. When several mirror rules apply, the one with the longest prefix is used.
+func MirroredImageNames(image string, mirrors []config.RegistryMirrorConfig) ([]string, bool) {
+	repository := NormalizeImageRepository(image)
+
+	var rule *config.RegistryMirrorConfig
+	prefix := ""
+	for i, m := range mirrors {
+		if p := normalizeMirrorPrefix(m.Prefix); repositoryHasPrefix(repository, p) && len(p) > len(prefix) {
+			rule = &mirrors[i]
+			prefix = p
+		}
+	}
+	if rule == nil {
+		return []string{}, true
+	}
+
+	names := make([]string, 0, len(rule.Mirrors))
+	for _, mirror := range rule.Mirrors {
+		names = append(names, replaceImagePrefix(image, repository, prefix, mirror))
+	}
+	return names, !rule.NoFallback || len(names) == 0
+}
+
+// Returns the name that an image pulled from a registry mirror has in its own registry. A name that is not in a mirror is
+// returned unchanged.
+func OriginalImageName(name string, mirrors []config.RegistryMirrorConfig) string {
+	repository := NormalizeImageRepository(name)
+	for _, m := range mirrors {
+		for _, mirror := range m.Mirrors {
+			if mirror = strings.TrimSuffix(mirror, "/"); repositoryHasPrefix(repository, mirror) {
+				return replaceImagePrefix(name, repository, mirror, normalizeMirrorPrefix(m.Prefix))
+			}
+		}
+	}
+	return name
+}
+
+// Returns the repo digests of a local image with the names they have in their own registry added for the digests of images
+// pulled from a registry mirror, so that the image can be checked against the original image name.
+func OriginalRepoDigests(repoDigests []string, mirrors []config.RegistryMirrorConfig) []string {
+	digests := append([]string{}, repoDigests...)
+	for _, repoDigest := range repoDigests {
+		if original := OriginalImageName(repoDigest, mirrors); original != repoDigest {
+			digests = append(digests, original)
+		}
+	}
+	return digests
+}
+
 func CopyMap(m1 map[string]interface{}, m2 map[string]interface{}) {
 	for k, v := range m1 {
 		m2[k] = v
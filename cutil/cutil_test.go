@@ -5,6 +5,7 @@ package cutil
 
 import (
 	"fmt"
+	"github.com/open-horizon/anax/config"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -173,6 +174,62 @@ func Test_ParseDockerImagePath_Other_Cases(t *testing.T) {
 
 }
 
+func Test_NormalizeImageRepository(t *testing.T) {
+	assert.Equal(t, "docker.io/library/busybox", NormalizeImageRepository("busybox:latest"), "Wrong repository for busybox:latest.")
+	assert.Equal(t, "docker.io/library/busybox", NormalizeImageRepository("index.docker.io/busybox"), "Wrong repository for index.docker.io/busybox.")
+	assert.Equal(t, "docker.io/myorg/myservice", NormalizeImageRepository("myorg/myservice@sha256:1234"), "Wrong repository for myorg/myservice@sha256:1234.")
+	assert.Equal(t, "mydomain.com:8080/x86_64/myservice", NormalizeImageRepository("mydomain.com:8080/x86_64/myservice:v1.0@sha256:1234"), "Wrong repository for mydomain.com:8080/x86_64/myservice:v1.0@sha256:1234.")
+}
+
+func Test_ImageDigestMatches(t *testing.T) {
+	repoDigests := []string{"mydomain.com/myservice@sha256:1234", "busybox@sha256:5678"}
+
+	assert.True(t, ImageDigestMatches("mydomain.com/myservice@sha256:1234", repoDigests), "The digest should match.")
+	assert.True(t, ImageDigestMatches("mydomain.com/myservice:v1.0@sha256:1234", repoDigests), "The digest should match with a tag.")
+	assert.True(t, ImageDigestMatches("docker.io/library/busybox@sha256:5678", repoDigests), "The docker hub digest should match.")
+	assert.False(t, ImageDigestMatches("mydomain.com/myservice@sha256:5678", repoDigests), "The digest of another repository should not match.")
+	assert.False(t, ImageDigestMatches("mydomain.com/myservice@sha256:9999", repoDigests), "Another digest should not match.")
+	assert.False(t, ImageDigestMatches("mydomain.com/myservice:v1.0", repoDigests), "An image without a digest should not match.")
+}
+
+func Test_ImageNameMatches(t *testing.T) {
+	repoTags := []string{"mydomain.com/myservice:v1.0", "busybox:latest"}
+	repoDigests := []string{"mydomain.com/myservice@sha256:1234"}
+
+	assert.True(t, ImageNameMatches("mydomain.com/myservice:v1.0", repoTags, repoDigests), "The tag should match.")
+	assert.True(t, ImageNameMatches("docker.io/library/busybox", repoTags, repoDigests), "The docker hub image without a tag should match the latest tag.")
+	assert.True(t, ImageNameMatches("mydomain.com/myservice:v2.0@sha256:1234", repoTags, repoDigests), "The digest should match.")
+	assert.False(t, ImageNameMatches("mydomain.com/myservice:v2.0", repoTags, repoDigests), "Another tag should not match.")
+	assert.False(t, ImageNameMatches("mydomain.com/myservice", repoTags, repoDigests), "The latest tag should not match.")
+	assert.False(t, ImageNameMatches("mydomain.com/myservice:v1.0@sha256:9999", repoTags, repoDigests), "Another digest should not match.")
+}
+
+func Test_MirroredImageNames(t *testing.T) {
+	mirrors := []config.RegistryMirrorConfig{
+		{Prefix: "docker.io", Mirrors: []string{"cache.example.com:5000"}},
+		{Prefix: "quay.io/myorg/", Mirrors: []string{"mirror.example.com/quay/myorg", "cache.example.com:5001"}, NoFallback: true},
+	}
+
+	names, fallback := MirroredImageNames("busybox:1.36", mirrors)
+	assert.Equal(t, []string{"cache.example.com:5000/library/busybox:1.36"}, names, "Wrong mirror names for a docker hub image.")
+	assert.True(t, fallback, "The docker hub image should fall back to its registry.")
+
+	names, fallback = MirroredImageNames("quay.io/myorg/myservice:1.0@sha256:1234", mirrors)
+	assert.Equal(t, []string{"mirror.example.com/quay/myorg/myservice:1.0@sha256:1234", "cache.example.com:5001/myservice:1.0@sha256:1234"}, names, "Wrong mirror names for a quay.io image.")
+	assert.False(t, fallback, "The quay.io image should not fall back to its registry.")
+
+	names, fallback = MirroredImageNames("quay.io/otherorg/myservice:1.0", mirrors)
+	assert.Empty(t, names, "The image should not be mirrored.")
+	assert.True(t, fallback, "The image that is not mirrored should be pulled from its registry.")
+
+	assert.Equal(t, "docker.io/library/busybox@sha256:5678", OriginalImageName("cache.example.com:5000/library/busybox@sha256:5678", mirrors), "Wrong original name of a docker hub image.")
+	assert.Equal(t, "quay.io/myorg/myservice:1.0", OriginalImageName("mirror.example.com/quay/myorg/myservice:1.0", mirrors), "Wrong original name of a quay.io image.")
+	assert.Equal(t, "mydomain.com/myservice:1.0", OriginalImageName("mydomain.com/myservice:1.0", mirrors), "The name of an image that is not mirrored should not change.")
+
+	repoDigests := OriginalRepoDigests([]string{"cache.example.com:5001/myservice@sha256:1234"}, mirrors)
+	assert.True(t, ImageDigestMatches("quay.io/myorg/myservice@sha256:1234", repoDigests), "The digest of the mirrored image should match the original image.")
+}
+
 func Test_FormDockerImageName(t *testing.T) {
 	image_name := FormDockerImageName("mydomain.com", "x86_64/gps", "1.0.1", "sha256:15315df0677ab1c7291/a822290731032b19462a9d29bdd4d4619df7cb0c0f567")
 	assert.Equal(t, "mydomain.com/x86_64/gps:1.0.1@sha256:15315df0677ab1c7291/a822290731032b19462a9d29bdd4d4619df7cb0c0f567", image_name, fmt.Sprintf("Wrong image name in %v.", image_name))
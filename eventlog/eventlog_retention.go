@@ -0,0 +1,27 @@
+package eventlog
+
+import (
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+)
+
+// Convert the event log retention limits in the agent config into the retention policy for the db.
+func GetRetentionPolicy(cfg config.EventLogRetentionConfig) persistence.EventLogRetentionPolicy {
+	policy := persistence.EventLogRetentionPolicy{
+		EventLogLimits: persistence.EventLogLimits{MaxAge: cfg.MaxAgeH * 3600, MaxCount: cfg.MaxCount},
+	}
+
+	for severity, limits := range cfg.SeverityLimits {
+		switch severity {
+		case persistence.SEVERITY_INFO, persistence.SEVERITY_WARN, persistence.SEVERITY_ERROR, persistence.SEVERITY_FATAL:
+			if policy.SeverityLimits == nil {
+				policy.SeverityLimits = make(map[string]persistence.EventLogLimits)
+			}
+			policy.SeverityLimits[severity] = persistence.EventLogLimits{MaxAge: limits.MaxAgeH * 3600, MaxCount: limits.MaxCount}
+		default:
+			glog.Warningf("Eventlog: ignoring the retention limits for unknown severity %v", severity)
+		}
+	}
+	return policy
+}
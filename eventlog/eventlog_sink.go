@@ -0,0 +1,217 @@
+package eventlog
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An event sink forwards the event logs saved by the agent to an external collector. Each configured sink gets all
+// the new event logs that match its severities and source types, independent of what is surfaced to the exchange.
+// The methods of a sink are only called from the goroutine that runs it, so a sink does not need to be thread safe.
+// A sink tries each send once. When a send fails, the sink keeps the event logs that were not sent and returns the
+// error, and the runner of the sink calls Flush again after a delay to retry them. The sink is not given new event
+// logs while it is retrying.
+type EventSink interface {
+	// Send an event log. The sink can hold the event log in a batch, but it must be sent when Flush returns.
+	Write(el persistence.EventLog) error
+
+	// Send the event logs held by the sink. Flush is called every FlushIntervalS seconds, to retry a failed send and
+	// before the sink is closed.
+	Flush() error
+
+	// Drop the event logs held by the sink after the retries of a failed send, and return how many were dropped.
+	Discard() int
+
+	// Release the connections held by the sink.
+	Close()
+}
+
+// Create an event sink from its config. The agent config is passed for the settings shared with the rest of the
+// agent, such as the HTTP client factory.
+type EventSinkFactory func(cfg config.EventSinkConfig, hcfg *config.HorizonConfig) (EventSink, error)
+
+var sinkTypesLock sync.Mutex
+var sinkTypes = map[string]EventSinkFactory{
+	EVENT_SINK_SYSLOG:  newSyslogSink,
+	EVENT_SINK_UNIX:    newUnixSink,
+	EVENT_SINK_WEBHOOK: newWebhookSink,
+}
+
+const EVENT_SINK_SYSLOG = "syslog"
+const EVENT_SINK_UNIX = "unix"
+const EVENT_SINK_WEBHOOK = "webhook"
+
+// Add a type of event sink that can be configured in the Type field of the EventSinks config. A type that is already
+// registered is replaced.
+func RegisterEventSinkType(sinkType string, factory EventSinkFactory) {
+	sinkTypesLock.Lock()
+	defer sinkTypesLock.Unlock()
+	sinkTypes[sinkType] = factory
+}
+
+// The delay before the first retry of a failed send, it doubles for each retry.
+var sinkRetryDelay = time.Second
+
+// The runner of one sink, it reads the new event logs from a subscription and passes them to the sink. When a send
+// fails, the runner stops reading the subscription until the retry succeeds or the retries run out, so the event
+// logs saved in the meantime wait in the subscription buffer. When the buffer is full, the event logs are dropped and
+// counted by the subscription. The runner never sleeps, so it can always be stopped.
+type sinkRunner struct {
+	cfg         config.EventSinkConfig
+	sink        EventSink
+	sub         *Subscriber
+	severities  map[string]bool
+	sourceTypes map[string]bool
+	done        chan struct{}
+	stopped     chan struct{}
+	failures    int              // the number of times in a row that a send has failed
+	retry       <-chan time.Time // fires when a failed send is to be retried, nil when no send has failed
+}
+
+var sinkRunnersLock sync.Mutex
+var sinkRunners []*sinkRunner
+
+// Start forwarding the event logs to the sinks in the agent config. The sinks that cannot be created are skipped,
+// and the errors for them are returned together.
+func StartEventSinks(hcfg *config.HorizonConfig) error {
+	sinkRunnersLock.Lock()
+	defer sinkRunnersLock.Unlock()
+
+	errs := make([]string, 0)
+	for _, cfg := range hcfg.Edge.EventSinks {
+		sinkTypesLock.Lock()
+		factory, ok := sinkTypes[cfg.Type]
+		sinkTypesLock.Unlock()
+
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown event sink type %v", cfg.Type))
+			continue
+		}
+		sink, err := factory(cfg, hcfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("unable to create %v event sink for %v: %v", cfg.Type, cfg.Address, err))
+			continue
+		}
+
+		r := &sinkRunner{
+			cfg:         cfg,
+			sink:        sink,
+			sub:         Subscribe(nil, i18n.GetMessagePrinter()),
+			severities:  stringSet(cfg.Severities),
+			sourceTypes: stringSet(cfg.SourceTypes),
+			done:        make(chan struct{}),
+			stopped:     make(chan struct{}),
+		}
+		sinkRunners = append(sinkRunners, r)
+		go r.run()
+		glog.V(3).Infof("Eventlog: started event sink %v", cfg)
+	}
+
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Stop the sinks, sending the event logs they have batched.
+func StopEventSinks() {
+	sinkRunnersLock.Lock()
+	defer sinkRunnersLock.Unlock()
+
+	for _, r := range sinkRunners {
+		close(r.done)
+		<-r.stopped
+	}
+	sinkRunners = nil
+}
+
+func (r *sinkRunner) run() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(time.Duration(r.cfg.GetFlushIntervalS()) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		// While a failed send is waiting to be retried, the new event logs are left in the subscription.
+		events := r.sub.Events
+		if r.retry != nil {
+			events = nil
+		}
+
+		select {
+		case el := <-events:
+			if r.matches(el) {
+				r.send(func() error { return r.sink.Write(el) })
+			}
+		case <-ticker.C:
+			if r.retry == nil {
+				r.send(r.sink.Flush)
+			}
+			r.logDropped()
+		case <-r.retry:
+			r.send(r.sink.Flush)
+		case <-r.done:
+			// The event logs that are waiting are given one more try, there is no time left to retry them.
+			Unsubscribe(r.sub)
+			for el := range r.sub.Events {
+				if r.matches(el) {
+					r.sink.Write(el)
+				}
+			}
+			if err := r.sink.Flush(); err != nil {
+				glog.Errorf("Eventlog: %v event sink %v dropped %v event logs while stopping, error: %v", r.cfg.Type, r.cfg.Address, r.sink.Discard(), err)
+			}
+			r.logDropped()
+			r.sink.Close()
+			return
+		}
+	}
+}
+
+// Run a send of the sink. When it fails, a retry is scheduled with a delay that doubles for each failure. When it has
+// been retried MaxRetries times, the event logs held by the sink are dropped.
+func (r *sinkRunner) send(send func() error) {
+	err := send()
+	if err == nil {
+		r.failures = 0
+		r.retry = nil
+		return
+	}
+
+	r.failures += 1
+	if r.failures > r.cfg.GetMaxRetries() {
+		glog.Errorf("Eventlog: %v event sink %v dropped %v event logs after %v retries, error: %v", r.cfg.Type, r.cfg.Address, r.sink.Discard(), r.cfg.GetMaxRetries(), err)
+		r.failures = 0
+		r.retry = nil
+		return
+	}
+
+	delay := sinkRetryDelay << (r.failures - 1)
+	glog.Warningf("Eventlog: %v event sink %v unable to send event logs, retrying in %v, error: %v", r.cfg.Type, r.cfg.Address, delay, err)
+	r.retry = time.After(delay)
+}
+
+func (r *sinkRunner) logDropped() {
+	if dropped := r.sub.Dropped(); dropped != 0 {
+		glog.Warningf("Eventlog: %v event sink %v is not keeping up, %v event logs were not forwarded", r.cfg.Type, r.cfg.Address, dropped)
+	}
+}
+
+func (r *sinkRunner) matches(el persistence.EventLog) bool {
+	return (len(r.severities) == 0 || r.severities[el.Severity]) && (len(r.sourceTypes) == 0 || r.sourceTypes[el.SourceType])
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,132 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// The syslog sink sends each event log as an RFC 5424 message. The MSGID is the event code and the MSG is the event
+// log in JSON, so that the collector gets the event source too. Messages sent over tcp are framed with their length
+// as described in RFC 6587. The local syslog daemon or journald is reached with unix:///dev/log. The messages that
+// could not be sent are kept in order until they are sent or discarded.
+type syslogSink struct {
+	network  string
+	address  string
+	facility int
+	hostname string
+	conn     net.Conn
+	pending  []string
+}
+
+func newSyslogSink(cfg config.EventSinkConfig, hcfg *config.HorizonConfig) (EventSink, error) {
+	u, err := url.Parse(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syslogSink{
+		facility: cfg.GetFacility(),
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		s.network, s.address = u.Scheme, u.Host
+	case "unix":
+		s.network, s.address = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("the address must start with udp://, tcp:// or unix://")
+	}
+	if s.facility < 0 || s.facility > 23 {
+		return nil, fmt.Errorf("facility %v is not between 0 and 23", s.facility)
+	}
+
+	if s.hostname, err = os.Hostname(); err != nil {
+		s.hostname = "-"
+	}
+	return s, nil
+}
+
+func (s *syslogSink) Write(el persistence.EventLog) error {
+	msg, err := formatSyslogMessage(el, s.facility, s.hostname, os.Getpid())
+	if err != nil {
+		return err
+	}
+	if s.network == "tcp" {
+		msg = fmt.Sprintf("%v %v", len(msg), msg)
+	}
+
+	s.pending = append(s.pending, msg)
+	return s.Flush()
+}
+
+func (s *syslogSink) Flush() error {
+	for len(s.pending) != 0 {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.address, 10*time.Second)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+		if _, err := s.conn.Write([]byte(s.pending[0])); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+		s.pending = s.pending[1:]
+	}
+	return nil
+}
+
+func (s *syslogSink) Discard() int {
+	dropped := len(s.pending)
+	s.pending = nil
+	return dropped
+}
+
+func (s *syslogSink) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// Convert the event log severity to a syslog severity.
+func syslogSeverity(severity string) int {
+	switch severity {
+	case persistence.SEVERITY_FATAL:
+		return 2 // critical
+	case persistence.SEVERITY_ERROR:
+		return 3 // error
+	case persistence.SEVERITY_WARN:
+		return 4 // warning
+	case persistence.SEVERITY_INFO:
+		return 6 // informational
+	default:
+		return 5 // notice
+	}
+}
+
+// Format the event log as an RFC 5424 message without structured data.
+func formatSyslogMessage(el persistence.EventLog, facility int, hostname string, pid int) (string, error) {
+	body, err := json.Marshal(el)
+	if err != nil {
+		return "", err
+	}
+
+	// The MSGID is limited to 32 characters.
+	msgId := el.EventCode
+	if msgId == "" {
+		msgId = "-"
+	} else if len(msgId) > 32 {
+		msgId = msgId[:32]
+	}
+
+	timestamp := time.Unix(int64(el.Timestamp), 0).UTC().Format(time.RFC3339)
+	return fmt.Sprintf("<%v>1 %v %v anax %v %v - %s", facility*8+syslogSeverity(el.Severity), timestamp, hostname, pid, msgId, body), nil
+}
@@ -0,0 +1,236 @@
+//go:build unit
+// +build unit
+
+package eventlog
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A sink that records the event logs sent to it. The sends fail while failures is not 0.
+type testSink struct {
+	lock      sync.Mutex
+	held      []persistence.EventLog
+	written   []persistence.EventLog
+	failures  int
+	sends     int
+	discarded int
+	closed    bool
+}
+
+func (s *testSink) Write(el persistence.EventLog) error {
+	s.lock.Lock()
+	s.held = append(s.held, el)
+	s.lock.Unlock()
+	return s.Flush()
+}
+
+func (s *testSink) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.held) == 0 {
+		return nil
+	}
+	s.sends += 1
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("collector unavailable")
+	}
+	s.written = append(s.written, s.held...)
+	s.held = nil
+	return nil
+}
+
+func (s *testSink) Discard() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	dropped := len(s.held)
+	s.discarded += dropped
+	s.held = nil
+	return dropped
+}
+
+// Returns the number of event logs sent and discarded by the sink.
+func (s *testSink) counts() (int, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.written), s.discarded
+}
+
+func (s *testSink) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+}
+
+func Test_EventSinks(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	sink := &testSink{}
+	RegisterEventSinkType("test", func(cfg config.EventSinkConfig, hcfg *config.HorizonConfig) (EventSink, error) {
+		return sink, nil
+	})
+
+	hcfg := &config.HorizonConfig{Edge: config.Config{EventSinks: []config.EventSinkConfig{
+		{Type: "test", Severities: []string{persistence.SEVERITY_ERROR}},
+		{Type: "nosuchtype"},
+	}}}
+	err = StartEventSinks(hcfg)
+	assert.NotNil(t, err, "an unknown sink type should be reported")
+	assert.True(t, strings.Contains(err.Error(), "nosuchtype"), "the error should name the unknown sink type")
+
+	LogExchangeEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("exchange %v is up", "ex1"), persistence.EC_EXCHANGE_ERROR, "http://exchange.com/v1")
+	LogExchangeEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta("exchange %v is down", "ex1"), persistence.EC_EXCHANGE_ERROR, "http://exchange.com/v1")
+
+	// Stopping the sinks passes the event logs that are waiting to the sink before it is closed.
+	StopEventSinks()
+
+	assert.True(t, sink.closed, "the sink should be closed")
+	if assert.Equal(t, 1, len(sink.written), "only the error should be forwarded") {
+		assert.Equal(t, "exchange ex1 is down", sink.written[0].Message, "the message should be translated")
+	}
+}
+
+func Test_EventSinkRetries(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	sinkRetryDelay = time.Millisecond
+
+	sink := &testSink{failures: 2}
+	RegisterEventSinkType("retrytest", func(cfg config.EventSinkConfig, hcfg *config.HorizonConfig) (EventSink, error) {
+		return sink, nil
+	})
+
+	hcfg := &config.HorizonConfig{Edge: config.Config{EventSinks: []config.EventSinkConfig{{Type: "retrytest", MaxRetries: 2}}}}
+	assert.Nil(t, StartEventSinks(hcfg))
+	defer StopEventSinks()
+
+	// Wait for the runner of the sink, it does not sleep while it waits to retry.
+	waitFor := func(sent int, discarded int) bool {
+		for i := 0; i < 200; i++ {
+			if s, d := sink.counts(); s == sent && d == discarded {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	// The event log is sent by the second retry.
+	LogExchangeEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta("exchange %v is down", "ex1"), persistence.EC_EXCHANGE_ERROR, "http://exchange.com/v1")
+	assert.True(t, waitFor(1, 0), "the event log should be sent after the retries")
+
+	// The event log is dropped when the retries run out.
+	sink.lock.Lock()
+	sink.failures = 10
+	sink.lock.Unlock()
+	LogExchangeEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta("exchange %v is down", "ex2"), persistence.EC_EXCHANGE_ERROR, "http://exchange.com/v1")
+	assert.True(t, waitFor(1, 1), "the event log should be dropped after the retries")
+
+	sink.lock.Lock()
+	assert.Equal(t, 6, sink.sends, "each event log should be tried once and retried twice")
+	sink.lock.Unlock()
+}
+
+func Test_FormatSyslogMessage(t *testing.T) {
+
+	source := persistence.NewNodeEventSource("mynode", "myorg", "", persistence.CONFIGSTATE_CONFIGURED)
+	el := persistence.NewEventLog(persistence.SEVERITY_ERROR, persistence.NewMessageMeta("node failed"), persistence.EC_NODE_CONFIG_REG_COMPLETE, persistence.SRC_TYPE_NODE, *source)
+	el.Id = "7"
+	el.Timestamp = 1600000000
+
+	msg, err := formatSyslogMessage(*el, 3, "myhost", 42)
+	assert.Nil(t, err)
+
+	// facility 3 * 8 + severity error 3
+	prefix := "<27>1 2020-09-13T12:26:40Z myhost anax 42 " + persistence.EC_NODE_CONFIG_REG_COMPLETE[:32] + " - "
+	if assert.True(t, strings.HasPrefix(msg, prefix), "wrong syslog header: %v", msg) {
+		var body map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(msg[len(prefix):]), &body), "the message should be the event log in JSON")
+		assert.Equal(t, "7", body["record_id"])
+	}
+}
+
+func Test_WebhookSink(t *testing.T) {
+
+	var lock sync.Mutex
+	batches := make([][]persistence.EventLog, 0)
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		} else if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []persistence.EventLog
+		json.NewDecoder(r.Body).Decode(&batch)
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	hcfg := &config.HorizonConfig{Collaborators: config.Collaborators{HTTPClientFactory: &config.HTTPClientFactory{
+		NewHTTPClient: func(overrideTimeoutS *uint) *http.Client { return server.Client() },
+	}}}
+	cfg := config.EventSinkConfig{Type: EVENT_SINK_WEBHOOK, Address: server.URL, BatchSize: 2, Headers: map[string]string{"Authorization": "Bearer token"}}
+	sink, err := newWebhookSink(cfg, hcfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first batch is sent when it is full. It fails once and is kept, so that the retry sends it.
+	for i := 0; i < 2; i++ {
+		el := persistence.EventLog{}
+		el.Id = string(rune('1' + i))
+		if i == 0 {
+			assert.Nil(t, sink.Write(el))
+		} else {
+			assert.NotNil(t, sink.Write(el), "the failed send should be reported")
+		}
+	}
+	assert.Nil(t, sink.Flush(), "the retry should send the batch")
+	assert.Equal(t, 1, len(batches), "the full batch should be sent")
+	assert.Equal(t, 2, len(batches[0]), "the batch should hold 2 event logs")
+
+	// The rest are sent by a flush.
+	el := persistence.EventLog{}
+	el.Id = "3"
+	assert.Nil(t, sink.Write(el))
+	assert.Nil(t, sink.Flush())
+	assert.Equal(t, 2, len(batches), "the partial batch should be sent by the flush")
+	assert.Equal(t, "3", batches[1][0].Id)
+
+	// A batch that keeps failing is kept until it is discarded.
+	failures = 10
+	sink.Write(persistence.EventLog{})
+	assert.NotNil(t, sink.Flush(), "the failed batch should be reported")
+	assert.Equal(t, 1, sink.Discard(), "the failed batch should be discarded")
+	assert.Nil(t, sink.Flush(), "the failed batch should have been dropped")
+
+	_, err = newWebhookSink(config.EventSinkConfig{Address: "ftp://host/path"}, hcfg)
+	assert.NotNil(t, err, "only http and https addresses are allowed")
+}
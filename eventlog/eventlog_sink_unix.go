@@ -0,0 +1,65 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"net"
+	"time"
+)
+
+// The unix sink writes each event log as one line of JSON to a local stream socket, such as the input of a log
+// shipper. The connection is opened again when a write fails, and the lines that could not be written are kept in
+// order until they are written or discarded.
+type unixSink struct {
+	path    string
+	conn    net.Conn
+	pending [][]byte
+}
+
+func newUnixSink(cfg config.EventSinkConfig, hcfg *config.HorizonConfig) (EventSink, error) {
+	return &unixSink{path: cfg.Address}, nil
+}
+
+func (s *unixSink) Write(el persistence.EventLog) error {
+	line, err := json.Marshal(el)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.pending = append(s.pending, line)
+	return s.Flush()
+}
+
+func (s *unixSink) Flush() error {
+	for len(s.pending) != 0 {
+		if s.conn == nil {
+			conn, err := net.DialTimeout("unix", s.path, 10*time.Second)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+		if _, err := s.conn.Write(s.pending[0]); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+		s.pending = s.pending[1:]
+	}
+	return nil
+}
+
+func (s *unixSink) Discard() int {
+	dropped := len(s.pending)
+	s.pending = nil
+	return dropped
+}
+
+func (s *unixSink) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
@@ -0,0 +1,90 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// The webhook sink POSTs the event logs to a URL as a JSON array. The event logs are sent in batches of up to
+// BatchSize records, or when FlushIntervalS has passed. A batch that fails is kept to be sent again, and it is
+// dropped when the retries run out.
+type webhookSink struct {
+	url        string
+	headers    map[string]string
+	batchSize  int
+	httpClient *http.Client
+	batch      []persistence.EventLog
+}
+
+func newWebhookSink(cfg config.EventSinkConfig, hcfg *config.HorizonConfig) (EventSink, error) {
+	if u, err := url.Parse(cfg.Address); err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("the address must be an http or https URL")
+	}
+
+	return &webhookSink{
+		url:        cfg.Address,
+		headers:    cfg.Headers,
+		batchSize:  cfg.GetBatchSize(),
+		httpClient: hcfg.Collaborators.HTTPClientFactory.NewHTTPClient(nil),
+		batch:      make([]persistence.EventLog, 0),
+	}, nil
+}
+
+func (s *webhookSink) Write(el persistence.EventLog) error {
+	s.batch = append(s.batch, el)
+	if len(s.batch) >= s.batchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *webhookSink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(s.batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for h, v := range s.headers {
+		req.Header.Set(h, v)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %v for %v event logs", resp.StatusCode, len(s.batch))
+	}
+	s.batch = make([]persistence.EventLog, 0)
+	return nil
+}
+
+func (s *webhookSink) Discard() int {
+	dropped := len(s.batch)
+	s.batch = make([]persistence.EventLog, 0)
+	return dropped
+}
+
+func (s *webhookSink) Close() {
+	s.httpClient.CloseIdleConnections()
+}
@@ -0,0 +1,88 @@
+package eventlog
+
+import (
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	"golang.org/x/text/message"
+	"sync"
+)
+
+// The number of event log records that can be waiting for a subscriber before new records are dropped.
+const SUBSCRIBER_BUFFER_SIZE = 100
+
+// A subscriber receives the event log records that match its selectors as soon as they are saved in the db. The
+// message of each record is translated with the subscriber's message printer before the selectors are checked, the
+// same as GetEventLogs does.
+type Subscriber struct {
+	Events          chan persistence.EventLog
+	baseSelectors   map[string][]persistence.Selector
+	sourceSelectors map[string][]persistence.Selector
+	msgPrinter      *message.Printer
+	dropped         uint64
+}
+
+var subscribersLock sync.Mutex
+var subscribers = make(map[*Subscriber]bool)
+
+// Add a subscriber for new event log records. The caller must call Unsubscribe when it is done with the subscriber.
+func Subscribe(selectors map[string][]persistence.Selector, msgPrinter *message.Printer) *Subscriber {
+	// separate base selectors from the source selectors, the same as FindEventLogsWithSelectors
+	baseSelectors, sourceSelectors := persistence.GroupSelectors(selectors)
+
+	sub := &Subscriber{
+		Events:          make(chan persistence.EventLog, SUBSCRIBER_BUFFER_SIZE),
+		baseSelectors:   baseSelectors,
+		sourceSelectors: sourceSelectors,
+		msgPrinter:      msgPrinter,
+	}
+
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	subscribers[sub] = true
+	return sub
+}
+
+// Remove the subscriber and close its channel.
+func Unsubscribe(sub *Subscriber) {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	if subscribers[sub] {
+		delete(subscribers, sub)
+		close(sub.Events)
+	}
+}
+
+// Returns the number of records dropped because the subscriber was not keeping up, and resets the count.
+func (s *Subscriber) Dropped() uint64 {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
+
+// Pass a newly saved event log record to the subscribers. This never blocks the caller, a record is dropped for a
+// subscriber that has a full buffer.
+func publish(el persistence.EventLog) {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+
+	for sub := range subscribers {
+		subEl := el
+		if sub.msgPrinter != nil {
+			subEl.EventLogBase.TranslateMessage(sub.msgPrinter)
+		}
+		if !subEl.EventLogBase.Matches(sub.baseSelectors) {
+			continue
+		} else if subEl.Source == nil || !subEl.Source.Matches(sub.sourceSelectors) {
+			continue
+		}
+
+		select {
+		case sub.Events <- subEl:
+		default:
+			sub.dropped++
+			glog.Warningf("Eventlog: subscriber is not keeping up, dropped event log record %v", el.Id)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+//go:build unit
+// +build unit
+
+package eventlog
+
+import (
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func Test_Subscribe(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	msgPrinter := i18n.GetMessagePrinterWithLocale("en")
+	errorsOnly := Subscribe(map[string][]persistence.Selector{"severity": {{"=", persistence.SEVERITY_ERROR}}}, msgPrinter)
+	all := Subscribe(map[string][]persistence.Selector{}, msgPrinter)
+
+	if err := LogExchangeEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("exchange %v is up", "ex1"), persistence.EC_EXCHANGE_ERROR, "http://exchange.com/v1"); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	} else if err := LogExchangeEvent(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta("exchange %v is down", "ex1"), persistence.EC_EXCHANGE_ERROR, "http://exchange.com/v1"); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	}
+
+	assert.Equal(t, 2, len(all.Events), "both records should be passed to the subscriber without selectors")
+	assert.Equal(t, 1, len(errorsOnly.Events), "only the error record should be passed to the subscriber")
+
+	el := <-errorsOnly.Events
+	assert.Equal(t, "2", el.Id, "the record should have its db record id")
+	assert.Equal(t, "exchange ex1 is down", el.Message, "the message should be translated")
+
+	// A subscriber that does not keep up misses records instead of blocking the caller.
+	Unsubscribe(errorsOnly)
+	for i := 0; i < SUBSCRIBER_BUFFER_SIZE; i++ {
+		LogDatabaseEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("database event"), persistence.EC_DATABASE_ERROR)
+	}
+	assert.Equal(t, SUBSCRIBER_BUFFER_SIZE, len(all.Events), "the subscriber buffer should be full")
+	assert.Equal(t, uint64(2), all.Dropped(), "2 records should have been dropped")
+	assert.Equal(t, uint64(0), all.Dropped(), "the dropped count should be reset")
+
+	Unsubscribe(all)
+	Unsubscribe(all)
+	if _, ok := <-errorsOnly.Events; ok {
+		t.Errorf("the channel of a removed subscriber should be closed")
+	}
+}
+
+func Test_Subscribe_SourceSelectors(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	msgPrinter := i18n.GetMessagePrinterWithLocale("en")
+	ag1 := Subscribe(map[string][]persistence.Selector{"agreement_id": {{"=", "ag1"}}}, msgPrinter)
+	defer Unsubscribe(ag1)
+	gps := Subscribe(map[string][]persistence.Selector{"severity": {{"=", persistence.SEVERITY_INFO}}, "service_url": {{"~", "gps"}}}, msgPrinter)
+	defer Unsubscribe(gps)
+
+	workload := persistence.WorkloadInfo{URL: "https://bluehorizon.network/services/netspeed", Org: "e2edev", Version: "1.0.0", Arch: "amd64"}
+	if err := LogAgreementEvent2(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("agreement %v reached", "ag1"), persistence.EC_AGREEMENT_REACHED, "ag1", workload, persistence.ServiceSpecs{}, "consumer", "Basic"); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	} else if err := LogAgreementEvent2(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("agreement %v reached", "ag2"), persistence.EC_AGREEMENT_REACHED, "ag2", workload, persistence.ServiceSpecs{}, "consumer", "Basic"); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	} else if err := LogServiceEvent2(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("service %v started", "gps"), persistence.EC_START_SERVICE, "", "https://bluehorizon.network/services/gps", "e2edev", "1.0.0", "amd64", []string{"ag1"}); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	} else if err := LogServiceEvent2(db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta("service %v failed", "gps"), persistence.EC_ERROR_START_SERVICE, "", "https://bluehorizon.network/services/gps", "e2edev", "1.0.0", "amd64", []string{"ag1"}); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	}
+
+	// the service records carry the agreement ids of the service
+	assert.Equal(t, 3, len(ag1.Events), "only the records of agreement ag1 should be passed to the subscriber")
+	for _, id := range []string{"1", "3", "4"} {
+		if el := <-ag1.Events; el.Id != id {
+			t.Errorf("the subscriber should get record %v, but got %v", id, el)
+		}
+	}
+
+	assert.Equal(t, 1, len(gps.Events), "only the info record of the gps service should be passed to the subscriber")
+	if el := <-gps.Events; el.Message != "service gps started" {
+		t.Errorf("the subscriber should get the gps start record, but got %v", el)
+	}
+}
+
+func Test_Subscribe_Order(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	sub := Subscribe(map[string][]persistence.Selector{}, i18n.GetMessagePrinterWithLocale("en"))
+	defer Unsubscribe(sub)
+
+	// Records saved at the same time are passed to the subscribers in record id order.
+	var wg sync.WaitGroup
+	for i := 0; i < SUBSCRIBER_BUFFER_SIZE; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			LogDatabaseEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("database event"), persistence.EC_DATABASE_ERROR)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, SUBSCRIBER_BUFFER_SIZE, len(sub.Events), "all the records should be passed to the subscriber")
+	for i := 1; i <= SUBSCRIBER_BUFFER_SIZE; i++ {
+		el := <-sub.Events
+		assert.Equal(t, strconv.Itoa(i), el.Id, "the records should be passed in record id order")
+	}
+}
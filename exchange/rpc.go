@@ -16,6 +16,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/semanticversion"
 	"github.com/open-horizon/edge-sync-service/common"
 )
@@ -47,9 +48,33 @@ type PutPostDeleteStandardResponse struct {
 	Msg  string `json:"msg"`
 }
 
+// Metrics for calls made to the exchange.
+var (
+	exchangeRequests = metrics.DefaultRegistry.NewCounterVec("anax_exchange_requests_total",
+		"Number of exchange API invocations by HTTP method and result (success, error or transport_error).", "method", "result")
+	exchangeRequestDuration = metrics.DefaultRegistry.NewHistogramVec("anax_exchange_request_duration_seconds",
+		"Latency of exchange API invocations by HTTP method.", nil, "method")
+)
+
 // This function is used to invoke an exchange API
 // For GET, the given resp parameter will be untouched when http returns code 404.
 func InvokeExchange(httpClient *http.Client, method string, urlPath string, user string, pw string, params interface{}, resp *interface{}) (error, error) {
+	start := time.Now()
+	err, tpErr := invokeExchange(httpClient, method, urlPath, user, pw, params, resp)
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	} else if tpErr != nil {
+		result = "transport_error"
+	}
+	exchangeRequests.Inc(method, result)
+	exchangeRequestDuration.Observe(time.Since(start).Seconds(), method)
+
+	return err, tpErr
+}
+
+func invokeExchange(httpClient *http.Client, method string, urlPath string, user string, pw string, params interface{}, resp *interface{}) (error, error) {
 
 	if len(method) == 0 {
 		return errors.New(fmt.Sprintf("Error invoking exchange, method name must be specified")), nil
@@ -0,0 +1,291 @@
+package exchangecommon
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/i18n"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MIN_MAINTENANCE_WINDOW_DURATION = 60        // 1 minute
+const MAX_MAINTENANCE_WINDOW_DURATION = 7 * 86400 // 1 week
+
+// A recurring maintenance window. Non-urgent workload changes on a node, such as service upgrades and agreement
+// cancellations caused by policy changes, are only made while a maintenance window is open.
+// The schedule is a standard 5 field cron expression (minute hour day-of-month month day-of-week) that
+// specifies when the window opens. The window stays open for the given number of seconds.
+type MaintenanceWindow struct {
+	Schedule string `json:"schedule"`           // e.g. "0 2 * * 6" opens the window at 2am every Saturday
+	Duration int    `json:"duration"`           // the number of seconds the window stays open
+	Timezone string `json:"timezone,omitempty"` // an IANA time zone name such as "America/New_York", the default is UTC
+}
+
+func (m MaintenanceWindow) String() string {
+	return fmt.Sprintf("Schedule: %v, Duration: %v, Timezone: %v", m.Schedule, m.Duration, m.Timezone)
+}
+
+// A list of maintenance windows. Workload changes are allowed when any one of the windows is open. An empty
+// list means there are no restrictions.
+type MaintenanceWindows []MaintenanceWindow
+
+func (m MaintenanceWindows) DeepCopy() MaintenanceWindows {
+	if m == nil {
+		return nil
+	}
+	copyM := make(MaintenanceWindows, len(m))
+	copy(copyM, m)
+	return copyM
+}
+
+func (m MaintenanceWindows) Validate() error {
+	for _, mw := range m {
+		if err := mw.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if workload changes are allowed at the given time.
+func (m MaintenanceWindows) IsOpen(t time.Time) bool {
+	if len(m) == 0 {
+		return true
+	}
+	for _, mw := range m {
+		if open, err := mw.IsOpen(t); err == nil && open {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the next time one of the windows opens after the given time. The zero time is returned if none of the
+// windows ever open.
+func (m MaintenanceWindows) NextOpen(t time.Time) time.Time {
+	next := time.Time{}
+	for _, mw := range m {
+		if n, err := mw.NextOpen(t); err == nil && !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+func (mw MaintenanceWindow) Validate() error {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if _, err := parseCronSchedule(mw.Schedule); err != nil {
+		return fmt.Errorf(msgPrinter.Sprintf("maintenance window schedule %v is not valid: %v", mw.Schedule, err))
+	} else if mw.Duration < MIN_MAINTENANCE_WINDOW_DURATION || mw.Duration > MAX_MAINTENANCE_WINDOW_DURATION {
+		return fmt.Errorf(msgPrinter.Sprintf("maintenance window duration must be between %v and %v seconds.", MIN_MAINTENANCE_WINDOW_DURATION, MAX_MAINTENANCE_WINDOW_DURATION))
+	} else if _, err := mw.location(); err != nil {
+		return fmt.Errorf(msgPrinter.Sprintf("maintenance window timezone %v is not valid: %v", mw.Timezone, err))
+	}
+	return nil
+}
+
+// Returns true if the window is open at the given time, that is if the window opened within the last Duration
+// seconds.
+func (mw MaintenanceWindow) IsOpen(t time.Time) (bool, error) {
+	sched, err := parseCronSchedule(mw.Schedule)
+	if err != nil {
+		return false, err
+	}
+	loc, err := mw.location()
+	if err != nil {
+		return false, err
+	}
+
+	opened := sched.next(t.Add(-time.Duration(mw.Duration) * time.Second).In(loc))
+	return !opened.IsZero() && !opened.After(t), nil
+}
+
+// Returns the next time the window opens after the given time, or the zero time if it never opens.
+func (mw MaintenanceWindow) NextOpen(t time.Time) (time.Time, error) {
+	sched, err := parseCronSchedule(mw.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := mw.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return sched.next(t.In(loc)), nil
+}
+
+func (mw MaintenanceWindow) location() (*time.Location, error) {
+	if mw.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(mw.Timezone)
+}
+
+// A parsed cron schedule, each field is the set of allowed values.
+type cronSchedule struct {
+	minute     map[int]bool
+	hour       map[int]bool
+	dayOfMonth map[int]bool
+	month      map[int]bool
+	dayOfWeek  map[int]bool
+	domStar    bool
+	dowStar    bool
+}
+
+// The number of years searched for the next time a schedule matches. February 29th can be 8 years away.
+const CRON_SEARCH_YEARS = 8
+
+// Day of month and day of week follow the usual cron rule, when both are restricted a time matches if either one does.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.dayOfMonth[t.Day()]
+	dowMatch := c.dayOfWeek[int(t.Weekday())]
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Returns the first minute after the given time that matches the schedule, in the location of the given time, or the
+// zero time if the schedule never matches. The fields are matched from the month down to the minute, a field that does
+// not match moves the time to the start of the next month, day, hour or minute.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(CRON_SEARCH_YEARS, 0, 0)
+	for t.Before(end) {
+		if !c.month[int(t.Month())] {
+			t = startOfDay(t.Year(), t.Month()+1, 1, loc)
+		} else if !c.dayMatches(t) {
+			t = startOfDay(t.Year(), t.Month(), t.Day()+1, loc)
+		} else if !c.hour[t.Hour()] {
+			// The next hour is found by elapsed time, the wall clock hour after the current one may not exist.
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		} else if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+		} else {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Returns the first minute of the given day in the location, the day is normalized the same as time.Date does. Where the
+// clocks go forward at midnight, the day starts at 1am.
+func startOfDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	t := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	if t.Day() != date.Day() {
+		t = t.Add(time.Hour)
+	}
+	return t
+}
+
+// Returns true if the schedule can match. A schedule that is restricted to days of the month that none of its months
+// have, such as February 30th, never matches, unless the day of week is also restricted.
+func (c *cronSchedule) canMatch() bool {
+	if c.domStar || !c.dowStar {
+		return true
+	}
+	// The longest length of each month, February has 29 days in leap years.
+	monthDays := []int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	for month := range c.month {
+		for day := range c.dayOfMonth {
+			if day <= monthDays[month-1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The parsed cron schedules, keyed by schedule string. The maintenance windows are checked often and there are only a
+// few distinct schedules, so each schedule is only parsed once.
+var cronSchedules sync.Map
+
+func parseCronSchedule(schedule string) (*cronSchedule, error) {
+	if c, ok := cronSchedules.Load(schedule); ok {
+		return c.(*cronSchedule), nil
+	}
+	c, err := parseCronFields(schedule)
+	if err != nil {
+		return nil, err
+	}
+	cronSchedules.Store(schedule, c)
+	return c, nil
+}
+
+func parseCronFields(schedule string) (*cronSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week) but found %v", len(fields))
+	}
+
+	c := new(cronSchedule)
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	} else if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	} else if c.dayOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	} else if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	} else if c.dayOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+
+	// Both 0 and 7 are Sunday.
+	if c.dayOfWeek[7] {
+		c.dayOfWeek[0] = true
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	if !c.canMatch() {
+		return nil, fmt.Errorf("the days of the month do not exist in any of the months")
+	}
+	return c, nil
+}
+
+// Parse a single cron field. Each comma separated item is *, a value, or a range, optionally followed by a /step.
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i != -1 {
+			rangePart = item[:i]
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("invalid step in %v", item)
+			}
+			step = s
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			l, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %v", item)
+			}
+			low, high = l, l
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value in %v", item)
+				}
+			} else if step != 1 {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%v is outside the range %v-%v", item, min, max)
+		}
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
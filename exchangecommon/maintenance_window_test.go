@@ -0,0 +1,140 @@
+//go:build unit
+// +build unit
+
+package exchangecommon
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_MaintenanceWindow_Validate(t *testing.T) {
+
+	valid := []MaintenanceWindow{
+		{Schedule: "0 2 * * 6", Duration: 3600},
+		{Schedule: "*/15 1-3,22 1,15 * 1-5", Duration: 60, Timezone: "America/New_York"},
+		{Schedule: "30 23 * 12 7", Duration: MAX_MAINTENANCE_WINDOW_DURATION},
+		{Schedule: "0 0 29 2 *", Duration: 3600},
+		{Schedule: "0 0 31 2 1", Duration: 3600},
+	}
+	for _, mw := range valid {
+		if err := mw.Validate(); err != nil {
+			t.Errorf("maintenance window %v should be valid, error: %v", mw, err)
+		}
+	}
+
+	invalid := []MaintenanceWindow{
+		{Schedule: "0 2 * *", Duration: 3600},
+		{Schedule: "60 2 * * *", Duration: 3600},
+		{Schedule: "0 2 0 * *", Duration: 3600},
+		{Schedule: "0 5-2 * * *", Duration: 3600},
+		{Schedule: "*/0 2 * * *", Duration: 3600},
+		{Schedule: "0 2 * * mon", Duration: 3600},
+		{Schedule: "0 2 * * *", Duration: 59},
+		{Schedule: "0 2 * * *", Duration: MAX_MAINTENANCE_WINDOW_DURATION + 1},
+		{Schedule: "0 2 * * *", Duration: 3600, Timezone: "Not/AZone"},
+		{Schedule: "0 0 31 2 *", Duration: 3600},
+		{Schedule: "0 0 30,31 2 *", Duration: 3600},
+		{Schedule: "0 0 31 4,6,9,11 *", Duration: 3600},
+	}
+	for _, mw := range invalid {
+		if err := mw.Validate(); err == nil {
+			t.Errorf("maintenance window %v should not be valid", mw)
+		}
+	}
+}
+
+func Test_MaintenanceWindow_IsOpen(t *testing.T) {
+
+	// Saturday Oct 3 2020, 2am to 3am UTC
+	mws := MaintenanceWindows{{Schedule: "0 2 * * 6", Duration: 3600}}
+
+	tests := []struct {
+		t    time.Time
+		open bool
+	}{
+		{time.Date(2020, 10, 3, 1, 59, 0, 0, time.UTC), false},
+		{time.Date(2020, 10, 3, 2, 0, 0, 0, time.UTC), true},
+		{time.Date(2020, 10, 3, 2, 59, 59, 0, time.UTC), true},
+		{time.Date(2020, 10, 3, 3, 0, 0, 0, time.UTC), false},
+		{time.Date(2020, 10, 4, 2, 30, 0, 0, time.UTC), false},
+		{time.Date(2020, 10, 3, 2, 0, 30, 0, time.UTC), true},
+	}
+	for _, test := range tests {
+		if open := mws.IsOpen(test.t); open != test.open {
+			t.Errorf("window open at %v should be %v", test.t, test.open)
+		}
+	}
+
+	// No windows means changes can be made at any time.
+	if !(MaintenanceWindows{}).IsOpen(time.Now()) {
+		t.Errorf("empty maintenance windows should always be open")
+	}
+
+	// A window that spans midnight in another time zone, 11pm to 1am in New York is 3am to 5am UTC in October.
+	mws = MaintenanceWindows{{Schedule: "0 23 * * *", Duration: 7200, Timezone: "America/New_York"}}
+	if !mws.IsOpen(time.Date(2020, 10, 3, 4, 30, 0, 0, time.UTC)) {
+		t.Errorf("window should be open at 12:30am in New York")
+	} else if mws.IsOpen(time.Date(2020, 10, 3, 23, 30, 0, 0, time.UTC)) {
+		t.Errorf("window should be closed at 7:30pm in New York")
+	}
+
+	// When both day of month and day of week are restricted, either one matches.
+	mws = MaintenanceWindows{{Schedule: "0 0 1 * 0", Duration: 60}}
+	if !mws.IsOpen(time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)) || !mws.IsOpen(time.Date(2020, 10, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("window should be open on the first of the month and on Sunday")
+	} else if mws.IsOpen(time.Date(2020, 10, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("window should be closed on Friday the 2nd")
+	}
+}
+
+func Test_MaintenanceWindow_NextOpen(t *testing.T) {
+
+	mws := MaintenanceWindows{
+		{Schedule: "0 2 * * 6", Duration: 3600},
+		{Schedule: "30 4 * * *", Duration: 600},
+	}
+
+	now := time.Date(2020, 10, 3, 1, 0, 0, 0, time.UTC)
+	if next := mws.NextOpen(now); !next.Equal(time.Date(2020, 10, 3, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("next window should open at 2am but opens at %v", next)
+	}
+
+	now = time.Date(2020, 10, 3, 2, 0, 0, 0, time.UTC)
+	if next := mws.NextOpen(now); !next.Equal(time.Date(2020, 10, 3, 4, 30, 0, 0, time.UTC)) {
+		t.Errorf("next window should open at 4:30am but opens at %v", next)
+	}
+
+	// February 30th never happens.
+	mws = MaintenanceWindows{{Schedule: "0 0 30 2 *", Duration: 3600}}
+	if next := mws.NextOpen(now); !next.IsZero() {
+		t.Errorf("window should never open but opens at %v", next)
+	}
+
+	// February 29th is more than a year away, and 8 years away across 2100.
+	mw := MaintenanceWindow{Schedule: "0 0 29 2 *", Duration: 3600}
+	if next, err := mw.NextOpen(now); err != nil || !next.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("window should open on February 29th 2024 but opens at %v, error: %v", next, err)
+	} else if next, err := mw.NextOpen(time.Date(2096, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil || !next.Equal(time.Date(2104, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("window should open on February 29th 2104 but opens at %v, error: %v", next, err)
+	}
+
+	// The next opening is in the time zone of the window, and skips the hour that does not exist when the clocks go
+	// forward.
+	mw = MaintenanceWindow{Schedule: "30 2 * * *", Duration: 3600, Timezone: "America/New_York"}
+	if next, err := mw.NextOpen(time.Date(2021, 3, 13, 12, 0, 0, 0, time.UTC)); err != nil || !next.Equal(time.Date(2021, 3, 15, 6, 30, 0, 0, time.UTC)) {
+		t.Errorf("window should open at 2:30am on March 15th in New York but opens at %v, error: %v", next, err)
+	}
+
+	// In Santiago the clocks go forward at midnight, so September 5th 2021 starts at 1am.
+	mw = MaintenanceWindow{Schedule: "30 1 * * *", Duration: 3600, Timezone: "America/Santiago"}
+	if next, err := mw.NextOpen(time.Date(2021, 9, 4, 12, 0, 0, 0, time.UTC)); err != nil || !next.Equal(time.Date(2021, 9, 5, 4, 30, 0, 0, time.UTC)) {
+		t.Errorf("window should open at 1:30am on September 5th in Santiago but opens at %v, error: %v", next, err)
+	}
+
+	// The next opening is found in the following year.
+	mw = MaintenanceWindow{Schedule: "15 6 1 1 *", Duration: 3600}
+	if next, err := mw.NextOpen(time.Date(2020, 1, 1, 6, 15, 0, 0, time.UTC)); err != nil || !next.Equal(time.Date(2021, 1, 1, 6, 15, 0, 0, time.UTC)) {
+		t.Errorf("window should open on January 1st 2021 but opens at %v, error: %v", next, err)
+	}
+}
@@ -199,8 +199,9 @@ func StatusFromNewPolicy(policy ExchangeNodeManagementPolicy, workingDir string)
 	return newStatus
 }
 
+// Only the policies with an agent upgrade job have a scheduled start time, there is nothing to start for the other policies.
 func (n NodeManagementPolicyStatus) TimeToStart() bool {
-	if n.AgentUpgradeInternal != nil {
+	if n.AgentUpgradeInternal != nil && !n.AgentUpgradeInternal.ScheduledUnixTime.IsZero() {
 		return n.AgentUpgradeInternal.ScheduledUnixTime.Before(time.Now())
 	}
 	return false
@@ -173,6 +173,7 @@ func GetProcessedExchangeNodePolicy(pDevice *persistence.ExchangeDevice, getExch
 			tmpExchangeNodePol.Description = exchangeNodePolicy.Description
 			tmpExchangeNodePol.Deployment = exchangeNodePolicy.Deployment
 			tmpExchangeNodePol.Management = exchangeNodePolicy.Management
+			tmpExchangeNodePol.MaintenanceWindows = exchangeNodePolicy.MaintenanceWindows
 		}
 		_, err := putExchangeNodePolicy(fmt.Sprintf("%v/%v", pDevice.Org, pDevice.Id), &tmpExchangeNodePol)
 		if err != nil {
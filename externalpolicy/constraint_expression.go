@@ -9,8 +9,18 @@ import (
 // This type implements all the ConstraintLanguage Plugin methods and delegates to plugin system.
 type ConstraintExpression []string
 
+// Each constraint is validated by the plugin of its own language, so that the merged constraints of several policies
+// can be written in different languages.
 func (c *ConstraintExpression) Validate() ([]string, error) {
-	return plugin_registry.ConstraintLanguagePlugins.ValidatedByOne((*c).GetStrings())
+	validated := make([]string, 0, len(*c))
+	for _, constraint := range *c {
+		if v, err := plugin_registry.ConstraintLanguagePlugins.ValidatedByOne([]string{constraint}); err != nil {
+			return nil, err
+		} else {
+			validated = append(validated, v...)
+		}
+	}
+	return validated, nil
 }
 
 func (c *ConstraintExpression) GetLanguageHandler() (plugin_registry.ConstraintLanguagePlugin, error) {
@@ -132,8 +142,8 @@ func RequiredPropertyFromConstraint(extConstraint *ConstraintExpression) (*Requi
 	for _, remainder := range *extConstraint {
 		remainder := strings.Replace(remainder, "\a", " ", -1)
 
-		// Get a handle to the specific language handler we will be using.
-		handler, err = extConstraint.GetLanguageHandler()
+		// Get a handle to the specific language handler we will be using for this constraint.
+		handler, err = plugin_registry.ConstraintLanguagePlugins.GetLanguageHandlerByOne([]string{remainder})
 		if err != nil {
 			return nil, fmt.Errorf("unable to obtain policy constraint language handler, error %v", err)
 		}
@@ -179,14 +189,19 @@ func parseConstraintExpression(constraint string, handler plugin_registry.Constr
 			return nil, constraint, err
 		}
 
-		if ctrlOp == "(" {
+		if ctrlOp == "(" || ctrlOp == "NOT" {
 			// handle a parenthetical expression as a seperate constraint expression
+			negated := ctrlOp == "NOT"
 			subExpr, constraint, err = parseConstraintExpression(constraint, handler)
 			if err != nil {
 				return nil, constraint, err
 			}
 
-			andArray = append(andArray, subExpr)
+			if negated {
+				andArray = append(andArray, map[string]interface{}{OP_NOT: []interface{}{subExpr}})
+			} else {
+				andArray = append(andArray, subExpr)
+			}
 
 			ctrlOp, constraint, err = handler.GetNextOperator(constraint)
 			if err != nil {
@@ -239,14 +254,19 @@ func getConstraintExpressionWithName(constraint string, handler plugin_registry.
 			return nil, constraint, err
 		}
 
-		if ctrlOp == "(" {
+		if ctrlOp == "(" || ctrlOp == "NOT" {
 			// handle a parenthetical expression as a seperate constraint expression
+			negated := ctrlOp == "NOT"
 			subExpr, constraint, err = getConstraintExpressionWithName(constraint, handler, constrainName)
 			if err != nil {
 				return nil, constraint, err
 			}
 
-			andArray = append(andArray, subExpr)
+			if negated {
+				andArray = append(andArray, map[string]interface{}{OP_NOT: []interface{}{subExpr}})
+			} else {
+				andArray = append(andArray, subExpr)
+			}
 
 			ctrlOp, constraint, err = handler.GetNextOperator(constraint)
 			if err != nil {
@@ -284,8 +304,8 @@ func GetParseConstraintWithName(extConstraint *ConstraintExpression, constraintN
 	for _, remainder := range *extConstraint {
 		remainder := strings.Replace(remainder, "\a", " ", -1)
 
-		// Get a handle to the specific language handler we will be using.
-		handler, err = extConstraint.GetLanguageHandler()
+		// Get a handle to the specific language handler we will be using for this constraint.
+		handler, err = plugin_registry.ConstraintLanguagePlugins.GetLanguageHandlerByOne([]string{remainder})
 		if err != nil {
 			return nil, fmt.Errorf("unable to obtain policy constraint language handler, error %v", err)
 		}
@@ -4,6 +4,7 @@
 package externalpolicy
 
 import (
+	_ "github.com/open-horizon/anax/externalpolicy/json_language"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
 	"testing"
 )
@@ -298,6 +299,73 @@ func Test_fail_IsSatisfiedBy(t *testing.T) {
 	}
 }
 
+// Test the negation, existence, regular expression, prefix and suffix operators.
+func Test_operators_IsSatisfiedBy(t *testing.T) {
+	prop_list := `[{"name":"hostname", "value":"line-12"},{"name":"location", "value":"us-east"},{"name":"gpu", "value":true},{"name":"cpus", "value":4},{"name":"zones", "value":"a1, b2", "type":"list of strings"}]`
+	props := create_property_list(prop_list, t)
+
+	for _, constraint := range []string{
+		"hostname ~= \"line-[0-9]+\"",
+		"hostname~=\"line-1.*\" AND gpu exists AND simulator not exists",
+		"location startswith us- && location endswith \"-east\"",
+		"NOT (location == us-west) AND NOT (hostname ~= \"line-[a-z]+\" OR cpus > 8)",
+		"(NOT (simulator exists)) || cpus < 2",
+		"zones startswith b",
+	} {
+		ce := ConstraintExpression([]string{constraint})
+		if err := ce.IsSatisfiedBy(*props); err != nil {
+			t.Errorf("Error: %v should satisfy %v, but it did not: %v", prop_list, constraint, err)
+		}
+	}
+
+	for constraint, message := range map[string]string{
+		"hostname ~= \"line-\"":                "The required property 'hostname ~= \"line-\"' were not found in the available properties hostname=line-12, location=us-east, gpu=true, cpus=4, zones=a1, b2",
+		"location startswith eu- || cpus ~= 4": "The required properties location startswith eu-, cpus~=4 were not found in the available properties hostname=line-12, location=us-east, gpu=true, cpus=4, zones=a1, b2",
+		"gpu not exists":                       "The property 'gpu' must not be set, but was found in the available properties hostname=line-12, location=us-east, gpu=true, cpus=4, zones=a1, b2",
+		"simulator exists":                     "The required property 'simulator exists' were not found in the available properties hostname=line-12, location=us-east, gpu=true, cpus=4, zones=a1, b2",
+		"NOT (cpus == 4 AND gpu exists)":       "The available properties hostname=line-12, location=us-east, gpu=true, cpus=4, zones=a1, b2 satisfy the negated requirement NOT (cpus==4 AND gpu exists)",
+	} {
+		ce := ConstraintExpression([]string{constraint})
+		if err := ce.IsSatisfiedBy(*props); err == nil {
+			t.Errorf("Error: constraint %v not satisfied but no error occured", constraint)
+		} else if err.Error() != message {
+			t.Errorf("Error: the error for %v is %v, it should be %v", constraint, err, message)
+		}
+	}
+}
+
+// Test that the constraints of different languages are validated and evaluated by their own plugins.
+func Test_mixed_languages_IsSatisfiedBy(t *testing.T) {
+	prop_list := `[{"name":"hostname", "value":"line-12"},{"name":"cpus", "value":4},{"name":"zones", "value":"a1, b2", "type":"list of strings"}]`
+	props := create_property_list(prop_list, t)
+
+	ce := ConstraintExpression([]string{
+		"hostname ~= \"line-[0-9]+\"",
+		`{"and": [{"property": "cpus", "op": ">", "value": 2}, {"not": {"property": "zones", "op": "in", "value": ["c3"]}}]}`,
+	})
+	if _, err := ce.Validate(); err != nil {
+		t.Errorf("Error: %v should be valid, but it was not: %v", ce, err)
+	} else if err := ce.IsSatisfiedBy(*props); err != nil {
+		t.Errorf("Error: %v should satisfy %v, but it did not: %v", prop_list, ce, err)
+	}
+
+	ce = ConstraintExpression([]string{
+		"hostname ~= \"line-[0-9]+\"",
+		`{"or": [{"property": "cpus", "op": ">", "value": 8}, {"property": "zones", "op": "in", "value": ["c3", "d4"]}]}`,
+	})
+	message := "The required properties cpus>8, zones in c3,d4 were not found in the available properties hostname=line-12, cpus=4, zones=a1, b2"
+	if err := ce.IsSatisfiedBy(*props); err == nil {
+		t.Errorf("Error: constraint %v not satisfied but no error occured", ce)
+	} else if err.Error() != message {
+		t.Errorf("Error: the error for %v is %v, it should be %v", ce, err, message)
+	}
+
+	ce = ConstraintExpression([]string{"hostname == line-12", `{"property": "cpus", "op": "<", "value": "many"}`})
+	if _, err := ce.Validate(); err == nil {
+		t.Errorf("Error: %v should not be valid", ce)
+	}
+}
+
 func Test_MergeWith(t *testing.T) {
 	ce1 := new(ConstraintExpression)
 	ce2 := new(ConstraintExpression)
@@ -337,3 +405,62 @@ func Test_MergeWith(t *testing.T) {
 		t.Errorf("Error: constraints %v should have 4 elements but got %v", ce1, len(*ce1))
 	}
 }
+
+// Test that the caret, tilde and wildcard version ranges are evaluated in the text and JSON constraint languages.
+func Test_version_ranges_IsSatisfiedBy(t *testing.T) {
+	prop_list := `[{"name":"fw", "value":"1.4.2", "type":"version"},{"name":"os_version", "value":"0.2.9"}]`
+	props := create_property_list(prop_list, t)
+
+	for _, constraint := range []string{
+		"fw in ^1.2 AND os_version in ^0.2.3",
+		"fw in ~1.4.0 && fw in 1.x && fw in 1.4.* && fw in *",
+		`{"and": [{"property": "fw", "op": "in", "value": "^1"}, {"property": "os_version", "op": "in", "value": "0.2.x"}]}`,
+	} {
+		ce := ConstraintExpression([]string{constraint})
+		if _, err := ce.Validate(); err != nil {
+			t.Errorf("Error: %v should be valid, but it was not: %v", constraint, err)
+		} else if err := ce.IsSatisfiedBy(*props); err != nil {
+			t.Errorf("Error: %v should satisfy %v, but it did not: %v", prop_list, constraint, err)
+		}
+	}
+
+	for _, constraint := range []string{
+		"fw in ^2",
+		"os_version in ^0.3",
+		"fw in ~1.3.9",
+		`{"property": "fw", "op": "in", "value": "1.5.x"}`,
+	} {
+		ce := ConstraintExpression([]string{constraint})
+		if _, err := ce.Validate(); err != nil {
+			t.Errorf("Error: %v should be valid, but it was not: %v", constraint, err)
+		} else if err := ce.IsSatisfiedBy(*props); err == nil {
+			t.Errorf("Error: constraint %v not satisfied but no error occured", constraint)
+		}
+	}
+}
+
+// Test that a string value that begins with a tilde is compared as a string, not as a tilde version range.
+func Test_tilde_strings_IsSatisfiedBy(t *testing.T) {
+	prop_list := `[{"name":"site", "value":"~1a"},{"name":"rack", "value":"~x1"}]`
+	props := create_property_list(prop_list, t)
+
+	for _, constraint := range []string{
+		"site == ~1a",
+		"site == ~1a AND rack == ~x1",
+		"site startswith ~1 OR rack == ~1.2",
+	} {
+		ce := ConstraintExpression([]string{constraint})
+		if _, err := ce.Validate(); err != nil {
+			t.Errorf("Error: %v should be valid, but it was not: %v", constraint, err)
+		} else if err := ce.IsSatisfiedBy(*props); err != nil {
+			t.Errorf("Error: %v should satisfy %v, but it did not: %v", prop_list, constraint, err)
+		}
+	}
+
+	ce := ConstraintExpression([]string{"site == ~1b"})
+	if _, err := ce.Validate(); err != nil {
+		t.Errorf("Error: %v should be valid, but it was not: %v", ce, err)
+	} else if err := ce.IsSatisfiedBy(*props); err == nil {
+		t.Errorf("Error: constraint %v not satisfied but no error occured", ce)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/open-horizon/anax/semanticversion"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -23,9 +24,12 @@ import (
 // _control_operator_    = {"and", "or", "not"}
 // _expression_          = _control_operator_: [_expression_] || property
 // _property_            = "name": _property_name_, "value": _property_value, "op": _comparison_operator_
-// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!="}
+// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!=", "in", "~=", "startswith", "endswith", "exists", "not exists"}
 // The "=" and "!=" comparison operators can be applied to strings and integers.
+// The "~=", "startswith" and "endswith" operators can only be applied to strings.
+// The "exists" and "not exists" operators ignore the value, they only check if the property is set.
 // If the "op" key is missing, then equal is assumed.
+// The "not" control operator is satisfied when the expressions in its array are not all satisfied.
 //
 // See the unit tests for examples of valid and invalid syntax
 //
@@ -65,6 +69,11 @@ const lessthaneq = "<="
 const greaterthaneq = ">="
 const notequalto = "!="
 const isin = "in"
+const matches = "~="
+const startswith = "startswith"
+const endswith = "endswith"
+const exists = "exists"
+const notexists = "not exists"
 
 // This struct represents property value expressions to be satisfied
 type PropertyExpression struct {
@@ -132,7 +141,10 @@ func (self *RequiredProperty) satisfied(cop *map[string]interface{}, props *[]Pr
 		for _, p := range propArray {
 			if prop := isPropertyExpression(p); prop != nil {
 				if !propertyInArray(prop, props) {
-					return errors.New(fmt.Sprintf("The required property '%v %v %v' were not found in the available properties %v", prop.Name, prop.Op, prop.Value, displayProperties(props)))
+					if prop.Op == notexists {
+						return errors.New(fmt.Sprintf("The property '%v' must not be set, but was found in the available properties %v", prop.Name, displayProperties(props)))
+					}
+					return errors.New(fmt.Sprintf("The required property '%v' were not found in the available properties %v", displayPropertyExpression(prop, " "), displayProperties(props)))
 				}
 			} else if cop := isControlOp(p); cop != nil {
 				if err := self.satisfied(cop, props); err != nil {
@@ -154,6 +166,10 @@ func (self *RequiredProperty) satisfied(cop *map[string]interface{}, props *[]Pr
 				}
 			} else if cop := isControlOp(p); cop != nil {
 				if err := self.satisfied(cop, props); err != nil {
+					// without alternatives, the error of the only expression is the most precise explanation.
+					if len(propArray) == 1 {
+						return err
+					}
 					continue
 				} else {
 					return nil
@@ -165,6 +181,11 @@ func (self *RequiredProperty) satisfied(cop *map[string]interface{}, props *[]Pr
 		return errors.New(fmt.Sprintf("The required properties %v were not found in the available properties %v", displayRequiredProperty(cop), displayProperties(props)))
 	} else if controlOp == OP_NOT {
 
+		// the negated expressions are evaluated as if they were ANDed together.
+		andMap := map[string]interface{}{OP_AND: (*cop)[controlOp]}
+		if err := self.satisfied(&andMap, props); err == nil {
+			return errors.New(fmt.Sprintf("The available properties %v satisfy the negated requirement %v", displayProperties(props), displayRequiredProperty(cop)))
+		}
 	}
 
 	return nil
@@ -274,20 +295,25 @@ func getControlOperator(m *map[string]interface{}) string {
 // Return a map of control operators so that it's easy to check if a string is equivalent to one
 // of the supported control operators.
 func controlOperators() map[string]int {
-	// return map[string]int {and:0, or:0, not:0}
-	return map[string]int{OP_AND: 0, OP_OR: 0}
+	return map[string]int{OP_AND: 0, OP_OR: 0, OP_NOT: 0}
 }
 
 // Return a map of comparison operators so that it's easy to check if a string is equivalent to one
 // of the supported comparison operators.
 func comparisonOperators() map[string]int {
 	// return map[string]int {and:0, or:0, not:0}
-	return map[string]int{lessthan: 0, greaterthan: 0, doubleequalto: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0, isin: 0}
+	return map[string]int{lessthan: 0, greaterthan: 0, doubleequalto: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0, isin: 0,
+		matches: 0, startswith: 0, endswith: 0, exists: 0, notexists: 0}
 }
 
 // Return a map of comparison operators that only work on strings
 func stringOperators() map[string]int {
-	return map[string]int{doubleequalto: 0, equalto: 0, notequalto: 0, isin: 0}
+	return map[string]int{doubleequalto: 0, equalto: 0, notequalto: 0, isin: 0, matches: 0, startswith: 0, endswith: 0}
+}
+
+// Return a map of comparison operators that match a string value with a pattern, a prefix or a suffix.
+func stringMatchOperators() map[string]int {
+	return map[string]int{matches: 0, startswith: 0, endswith: 0}
 }
 
 // This function checks the type of the input interface object to see if it's a map of string to
@@ -412,12 +438,39 @@ func getKeys(m map[string]interface{}) []string {
 // This function compares a Property object with an array of Property objects to see if it's
 // in the array with an appropriate value.
 func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
+	// The existence operators only check the property name.
+	if propexp.Op == exists || propexp.Op == notexists {
+		found := false
+		for _, p := range *props {
+			if p.Name == propexp.Name {
+				found = true
+				break
+			}
+		}
+		return found == (propexp.Op == exists)
+	}
+
 	for _, p := range *props {
 		if p.Name != propexp.Name {
 			// These are not the droids we're looking for
 			continue
 		} else {
-			if isFloat64(p.Value) {
+			if _, ok := stringMatchOperators()[propexp.Op]; ok {
+				if !isString(p.Value) || !isString(propexp.Value) {
+					return false
+				}
+				pValue := removeSpaces(removeQuotes(p.Value.(string)))
+				propexpValue := removeQuotes(removeSpaces(propexp.Value.(string)))
+				if p.Type == LIST_TYPE {
+					for _, pVal := range strings.Split(pValue, ",") {
+						if stringMatches(removeQuotes(removeSpaces(pVal)), propexp.Op, propexpValue) {
+							return true
+						}
+					}
+					return false
+				}
+				return stringMatches(pValue, propexp.Op, propexpValue)
+			} else if isFloat64(p.Value) {
 				var propexpFloat float64
 				if isFloat64(propexp.Value) {
 					propexpFloat = propexp.Value.(float64)
@@ -489,6 +542,21 @@ func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
 	return false
 }
 
+// Returns true if the string matches the regular expression, or starts or ends with the given value. The regular expression
+// must match the whole string.
+func stringMatches(value string, op string, constrValue string) bool {
+	switch op {
+	case matches:
+		re, err := regexp.Compile("^(?:" + constrValue + ")$")
+		return err == nil && re.MatchString(value)
+	case startswith:
+		return strings.HasPrefix(value, constrValue)
+	case endswith:
+		return strings.HasSuffix(value, constrValue)
+	}
+	return false
+}
+
 func removeSpaces(value string) string {
 	return strings.Trim(value, " ")
 }
@@ -537,12 +605,10 @@ func displayRequiredProperty(cop *map[string]interface{}) string {
 	controlOp := getControlOperator(cop)
 
 	op_display := ""
-	if controlOp == OP_AND {
+	if controlOp == OP_AND || controlOp == OP_NOT {
 		op_display = " AND "
 	} else if controlOp == OP_OR {
 		op_display = ", "
-	} else if controlOp == OP_NOT {
-		op_display = " NOT "
 	}
 
 	propArray := (*cop)[controlOp].([]interface{})
@@ -552,11 +618,10 @@ func displayRequiredProperty(cop *map[string]interface{}) string {
 			if prop.Op == "" {
 				prop.Op = doubleequalto
 			}
-			s := fmt.Sprintf("%v%v%v", prop.Name, prop.Op, prop.Value)
-			display_strings = append(display_strings, s)
+			display_strings = append(display_strings, displayPropertyExpression(prop, ""))
 		} else if cop1 := isControlOp(p); cop1 != nil {
 			s := displayRequiredProperty(cop1)
-			if controlOp == OP_OR {
+			if controlOp == OP_OR || getControlOperator(cop1) == OP_NOT || (controlOp == OP_NOT && len(propArray) == 1) {
 				display_strings = append(display_strings, fmt.Sprintf("%v", s))
 			} else {
 				display_strings = append(display_strings, fmt.Sprintf("(%v)", s))
@@ -567,9 +632,23 @@ func displayRequiredProperty(cop *map[string]interface{}) string {
 		}
 	}
 
+	if controlOp == OP_NOT {
+		return fmt.Sprintf("NOT (%v)", strings.Join(display_strings, op_display))
+	}
 	return strings.Join(display_strings, op_display)
 }
 
+// This function displays a property expression as "name<sep>op<sep>value". The operators that are words are always
+// separated from the name and the value by a space, the existence operators do not display the value.
+func displayPropertyExpression(prop *PropertyExpression, sep string) string {
+	if prop.Op == exists || prop.Op == notexists {
+		return fmt.Sprintf("%v %v", prop.Name, prop.Op)
+	} else if prop.Op == startswith || prop.Op == endswith || prop.Op == isin {
+		sep = " "
+	}
+	return fmt.Sprintf("%v%v%v%v%v", prop.Name, sep, prop.Op, sep, prop.Value)
+}
+
 // This fuction displays the a property list to "key1=value1, key1=value2..." format.
 func displayProperties(props *[]Property) string {
 	if props != nil && len(*props) > 0 {
@@ -0,0 +1,351 @@
+package json_language
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/externalpolicy/plugin_registry"
+	"github.com/open-horizon/anax/semanticversion"
+	"regexp"
+	"strings"
+)
+
+// The JSON constraint language expresses a constraint as a tree of JSON objects, which is easier to generate from
+// other systems than the text language. Each constraint in the constraint list is one JSON object:
+//
+// _expression_ = {"and": [_expression_, ...]} | {"or": [_expression_, ...]} | {"not": _expression_} | _property_
+// _property_   = {"property": _property_name_, "op": _operator_, "value": _property_value_}
+// _operator_   = "==", "=", "!=", "<", ">", "<=", ">=", "in", "~=", "startswith", "endswith", "exists", "not exists"
+//
+// The value of the "in" operator is a version, a version range or an array of strings. The existence operators do
+// not have a value. The plugin returns the expressions and control operators in the same format as the text language,
+// so that they are converted to a RequiredProperty in the same way.
+func init() {
+	plugin_registry.Register("json", NewJSONConstraintLanguagePlugin())
+}
+
+type JSONConstraintLanguagePlugin struct {
+}
+
+func NewJSONConstraintLanguagePlugin() plugin_registry.ConstraintLanguagePlugin {
+	return new(JSONConstraintLanguagePlugin)
+}
+
+// The keys of the JSON objects.
+const KEY_AND = "and"
+const KEY_OR = "or"
+const KEY_NOT = "not"
+const KEY_PROPERTY = "property"
+const KEY_OP = "op"
+const KEY_VALUE = "value"
+
+// The operators of a property expression.
+const OP_EQ = "=="
+const OP_EQ_SINGLE = "="
+const OP_NE = "!="
+const OP_LT = "<"
+const OP_GT = ">"
+const OP_LE = "<="
+const OP_GE = ">="
+const OP_IN = "in"
+const OP_MATCH = "~="
+const OP_STARTSWITH = "startswith"
+const OP_ENDSWITH = "endswith"
+const OP_EXISTS = "exists"
+const OP_NOT_EXISTS = "not exists"
+
+// The plugin owns the constraints when all of them are JSON objects. An error is returned only for the constraints
+// that it owns.
+func (p *JSONConstraintLanguagePlugin) Validate(dconstraints interface{}) (bool, []string, error) {
+
+	constraints, ok := dconstraints.([]string)
+	if !ok || len(constraints) == 0 {
+		return false, nil, nil
+	}
+	for _, constraint := range constraints {
+		if !isJSONConstraint(constraint) {
+			return false, nil, nil
+		}
+	}
+
+	for _, constraint := range constraints {
+		if _, err := parseExpression([]byte(constraint)); err != nil {
+			return true, nil, fmt.Errorf("Error in the JSON constraint expression %v. Error was: %v", constraint, err)
+		}
+	}
+	return true, constraints, nil
+}
+
+// This function returns the next property expression as "name\aop\avalue", with the remainder of the expression. When
+// the next token is a control operator, the expression is empty and the remainder is the input expression. The input
+// is either a JSON constraint or a remainder returned by this plugin.
+func (p *JSONConstraintLanguagePlugin) GetNextExpression(expression string) (string, string, error) {
+	tokens, err := getTokens(expression)
+	if err != nil || len(tokens) == 0 {
+		return "", "", err
+	}
+
+	if tokens[0].Ctrl != "" {
+		remainder, err := marshalTokens(tokens)
+		return "", remainder, err
+	}
+
+	remainder, err := marshalTokens(tokens[1:])
+	return fmt.Sprintf("%v\a%v\a%v", tokens[0].Name, tokens[0].Op, tokens[0].Value), remainder, err
+}
+
+// This function returns the next control operator, one of AND, OR, NOT, ( and ), with the remainder of the expression.
+// NOT includes the open parenthesis of the negated expression.
+func (p *JSONConstraintLanguagePlugin) GetNextOperator(expression string) (string, string, error) {
+	tokens, err := getTokens(expression)
+	if err != nil || len(tokens) == 0 {
+		return "", "", err
+	}
+
+	if tokens[0].Ctrl == "" {
+		return "", expression, fmt.Errorf("No control operator found. Expecting one of AND,OR,NOT. Found: %v", tokens[0])
+	}
+
+	remainder, err := marshalTokens(tokens[1:])
+	return tokens[0].Ctrl, remainder, err
+}
+
+// A token of a constraint, either a control operator or a property expression. The remainder of a constraint is the
+// array of its tokens.
+type token struct {
+	Ctrl  string `json:"c,omitempty"`
+	Name  string `json:"n,omitempty"`
+	Op    string `json:"o,omitempty"`
+	Value string `json:"v,omitempty"`
+}
+
+func (t token) String() string {
+	if t.Ctrl != "" {
+		return t.Ctrl
+	}
+	return fmt.Sprintf("%v %v %v", t.Name, t.Op, t.Value)
+}
+
+// A node of the expression tree. Only one of the and, or, not and property fields is set.
+type expression struct {
+	and      []*expression
+	or       []*expression
+	not      *expression
+	property *token
+}
+
+// Returns the tokens of a JSON constraint, or of a remainder.
+func getTokens(exp string) ([]token, error) {
+	exp = strings.TrimSpace(exp)
+	if exp == "" {
+		return nil, nil
+	} else if strings.HasPrefix(exp, "[") {
+		tokens := make([]token, 0)
+		if err := json.Unmarshal([]byte(exp), &tokens); err != nil {
+			return nil, fmt.Errorf("unable to demarshal the remainder %v of a JSON constraint expression, error %v", exp, err)
+		}
+		return tokens, nil
+	} else if e, err := parseExpression([]byte(exp)); err != nil {
+		return nil, err
+	} else {
+		return e.tokens(nil, true), nil
+	}
+}
+
+func marshalTokens(tokens []token) (string, error) {
+	if len(tokens) == 0 {
+		return "", nil
+	} else if b, err := json.Marshal(tokens); err != nil {
+		return "", fmt.Errorf("unable to marshal the remainder of a JSON constraint expression, error %v", err)
+	} else {
+		return string(b), nil
+	}
+}
+
+// Append the tokens of the expression to the tokens. The expressions of an AND or an OR are in parentheses, except at
+// the top level. The parenthesis of NOT is included in the NOT token.
+func (e *expression) tokens(tokens []token, top bool) []token {
+	if e.property != nil {
+		return append(tokens, *e.property)
+	} else if e.not != nil {
+		tokens = append(tokens, token{Ctrl: "NOT"})
+		return append(e.not.tokens(tokens, true), token{Ctrl: ")"})
+	}
+
+	children, ctrl := e.and, "AND"
+	if e.or != nil {
+		children, ctrl = e.or, "OR"
+	}
+	if !top {
+		tokens = append(tokens, token{Ctrl: "("})
+	}
+	for i, child := range children {
+		if i > 0 {
+			tokens = append(tokens, token{Ctrl: ctrl})
+		}
+		tokens = child.tokens(tokens, false)
+	}
+	if !top {
+		tokens = append(tokens, token{Ctrl: ")"})
+	}
+	return tokens
+}
+
+// Parse and validate a JSON expression.
+func parseExpression(data []byte) (*expression, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%v is not a JSON object: %v", string(data), err)
+	}
+
+	e := new(expression)
+	if _, ok := fields[KEY_PROPERTY]; ok {
+		prop, err := parseProperty(fields)
+		if err != nil {
+			return nil, err
+		}
+		e.property = prop
+		return e, nil
+	} else if len(fields) != 1 {
+		return nil, fmt.Errorf("%v must have one of the keys %v, %v, %v or %v", string(data), KEY_AND, KEY_OR, KEY_NOT, KEY_PROPERTY)
+	}
+
+	for key, value := range fields {
+		switch key {
+		case KEY_AND, KEY_OR:
+			raws := make([]json.RawMessage, 0)
+			if err := json.Unmarshal(value, &raws); err != nil || len(raws) == 0 {
+				return nil, fmt.Errorf("the value of %v must be a non-empty array of expressions, found %v", key, string(value))
+			}
+			children := make([]*expression, 0, len(raws))
+			for _, raw := range raws {
+				child, err := parseExpression(raw)
+				if err != nil {
+					return nil, err
+				}
+				children = append(children, child)
+			}
+			if key == KEY_AND {
+				e.and = children
+			} else {
+				e.or = children
+			}
+		case KEY_NOT:
+			child, err := parseExpression(value)
+			if err != nil {
+				return nil, err
+			}
+			e.not = child
+		default:
+			return nil, fmt.Errorf("%v must have one of the keys %v, %v, %v or %v, found %v", string(data), KEY_AND, KEY_OR, KEY_NOT, KEY_PROPERTY, key)
+		}
+	}
+	return e, nil
+}
+
+// Parse and validate a property expression, the value is converted to the string used by the text language.
+func parseProperty(fields map[string]json.RawMessage) (*token, error) {
+	for key := range fields {
+		if key != KEY_PROPERTY && key != KEY_OP && key != KEY_VALUE {
+			return nil, fmt.Errorf("a property expression can only have the keys %v, %v and %v, found %v", KEY_PROPERTY, KEY_OP, KEY_VALUE, key)
+		}
+	}
+
+	prop := new(token)
+	if err := json.Unmarshal(fields[KEY_PROPERTY], &prop.Name); err != nil || strings.TrimSpace(prop.Name) == "" || strings.Contains(prop.Name, "\a") {
+		return nil, fmt.Errorf("the %v must be a property name, found %v", KEY_PROPERTY, string(fields[KEY_PROPERTY]))
+	}
+	prop.Op = OP_EQ
+	if raw, ok := fields[KEY_OP]; ok {
+		if err := json.Unmarshal(raw, &prop.Op); err != nil {
+			return nil, fmt.Errorf("the %v of property %v must be a string, found %v", KEY_OP, prop.Name, string(raw))
+		}
+	}
+
+	var value interface{}
+	if raw, ok := fields[KEY_VALUE]; ok {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("the %v of property %v is not valid: %v", KEY_VALUE, prop.Name, err)
+		}
+	}
+
+	var err error
+	if prop.Value, err = propertyValue(prop.Name, prop.Op, value); err != nil {
+		return nil, err
+	}
+	return prop, nil
+}
+
+// Check that the operator is valid for the value, and return the value as a string.
+func propertyValue(name string, op string, value interface{}) (string, error) {
+	str, isString := value.(string)
+	if isString && strings.Contains(str, "\a") {
+		return "", fmt.Errorf("the value of property %v contains an invalid character", name)
+	}
+
+	switch op {
+	case OP_EXISTS, OP_NOT_EXISTS:
+		if value != nil {
+			return "", fmt.Errorf("the operator '%v' of property %v does not have a value, found %v", op, name, value)
+		}
+		return "", nil
+
+	case OP_EQ, OP_EQ_SINGLE, OP_NE:
+		switch v := value.(type) {
+		case json.Number:
+			return v.String(), nil
+		case bool:
+			return fmt.Sprintf("%v", v), nil
+		case string:
+			if strings.Contains(v, ",") {
+				return "", fmt.Errorf("the value %v of property %v is a list of strings, it can only use operator 'in'", v, name)
+			}
+			return v, nil
+		}
+		return "", fmt.Errorf("the value of property %v with operator '%v' must be a string, a number or a boolean, found %v", name, op, value)
+
+	case OP_LT, OP_GT, OP_LE, OP_GE:
+		if v, ok := value.(json.Number); ok {
+			return v.String(), nil
+		}
+		return "", fmt.Errorf("cannot use numerical comparison operator '%v' of property %v with value %v", op, name, value)
+
+	case OP_IN:
+		if list, ok := value.([]interface{}); ok && len(list) > 0 {
+			strs := make([]string, 0, len(list))
+			for _, elem := range list {
+				if s, ok := elem.(string); !ok || strings.Contains(s, ",") || strings.Contains(s, "\a") {
+					return "", fmt.Errorf("the values of property %v with operator 'in' must be strings without commas, found %v", name, elem)
+				} else {
+					strs = append(strs, s)
+				}
+			}
+			return strings.Join(strs, ","), nil
+		} else if isString && (semanticversion.IsVersionString(str) || semanticversion.IsVersionExpression(str)) {
+			if _, err := semanticversion.Version_Expression_Factory(str); err != nil {
+				return "", err
+			}
+			return str, nil
+		}
+		return "", fmt.Errorf("the value of property %v with operator 'in' must be a version, a version range or an array of strings, found %v", name, value)
+
+	case OP_MATCH, OP_STARTSWITH, OP_ENDSWITH:
+		if !isString {
+			return "", fmt.Errorf("the value of property %v with operator '%v' must be a string, found %v", name, op, value)
+		} else if op == OP_MATCH {
+			if _, err := regexp.Compile(str); err != nil {
+				return "", fmt.Errorf("the value %v of property %v is not a valid regular expression: %v", str, name, err)
+			}
+		}
+		return str, nil
+	}
+
+	return "", fmt.Errorf("the operator '%v' of property %v is not supported", op, name)
+}
+
+// Returns true when the constraint is a JSON object.
+func isJSONConstraint(constraint string) bool {
+	return strings.HasPrefix(strings.TrimSpace(constraint), "{")
+}
@@ -0,0 +1,117 @@
+//go:build unit
+// +build unit
+
+package json_language
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Validate_Succeed(t *testing.T) {
+	jsonConstraintLanguagePlugin := NewJSONConstraintLanguagePlugin()
+	constraintStrings := []string{
+		`{"property": "iame2edev", "value": true}`,
+		`{"and": [{"property": "cpu", "op": ">=", "value": 3}, {"property": "memory", "op": "<", "value": 32.5}]}`,
+		`{"or": [{"property": "version", "op": "in", "value": "[1.0.0,2.0.0)"}, {"property": "version", "op": "==", "value": "1.1.1"}]}`,
+		`{"and": [{"not": {"property": "os", "value": "windows"}}, {"property": "cert", "op": "in", "value": ["USDA", "Organic"]}]}`,
+		`{"and": [{"property": "hostname", "op": "~=", "value": "line-[0-9]+"}, {"property": "gpu", "op": "exists"}, {"property": "sim", "op": "not exists"}]}`,
+		`{"or": [{"property": "location", "op": "startswith", "value": "us-"}, {"property": "location", "op": "endswith", "value": "east"}]}`,
+	}
+
+	validated, _, err := jsonConstraintLanguagePlugin.Validate(interface{}(constraintStrings))
+	if validated == false {
+		t.Errorf("Should validate successfully but not, err: %v", err)
+	} else if err != nil {
+		t.Errorf("Should validate without err, but returned err: %v", err)
+	}
+}
+
+func Test_Validate_Not_Owned(t *testing.T) {
+	jsonConstraintLanguagePlugin := NewJSONConstraintLanguagePlugin()
+
+	// text constraints, and lists that mix text and JSON constraints, are not owned by the plugin.
+	for _, constraints := range [][]string{
+		{"iame2edev == true && cpu == 3"},
+		{`{"property": "a", "value": 1}`, "b == 2"},
+		{},
+	} {
+		if validated, _, err := jsonConstraintLanguagePlugin.Validate(interface{}(constraints)); validated {
+			t.Errorf("%v should not be owned by the JSON plugin", constraints)
+		} else if err != nil {
+			t.Errorf("%v should not return an error, but returned: %v", constraints, err)
+		}
+	}
+
+	if validated, _, _ := jsonConstraintLanguagePlugin.Validate(interface{}("a == b")); validated {
+		t.Errorf("A string should not be owned by the JSON plugin")
+	}
+}
+
+func Test_Validate_Failed(t *testing.T) {
+	jsonConstraintLanguagePlugin := NewJSONConstraintLanguagePlugin()
+
+	for constraint, message := range map[string]string{
+		`{"property": "a", "value": 1`:              "is not a JSON object",
+		`{"nand": [{"property": "a", "value": 1}]}`: "must have one of the keys and, or, not or property, found nand",
+		`{"and": []}`: "the value of and must be a non-empty array of expressions",
+		`{"and": [{"property": "a", "value": 1}], "or": []}`:     "must have one of the keys and, or, not or property",
+		`{"property": "a", "op": "==", "value": 1, "type": "x"}`: "a property expression can only have the keys property, op and value, found type",
+		`{"property": "", "value": 1}`:                           "the property must be a property name",
+		`{"property": "a", "op": "<", "value": "one"}`:           "cannot use numerical comparison operator '<' of property a with value one",
+		`{"property": "a", "op": "==", "value": "x, y"}`:         "it can only use operator 'in'",
+		`{"property": "a", "op": "in", "value": "abc"}`:          "must be a version, a version range or an array of strings",
+		`{"property": "a", "op": "in", "value": [1, 2]}`:         "must be strings without commas",
+		`{"property": "a", "op": "~=", "value": "x[1"}`:          "is not a valid regular expression",
+		`{"property": "a", "op": "exists", "value": true}`:       "does not have a value",
+		`{"property": "a", "op": "like", "value": "b"}`:          "the operator 'like' of property a is not supported",
+	} {
+		validated, _, err := jsonConstraintLanguagePlugin.Validate(interface{}([]string{constraint}))
+		if !validated {
+			t.Errorf("%v should be owned by the JSON plugin", constraint)
+		} else if err == nil {
+			t.Errorf("Validation of %v should fail and return err, but didn't", constraint)
+		} else if !strings.Contains(err.Error(), message) {
+			t.Errorf("Error message: %v does not contain the expected error message %v", err, message)
+		}
+	}
+}
+
+func Test_GetNextExpression_GetNextOperator(t *testing.T) {
+	jsonConstraintLanguagePlugin := NewJSONConstraintLanguagePlugin()
+
+	ce := `{"or": [{"and": [{"property": "a", "value": 1}, {"not": {"or": [{"property": "b", "op": "exists"}, {"property": "c", "op": "in", "value": ["x", "y"]}]}}]}, {"property": "d", "op": "!=", "value": false}]}`
+	expected := []string{"(", "a\a==\a1", "AND", "NOT", "b\aexists\a", "OR", "c\ain\ax,y", ")", ")", "OR", "d\a!=\afalse", ""}
+
+	found := make([]string, 0)
+	rem := ce
+	var exp, op string
+	var err error
+	for i := 0; i < 20; i++ {
+		exp, rem, err = jsonConstraintLanguagePlugin.GetNextExpression(rem)
+		if err != nil {
+			t.Errorf("Error parsing constraint expression %v with GetNextExpression: %v", ce, err)
+			break
+		} else if exp != "" {
+			found = append(found, exp)
+		}
+		op, rem, err = jsonConstraintLanguagePlugin.GetNextOperator(rem)
+		if err != nil {
+			t.Errorf("Error parsing constraint expression %v with GetNextOperator: %v", ce, err)
+			break
+		}
+		found = append(found, op)
+		if rem == "" {
+			break
+		}
+	}
+
+	if strings.Join(found, " ") != strings.Join(expected, " ") {
+		t.Errorf("The tokens %q of %v should be %q", found, ce, expected)
+	}
+
+	// a property expression is not a control operator
+	if _, _, err := jsonConstraintLanguagePlugin.GetNextOperator(`{"property": "a", "value": 1}`); err == nil {
+		t.Errorf("GetNextOperator should return an error for a property expression")
+	}
+}
@@ -3,6 +3,7 @@ package plugin_registry
 import (
 	"errors"
 	"fmt"
+	"sort"
 )
 
 // Each constraint language plugin implements this interface.
@@ -27,7 +28,7 @@ func Register(name string, p ConstraintLanguagePlugin) {
 // returned, then one of the plugins has validated the constraint expression.
 func (d ConstraintLanguageRegistry) ValidatedByOne(constraints interface{}) ([]string, error) {
 	errs := ""
-	for _, p := range d {
+	for _, p := range d.plugins() {
 		if owned, constraints, err := p.Validate(constraints); owned {
 			return constraints, err
 		} else if err != nil {
@@ -42,7 +43,7 @@ func (d ConstraintLanguageRegistry) ValidatedByOne(constraints interface{}) ([]s
 // until one of them claims ownership of the constraint expression. If no error is
 // returned, then one of the plugins has claimed ownership.
 func (d ConstraintLanguageRegistry) GetLanguageHandlerByOne(constraints interface{}) (ConstraintLanguagePlugin, error) {
-	for _, p := range d {
+	for _, p := range d.plugins() {
 		if owned, _, err := p.Validate(constraints); owned {
 			return p, err
 		}
@@ -51,6 +52,21 @@ func (d ConstraintLanguageRegistry) GetLanguageHandlerByOne(constraints interfac
 	return nil, errors.New(fmt.Sprintf("constraint language %v is not supported", constraints))
 }
 
+// Returns the plugins in the order of their names, so that the plugins are always asked in the same order.
+func (d ConstraintLanguageRegistry) plugins() []ConstraintLanguagePlugin {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	plugins := make([]ConstraintLanguagePlugin, 0, len(d))
+	for _, name := range names {
+		plugins = append(plugins, d[name])
+	}
+	return plugins
+}
+
 // Utility methods that can be used by other parts of the system to ask the global registry about plugins.
 func (d ConstraintLanguageRegistry) HasPlugin(name string) bool {
 	if _, ok := d[name]; ok {
@@ -8,6 +8,7 @@ import (
 	"github.com/open-horizon/anax/externalpolicy/plugin_registry"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/semanticversion"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -19,6 +20,15 @@ func init() {
 type TextConstraintLanguagePlugin struct {
 }
 
+// The control operator that negates a parenthetical expression, i.e. NOT (...)
+const NOT_OP = "NOT"
+
+// The comparison operators that are words. The existence operators do not have a value.
+const OP_EXISTS = "exists"
+const OP_NOT_EXISTS = "not exists"
+const OP_STARTSWITH = "startswith"
+const OP_ENDSWITH = "endswith"
+
 func NewTextConstraintLanguagePlugin() plugin_registry.ConstraintLanguagePlugin {
 	return new(TextConstraintLanguagePlugin)
 }
@@ -65,6 +75,12 @@ func (p *TextConstraintLanguagePlugin) Validate(dconstraints interface{}) (bool,
 					parenCount--
 				} else if ctrlOp == "(" {
 					parenCount++
+				} else if ctrlOp == NOT_OP {
+					// NOT negates the parenthetical expression that follows it, so it can only be used where an expression is expected.
+					if exp != "" {
+						return false, nil, fmt.Errorf("Error finding a control operator in %s. Error was: %v must follow one of AND,&&,OR,|| or an open parenthesis.", fullConstr, NOT_OP)
+					}
+					parenCount++
 				} else {
 					foundOp = true
 				}
@@ -102,7 +118,7 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 	nextRune := nextToken.Type
 
 	// Start of property expression. This case will consume the entire expression.
-	if nextRune == def["Str"] || nextRune == def["InStr"] {
+	if nextRune == def["Str"] || nextRune == def["InStr"] || isTildeStr(nextToken.Value, nextRune, def) {
 		name := nextToken.Value
 		var opType rune
 		var valType rune
@@ -114,7 +130,37 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 		}
 
 		nextRune = nextToken.Type
-		if nextRune != def["OpEq"] && nextRune != def["OpComp"] && nextRune != def["OpIn"] {
+
+		// The start of a negated parenthetical expression, which is returned by GetNextOperator.
+		if strings.TrimSpace(name) == NOT_OP && nextRune == def["OpenParen"] {
+			return "", expression, nil
+		}
+
+		// The operators that are words are lexed as strings.
+		if nextRune == def["Str"] {
+			switch strings.TrimSpace(nextToken.Value) {
+			case OP_EXISTS:
+				return fmt.Sprintf("%v\a%v\a", name, OP_EXISTS), strings.Replace(expression, name+nextToken.Value, "", 1), nil
+			case "not":
+				notToken := nextToken
+				if nextToken, err = lex.Next(); err != nil {
+					return "", expression, fmt.Errorf("Unrecognized token found: %v", err)
+				} else if nextToken.Type != def["Str"] || strings.TrimSpace(nextToken.Value) != OP_EXISTS {
+					return "", expression, fmt.Errorf("Non-operator token proceeding property name token. \"%s%s%s\"", name, notToken.Value, nextToken.Value)
+				}
+				return fmt.Sprintf("%v\a%v\a", name, OP_NOT_EXISTS), strings.Replace(expression, name+notToken.Value+nextToken.Value, "", 1), nil
+			case OP_STARTSWITH, OP_ENDSWITH:
+				op = nextToken.Value
+				opType = nextRune
+			}
+		} else if nextRune == def["OpEq"] && strings.HasSuffix(name, "~") && strings.TrimSpace(nextToken.Value) == "=" {
+			// without whitespace before the ~= operator, the ~ is lexed as part of the property name.
+			name = name[:len(name)-1]
+			nextToken.Value = "~" + nextToken.Value
+			nextRune = def["OpMatch"]
+		}
+
+		if op == "" && nextRune != def["OpEq"] && nextRune != def["OpComp"] && nextRune != def["OpIn"] && nextRune != def["OpMatch"] {
 			if len(name) > 3 && name[len(name)-2:] == "in" {
 				op = "in"
 				opType = def["in"]
@@ -125,9 +171,11 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 				return "", expression, fmt.Errorf("Non-operator token proceeding property name token. \"%s%s\"", name, nextToken.Value)
 			}
 		}
-		if op == "" {
-			op = nextToken.Value
-			opType = nextRune
+		if val == "" {
+			if op == "" {
+				op = nextToken.Value
+				opType = nextRune
+			}
 			nextToken, err = lex.Next()
 			if err != nil {
 				return "", expression, fmt.Errorf("Unrecognized token found: %v", err)
@@ -135,13 +183,16 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 			nextRune = nextToken.Type
 		}
 
-		if nextRune != def["Str"] && nextRune != def["InStr"] && nextRune != def["QuoteStr"] && nextRune != def["ListStr"] && nextRune != def["Vers"] && nextRune != def["VersRange"] && nextRune != def["Num"] {
+		if nextRune != def["Str"] && nextRune != def["InStr"] && nextRune != def["QuoteStr"] && nextRune != def["ListStr"] && nextRune != def["PatternStr"] && nextRune != def["Vers"] && nextRune != def["VersRange"] && nextRune != def["Num"] {
 			return "", expression, fmt.Errorf("Invalid property value. %v%v%v", name, op, nextToken.Value)
 		}
 		if val == "" {
 			val = nextToken.Value
 			valType = nextRune
 		}
+		if strings.TrimSpace(op) != "in" && isTildeStr(val, valType, def) {
+			valType = def["Str"]
+		}
 		if err = validOpValuePair(name, op, opType, val, valType, def); err != nil {
 			return "", expression, err
 		}
@@ -184,6 +235,13 @@ func (p *TextConstraintLanguagePlugin) GetNextOperator(expression string) (strin
 		op := nextToken.Value
 		return strings.TrimSpace(op), strings.Replace(expression, op, "", 1), nil
 	}
+
+	// NOT is lexed as a string, it is returned with the open parenthesis that follows it.
+	if nextRune == def["Str"] && strings.TrimSpace(nextToken.Value) == NOT_OP {
+		if parenToken, err := lex.Next(); err == nil && parenToken.Type == def["OpenParen"] {
+			return NOT_OP, strings.Replace(expression, nextToken.Value+parenToken.Value, "", 1), nil
+		}
+	}
 	return "", expression, fmt.Errorf("No control operator found. Expecting one of AND,&&,OR,||. Found: %v", expression)
 }
 
@@ -193,6 +251,8 @@ func (p *TextConstraintLanguagePlugin) GetNextOperator(expression string) (strin
 // 4. for string types, a quoted string, inside which is a list of comma separated strings provide acceptable values
 // 5. string values that contain spaces must be quoted
 // 6. for the version type, supported values are a single version or a range of versions in the semantic version format (the same as used for service verions). The == operator implies that the value is a single version. The 'in' operator treats the value as a version range. As with service versions, the version 1.0.0 when treated as a version range is equivalent to the explicit range [1.0.0,INFINITY).
+// 7. for string types, ~= matches the value with a regular expression, startswith and endswith match the beginning or the end of the value. A quoted value that contains other special characters can only be used with these operators.
+// 8. exists and not exists check that the property is or is not set, they do not have a value.
 
 // This function checks that the operator is valid for the specified value and validates version ranges with the semanticversion Factory function
 // Returns a property expression struct with numerical values as float64
@@ -200,6 +260,19 @@ func (p *TextConstraintLanguagePlugin) GetNextOperator(expression string) (strin
 func validOpValuePair(name string, op string, opType rune, val interface{}, valType rune, lexMap map[string]rune) error {
 	var err error
 
+	if lexMap["PatternStr"] == valType && lexMap["OpMatch"] != opType && !isStringMatchOp(op) {
+		return fmt.Errorf("A quoted value with special characters can only be used with the operators ~=, %v and %v.", OP_STARTSWITH, OP_ENDSWITH)
+	}
+	if lexMap["OpMatch"] == opType || isStringMatchOp(op) {
+		if lexMap["VersRange"] == valType {
+			return fmt.Errorf("Version range can only use operator 'in'.")
+		}
+		if lexMap["OpMatch"] == opType {
+			if _, err = regexp.Compile(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(val.(string)), "\""), "\"")); err != nil {
+				return fmt.Errorf("The value %v of the ~= operator is not a valid regular expression: %v", strings.TrimSpace(val.(string)), err)
+			}
+		}
+	}
 	if lexMap["OpEq"] == opType {
 		if lexMap["VersRange"] == valType {
 			return fmt.Errorf("Version range can only use operator 'in'.")
@@ -253,8 +326,12 @@ func getLexer() lexer.Definition {
 		OpComp =  {whitespace} ( ["="] (">" | "<") ["="] ) {whitespace} .
 		OpIn =  {whitespace} "in" {whitespace} .
 	  OpEq =  {whitespace}  ( "!=" | "="["="] )  {whitespace} .
+	  OpMatch =  {whitespace}  "~="  {whitespace} .
 
-	  VersRange = {whitespace}  ( "(" | "[" )  vers {whitespace}  "," {whitespace}  (vers | "INFINITY")  ("]" | ")").
+	  VersRange = {whitespace}  ( ( "(" | "[" )  vers {whitespace}  "," {whitespace}  (vers | "INFINITY")  ("]" | ")") | ( "^" | "~" ) shortvers {shortvers} | wildvers ) .
+	  shortvers = alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | "." | "*" .
+	  wildvers = digit {digit} "." ( wildcard {"." wildcard} | digit {digit} "." wildcard ) | "*" .
+	  wildcard = "x" | "X" | "*" .
 		Vers = {whitespace}  vers .
 	  Num = {whitespace} ["-"] digit {digit} ["." {digit}] .
 	  whitespace = "\n" | "\r" | "\t" | " " .
@@ -265,11 +342,26 @@ func getLexer() lexer.Definition {
 	  Str =  {whitespace} (alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | ".") {alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | "."} .
 	  QuoteStr = {whitespace} "\x22" (alphanumeric  | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | " " | "\t") {alphanumeric | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | " " | "\t" } "\x22" .
 		ListStr = {whitespace} "\x22" (alphanumeric  | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | "," | " " | "\t") {alphanumeric | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | "," | " " | "\t" } "\x22" .
+	  PatternStr = {whitespace} "\x22" ("\t" | " "…"\U0010FFFF"-"\x22") {"\t" | " "…"\U0010FFFF"-"\x22"} "\x22" .
 
 
 	  Unused = digit .`))
 }
 
+// The tilde ranges are lexed with all the characters of a string that follow the tilde, so that a string that begins with
+// a tilde, such as ~1a, is not split into a range and a string. Returns true when the token is such a string, the caller
+// decides whether it is a string or a tilde range from the operator it is used with.
+func isTildeStr(val string, valType rune, def map[string]rune) bool {
+	val = strings.TrimSpace(val)
+	return valType == def["VersRange"] && strings.HasPrefix(val, "~") && !strings.Contains(val, "*")
+}
+
+// Returns true for the operators that match the beginning or the end of a string.
+func isStringMatchOp(op string) bool {
+	op = strings.TrimSpace(op)
+	return op == OP_STARTSWITH || op == OP_ENDSWITH
+}
+
 func isConstraintExpression(x interface{}) bool {
 	switch x.(type) {
 	case []string:
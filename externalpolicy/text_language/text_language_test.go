@@ -4,6 +4,7 @@
 package text_language
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -299,6 +300,134 @@ func Test_Validate_Succeed8(t *testing.T) {
 	}
 }
 
+func Test_Validate_Succeed9(t *testing.T) {
+
+	// negation, existence, regular expression, prefix and suffix operators.
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+	constraintStrings := []string{
+		"NOT (os == windows OR arch == arm) AND gpu exists",
+		"hostname ~= \"line-[0-9]+\" && simulator not exists",
+		"hostname~=\"^line-.*$\" OR (location startswith \"us-\" AND NOT(location endswith east))",
+		"NOTE == important AND (NOT ( a == 1 ))",
+	}
+	ce := constraintStrings
+
+	validated, _, err := textConstraintLanguagePlugin.Validate(interface{}(ce))
+	if validated == false {
+		t.Errorf("Validation failed but should not, err: %v", err)
+	} else if err != nil {
+		t.Errorf("Validation succeeded but also returned an error: %v", err)
+	}
+}
+
+func Test_Validate_Failed6(t *testing.T) {
+
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+	for constraint, message := range map[string]string{
+		// NOT must follow a control operator
+		"a == 1 NOT (b == 2)": "Error finding a control operator in a == 1 NOT (b == 2). Error was: NOT must follow one of AND,&&,OR,|| or an open parenthesis.",
+		// quoted values with special characters are patterns
+		"a == \"x[1]\"": "Error finding an expression in a == \"x[1]\". Error was: A quoted value with special characters can only be used with the operators ~=, startswith and endswith.",
+		// the regular expression must compile
+		"a ~= \"x[1\"": "Error finding an expression in a ~= \"x[1\". Error was: The value \"x[1\" of the ~= operator is not a valid regular expression: error parsing regexp: missing closing ]: `[1`",
+		// not must be followed by exists
+		"a not b":             "Error finding an expression in a not b. Error was: Non-operator token proceeding property name token. \"a not b\"",
+		"(a ~= [1.0.0,2.0.0]": "Error finding an expression in (a ~= [1.0.0,2.0.0]. Error was: Version range can only use operator 'in'.",
+	} {
+		validated, _, err := textConstraintLanguagePlugin.Validate(interface{}([]string{constraint}))
+		if validated == true {
+			t.Errorf("Validation of %v should fail but did not, err: %v", constraint, err)
+		} else if err == nil {
+			t.Errorf("Validation of %v should fail and return err, but didn't", constraint)
+		} else if err.Error() != message {
+			t.Errorf("Error message: %v is not the expected error message", err)
+		}
+	}
+}
+
+func Test_Validate_Version_Ranges(t *testing.T) {
+
+	// caret, tilde and wildcard version ranges
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+	constraintStrings := []string{
+		"version in ^1.2 AND fw in ~2.3.4",
+		"version in 1.2.x || version in 1.* || version in *",
+		"(version in ^0.2.3-beta.1 OR version in [1.0.0,2.0.0))",
+		"site == ~1a OR site == ~x1 OR (site ~= ~1.2 AND version in ~1.2.x)",
+	}
+
+	validated, _, err := textConstraintLanguagePlugin.Validate(interface{}(constraintStrings))
+	if validated == false {
+		t.Errorf("Validation failed but should not, err: %v", err)
+	} else if err != nil {
+		t.Errorf("Validation succeeded but also returned an error: %v", err)
+	}
+
+	for ce, expected := range map[string]string{
+		"version in ^1.2 AND a == 1": "version\ain\a^1.2",
+		"version in ~1.2.3":          "version\ain\a~1.2.3",
+		"version in 1.2.x":           "version\ain\a1.2.x",
+		"name == ~foo":               "name\a==\a~foo",
+		"site == ~1a":                "site\a==\a~1a",
+		"site == ~x1 AND a == 1":     "site\a==\a~x1",
+		"site startswith ~1.2":       "site\astartswith\a~1.2",
+		"~1a == b":                   "~1a\a==\ab",
+	} {
+		if exp, _, err := textConstraintLanguagePlugin.GetNextExpression(ce); err != nil {
+			t.Errorf("Error parsing constraint expression %v with GetNextExpression: %v", ce, err)
+		} else if exp != expected {
+			t.Errorf("Expression %q parsed from %v is not the expected expression %q", exp, ce, expected)
+		}
+	}
+
+	for constraint, message := range map[string]string{
+		"version == ^1.2":     "Error finding an expression in version == ^1.2. Error was: Version range can only use operator 'in'.",
+		"version in ^1.2.3.4": "Error finding an expression in version in ^1.2.3.4. Error was: Version_Expression: ^1.2.3.4 is not a valid version range.",
+		"version in ~1a":      "Error finding an expression in version in ~1a. Error was: Version_Expression: ~1a is not a valid version range.",
+	} {
+		validated, _, err := textConstraintLanguagePlugin.Validate(interface{}([]string{constraint}))
+		if validated == true {
+			t.Errorf("Validation of %v should fail but did not, err: %v", constraint, err)
+		} else if err == nil {
+			t.Errorf("Validation of %v should fail and return err, but didn't", constraint)
+		} else if err.Error() != message {
+			t.Errorf("Error message: %v is not the expected error message", err)
+		}
+	}
+}
+
+func Test_GetNextExpression_Operators(t *testing.T) {
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+
+	for ce, expected := range map[string]string{
+		"gpu exists AND a == 1":          "gpu\aexists\a",
+		"gpu not  exists":                "gpu\anot exists\a",
+		"hostname ~= \"line-[0-9]+\"":    "hostname\a~=\a\"line-[0-9]+\"",
+		"hostname~=\"line-[0-9]+\"":      "hostname\a~=\a\"line-[0-9]+\"",
+		"location startswith \"us-\"":    "location\astartswith\a\"us-\"",
+		"location endswith east || a==1": "location\aendswith\aeast",
+	} {
+		exp, rem, err := textConstraintLanguagePlugin.GetNextExpression(ce)
+		if err != nil {
+			t.Errorf("Error parsing constraint expression %v with GetNextExpression: %v", ce, err)
+		} else if exp != expected {
+			t.Errorf("Expression %q parsed from %v is not the expected expression %q", exp, ce, expected)
+		} else if strings.TrimSpace(rem) != "" {
+			if op, _, err := textConstraintLanguagePlugin.GetNextOperator(rem); err != nil || (op != "AND" && op != "||") {
+				t.Errorf("Remainder %v of %v should start with an operator, found %v, error %v", rem, ce, op, err)
+			}
+		}
+	}
+
+	// NOT is returned by GetNextOperator with its parenthesis
+	ce := "NOT (a == 1)"
+	if exp, rem, err := textConstraintLanguagePlugin.GetNextExpression(ce); err != nil || exp != "" || rem != ce {
+		t.Errorf("GetNextExpression should not consume NOT, returned %v, %v, %v", exp, rem, err)
+	} else if op, rem, err := textConstraintLanguagePlugin.GetNextOperator(rem); err != nil || op != "NOT" || rem != "a == 1)" {
+		t.Errorf("GetNextOperator should return NOT, returned %v, %v, %v", op, rem, err)
+	}
+}
+
 func Test_GetNextExpression_Succeed(t *testing.T) {
 	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
 	ce := "version == 1.1.1 OR USDA == true AND book == \"one fish two fish\" && author == \"Suess\""
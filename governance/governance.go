@@ -41,6 +41,7 @@ const MICROSERVICE_GOVERNOR = "MicroserviceGovernor"
 const BC_GOVERNOR = "BlockchainGovernor"
 const SURFACEERRORS = "SurfaceExchErrors"
 const NODESTATUS = "NodeStatus"
+const EVENTLOG_RETENTION = "EventLogRetention"
 
 // Keys for the exchange errors cache in the worker
 const EXCHANGE_ERRORS = "ExchangeErrors"
@@ -479,12 +480,17 @@ func (w *GovernanceWorker) governAgreements() {
 				if ag.AgreementExecutionStartTime == 0 {
 					// workload not started yet and in an agreement ...
 					if (int64(ag.AgreementAcceptedTime) + (w.Config.Edge.MaxAgreementPrelaunchTimeM * 60)) < time.Now().Unix() {
-						glog.Infof(logString(fmt.Sprintf("terminating agreement %v because it hasn't been launched in max allowed time. This could be because of a workload failure.", ag.CurrentAgreementId)))
-						reason := w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_NOT_EXECUTED_TIMEOUT)
-						eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_INFO,
-							persistence.NewMessageMeta(EL_GOV_START_TERM_AG_WITH_REASON, ag.RunningWorkload.URL, w.producerPH[ag.AgreementProtocol].GetTerminationReason(reason)),
-							persistence.EC_CANCEL_AGREEMENT_EXECUTION_TIMEOUT, ag)
-						w.cancelGovernedAgreement(&ag, reason)
+						if w.pullingImages(&ag) {
+							// the images may still be pulled within the image pull retry deadline.
+							glog.V(3).Infof(logString(fmt.Sprintf("agreement %v hasn't been launched in max allowed time, waiting for its images to be pulled.", ag.CurrentAgreementId)))
+						} else {
+							glog.Infof(logString(fmt.Sprintf("terminating agreement %v because it hasn't been launched in max allowed time. This could be because of a workload failure.", ag.CurrentAgreementId)))
+							reason := w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_NOT_EXECUTED_TIMEOUT)
+							eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_INFO,
+								persistence.NewMessageMeta(EL_GOV_START_TERM_AG_WITH_REASON, ag.RunningWorkload.URL, w.producerPH[ag.AgreementProtocol].GetTerminationReason(reason)),
+								persistence.EC_CANCEL_AGREEMENT_EXECUTION_TIMEOUT, ag)
+							w.cancelGovernedAgreement(&ag, reason)
+						}
 					}
 				} else {
 					// Finalized agreements could become out of policy if the policy changes on the node. Verify that the existing agreement
@@ -523,12 +529,17 @@ func (w *GovernanceWorker) governAgreements() {
 
 						// The proposal for this agreement is no longer compatible with the node's policy, so cancel the agreement.
 						glog.V(3).Infof(logString(fmt.Sprintf("current proposal for %v is out of policy: %v", ag.CurrentAgreementId, err)))
-						glog.V(3).Infof(logString(fmt.Sprintf("terminating agreement %v because it cannot be verified by the agreement bot.", ag.CurrentAgreementId)))
-						reason := w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_POLICY_CHANGED)
-						eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_INFO,
-							persistence.NewMessageMeta(EL_GOV_START_TERM_AG_WITH_REASON, ag.RunningWorkload.URL, w.producerPH[ag.AgreementProtocol].GetTerminationReason(reason)),
-							persistence.EC_CANCEL_AGREEMENT, ag)
-						w.cancelGovernedAgreement(&ag, reason)
+						if !w.inMaintenanceWindow(tcPolicy) {
+							// The cancellation is not urgent, it is retried the next time the agreements are governed.
+							glog.V(3).Infof(logString(fmt.Sprintf("deferring termination of agreement %v until the maintenance window opens.", ag.CurrentAgreementId)))
+						} else {
+							glog.V(3).Infof(logString(fmt.Sprintf("terminating agreement %v because it cannot be verified by the agreement bot.", ag.CurrentAgreementId)))
+							reason := w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_POLICY_CHANGED)
+							eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_INFO,
+								persistence.NewMessageMeta(EL_GOV_START_TERM_AG_WITH_REASON, ag.RunningWorkload.URL, w.producerPH[ag.AgreementProtocol].GetTerminationReason(reason)),
+								persistence.EC_CANCEL_AGREEMENT, ag)
+							w.cancelGovernedAgreement(&ag, reason)
+						}
 
 					} else {
 						glog.V(5).Infof(logString(fmt.Sprintf("agreement %v is still in policy.", ag.CurrentAgreementId)))
@@ -796,6 +807,11 @@ func (w *GovernanceWorker) Initialize() bool {
 	// Fire up the microservice governor
 	w.DispatchSubworker(MICROSERVICE_GOVERNOR, w.governMicroservices, 60, false)
 
+	// remove the event log records that exceed the configured retention limits
+	if !eventlog.GetRetentionPolicy(w.BaseWorker.Manager.Config.Edge.EventLogRetention).IsEmpty() {
+		w.DispatchSubworker(EVENTLOG_RETENTION, w.pruneEventLogs, w.BaseWorker.Manager.Config.Edge.EventLogRetention.CheckIntervalS, false)
+	}
+
 	// for the policy case update the exchange with the latest registeredServices
 	if w.devicePattern == "" {
 		w.UpdateRegisteredServicesWithAgreement()
@@ -805,6 +821,17 @@ func (w *GovernanceWorker) Initialize() bool {
 
 }
 
+// Remove the event log records that exceed the retention limits in the config.
+func (w *GovernanceWorker) pruneEventLogs() int {
+	retention := eventlog.GetRetentionPolicy(w.BaseWorker.Manager.Config.Edge.EventLogRetention)
+	if count, err := persistence.PruneEventLogs(w.db, retention, uint64(time.Now().Unix())); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to prune the event logs with retention policy %v. Error: %v", retention, err)))
+	} else if count > 0 {
+		glog.V(3).Infof(logString(fmt.Sprintf("Removed %v event log records that exceed retention policy %v", count, retention)))
+	}
+	return 0
+}
+
 func (w *GovernanceWorker) CommandHandler(command worker.Command) bool {
 
 	// It's possible that the command handler stays busy enough that the noworkhandler never gets
@@ -2090,6 +2117,31 @@ func (w *GovernanceWorker) agreementRequiresService(ag persistence.EstablishedAg
 	return false, nil
 }
 
+// Returns true if the images of the agreement are being pulled and the image pull retry deadline, counted from the time the
+// agreement was accepted, has not passed. Such an agreement is not cancelled for not being launched in time.
+func (w *GovernanceWorker) pullingImages(ag *persistence.EstablishedAgreement) bool {
+	if deadlineS := int64(w.Config.Edge.ImagePull.RetryDeadlineM * 60); int64(ag.AgreementAcceptedTime)+deadlineS < time.Now().Unix() {
+		return false
+	} else if pulls, err := persistence.FindImagePulls(w.db, []persistence.IPFilter{persistence.InProgressIPFilter(), persistence.AgreementIPFilter(ag.CurrentAgreementId)}); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read the image pulls of agreement %v from the local database, error %v", ag.CurrentAgreementId, err)))
+		return false
+	} else {
+		return len(pulls) != 0
+	}
+}
+
+// Returns true if non-urgent workload changes can be made now, that is when the maintenance windows in the node policy
+// and in the TsAndCs of the agreement are both open.
+func (w *GovernanceWorker) inMaintenanceWindow(tcPolicy *policy.Policy) bool {
+	now := time.Now()
+	if nodePol, err := persistence.FindNodePolicy(w.db); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read node policy from the local database, error %v", err)))
+	} else if nodePol != nil && !nodePol.MaintenanceWindows.IsOpen(now) {
+		return false
+	}
+	return tcPolicy == nil || tcPolicy.MaintenanceWindows.IsOpen(now)
+}
+
 func (w *GovernanceWorker) cancelAllAgreements() {
 	glog.V(5).Infof(logString("Canceling all agreements..."))
 
@@ -0,0 +1,457 @@
+package imagefetch
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/worker"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const IMAGE_LIFECYCLE = "ImageLifecycle"
+
+// The images of an archived agreement are still in use for at least this number of seconds after the agreement was
+// terminated, so that they are not removed while a new agreement for the same service is being made.
+const ARCHIVED_AGREEMENT_IMAGE_HOLD_S = 3600
+
+// The image lifecycle worker pre-loads the images in the pre-seed directory and removes the images that are no longer used
+// by the services on the node. Images pulled by the agent are removed when they have not been used for the grace period
+// in the config, or when the disk budget in the config is exceeded. Any image can be removed by the image removal policies
+// of the node management policies that apply to the node.
+type ImageLifecycleWorker struct {
+	worker.BaseWorker // embedded field
+	db                *bolt.DB
+	client            *docker.Client
+}
+
+func NewImageLifecycleWorker(name string, config *config.HorizonConfig, db *bolt.DB) *ImageLifecycleWorker {
+
+	// do not start this worker if the the node is registered and the type is cluster, or there is no docker
+	dev, _ := persistence.FindExchangeDevice(db)
+	if (dev != nil && dev.GetNodeType() == persistence.DEVICE_TYPE_CLUSTER) || config.Edge.DockerEndpoint == "" {
+		return nil
+	}
+
+	client, err := docker.NewClient(config.Edge.DockerEndpoint)
+	if err != nil {
+		glog.Errorf("Failed to instantiate docker Client: %v", err)
+		panic("Unable to instantiate docker Client")
+	}
+
+	worker := &ImageLifecycleWorker{
+		BaseWorker: worker.NewBaseWorker(name, config, nil),
+		db:         db,
+		client:     client,
+	}
+
+	worker.Start(worker, 0)
+	return worker
+}
+
+func (w *ImageLifecycleWorker) Messages() chan events.Message {
+	return w.BaseWorker.Manager.Messages
+}
+
+func (w *ImageLifecycleWorker) NewEvent(incoming events.Message) {
+
+	switch incoming.(type) {
+	case *events.EdgeRegisteredExchangeMessage:
+		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
+
+		// stop the image lifecycle worker for the cluster device type
+		if msg.DeviceType() == persistence.DEVICE_TYPE_CLUSTER {
+			w.Commands <- worker.NewBeginShutdownCommand()
+			w.Commands <- worker.NewTerminateCommand("cluster node")
+		}
+
+	case *events.NodeShutdownMessage:
+		msg, _ := incoming.(*events.NodeShutdownMessage)
+		switch msg.Event().Id {
+		case events.START_UNCONFIGURE:
+			w.Commands <- worker.NewBeginShutdownCommand()
+		}
+
+	case *events.NodeShutdownCompleteMessage:
+		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
+		switch msg.Event().Id {
+		case events.UNCONFIGURE_COMPLETE:
+			w.Commands <- worker.NewTerminateCommand("shutdown")
+		}
+
+	default: //nothing
+
+	}
+
+	return
+}
+
+func (w *ImageLifecycleWorker) Initialize() bool {
+	// load the pre-seeded images right away so that they are available for the first agreements
+	w.preseedImages()
+
+	w.DispatchSubworker(IMAGE_LIFECYCLE, w.manageImages, w.Config.Edge.ImageLifecycle.CheckIntervalS, false)
+	return true
+}
+
+func (w *ImageLifecycleWorker) CommandHandler(command worker.Command) bool {
+	return false
+}
+
+// The image lifecycle subworker.
+func (w *ImageLifecycleWorker) manageImages() int {
+	preseeded := w.preseedImages()
+	if err := w.removeImages(preseeded, uint64(time.Now().Unix())); err != nil {
+		glog.Errorf("Image lifecycle: unable to remove unused images: %v", err)
+	}
+	return 0
+}
+
+// Load the image archives in the pre-seed directory whose images are not on the node. Returns the names of all the images
+// in the archives, they are never removed while their archive is in the pre-seed directory.
+func (w *ImageLifecycleWorker) preseedImages() []string {
+	dir := w.Config.Edge.ImageLifecycle.PreseedDirectory
+	if dir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		glog.Errorf("Image lifecycle: unable to read image pre-seed directory %v: %v", dir, err)
+		return nil
+	}
+
+	images, err := w.client.ListImages(docker.ListImagesOptions{})
+	if err != nil {
+		glog.Errorf("Image lifecycle: unable to list local images: %v", err)
+		return nil
+	}
+
+	preseeded := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tar") {
+			continue
+		}
+
+		fileName := filepath.Join(dir, entry.Name())
+		tags, err := readImageArchiveTags(fileName)
+		if err != nil {
+			glog.Errorf("Image lifecycle: unable to read image archive %v: %v", fileName, err)
+			continue
+		} else if len(tags) == 0 {
+			glog.Warningf("Image lifecycle: ignoring image archive %v, its images have no tags", fileName)
+			continue
+		}
+		preseeded = append(preseeded, tags...)
+
+		if missing := missingImages(tags, images); len(missing) != 0 {
+			glog.Infof("Image lifecycle: loading images %v from %v", missing, fileName)
+			if err := w.loadImageArchive(fileName); err != nil {
+				glog.Errorf("Image lifecycle: unable to load image archive %v: %v", fileName, err)
+			}
+		}
+	}
+	return preseeded
+}
+
+func (w *ImageLifecycleWorker) loadImageArchive(fileName string) error {
+	file, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return w.client.LoadImage(docker.LoadImageOptions{InputStream: file})
+}
+
+// Returns the image names in the manifest of an image archive in docker save format.
+func readImageArchiveTags(fileName string) ([]string, error) {
+	file, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("there is no manifest.json in the archive")
+		} else if err != nil {
+			return nil, err
+		} else if header.Name != "manifest.json" {
+			continue
+		}
+
+		var manifest []struct {
+			RepoTags []string `json:"RepoTags"`
+		}
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to demarshal manifest.json, error: %v", err))
+		}
+
+		tags := make([]string, 0)
+		for _, m := range manifest {
+			tags = append(tags, m.RepoTags...)
+		}
+		return tags, nil
+	}
+}
+
+// Returns the image names that do not match any of the local images.
+func missingImages(names []string, images []docker.APIImages) []string {
+	missing := make([]string, 0)
+	for _, name := range names {
+		found := false
+		for _, image := range images {
+			if cutil.ImageNameMatches(name, image.RepoTags, image.RepoDigests) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Remove the local images that are not used by any service and that have not been used for the configured grace period,
+// that are selected by an image removal policy or that exceed the disk budget. Nothing is removed when the images in use
+// cannot be determined.
+func (w *ImageLifecycleWorker) removeImages(preseeded []string, now uint64) error {
+	lifecycle := w.Config.Edge.ImageLifecycle
+
+	inUse, err := w.imagesInUse(now)
+	if err != nil {
+		return err
+	}
+	inUse = append(inUse, preseeded...)
+
+	removalPolicies, err := w.imageRemovalPolicies()
+	if err != nil {
+		return err
+	}
+
+	if lifecycle.UnusedGracePeriodM == 0 && lifecycle.DiskBudgetMB == 0 && len(removalPolicies) == 0 {
+		return nil
+	}
+
+	images, err := w.client.ListImages(docker.ListImagesOptions{})
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to list local images, error: %v", err))
+	}
+	// the images pulled from a registry mirror are recorded with their original names
+	for i := range images {
+		images[i].RepoDigests = cutil.OriginalRepoDigests(images[i].RepoDigests, w.Config.Edge.RegistryMirrors)
+	}
+
+	containers, err := w.client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to list containers, error: %v", err))
+	}
+	for _, container := range containers {
+		inUse = append(inUse, container.Image)
+	}
+
+	usages, err := persistence.FindServiceImageUsageWithFilters(w.db, []persistence.IUFilter{})
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read image usage records, error: %v", err))
+	}
+
+	// the images that are still in use were last used now, the grace period starts when they are no longer in use
+	for i, usage := range usages {
+		for _, name := range inUse {
+			if name == usage.ImageId {
+				usages[i].TimeLastUsed = now
+				if err := persistence.SaveOrUpdateServiceImage(w.db, &usages[i]); err != nil {
+					glog.Errorf("Image lifecycle: unable to update usage record of image %v: %v", usage.ImageId, err)
+				}
+				break
+			}
+		}
+	}
+
+	for _, r := range selectImagesToRemove(images, usages, inUse, removalPolicies, lifecycle, now) {
+		glog.Infof("Image lifecycle: removing image %v %v, %v", r.image.ID, r.image.RepoTags, r.reason)
+		if err := w.client.RemoveImageExtended(r.image.ID, docker.RemoveImageOptions{Force: true}); err != nil {
+			glog.Errorf("Image lifecycle: unable to remove image %v %v: %v", r.image.ID, r.image.RepoTags, err)
+			continue
+		}
+		for _, imageId := range r.usageIds {
+			if err := persistence.DeleteServiceImage(w.db, imageId); err != nil {
+				glog.Errorf("Image lifecycle: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the names of the images of the active agreements, the recently archived agreements and the dependent services
+// on the node.
+func (w *ImageLifecycleWorker) imagesInUse(now uint64) ([]string, error) {
+	hold := w.Config.Edge.ImageLifecycle.UnusedGracePeriodM * 60
+	if hold < ARCHIVED_AGREEMENT_IMAGE_HOLD_S {
+		hold = ARCHIVED_AGREEMENT_IMAGE_HOLD_S
+	}
+
+	inUse := make([]string, 0)
+	agreements, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read agreements, error: %v", err))
+	}
+	for _, ag := range agreements {
+		if ag.Archived && ag.AgreementTerminatedTime+hold < now {
+			continue
+		}
+		for _, serviceConfig := range ag.CurrentDeployment {
+			inUse = append(inUse, serviceConfig.Config.Image)
+		}
+	}
+
+	msDefs, err := persistence.FindMicroserviceDefs(w.db, []persistence.MSFilter{persistence.UnarchivedMSFilter()})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read service definitions, error: %v", err))
+	}
+	for _, msDef := range msDefs {
+		if deployment, err := containermessage.GetNativeDeployment(msDef.Deployment); err == nil {
+			for _, service := range deployment.Services {
+				inUse = append(inUse, service.Image)
+			}
+		}
+	}
+	return inUse, nil
+}
+
+// Returns the image removal policies of the enabled node management policies that apply to the node. The policies that
+// apply to the node are the ones that have a status on the node.
+func (w *ImageLifecycleWorker) imageRemovalPolicies() ([]exchangecommon.ImageRemovalPolicy, error) {
+	nmps, err := persistence.FindAllNodeManagementPolicies(w.db)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read node management policies, error: %v", err))
+	}
+	statuses, err := persistence.FindAllNMPStatus(w.db)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read node management policy statuses, error: %v", err))
+	}
+
+	removalPolicies := make([]exchangecommon.ImageRemovalPolicy, 0)
+	for name, nmp := range nmps {
+		if _, ok := statuses[name]; ok && nmp.Enabled && nmp.AgentImagePolicy != nil {
+			removalPolicies = append(removalPolicies, nmp.AgentImagePolicy.Removal...)
+		}
+	}
+	return removalPolicies, nil
+}
+
+// A local image that will be removed.
+type imageRemoval struct {
+	image    docker.APIImages
+	usageIds []string // the image usage records of the image, they are deleted with the image
+	lastUsed uint64
+	reason   string
+}
+
+// Select the local images to remove. The images that match an image name in use are never removed. The images pulled by
+// the agent, the ones with image usage records, are removed when they have not been used for the grace period. Any image
+// selected by a removal policy is removed when it has not been used for the time in the policy, the time an image without
+// a usage record was last used is the time it was created. When the images pulled by the agent use more disk space than
+// the disk budget, the least recently used ones are removed until they fit.
+func selectImagesToRemove(images []docker.APIImages, usages []persistence.ServiceImageUsage, inUse []string, removalPolicies []exchangecommon.ImageRemovalPolicy, lifecycle config.ImageLifecycleConfig, now uint64) []imageRemoval {
+	removals := make([]imageRemoval, 0)
+	kept := make([]imageRemoval, 0)
+	var keptSize int64
+
+	for _, image := range images {
+		if imageInUse(image, inUse) {
+			continue
+		}
+
+		r := imageRemoval{image: image, lastUsed: uint64(image.Created)}
+		for _, usage := range usages {
+			if cutil.ImageNameMatches(usage.ImageId, image.RepoTags, image.RepoDigests) {
+				if len(r.usageIds) == 0 || usage.TimeLastUsed > r.lastUsed {
+					r.lastUsed = usage.TimeLastUsed
+				}
+				r.usageIds = append(r.usageIds, usage.ImageId)
+			}
+		}
+		pulled := len(r.usageIds) != 0
+
+		if pulled && lifecycle.UnusedGracePeriodM != 0 && r.lastUsed+lifecycle.UnusedGracePeriodM*60 <= now {
+			r.reason = fmt.Sprintf("not used for %v minutes", lifecycle.UnusedGracePeriodM)
+		} else if removalPolicy := matchRemovalPolicy(r, pulled, removalPolicies, now); removalPolicy != nil {
+			r.reason = fmt.Sprintf("selected by image removal policy %v", *removalPolicy)
+		}
+
+		if r.reason != "" {
+			removals = append(removals, r)
+		} else if pulled {
+			kept = append(kept, r)
+			keptSize += image.Size
+		}
+	}
+
+	if budget := int64(lifecycle.DiskBudgetMB) * 1024 * 1024; budget != 0 && keptSize > budget {
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].lastUsed < kept[j].lastUsed })
+		for _, r := range kept {
+			if keptSize <= budget {
+				break
+			}
+			r.reason = fmt.Sprintf("the images pulled by the agent exceed the disk budget of %v MB", lifecycle.DiskBudgetMB)
+			removals = append(removals, r)
+			keptSize -= r.image.Size
+		}
+	}
+	return removals
+}
+
+// Returns true if the image matches one of the image names in use. Containers can also refer to their image by id.
+func imageInUse(image docker.APIImages, inUse []string) bool {
+	for _, name := range inUse {
+		if name == "" {
+			continue
+		} else if image.ID == name || strings.TrimPrefix(image.ID, "sha256:") == name || cutil.ImageNameMatches(name, image.RepoTags, image.RepoDigests) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the first removal policy that selects the image. The image id of a removal policy is a regular expression that
+// is matched against the names of the image.
+func matchRemovalPolicy(r imageRemoval, pulled bool, removalPolicies []exchangecommon.ImageRemovalPolicy, now uint64) *exchangecommon.ImageRemovalPolicy {
+	names := append(append([]string{}, r.usageIds...), r.image.RepoTags...)
+	for i, removalPolicy := range removalPolicies {
+		if (removalPolicy.AgentDownloadedOnly && !pulled) || r.lastUsed+removalPolicy.DeleteAfterMinutes*60 > now {
+			continue
+		}
+		re, err := regexp.Compile(removalPolicy.ImageId)
+		if err != nil {
+			glog.Warningf("Image lifecycle: ignoring image removal policy %v, the image id is not a valid regular expression: %v", removalPolicy, err)
+			continue
+		}
+		for _, name := range names {
+			if re.MatchString(name) {
+				return &removalPolicies[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+//go:build unit
+// +build unit
+
+package imagefetch
+
+import (
+	"archive/tar"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
+	"sort"
+	"testing"
+)
+
+func removedImageIds(removals []imageRemoval) []string {
+	ids := make([]string, 0)
+	for _, r := range removals {
+		ids = append(ids, r.image.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func Test_selectImagesToRemove(t *testing.T) {
+	now := uint64(100000)
+	mb := int64(1024 * 1024)
+
+	images := []docker.APIImages{
+		{ID: "sha256:1", RepoTags: []string{"quay.io/myorg/svc1:1.0.0"}, Created: 1000, Size: 100 * mb},
+		{ID: "sha256:2", RepoTags: []string{"quay.io/myorg/svc1:2.0.0"}, Created: 1000, Size: 100 * mb},
+		{ID: "sha256:3", RepoTags: []string{"quay.io/myorg/svc2:1.0.0"}, Created: 1000, Size: 100 * mb},
+		{ID: "sha256:4", RepoTags: []string{"busybox:latest"}, Created: 1000, Size: 10 * mb},
+		{ID: "sha256:5", RepoTags: []string{"quay.io/myorg/svc3:1.0.0"}, RepoDigests: []string{"quay.io/myorg/svc3@sha256:abcd"}, Created: 1000, Size: 100 * mb},
+	}
+	usages := []persistence.ServiceImageUsage{
+		{ImageId: "quay.io/myorg/svc1:1.0.0", TimeLastUsed: now - 3600},
+		{ImageId: "quay.io/myorg/svc1:2.0.0", TimeLastUsed: now - 60},
+		{ImageId: "quay.io/myorg/svc2:1.0.0", TimeLastUsed: now - 7200},
+		{ImageId: "quay.io/myorg/svc3@sha256:abcd", TimeLastUsed: now - 7200},
+	}
+	inUse := []string{"quay.io/myorg/svc2:1.0.0"}
+
+	// Nothing is removed without a grace period, a disk budget or a removal policy.
+	assert.Empty(t, selectImagesToRemove(images, usages, inUse, nil, config.ImageLifecycleConfig{}, now), "no image should be removed")
+
+	// The images pulled by the agent that are not in use are removed after the grace period.
+	removals := selectImagesToRemove(images, usages, inUse, nil, config.ImageLifecycleConfig{UnusedGracePeriodM: 30}, now)
+	assert.Equal(t, []string{"sha256:1", "sha256:5"}, removedImageIds(removals), "wrong images removed after the grace period")
+	for _, r := range removals {
+		if r.image.ID == "sha256:5" {
+			assert.Equal(t, []string{"quay.io/myorg/svc3@sha256:abcd"}, r.usageIds, "the usage record of the image should be removed")
+		}
+	}
+
+	// The least recently used images pulled by the agent are removed until they fit in the disk budget.
+	removals = selectImagesToRemove(images, usages, inUse, nil, config.ImageLifecycleConfig{DiskBudgetMB: 150}, now)
+	assert.Equal(t, []string{"sha256:1", "sha256:5"}, removedImageIds(removals), "wrong images removed for the disk budget")
+	removals = selectImagesToRemove(images, usages, inUse, nil, config.ImageLifecycleConfig{DiskBudgetMB: 250}, now)
+	assert.Equal(t, []string{"sha256:5"}, removedImageIds(removals), "wrong images removed for the disk budget")
+
+	// Removal policies can remove images that were not pulled by the agent, unless they are restricted to them.
+	removalPolicies := []exchangecommon.ImageRemovalPolicy{{ImageId: "busybox", DeleteAfterMinutes: 60}}
+	removals = selectImagesToRemove(images, usages, inUse, removalPolicies, config.ImageLifecycleConfig{}, now)
+	assert.Equal(t, []string{"sha256:4"}, removedImageIds(removals), "wrong images removed by the removal policy")
+	removalPolicies = []exchangecommon.ImageRemovalPolicy{{ImageId: "busybox", DeleteAfterMinutes: 60, AgentDownloadedOnly: true}}
+	assert.Empty(t, selectImagesToRemove(images, usages, inUse, removalPolicies, config.ImageLifecycleConfig{}, now), "the image not pulled by the agent should not be removed")
+	removalPolicies = []exchangecommon.ImageRemovalPolicy{{ImageId: "^quay.io/myorg/svc[12]", DeleteAfterMinutes: 30}}
+	removals = selectImagesToRemove(images, usages, inUse, removalPolicies, config.ImageLifecycleConfig{}, now)
+	assert.Equal(t, []string{"sha256:1"}, removedImageIds(removals), "wrong images removed by the removal policy")
+
+	// Images in use, by name or by id, are never removed.
+	removals = selectImagesToRemove(images, usages, []string{"quay.io/myorg/svc1:1.0.0", "quay.io/myorg/svc2:1.0.0", "5"}, nil, config.ImageLifecycleConfig{DiskBudgetMB: 1}, now)
+	assert.Equal(t, []string{"sha256:2"}, removedImageIds(removals), "images in use should not be removed")
+}
+
+func Test_readImageArchiveTags(t *testing.T) {
+	dir, err := os.MkdirTemp("", "imagelifecycle-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeArchive := func(name string, files map[string]string) string {
+		fileName := path.Join(dir, name)
+		file, err := os.Create(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		tw := tar.NewWriter(file)
+		for fn, content := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: fn, Mode: 0600, Size: int64(len(content))}); err != nil {
+				t.Fatal(err)
+			} else if _, err := tw.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+
+	archive := writeArchive("images.tar", map[string]string{
+		"manifest.json": `[{"Config":"1.json","RepoTags":["quay.io/myorg/svc1:1.0.0"],"Layers":["l1/layer.tar"]},{"Config":"2.json","RepoTags":["busybox:latest"],"Layers":["l2/layer.tar"]}]`,
+		"l1/layer.tar":  "layer",
+	})
+	tags, err := readImageArchiveTags(archive)
+	assert.Nil(t, err, "the archive should be read")
+	assert.Equal(t, []string{"quay.io/myorg/svc1:1.0.0", "busybox:latest"}, tags, "wrong image names in the archive")
+
+	images := []docker.APIImages{{ID: "sha256:4", RepoTags: []string{"docker.io/library/busybox:latest"}}}
+	assert.Equal(t, []string{"quay.io/myorg/svc1:1.0.0"}, missingImages(tags, images), "wrong missing images")
+
+	_, err = readImageArchiveTags(writeArchive("nomanifest.tar", map[string]string{"l1/layer.tar": "layer"}))
+	assert.NotNil(t, err, "the archive without a manifest should not be read")
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
@@ -18,6 +19,7 @@ type ImageFetchWorker struct {
 	worker.BaseWorker // embedded field
 	db                *bolt.DB
 	client            *docker.Client
+	pulls             *pullManager
 }
 
 func NewImageFetchWorker(name string, config *config.HorizonConfig, db *bolt.DB) *ImageFetchWorker {
@@ -42,8 +44,10 @@ func NewImageFetchWorker(name string, config *config.HorizonConfig, db *bolt.DB)
 		BaseWorker: worker.NewBaseWorker(name, config, nil),
 		db:         db,
 		client:     client,
+		pulls:      newPullManager(db, config.Edge.ImagePull),
 	}
 
+	worker.pulls.cleanupPulls()
 	worker.Start(worker, 0)
 	return worker
 }
@@ -192,7 +196,7 @@ func processDeployment(cfg *config.HorizonConfig, containerConfig events.Contain
 	return pemFiles, &deploymentDesc, nil
 }
 
-func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, imageDockerAuths []events.ImageDockerAuth) error {
+func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, newPull func(image string) *imagePull, deploymentDesc *containermessage.DeploymentDescription, imageDockerAuths []events.ImageDockerAuth) error {
 	if client == nil {
 		return fmt.Errorf("Docker client is nil. Please make sure DockerEndpoint is set in the configuration file.")
 	}
@@ -211,17 +215,21 @@ func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB,
 		glog.Errorf("Failed to fetch authentication facts from the attributes before processing packages and / or Docker pulls: %v. Continuing anyway", err)
 	}
 
-	return fetchImage(cfg, client, db, deploymentDesc, dockerAuthConfigurations)
+	if err := fetchImage(cfg, client, newPull, deploymentDesc, dockerAuthConfigurations); err != nil {
+		return err
+	}
+
+	return verifyImageSignatures(cfg, client, deploymentDesc)
 }
 
-func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
+func fetchImage(cfg *config.HorizonConfig, client *docker.Client, newPull func(image string) *imagePull, deploymentDesc *containermessage.DeploymentDescription, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
 
 	skipCheckFn := SkipCheckFn(client)
 	// using Docker pull (newer option, uses docker client to pull images from repos in image names in deployment description)
 	// Note: we don't want to make this a fallback option, it's a potential security vector
 	glog.V(3).Infof("Using Docker pull mechanism to retrieve and load Docker images into local registry")
 
-	fetchErr := pullImageFromRepos(cfg.Edge, dockerAuthConfigurations, client, &skipCheckFn, deploymentDesc)
+	fetchErr := pullImageFromRepos(cfg.Edge, newPull, dockerAuthConfigurations, client, &skipCheckFn, deploymentDesc)
 	return fetchErr
 }
 
@@ -258,7 +266,45 @@ func ProcessImageFetch(cfg *config.HorizonConfig, client *docker.Client, contain
 		return fmt.Errorf("Error Unmarshalling deployment string %v, error: %v", containerConfig.Deployment, err)
 	}
 
-	return fetchImage(cfg, client, nil, &deploymentDesc, dockerAuthNew)
+	// the pulls are not saved outside of the agent
+	pulls := newPullManager(nil, cfg.Edge.ImagePull)
+	newPull := func(image string) *imagePull {
+		return pulls.newImagePull(image, nil, nil)
+	}
+
+	return fetchImage(cfg, client, newPull, &deploymentDesc, dockerAuthNew)
+}
+
+// Returns the function that creates the image pulls for the launch context. The pulls are recorded with the agreements that
+// the images are pulled for, and their event logs have the agreement or the service as their source.
+func (b *ImageFetchWorker) newPullFn(lc events.LaunchContext) func(image string) *imagePull {
+	agreementIds := []string{}
+	var logEvent pullEventLogger
+
+	switch lc.(type) {
+	case *events.AgreementLaunchContext:
+		alc := lc.(*events.AgreementLaunchContext)
+		agreementIds = append(agreementIds, alc.AgreementId)
+		logEvent = func(severity string, messageMeta *persistence.MessageMeta, eventCode string) {
+			if ags, err := persistence.FindEstablishedAgreements(b.db, alc.AgreementProtocol, []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(alc.AgreementId)}); err != nil {
+				glog.Errorf("Unable to retrieve agreement %v from database, error %v", alc.AgreementId, err)
+			} else if len(ags) == 1 {
+				eventlog.LogAgreementEvent(b.db, severity, messageMeta, eventCode, ags[0])
+			}
+		}
+
+	case *events.ContainerLaunchContext:
+		clc := lc.(*events.ContainerLaunchContext)
+		agreementIds = append(agreementIds, clc.AgreementIds...)
+		serviceInfo := clc.GetServicePathElement()
+		logEvent = func(severity string, messageMeta *persistence.MessageMeta, eventCode string) {
+			eventlog.LogServiceEvent2(b.db, severity, messageMeta, eventCode, "", serviceInfo.URL, serviceInfo.Org, serviceInfo.Version, "", clc.AgreementIds)
+		}
+	}
+
+	return func(image string) *imagePull {
+		return b.pulls.newImagePull(image, agreementIds, logEvent)
+	}
 }
 
 func (b *ImageFetchWorker) CommandHandler(command worker.Command) bool {
@@ -294,18 +340,9 @@ func (b *ImageFetchWorker) CommandHandler(command worker.Command) bool {
 				return true
 			}
 
-			if fetchErr := processFetch(b.Config, b.client, b.db, deploymentDesc, lc.ContainerConfig().ImageDockerAuths); fetchErr != nil {
-				var id events.EventId
-				if strings.Contains(fetchErr.Error(), "Auth error") {
-					id = events.IMAGE_FETCH_AUTH_ERROR
-				} else {
-					id = events.IMAGE_FETCH_ERROR
-				}
-				glog.Errorf("Failed to fetch image files: %v", fetchErr)
-				b.Messages() <- events.NewImageFetchMessage(id, deploymentDesc, lc, fetchErr)
-			} else {
-				b.Messages() <- events.NewImageFetchMessage(events.IMAGE_FETCHED, deploymentDesc, lc, nil)
-			}
+			// the deployment is fetched outside of the command loop so that a slow pull does not block the worker, the pull
+			// manager limits the number of images that are pulled at the same time.
+			go b.fetchDeployment(lc, deploymentDesc)
 
 		}
 
@@ -316,6 +353,22 @@ func (b *ImageFetchWorker) CommandHandler(command worker.Command) bool {
 
 }
 
+// Pull the images of the deployment and report the result.
+func (b *ImageFetchWorker) fetchDeployment(lc events.LaunchContext, deploymentDesc *containermessage.DeploymentDescription) {
+	if fetchErr := processFetch(b.Config, b.client, b.db, b.newPullFn(lc), deploymentDesc, lc.ContainerConfig().ImageDockerAuths); fetchErr != nil {
+		var id events.EventId
+		if strings.Contains(fetchErr.Error(), "Auth error") {
+			id = events.IMAGE_FETCH_AUTH_ERROR
+		} else {
+			id = events.IMAGE_FETCH_ERROR
+		}
+		glog.Errorf("Failed to fetch image files: %v", fetchErr)
+		b.Messages() <- events.NewImageFetchMessage(id, deploymentDesc, lc, fetchErr)
+	} else {
+		b.Messages() <- events.NewImageFetchMessage(events.IMAGE_FETCHED, deploymentDesc, lc, nil)
+	}
+}
+
 type FetchCommand struct {
 	LaunchContext interface{}
 }
@@ -0,0 +1,96 @@
+package imagefetch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/rsapss-tool/verify"
+)
+
+const (
+	COSIGN_SIGNATURE_TYPE = "cosign container image signature"
+	ATOMIC_SIGNATURE_TYPE = "atomic container signature"
+)
+
+// The sigstore simple signing document that is signed in an image signature. Only the critical section is used, the optional
+// section is ignored.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// Verify the signature of a pulled image. The signature must be made by one of the keys in the key files, and the signed
+// document must name the repository of the image and one of the manifest digests that the registry returned when the image
+// was pulled. When the image is named by digest, the signed digest must also be that digest.
+func verifyImageSignature(image string, signature *containermessage.ImageSignature, repoDigests []string, keyFiles []string) error {
+	if signature == nil {
+		return fmt.Errorf("image %v is not signed", image)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(signature.Payload)
+	if err != nil {
+		return fmt.Errorf("unable to decode the signature payload of image %v, error: %v", image, err)
+	}
+
+	if verified, fn_success, failed_map := verify.InputVerifiedByAnyKey(keyFiles, signature.Signature, payload); !verified {
+		glog.Errorf("Unable to verify the signature of image %v: %v", image, failed_map)
+		return fmt.Errorf("there is no public key available to verify the signature of image %v", image)
+	} else {
+		glog.V(3).Infof("Image %v signature verification successful with RSA pubkey in file: %v", image, fn_success)
+	}
+
+	var doc simpleSigning
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return fmt.Errorf("unable to demarshal the signature payload of image %v, error: %v", image, err)
+	} else if doc.Critical.Type != COSIGN_SIGNATURE_TYPE && doc.Critical.Type != ATOMIC_SIGNATURE_TYPE {
+		return fmt.Errorf("the signature payload of image %v has unsupported type %v", image, doc.Critical.Type)
+	}
+
+	repository := cutil.NormalizeImageRepository(image)
+	signedDigest := doc.Critical.Image.DockerManifestDigest
+	if signedRepository := cutil.NormalizeImageRepository(doc.Critical.Identity.DockerReference); signedRepository != repository {
+		return fmt.Errorf("the signature of image %v is for repository %v", image, signedRepository)
+	} else if _, _, _, digest := cutil.ParseDockerImagePath(image); digest != "" && digest != signedDigest {
+		return fmt.Errorf("the signature of image %v is for digest %v", image, signedDigest)
+	} else if !cutil.ImageDigestMatches(repository+"@"+signedDigest, repoDigests) {
+		return fmt.Errorf("the digest %v in the signature of image %v does not match the pulled image digests %v", signedDigest, image, repoDigests)
+	}
+	return nil
+}
+
+// Verify the signatures of the images of all the services in the deployment after they are pulled, when image signature
+// verification is turned on in the config. The signatures are verified with the same public keys as the deployment signature.
+// The images pulled from a registry mirror are verified with their original names.
+func verifyImageSignatures(cfg *config.HorizonConfig, client *docker.Client, deploymentDesc *containermessage.DeploymentDescription) error {
+	if !cfg.Edge.VerifyImageSignatures {
+		return nil
+	}
+
+	keyFiles, err := cfg.Collaborators.KeyFileNamesFetcher.GetKeyFileNames(cfg.Edge.PublicKeyPath, cfg.UserPublicKeyPath())
+	if err != nil {
+		return fmt.Errorf("Unable to read pemFiles from KeyFileNamesFetcher. Error: %v", err)
+	}
+
+	for name, service := range deploymentDesc.Services {
+		if image, _, err := container.InspectLocalImage(client, service.Image, cfg.Edge.RegistryMirrors); err != nil {
+			return fmt.Errorf("Image signature verification failed for service %v, unable to inspect image %v, error: %v", name, service.Image, err)
+		} else if err := verifyImageSignature(service.Image, service.ImageSignature, cutil.OriginalRepoDigests(image.RepoDigests, cfg.Edge.RegistryMirrors), keyFiles); err != nil {
+			return fmt.Errorf("Image signature verification failed for service %v: %v", name, err)
+		}
+		glog.V(3).Infof("Verified the signature of image %v for service %v", service.Image, name)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+//go:build unit
+// +build unit
+
+package imagefetch
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
+	"testing"
+)
+
+// Create a signing key pair in the directory, returns the private and public key file names.
+func createKeyPair(t *testing.T, dir string, name string) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privFile := path.Join(dir, name+"-private.key")
+	pubFile := path.Join(dir, name+"-public.pem")
+	if err := os.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return privFile, pubFile
+}
+
+func signImage(t *testing.T, privFile string, reference string, digest string) *containermessage.ImageSignature {
+	payload := fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%v"},"image":{"docker-manifest-digest":"%v"},"type":"cosign container image signature"},"optional":null}`, reference, digest)
+	sig, err := sign.Input(privFile, []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &containermessage.ImageSignature{Payload: base64.StdEncoding.EncodeToString([]byte(payload)), Signature: sig}
+}
+
+func Test_verifyImageSignature(t *testing.T) {
+	dir, err := os.MkdirTemp("", "imageverify-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	privFile, pubFile := createKeyPair(t, dir, "publisher")
+	otherPrivFile, _ := createKeyPair(t, dir, "other")
+	keyFiles := []string{pubFile}
+
+	digest := "sha256:2a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b"
+	otherDigest := "sha256:9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e"
+	repoDigests := []string{"quay.io/myorg/myservice@" + digest}
+
+	// The signature matches the pulled image, with or without a tag or digest in the image name.
+	sig := signImage(t, privFile, "quay.io/myorg/myservice", digest)
+	assert.Nil(t, verifyImageSignature("quay.io/myorg/myservice:1.0.0", sig, repoDigests, keyFiles), "the image signature should be verified")
+	assert.Nil(t, verifyImageSignature("quay.io/myorg/myservice@"+digest, sig, repoDigests, keyFiles), "the image signature should be verified")
+
+	// Docker hub images can be named in different ways.
+	sig = signImage(t, privFile, "docker.io/library/busybox", digest)
+	assert.Nil(t, verifyImageSignature("busybox:latest", sig, []string{"busybox@" + digest}, keyFiles), "the image signature should be verified")
+
+	// The image is not signed, the signature is made with another key, for another repository or for another digest.
+	assert.NotNil(t, verifyImageSignature("quay.io/myorg/myservice:1.0.0", nil, repoDigests, keyFiles), "the unsigned image should not be verified")
+	assert.NotNil(t, verifyImageSignature("quay.io/myorg/myservice:1.0.0", signImage(t, otherPrivFile, "quay.io/myorg/myservice", digest), repoDigests, keyFiles), "the image signed with an unknown key should not be verified")
+	assert.NotNil(t, verifyImageSignature("quay.io/myorg/myservice:1.0.0", signImage(t, privFile, "quay.io/myorg/otherservice", digest), repoDigests, keyFiles), "the signature for another repository should not be verified")
+	assert.NotNil(t, verifyImageSignature("quay.io/myorg/myservice:1.0.0", signImage(t, privFile, "quay.io/myorg/myservice", otherDigest), repoDigests, keyFiles), "the signature for another digest should not be verified")
+	assert.NotNil(t, verifyImageSignature("quay.io/myorg/myservice@"+otherDigest, signImage(t, privFile, "quay.io/myorg/myservice", digest), repoDigests, keyFiles), "the signature for another digest should not be verified")
+
+	// The payload is changed after it is signed.
+	sig = signImage(t, privFile, "quay.io/myorg/myservice", otherDigest)
+	sig.Payload = signImage(t, otherPrivFile, "quay.io/myorg/myservice", digest).Payload
+	assert.NotNil(t, verifyImageSignature("quay.io/myorg/myservice:1.0.0", sig, repoDigests, keyFiles), "the changed payload should not be verified")
+}
@@ -3,9 +3,11 @@ package imagefetch
 import (
 	docker "github.com/fsouza/go-dockerclient"
 
+	"context"
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/cutil"
 	"os"
@@ -67,143 +69,236 @@ func authDockerFile(config config.Config, authConfigs map[string][]docker.AuthCo
 	return nil
 }
 
-func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.AuthConfiguration, client *docker.Client, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription) error {
+// Pull the images of the services in the deployment. The pull of each image is tracked by the image pull that newPull returns.
+func pullImageFromRepos(config config.Config, newPull func(image string) *imagePull, authConfigs map[string][]docker.AuthConfiguration, client *docker.Client, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription) error {
 
 	// append docker auth from docker file
 	authDockerFile(config, authConfigs)
 
+	// The images from the registries that the node's admission policy does not admit are not pulled.
+	admissionPolicy, err := container.LoadAdmissionPolicy(config.AdmissionPolicyFile)
+	if err != nil {
+		return err
+	}
+
 	// TODO: can we fetch in parallel with the docker client? If so, lift pattern from https://github.com/open-horizon/horizon-pkg-fetch/blob/master/fetch.go#L350
 	for name, service := range deploymentDesc.Services {
 
 		glog.V(3).Infof("Pulling image %v for service %v", service.Image, name)
 
-		var opts docker.PullImageOptions
-
-		domain, path, tag, digest := cutil.ParseDockerImagePath(service.Image)
-		if path == "" {
+		if _, path, _, digest := cutil.ParseDockerImagePath(service.Image); path == "" {
 			glog.Errorf("Invalid image name format specified: %v", service.Image)
 			return fmt.Errorf("Invalid image name format specified: %v", service.Image)
+		} else if digest == "" && config.RequireImageDigests {
+			glog.Errorf("Image %v for service %v is not pinned by digest", service.Image, name)
+			return fmt.Errorf("Image %v for service %v is not pinned by digest, only images pinned by digest are allowed on this node", service.Image, name)
+		} else if err := admissionPolicy.CheckImage(name, service.Image); err != nil {
+			glog.Errorf(err.Error())
+			return err
 		}
-		// the image name format is [[repo][:port]/][somedir/]image[:tag][@digest].
-		// tag and digest do not contain '/'
-		if digest != "" {
-			// this is the case where image repo digest is used, just put whole name there
-			opts = docker.PullImageOptions{
-				Repository: service.Image,
-			}
-		} else {
-			// this is case where image name:tag is used. The image repo may contain :, image tag itself cannot contain : or /.
-			// These are valid formats:
-			//  repo/a/b:tag
-			//  repo:port/a/b:tag
-			//  repo:port/a/b
-
-			var repo string
-			if domain == "" {
-				repo = path
-			} else {
-				repo = fmt.Sprintf("%v/%v", domain, path)
-			}
 
-			if tag == "" {
-				tag = "latest"
-			}
+		pull := newPull(service.Image)
+		pull.start()
+		err := pullImageFromSources(config, authConfigs, client, name, service.Image, pull)
+		pull.finish(err)
 
-			// TODO: check the on-disk image to make sure it still verifies
-			// N.B. It's possible to specify an outputstream here which means we could fetch a docker image and hash it, check the sig like we used to
-			opts = docker.PullImageOptions{
-				Repository: repo,
-				Tag:        tag,
-			}
+		if err != nil {
+			glog.Errorf("Docker image pull(s) failed for docker image %v. Error: %v.", service.Image, err)
+			return err
+		} else if err := checkPulledImageDigest(client, service.Image, config.RegistryMirrors); err != nil {
+			glog.Errorf(err.Error())
+			return err
+		} else {
+			glog.V(3).Infof("Succeeded fetching image %v for service %v", service.Image, name)
 		}
+	}
 
-		// default the doman to docker io.
-		if domain == "" {
-			domain = "docker.io"
-		}
+	return nil
+}
 
-		// get all the auths for this domain or repo.
-		auth_array := []docker.AuthConfiguration{}
-		for k, _ := range authConfigs {
-			// for "docker.io" repo, the repo string in ~/.docker/config.json is something like:
-			// "https://index.docker.io/v1/"
-			if k == domain || (domain == "docker.io" && strings.Contains(k, domain)) {
-				auth_array = append(auth_array, authConfigs[k]...)
-			}
-		}
+// Pull the image from its registry mirrors first, then from its own registry unless the mirrors are the only source. When
+// the image cannot be pulled from any of them, they are all tried again after a delay, until the retries are exhausted, see
+// nextPullRetryDelay. An auth error is not retried.
+func pullImageFromSources(config config.Config, authConfigs map[string][]docker.AuthConfiguration, client *docker.Client, serviceName string, image string, pull *imagePull) error {
 
-		// try auths one at a time
+	deadline := time.Duration(config.ImagePull.RetryDeadlineM) * time.Minute
+	mirrorNames, fallback := cutil.MirroredImageNames(image, config.RegistryMirrors)
+
+	for pullAttempts := 1; ; pullAttempts++ {
 		var err error
-		for i, auth := range auth_array {
-			err = pullSingleImageFromRepo(client, opts, auth)
-			if err == nil {
+		pulled := false
+		for _, mirrorName := range mirrorNames {
+			if err = pullImage(client, authConfigs, serviceName, mirrorName, pull); err != nil {
+				glog.Warningf("Unable to pull image %v for service %v from mirror %v. Error: %v", image, serviceName, mirrorName, err)
+			} else if err = tagMirroredImage(client, mirrorName, image); err != nil {
+				glog.Errorf(err.Error())
+				return err
+			} else {
+				glog.V(3).Infof("Pulled image %v for service %v from mirror %v", image, serviceName, mirrorName)
+				pulled = true
 				break
-			} else if i < len(auth_array)-1 {
-				glog.V(5).Infof("Docker image pull(s) failed for service %v docker image %v with auth name %v. Error: %v. Try next auth.", name, service.Image, auth.Username, err)
 			}
 		}
 
-		// if all auths failed or no auth specified for this domain, try without auth
-		if err != nil || len(auth_array) == 0 {
-			glog.V(5).Infof("Pulling image %v without auth.", service.Image)
-			err = pullSingleImageFromRepo(client, opts, docker.AuthConfiguration{})
+		if !pulled && fallback {
+			err = pullImage(client, authConfigs, serviceName, image, pull)
+		} else if !pulled {
+			err = fmt.Errorf("Unable to pull image %v from any of its registry mirrors %v. Error: %v", image, mirrorNames, err)
 		}
 
-		if err != nil {
-			glog.Errorf("Docker image pull(s) failed for docker image %v. Error: %v.", service.Image, err)
+		// no need to try more times if it is auth error
+		if err == nil || strings.Contains(err.Error(), "Auth error") {
 			return err
+		}
+
+		if delay, retry := nextPullRetryDelay(pullAttempts, time.Since(pull.startTime()), deadline); retry {
+			glog.V(5).Infof("Waiting %v before retrying to pull image %v. Error: %v", delay, image, err)
+			pull.retrying(delay, err)
+			time.Sleep(delay)
 		} else {
-			glog.V(3).Infof("Succeeded fetching image %v for service %v", service.Image, name)
+			glog.V(5).Infof("Max pull attempts reached (%d) for fetching Docker image %v.", pullAttempts, image)
+			return err
 		}
 	}
-
-	return nil
 }
 
-// This function try maxPullAttempts times to pull the image from the repo. It exits out imediately if there is auth error.
-func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
-	glog.V(5).Infof("Pulling image %v with auth name %v.", opts, auth.Username)
+// Pull the image with the auths for its registry, or without auth when there are none or none of them works.
+func pullImage(client *docker.Client, authConfigs map[string][]docker.AuthConfiguration, serviceName string, image string, pull *imagePull) error {
 
-	var pullAttempts int
+	var opts docker.PullImageOptions
 
-	for pullAttempts <= maxPullAttempts {
-		if err := client.PullImage(opts, auth); err == nil {
-			return nil
+	domain, path, tag, digest := cutil.ParseDockerImagePath(image)
+	// the image name format is [[repo][:port]/][somedir/]image[:tag][@digest].
+	// tag and digest do not contain '/'
+	if digest != "" {
+		// this is the case where image repo digest is used, just put whole name there
+		opts = docker.PullImageOptions{
+			Repository: image,
+		}
+	} else {
+		// this is case where image name:tag is used. The image repo may contain :, image tag itself cannot contain : or /.
+		// These are valid formats:
+		//  repo/a/b:tag
+		//  repo:port/a/b:tag
+		//  repo:port/a/b
+
+		var repo string
+		if domain == "" {
+			repo = path
 		} else {
-			pullAttempts++
-
-			// no need to try more times if it is auth error
-			switch err.(type) {
-			case *docker.Error:
-				dErr := err.(*docker.Error)
-				if strings.Contains(dErr.Message, "cred") {
-					msg := fmt.Sprintf("Aborting fetch of Docker image %v.", opts.Repository)
-					return fmt.Errorf("Auth error. Msg: %v, InternalError: %v.", msg, dErr)
-				}
-			}
+			repo = fmt.Sprintf("%v/%v", domain, path)
+		}
 
-			if pullAttempts != maxPullAttempts {
-				glog.V(5).Infof("Waiting %d seconds before retry. Error: %v", pullAttemptDelayS, err)
-				time.Sleep(pullAttemptDelayS * time.Second)
-			} else {
-				msg := fmt.Sprintf("Max pull attempts reached (%d) for fetching Docker image %v.", pullAttempts, opts.Repository)
+		if tag == "" {
+			tag = "latest"
+		}
+
+		// TODO: check the on-disk image to make sure it still verifies
+		// N.B. It's possible to specify an outputstream here which means we could fetch a docker image and hash it, check the sig like we used to
+		opts = docker.PullImageOptions{
+			Repository: repo,
+			Tag:        tag,
+		}
+	}
 
-				switch err.(type) {
-				case *docker.Error:
-					glog.V(5).Infof(msg+"Docker client error occurred %v", err)
-					return err
+	// default the doman to docker io.
+	if domain == "" {
+		domain = "docker.io"
+	}
 
-				default:
-					glog.V(5).Infof(msg+"(Unknown error type, %T) Internal error of unidentifiable type: %v. Original: %v", err, msg, err)
-					return err
+	// get all the auths for this domain or repo.
+	auth_array := []docker.AuthConfiguration{}
+	for k, _ := range authConfigs {
+		// for "docker.io" repo, the repo string in ~/.docker/config.json is something like:
+		// "https://index.docker.io/v1/"
+		if k == domain || (domain == "docker.io" && strings.Contains(k, domain)) {
+			auth_array = append(auth_array, authConfigs[k]...)
+		}
+	}
 
-				}
-			}
+	// try auths one at a time
+	var err error
+	for i, auth := range auth_array {
+		err = pullSingleImageFromRepo(client, opts, auth, pull)
+		if err == nil {
+			break
+		} else if i < len(auth_array)-1 {
+			glog.V(5).Infof("Docker image pull(s) failed for service %v docker image %v with auth name %v. Error: %v. Try next auth.", serviceName, image, auth.Username, err)
 		}
 	}
+
+	// if all auths failed or no auth specified for this domain, try without auth
+	if err != nil || len(auth_array) == 0 {
+		glog.V(5).Infof("Pulling image %v without auth.", image)
+		err = pullSingleImageFromRepo(client, opts, docker.AuthConfiguration{}, pull)
+	}
+
+	return err
+}
+
+// Give an image pulled from a registry mirror its original name, so that its containers are created with the name in the
+// deployment. An image pinned by digest cannot be given another digest name, it is found by its name in the mirror.
+func tagMirroredImage(client *docker.Client, mirrorName string, image string) error {
+	domain, path, tag, digest := cutil.ParseDockerImagePath(image)
+	if digest != "" {
+		return nil
+	}
+
+	repo := path
+	if domain != "" {
+		repo = fmt.Sprintf("%v/%v", domain, path)
+	}
+	if tag == "" {
+		tag = "latest"
+	}
+
+	if err := client.TagImage(mirrorName, docker.TagImageOptions{Repo: repo, Tag: tag, Force: true}); err != nil {
+		return fmt.Errorf("Unable to tag image %v pulled from a registry mirror as %v:%v, error: %v", mirrorName, repo, tag, err)
+	}
+	return nil
+}
+
+// When an image is pinned by digest, check that the pulled image has that digest, so that a registry cannot substitute
+// another image for the one that was signed in the deployment. The digests of an image pulled from a registry mirror are
+// checked with the image's original name.
+func checkPulledImageDigest(client *docker.Client, imageName string, mirrors []config.RegistryMirrorConfig) error {
+	if _, _, _, digest := cutil.ParseDockerImagePath(imageName); digest == "" {
+		return nil
+	} else if image, _, err := container.InspectLocalImage(client, imageName, mirrors); err != nil {
+		return fmt.Errorf("Unable to inspect pulled image %v, error: %v", imageName, err)
+	} else if !cutil.ImageDigestMatches(imageName, cutil.OriginalRepoDigests(image.RepoDigests, mirrors)) {
+		return fmt.Errorf("Digest mismatch for pulled image %v, the image has digests %v", imageName, image.RepoDigests)
+	}
 	return nil
 }
 
+// This function tries once to pull the image from the repo. The progress stream of the pull is written to the image pull.
+func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions, auth docker.AuthConfiguration, pull *imagePull) error {
+	glog.V(5).Infof("Pulling image %v with auth name %v.", opts, auth.Username)
+
+	err := pull.attempt(func(ctx context.Context) error {
+		opts.Context = ctx
+		opts.OutputStream = pull
+		opts.RawJSONStream = true
+		return client.PullImage(opts, auth)
+	})
+
+	switch err.(type) {
+	case nil:
+		return nil
+	case *docker.Error:
+		dErr := err.(*docker.Error)
+		if strings.Contains(dErr.Message, "cred") {
+			msg := fmt.Sprintf("Aborting fetch of Docker image %v.", opts.Repository)
+			return fmt.Errorf("Auth error. Msg: %v, InternalError: %v.", msg, dErr)
+		}
+		glog.V(5).Infof("Docker client error occurred fetching Docker image %v: %v", opts.Repository, err)
+	default:
+		glog.V(5).Infof("(Unknown error type, %T) Internal error of unidentifiable type fetching Docker image %v: %v", err, opts.Repository, err)
+	}
+	return err
+}
+
 func listImages(client *docker.Client) ([]docker.APIImages, error) {
 
 	if images, err := client.ListImages(docker.ListImagesOptions{
@@ -6,8 +6,12 @@ package imagefetch
 import (
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
 	"reflect"
 	"testing"
 )
@@ -62,3 +66,31 @@ func Test_authDockerFile(t *testing.T) {
 	assert.Equal(t, 1, len(dockerAuthConfigurations["myrepo3.com"]), "The docker auth array should have 1 items.")
 
 }
+
+func Test_pullImageFromRepos_admission(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "imagepull-")
+	if !assert.Nil(t, err, "unable to create test dir") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	policyFile := path.Join(dir, "admission_policy.json")
+	if !assert.Nil(t, os.WriteFile(policyFile, []byte(`{"registries": {"allow": ["*"], "deny": ["docker.io"]}}`), 0600), "unable to write the admission policy") {
+		return
+	}
+
+	pulls := 0
+	newPull := func(image string) *imagePull {
+		pulls++
+		return nil
+	}
+
+	// the image from the denied registry is refused before it is pulled, so no docker client is needed.
+	cfg := config.Config{AdmissionPolicyFile: policyFile, DockerCredFilePath: "./test/docker_auths.json"}
+	deployment := &containermessage.DeploymentDescription{Services: map[string]*containermessage.Service{"svc1": {Image: "myorg/myservice:1.0.0"}}}
+	err = pullImageFromRepos(cfg, newPull, make(map[string][]docker.AuthConfiguration), nil, nil, deployment)
+	_, ok := err.(*container.AdmissionError)
+	assert.True(t, ok, "the image should not be admitted, error %v", err)
+	assert.Equal(t, 0, pulls, "the image should not be pulled")
+}
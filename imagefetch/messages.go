@@ -0,0 +1,29 @@
+package imagefetch
+
+import (
+	"github.com/open-horizon/anax/i18n"
+)
+
+// messages for event logs
+const (
+	EL_IMG_PULL_STARTED   = "Start pulling image %v."
+	EL_IMG_PULL_STALLED   = "The pull of image %v made no progress for %v seconds, %v of %v bytes downloaded. Restarting the pull."
+	EL_IMG_PULL_RETRY     = "The pull of image %v failed in attempt %v, retrying in %v seconds. Error: %v"
+	EL_IMG_PULL_COMPLETED = "Image %v pulled in %v seconds, %v bytes downloaded in %v attempt(s)."
+	EL_IMG_PULL_FAILED    = "Failed to pull image %v after %v attempt(s). Error: %v"
+)
+
+// This is does nothing useful at run time.
+// This code is only used in compileing time to make the eventlog messages gets into the catalog so that
+// they can be translated.
+// The event log messages will be saved in English. But the CLI can request them in different languages.
+func MarkI18nMessages() {
+	// get message printer. anax default language is English
+	msgPrinter := i18n.GetMessagePrinter()
+
+	msgPrinter.Sprintf(EL_IMG_PULL_STARTED)
+	msgPrinter.Sprintf(EL_IMG_PULL_STALLED)
+	msgPrinter.Sprintf(EL_IMG_PULL_RETRY)
+	msgPrinter.Sprintf(EL_IMG_PULL_COMPLETED)
+	msgPrinter.Sprintf(EL_IMG_PULL_FAILED)
+}
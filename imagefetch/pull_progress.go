@@ -0,0 +1,492 @@
+package imagefetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// the longest delay between two attempts of a pull that is retried until the retry deadline
+	maxPullRetryDelayS = 300
+
+	// the minimum time between two saves of the progress of a pull, and between two measures of its download rate
+	pullProgressIntervalS = 2
+
+	// the time between two checks for a free pull slot, and between two checks for a stalled pull
+	pullCheckIntervalS = 1
+
+	// the finished pull records are kept for a day
+	pullRecordRetentionS = 24 * 3600
+)
+
+// Saves an image pull event log with the agreement or the service that the image is pulled for as its source.
+type pullEventLogger func(severity string, messageMeta *persistence.MessageMeta, eventCode string)
+
+// A message in the JSON progress stream of a docker image pull.
+type pullMessage struct {
+	Id             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+type layerProgress struct {
+	status     string
+	bytesDone  int64
+	bytesTotal int64
+	done       bool
+}
+
+// The image pulls of the image fetch worker. The pull manager limits the number of images that are pulled at the same time,
+// and it only starts another pull while the pulls in progress download slower than the StartBelowKBps rate.
+//
+// The MaxDownloadKBps rate limits the downloads of all the pulls together. The docker daemon sends the progress of a pull
+// to the agent as it downloads the layers, and it holds back the downloads when the agent does not read the progress. The
+// pull manager schedules the bytes that the pulls report one after the other at the limit rate, and a pull does not read
+// more of its progress until its bytes are due.
+type pullManager struct {
+	db        *bolt.DB
+	config    config.ImagePullConfig
+	lock      sync.Mutex
+	active    map[*imagePull]bool
+	limitTime time.Time // the time at which the bytes reported by the pulls are all due at the MaxDownloadKBps rate
+}
+
+// The db is nil when the images are pulled outside of the agent, then the pulls are not saved.
+func newPullManager(db *bolt.DB, pullConfig config.ImagePullConfig) *pullManager {
+	if pullConfig.MaxConcurrentPulls <= 0 {
+		pullConfig.MaxConcurrentPulls = config.ImagePullMaxConcurrentPulls_DEFAULT
+	}
+	return &pullManager{
+		db:     db,
+		config: pullConfig,
+		active: make(map[*imagePull]bool),
+	}
+}
+
+// Mark the pulls that were in progress when the agent stopped as failed, and remove the pull records that finished more than
+// a day ago.
+func (m *pullManager) cleanupPulls() {
+	if m.db == nil {
+		return
+	}
+
+	pulls, err := persistence.FindImagePulls(m.db, []persistence.IPFilter{})
+	if err != nil {
+		glog.Errorf("Unable to read the image pull records, error: %v", err)
+		return
+	}
+
+	now := uint64(time.Now().Unix())
+	for i, pull := range pulls {
+		if pull.InProgress() {
+			pulls[i].Status = persistence.IMAGE_PULL_FAILED
+			pulls[i].EndTime = now
+			pulls[i].BytesPerSecond = 0
+			pulls[i].Error = "the pull was interrupted by an agent restart"
+			if err := persistence.SaveImagePull(m.db, &pulls[i]); err != nil {
+				glog.Errorf("Unable to save the image pull record of %v, error: %v", pull.Image, err)
+			}
+		} else if pull.EndTime+pullRecordRetentionS < now {
+			if err := persistence.DeleteImagePull(m.db, &pulls[i]); err != nil {
+				glog.Errorf(err.Error())
+			}
+		}
+	}
+}
+
+func (m *pullManager) newImagePull(image string, agreementIds []string, logEvent pullEventLogger) *imagePull {
+	if agreementIds == nil {
+		agreementIds = []string{}
+	}
+	return &imagePull{
+		manager:  m,
+		logEvent: logEvent,
+		layers:   make(map[string]*layerProgress),
+		record: persistence.ImagePull{
+			Image:        image,
+			Status:       persistence.IMAGE_PULL_WAITING,
+			AgreementIds: agreementIds,
+		},
+	}
+}
+
+// Returns true when another pull can start. The caller holds the manager lock.
+func (m *pullManager) canStart() bool {
+	if len(m.active) == 0 {
+		return true
+	} else if len(m.active) >= m.config.MaxConcurrentPulls {
+		return false
+	} else if m.config.StartBelowKBps == 0 {
+		return true
+	}
+
+	rate := int64(0)
+	for pull := range m.active {
+		rate += pull.bytesPerSecond()
+	}
+	return rate < int64(m.config.StartBelowKBps)*1024
+}
+
+// Wait for a pull slot.
+func (m *pullManager) acquire(pull *imagePull) {
+	waiting := false
+	for {
+		m.lock.Lock()
+		if m.canStart() {
+			m.active[pull] = true
+			m.lock.Unlock()
+			return
+		}
+		m.lock.Unlock()
+
+		if !waiting {
+			glog.V(3).Infof("Image %v is waiting for other image pulls to finish", pull.image())
+			pull.save(true)
+			waiting = true
+		}
+		time.Sleep(pullCheckIntervalS * time.Second)
+	}
+}
+
+func (m *pullManager) release(pull *imagePull) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.active, pull)
+}
+
+// Returns how long a pull waits before it reads more of its progress, after it reported the given number of downloaded bytes.
+func (m *pullManager) throttle(bytes int64, now time.Time) time.Duration {
+	if m.config.MaxDownloadKBps == 0 || bytes <= 0 {
+		return 0
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// The time the pulls did not download does not add up to a burst of bytes later.
+	if m.limitTime.Before(now) {
+		m.limitTime = now
+	}
+	m.limitTime = m.limitTime.Add(time.Duration(bytes) * time.Second / time.Duration(m.config.MaxDownloadKBps*1024))
+	return m.limitTime.Sub(now)
+}
+
+// The pull of an image, from its registry mirrors and its own registry. The progress stream of the docker pulls is written
+// to the image pull, which keeps the progress of each layer.
+type imagePull struct {
+	manager   *pullManager
+	logEvent  pullEventLogger
+	lock      sync.Mutex
+	record    persistence.ImagePull
+	layers    map[string]*layerProgress
+	partial   []byte // the start of a stream message that was not completely written yet
+	streamErr string // the error reported in the progress stream of the current attempt
+	stalled   bool   // the current attempt was cancelled because it stalled
+	lastSave  time.Time
+	rateTime  time.Time
+	rateBytes int64
+	throttled time.Time // the pull does not read its progress until this time, it is not stalled before then
+}
+
+func (p *imagePull) image() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.record.Image
+}
+
+func (p *imagePull) bytesPerSecond() int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.record.BytesPerSecond
+}
+
+// Returns the time the first attempt of the pull started.
+func (p *imagePull) startTime() time.Time {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return time.Unix(int64(p.record.StartTime), 0)
+}
+
+// Wait for a pull slot and start the pull.
+func (p *imagePull) start() {
+	p.manager.acquire(p)
+
+	p.lock.Lock()
+	p.record.StartTime = uint64(time.Now().Unix())
+	p.record.Status = persistence.IMAGE_PULL_PULLING
+	image := p.record.Image
+	p.lock.Unlock()
+
+	p.save(true)
+	p.log(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_IMG_PULL_STARTED, image), persistence.EC_IMAGE_PULL_STARTED)
+}
+
+// Finish the pull with the result of its last attempt and release its pull slot.
+func (p *imagePull) finish(err error) {
+	p.manager.release(p)
+
+	p.lock.Lock()
+	now := time.Now()
+	p.record.EndTime = uint64(now.Unix())
+	p.record.BytesPerSecond = 0
+	p.record.NextRetryTime = 0
+	record := p.record
+	if err != nil {
+		p.record.Status = persistence.IMAGE_PULL_FAILED
+		p.record.Error = err.Error()
+	} else {
+		p.record.Status = persistence.IMAGE_PULL_COMPLETED
+		p.record.Error = ""
+	}
+	p.lock.Unlock()
+
+	p.save(true)
+	if err != nil {
+		p.log(persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_IMG_PULL_FAILED, record.Image, record.Attempts, err.Error()), persistence.EC_ERROR_IMAGE_PULL)
+	} else {
+		p.log(persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_IMG_PULL_COMPLETED, record.Image, record.EndTime-record.StartTime, record.BytesDone, record.Attempts), persistence.EC_IMAGE_PULL_COMPLETED)
+	}
+}
+
+// Run one attempt of the pull. The attempt is cancelled when it makes no progress for the stall timeout. An error reported
+// in the progress stream is returned as the error of the attempt.
+func (p *imagePull) attempt(pullFn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p.lock.Lock()
+	p.record.Attempts++
+	p.record.Status = persistence.IMAGE_PULL_PULLING
+	p.record.LastProgressTime = uint64(time.Now().Unix())
+	p.record.NextRetryTime = 0
+	p.streamErr = ""
+	p.stalled = false
+	p.partial = nil
+	p.lock.Unlock()
+	p.save(true)
+
+	if p.manager.config.StallTimeoutS != 0 {
+		go p.watchStall(ctx, cancel)
+	}
+
+	err := pullFn(ctx)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stalled {
+		return fmt.Errorf("the pull of image %v made no progress for %v seconds", p.record.Image, p.manager.config.StallTimeoutS)
+	} else if err == nil && p.streamErr != "" {
+		return fmt.Errorf("%v", p.streamErr)
+	}
+	return err
+}
+
+// Cancel the attempt when it makes no progress for the stall timeout.
+func (p *imagePull) watchStall(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(pullCheckIntervalS * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.lock.Lock()
+			if uint64(now.Unix()) < p.record.LastProgressTime+p.manager.config.StallTimeoutS || now.Before(p.throttled) {
+				p.lock.Unlock()
+				continue
+			}
+			p.stalled = true
+			p.record.Status = persistence.IMAGE_PULL_STALLED
+			p.record.BytesPerSecond = 0
+			record := p.record
+			p.lock.Unlock()
+
+			glog.Warningf("The pull of image %v made no progress for %v seconds, restarting it", record.Image, p.manager.config.StallTimeoutS)
+			p.save(true)
+			p.log(persistence.SEVERITY_WARN, persistence.NewMessageMeta(EL_IMG_PULL_STALLED, record.Image, p.manager.config.StallTimeoutS, record.BytesDone, record.BytesTotal), persistence.EC_IMAGE_PULL_STALLED)
+			cancel()
+			return
+		}
+	}
+}
+
+// Record that the last attempt failed and the pull is retried after the delay.
+func (p *imagePull) retrying(delay time.Duration, err error) {
+	p.lock.Lock()
+	p.record.Status = persistence.IMAGE_PULL_RETRYING
+	p.record.NextRetryTime = uint64(time.Now().Add(delay).Unix())
+	p.record.BytesPerSecond = 0
+	p.record.Error = err.Error()
+	record := p.record
+	p.lock.Unlock()
+
+	p.save(true)
+	p.log(persistence.SEVERITY_WARN, persistence.NewMessageMeta(EL_IMG_PULL_RETRY, record.Image, record.Attempts, int(delay.Seconds()), err.Error()), persistence.EC_IMAGE_PULL_RETRY)
+}
+
+// The docker progress stream of the current attempt is written here. When the download rate is limited, the write returns
+// once the downloaded bytes that it reports are due, which holds back the docker daemon.
+func (p *imagePull) Write(data []byte) (int, error) {
+	p.lock.Lock()
+	bytesDone := p.record.BytesDone
+	p.partial = append(p.partial, data...)
+	for {
+		end := bytes.IndexByte(p.partial, '\n')
+		if end < 0 {
+			break
+		}
+		line := bytes.TrimSpace(p.partial[:end])
+		p.partial = p.partial[end+1:]
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg pullMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			glog.V(5).Infof("Unable to demarshal image pull progress message %v, error: %v", string(line), err)
+		} else {
+			p.update(msg, time.Now())
+		}
+	}
+	downloaded := p.record.BytesDone - bytesDone
+	p.lock.Unlock()
+
+	p.save(false)
+
+	// The manager lock is taken without the pull lock, the manager takes the pull locks of the active pulls.
+	now := time.Now()
+	if delay := p.manager.throttle(downloaded, now); delay > 0 {
+		p.lock.Lock()
+		p.throttled = now.Add(delay)
+		p.lock.Unlock()
+		time.Sleep(delay)
+	}
+	return len(data), nil
+}
+
+// Update the progress of the pull with a message from the progress stream. The caller holds the pull lock.
+func (p *imagePull) update(msg pullMessage, now time.Time) {
+	if msg.Error != "" {
+		p.streamErr = msg.Error
+		return
+	} else if msg.Id == "" || strings.HasPrefix(msg.Status, "Pulling from") {
+		// messages about the whole image, such as its digest
+		return
+	}
+
+	layer, ok := p.layers[msg.Id]
+	if !ok {
+		layer = &layerProgress{}
+		p.layers[msg.Id] = layer
+	}
+	progress := msg.Status != layer.status
+
+	switch msg.Status {
+	case "Downloading":
+		progress = progress || msg.ProgressDetail.Current != layer.bytesDone
+		layer.bytesDone = msg.ProgressDetail.Current
+		if msg.ProgressDetail.Total > 0 {
+			layer.bytesTotal = msg.ProgressDetail.Total
+		}
+	case "Verifying Checksum", "Download complete":
+		layer.bytesDone = layer.bytesTotal
+	case "Extracting":
+		// the progress detail is the extraction progress, the layer is downloaded
+		layer.bytesDone = layer.bytesTotal
+		progress = true
+	case "Pull complete", "Already exists":
+		layer.bytesDone = layer.bytesTotal
+		layer.done = true
+	}
+	layer.status = msg.Status
+
+	p.record.LayersTotal = len(p.layers)
+	p.record.LayersDone = 0
+	p.record.BytesDone = 0
+	p.record.BytesTotal = 0
+	for _, l := range p.layers {
+		if l.done {
+			p.record.LayersDone++
+		}
+		p.record.BytesDone += l.bytesDone
+		p.record.BytesTotal += l.bytesTotal
+	}
+
+	if progress {
+		p.record.LastProgressTime = uint64(now.Unix())
+	}
+
+	if p.rateTime.IsZero() {
+		p.rateTime = now
+		p.rateBytes = p.record.BytesDone
+	} else if elapsed := now.Sub(p.rateTime); elapsed >= pullProgressIntervalS*time.Second {
+		if rate := int64(float64(p.record.BytesDone-p.rateBytes) / elapsed.Seconds()); rate > 0 {
+			p.record.BytesPerSecond = rate
+		} else {
+			p.record.BytesPerSecond = 0
+		}
+		p.rateTime = now
+		p.rateBytes = p.record.BytesDone
+	}
+}
+
+// Save the pull record. The progress of a pull is saved at most every pullProgressIntervalS seconds, its state changes are
+// always saved.
+func (p *imagePull) save(force bool) {
+	if p.manager.db == nil {
+		return
+	}
+
+	p.lock.Lock()
+	if !force && time.Since(p.lastSave) < pullProgressIntervalS*time.Second {
+		p.lock.Unlock()
+		return
+	}
+	p.lastSave = time.Now()
+	record := p.record
+	p.lock.Unlock()
+
+	if err := persistence.SaveImagePull(p.manager.db, &record); err != nil {
+		glog.Errorf("Unable to save the image pull record of %v, error: %v", record.Image, err)
+	}
+}
+
+func (p *imagePull) log(severity string, messageMeta *persistence.MessageMeta, eventCode string) {
+	if p.manager.db != nil && p.logEvent != nil {
+		p.logEvent(severity, messageMeta, eventCode)
+	}
+}
+
+// Returns the delay before the next attempt of a pull, and false when the pull is not retried. Without a retry deadline a
+// pull is tried maxPullAttempts times, with a deadline the delay doubles from pullAttemptDelayS up to maxPullRetryDelayS and
+// the pull is retried as long as the next attempt starts before the deadline.
+func nextPullRetryDelay(attempts int, elapsed time.Duration, deadline time.Duration) (time.Duration, bool) {
+	if deadline == 0 {
+		return pullAttemptDelayS * time.Second, attempts < maxPullAttempts
+	}
+
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := time.Duration(maxPullRetryDelayS) * time.Second
+	if attempts <= 8 {
+		if d := time.Duration(pullAttemptDelayS<<uint(attempts-1)) * time.Second; d < delay {
+			delay = d
+		}
+	}
+	return delay, elapsed+delay < deadline
+}
@@ -0,0 +1,135 @@
+//go:build unit
+// +build unit
+
+package imagefetch
+
+import (
+	"context"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_imagePull_Write(t *testing.T) {
+	pulls := newPullManager(nil, config.ImagePullConfig{})
+	pull := pulls.newImagePull("busybox:1.36", nil, nil)
+
+	stream := `{"status":"Pulling from library/busybox","id":"1.36"}
+{"status":"Already exists","progressDetail":{},"id":"layer1"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"layer2"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"layer3"}
+{"status":"Downloading","progressDetail":{"current":1000,"total":4000},"progress":"[=>   ]","id":"layer2"}
+{"status":"Downloading","progressDetail":{"current":500,"total":2000},"progress":"[=>   ]","id":"layer3"}
+{"status":"Downloading","progressDetail":{"current":3000,"total":4000},"progress":"[===> ]","id":"layer2"}
+{"status":"Download complete","progressDetail":{},"id":"layer2"}
+{"status":"Extracting","progressDetail":{"current":32768,"total":4000},"id":"layer2"}
+{"status":"Pull complete","progressDetail":{},"id":"layer2"}
+`
+
+	// the stream is written in pieces that split the messages.
+	for i := 0; i < len(stream); i += 37 {
+		end := i + 37
+		if end > len(stream) {
+			end = len(stream)
+		}
+		n, err := pull.Write([]byte(stream[i:end]))
+		assert.Nil(t, err, "the stream should be written")
+		assert.Equal(t, end-i, n, "the whole piece should be written")
+	}
+
+	assert.Equal(t, 3, pull.record.LayersTotal, "wrong number of layers")
+	assert.Equal(t, 2, pull.record.LayersDone, "wrong number of layers done")
+	assert.Equal(t, int64(6000), pull.record.BytesTotal, "wrong number of bytes to download")
+	assert.Equal(t, int64(4500), pull.record.BytesDone, "wrong number of bytes downloaded")
+	assert.Empty(t, pull.streamErr, "the stream has no error")
+
+	// an error in the stream is the error of the attempt.
+	err := pull.attempt(func(ctx context.Context) error {
+		pull.Write([]byte(`{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}` + "\r\n"))
+		return nil
+	})
+	assert.EqualError(t, err, "manifest unknown", "the stream error should be returned")
+	assert.Equal(t, 1, pull.record.Attempts, "wrong number of attempts")
+}
+
+func Test_imagePull_stall(t *testing.T) {
+	pulls := newPullManager(nil, config.ImagePullConfig{StallTimeoutS: 1})
+	pull := pulls.newImagePull("busybox:1.36", nil, nil)
+
+	err := pull.attempt(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	})
+	assert.EqualError(t, err, "the pull of image busybox:1.36 made no progress for 1 seconds", "the stalled attempt should be cancelled")
+	assert.Equal(t, persistence.IMAGE_PULL_STALLED, pull.record.Status, "the pull should be stalled")
+}
+
+func Test_pullManager_canStart(t *testing.T) {
+	pulls := newPullManager(nil, config.ImagePullConfig{MaxConcurrentPulls: 2, StartBelowKBps: 100})
+	pull1 := pulls.newImagePull("image1", nil, nil)
+	pull2 := pulls.newImagePull("image2", nil, nil)
+
+	assert.True(t, pulls.canStart(), "the first pull should start")
+	pulls.active[pull1] = true
+	assert.True(t, pulls.canStart(), "the second pull should start below the start rate")
+
+	pull1.record.BytesPerSecond = 100 * 1024
+	assert.False(t, pulls.canStart(), "the second pull should not start at the start rate")
+
+	pull1.record.BytesPerSecond = 0
+	pulls.active[pull2] = true
+	assert.False(t, pulls.canStart(), "the third pull should not start")
+
+	pulls.release(pull1)
+	pulls.release(pull2)
+	assert.True(t, pulls.canStart(), "a pull should start when no pull is active")
+}
+
+func Test_pullManager_throttle(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, time.Duration(0), newPullManager(nil, config.ImagePullConfig{}).throttle(1024*1024, now), "the pulls should not wait without a limit")
+
+	pulls := newPullManager(nil, config.ImagePullConfig{MaxDownloadKBps: 100})
+	assert.Equal(t, time.Second, pulls.throttle(100*1024, now), "100 KB should be due after a second")
+	assert.Equal(t, 3*time.Second, pulls.throttle(200*1024, now), "the bytes of the pulls should be due one after the other")
+	assert.Equal(t, time.Duration(0), pulls.throttle(0, now.Add(2*time.Second)), "a progress message without bytes should not wait")
+	assert.Equal(t, 500*time.Millisecond, pulls.throttle(50*1024, now.Add(10*time.Second)), "the time without downloads should not be saved up")
+
+	// the progress of a pull is read once its bytes are due.
+	pull := pulls.newImagePull("busybox:1.36", nil, nil)
+	start := time.Now()
+	pull.Write([]byte(`{"status":"Downloading","progressDetail":{"current":10240,"total":20480},"id":"layer1"}` + "\n"))
+	pull.Write([]byte(`{"status":"Downloading","progressDetail":{"current":20480,"total":20480},"id":"layer1"}` + "\n"))
+	assert.True(t, time.Since(start) >= 200*time.Millisecond, "20 KB should take at least 200ms at 100 KB per second")
+}
+
+func Test_nextPullRetryDelay(t *testing.T) {
+	// without a deadline a pull is tried maxPullAttempts times.
+	delay, retry := nextPullRetryDelay(1, 0, 0)
+	assert.True(t, retry, "the pull should be retried")
+	assert.Equal(t, pullAttemptDelayS*time.Second, delay, "wrong retry delay")
+	_, retry = nextPullRetryDelay(maxPullAttempts, 0, 0)
+	assert.False(t, retry, "the pull should not be retried")
+
+	// with a deadline the delay doubles up to maxPullRetryDelayS.
+	deadline := time.Hour
+	expected := []time.Duration{15, 30, 60, 120, 240, 300, 300}
+	for i, e := range expected {
+		delay, retry = nextPullRetryDelay(i+1, time.Minute, deadline)
+		assert.True(t, retry, "the pull should be retried")
+		assert.Equal(t, e*time.Second, delay, "wrong retry delay")
+	}
+	delay, retry = nextPullRetryDelay(100, time.Minute, deadline)
+	assert.True(t, retry, "the pull should be retried")
+	assert.Equal(t, maxPullRetryDelayS*time.Second, delay, "wrong retry delay")
+
+	// the next attempt must start before the deadline.
+	_, retry = nextPullRetryDelay(3, deadline-30*time.Second, deadline)
+	assert.False(t, retry, "the pull should not be retried after the deadline")
+}
@@ -322,6 +322,56 @@ func (c KubeClient) Status(tar string, metadata map[string]interface{}, agId str
 	}
 }
 
+// The options of the logs of a container in a pod of an operator.
+type LogOptions struct {
+	Pod          string // a pod in the namespace of the operator, the operator pod when it is empty
+	Container    string // the container in the pod, it can be empty when the pod has only one container
+	TailLines    int64  // the number of lines from the end of the logs, all the lines when it is 0
+	SinceSeconds int64  // only the logs of the last number of seconds, all the logs when it is 0
+	Follow       bool   // stream the logs as they are written
+	Timestamps   bool   // prefix each line with its timestamp
+}
+
+// Logs opens the log stream of a container in the operator pod, or in another pod in the namespace of the operator. The caller
+// closes the stream.
+func (c KubeClient) Logs(ctx context.Context, tar string, metadata map[string]interface{}, agId string, reqNamespace string, opts LogOptions) (io.ReadCloser, error) {
+	apiObjMap, opNamespace, err := ProcessDeployment(tar, metadata, nil, map[string]string{}, "", "", map[string]string{}, agId, 0)
+	if err != nil {
+		return nil, err
+	}
+	namespace := getFinalNamespace(reqNamespace, opNamespace)
+
+	podName := opts.Pod
+	if podName == "" {
+		if len(apiObjMap[K8S_DEPLOYMENT_TYPE]) < 1 {
+			return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: failed to find operator deployment object.")))
+		} else if podList, err := apiObjMap[K8S_DEPLOYMENT_TYPE][0].Status(c, namespace); err != nil {
+			return nil, err
+		} else if podListTyped, ok := podList.(*corev1.PodList); !ok {
+			return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: deployment status returned unexpected type.")))
+		} else if len(podListTyped.Items) < 1 {
+			return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: the operator pod is not running in namespace %v.", namespace)))
+		} else {
+			podName = podListTyped.Items[0].ObjectMeta.Name
+		}
+	}
+
+	podLogOptions := &corev1.PodLogOptions{
+		Container:  opts.Container,
+		Follow:     opts.Follow,
+		Timestamps: opts.Timestamps,
+	}
+	if opts.TailLines > 0 {
+		podLogOptions.TailLines = &opts.TailLines
+	}
+	if opts.SinceSeconds > 0 {
+		podLogOptions.SinceSeconds = &opts.SinceSeconds
+	}
+
+	glog.V(5).Infof(kwlog(fmt.Sprintf("opening the logs of pod %v in namespace %v with options %v", podName, namespace, opts)))
+	return c.Client.CoreV1().Pods(namespace).GetLogs(podName, podLogOptions).Stream(ctx)
+}
+
 // Currently we only support service/vault secret update, this k8s secret is create with service secret value in agreement. It is not the secret.yml from operator file
 func (c KubeClient) Update(tar string, metadata map[string]interface{}, agId string, reqNamespace string, updatedEnv map[string]string, updatedSecrets []persistence.PersistedServiceSecret) error {
 	// Convert updatedSecrets to map[string]string
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/boltdb/bolt"
@@ -9,8 +10,11 @@ import (
 	"github.com/open-horizon/anax/agreementbot"
 	agbotPersistence "github.com/open-horizon/anax/agreementbot/persistence"
 	_ "github.com/open-horizon/anax/agreementbot/persistence/bolt"
+	"github.com/open-horizon/anax/agreementbot/persistence/migrate"
 	_ "github.com/open-horizon/anax/agreementbot/persistence/postgresql"
+	_ "github.com/open-horizon/anax/agreementbot/persistence/sqlite"
 	agbotSecretsImpl "github.com/open-horizon/anax/agreementbot/secrets"
+	_ "github.com/open-horizon/anax/agreementbot/secrets/local"
 	_ "github.com/open-horizon/anax/agreementbot/secrets/vault"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/changes"
@@ -18,7 +22,9 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/download"
+	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/exchange"
+	_ "github.com/open-horizon/anax/externalpolicy/json_language"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
 	"github.com/open-horizon/anax/governance"
 	"github.com/open-horizon/anax/i18n"
@@ -46,6 +52,8 @@ import (
 func main() {
 	configFile := flag.String("config", "/etc/colonus/anax.config", "Config file location")
 	cpuprofile := flag.String("cpuprofile", "", "write cpu profile to file")
+	migrateFrom := flag.String("migrate-agbot-db-from", "", "Migrate the agbot database configured in this config file to the agbot database configured by -config, then exit")
+	dryRun := flag.Bool("dry-run", false, "Used with -migrate-agbot-db-from to report what would be migrated without changing the target database")
 
 	flag.Parse()
 
@@ -72,6 +80,11 @@ func main() {
 	// eventlog messages.
 	i18n.InitMessagePrinter(true)
 
+	// migrate the agbot database if requested, the agbot is not started.
+	if *migrateFrom != "" {
+		os.Exit(migrateAgbotDatabase(*migrateFrom, cfg, *dryRun))
+	}
+
 	// open edge DB if necessary
 	var db *bolt.DB
 	if len(cfg.Edge.DBPath) != 0 {
@@ -79,13 +92,34 @@ func main() {
 			panic(err)
 		}
 
-		edgeDB, err := bolt.Open(path.Join(cfg.Edge.DBPath, "anax.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+		dbFile := path.Join(cfg.Edge.DBPath, "anax.db")
+		edgeDB, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: 10 * time.Second})
 		if err != nil {
 			panic(err)
 		}
+
+		// compact the db file if it was requested through the API, this can only be done while the db is closed.
+		if requested, err := persistence.IsCompactionRequested(edgeDB); err != nil {
+			glog.Errorf("Unable to check for a compaction request in local db file %v, error %v", dbFile, err)
+		} else if requested {
+			edgeDB.Close()
+			if oldSize, newSize, err := persistence.CompactDatabase(dbFile); err != nil {
+				glog.Errorf("Unable to compact local db file %v, error %v", dbFile, err)
+			} else {
+				glog.Infof("Compacted local db file %v from %v to %v bytes.", dbFile, oldSize, newSize)
+			}
+			if edgeDB, err = bolt.Open(dbFile, 0600, &bolt.Options{Timeout: 10 * time.Second}); err != nil {
+				panic(err)
+			}
+		}
 		db = edgeDB
 	}
 
+	// forward the event logs to the configured external collectors
+	if err := eventlog.StartEventSinks(cfg); err != nil {
+		glog.Errorf("Unable to start event sinks: %v", err)
+	}
+
 	// open Agreement Bot DB if necessary
 
 	var agbotDB agbotPersistence.AgbotDatabase
@@ -117,6 +151,7 @@ func main() {
 		glog.Infof("Closing up shop.")
 
 		pprof.StopCPUProfile()
+		eventlog.StopEventSinks()
 		if db != nil {
 			db.Close()
 			// remove the local db
@@ -188,6 +223,9 @@ func main() {
 		if imageWorker := imagefetch.NewImageFetchWorker("ImageFetch", cfg, db); imageWorker != nil {
 			workers.Add(imageWorker)
 		}
+		if imageLifecycleWorker := imagefetch.NewImageLifecycleWorker("ImageLifecycle", cfg, db); imageLifecycleWorker != nil {
+			workers.Add(imageLifecycleWorker)
+		}
 		workers.Add(kube_operator.NewKubeWorker("Kube", cfg, db, authm, secretm))
 		workers.Add(resource.NewResourceWorker("Resource", cfg, db, authm))
 		workers.Add(changes.NewChangesWorker("ExchangeChanges", cfg, db))
@@ -203,6 +241,8 @@ func main() {
 	// Get into the event processing loop until anax shuts itself down.
 	workers.ProcessEventMessages()
 
+	eventlog.StopEventSinks()
+
 	if db != nil {
 		db.Close()
 
@@ -222,3 +262,31 @@ func main() {
 
 	glog.Info("Main process terminating")
 }
+
+// Migrate the agbot database configured in the source config file to the agbot database in the target config, and write
+// the migration report to stdout. Returns the process exit code.
+func migrateAgbotDatabase(sourceConfigFile string, targetCfg *config.HorizonConfig, dryRun bool) int {
+	sourceCfg, err := config.Read(sourceConfigFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read config file %v: %v\n", sourceConfigFile, err)
+		return 1
+	}
+
+	report, err := migrate.Run(sourceCfg, targetCfg, dryRun)
+	if report != nil {
+		if output, jerr := json.MarshalIndent(report, "", "  "); jerr != nil {
+			fmt.Fprintf(os.Stderr, "Unable to serialize migration report %v: %v\n", report, jerr)
+		} else {
+			fmt.Println(string(output))
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Agbot database migration failed: %v\n", err)
+		return 1
+	} else if !report.Succeeded() {
+		fmt.Fprintf(os.Stderr, "Agbot database migration completed with %v problems.\n", len(report.Problems))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,347 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// This package implements a small, dependency free metrics registry that can be exposed in the Prometheus
+// text exposition format (version 0.0.4), which is also accepted by OpenMetrics scrapers. Both the agent
+// and the agbot publish their metrics through the DefaultRegistry on their /metrics API.
+
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	TYPE_COUNTER   = "counter"
+	TYPE_GAUGE     = "gauge"
+	TYPE_HISTOGRAM = "histogram"
+)
+
+// Default histogram buckets, in seconds, suitable for measuring the latency of remote calls.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// A Collector is called each time the registry is exposed, so that metrics whose values are computed on demand
+// (for example, counts of database records) can be refreshed just before they are written out.
+type Collector func()
+
+// The registry holds all the metric families known to the process.
+type Registry struct {
+	lock       sync.Mutex
+	families   map[string]*family
+	collectors map[string]Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families:   make(map[string]*family),
+		collectors: make(map[string]Collector),
+	}
+}
+
+// The registry used by the agent and agbot API.
+var DefaultRegistry = NewRegistry()
+
+// Register a collector under the given name. Registering another collector with the same name replaces the
+// previous one.
+func (r *Registry) RegisterCollector(name string, c Collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.collectors[name] = c
+}
+
+func (r *Registry) UnregisterCollector(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.collectors, name)
+}
+
+// Returns the family with the given name, creating it if it doesnt exist yet. A family that already exists with
+// a different type or label set is a programming error.
+func (r *Registry) getFamily(name string, help string, mType string, labelNames []string, buckets []float64) *family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.mType != mType || strings.Join(f.labelNames, ",") != strings.Join(labelNames, ",") {
+			panic(fmt.Sprintf("metric %v re-registered as %v with labels %v, was %v with labels %v", name, mType, labelNames, f.mType, f.labelNames))
+		}
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		mType:      mType,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+func (r *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{f: r.getFamily(name, help, TYPE_COUNTER, labelNames, nil)}
+}
+
+func (r *Registry) NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{f: r.getFamily(name, help, TYPE_GAUGE, labelNames, nil)}
+}
+
+// Create a histogram. If buckets is nil, the DefaultBuckets are used.
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+	return &HistogramVec{f: r.getFamily(name, help, TYPE_HISTOGRAM, labelNames, b)}
+}
+
+// Run all the collectors and then write every metric family in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+
+	r.lock.Lock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.lock.Unlock()
+
+	for _, c := range collectors {
+		c()
+	}
+
+	r.lock.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	r.lock.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.lock.Lock()
+		f := r.families[name]
+		r.lock.Unlock()
+		if _, err := io.WriteString(w, f.text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An http handler that exposes the registry. It is used by the /metrics API of the agent and the agbot.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		w.Header().Set("Content-Type", CONTENT_TYPE)
+		w.WriteHeader(http.StatusOK)
+		r.Write(w)
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// A metric family is a named metric with a fixed set of label names and any number of series, one per
+// unique set of label values.
+type family struct {
+	lock       sync.Mutex
+	name       string
+	help       string
+	mType      string
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64 // histogram only, one count per bucket
+	count       uint64   // histogram only
+}
+
+func (f *family) getSeries(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %v expects %v label values, got %v", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		lv := make([]string, len(labelValues))
+		copy(lv, labelValues)
+		s = &series{labelValues: lv}
+		if f.mType == TYPE_HISTOGRAM {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) reset() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.series = make(map[string]*series)
+}
+
+func (f *family) text() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# HELP %v %v\n", f.name, escapeHelp(f.help)))
+	sb.WriteString(fmt.Sprintf("# TYPE %v %v\n", f.name, f.mType))
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		if f.mType != TYPE_HISTOGRAM {
+			sb.WriteString(fmt.Sprintf("%v%v %v\n", f.name, labelText(f.labelNames, s.labelValues, "", ""), formatFloat(s.value)))
+			continue
+		}
+		cumulative := uint64(0)
+		for ix, b := range f.buckets {
+			cumulative += s.counts[ix]
+			sb.WriteString(fmt.Sprintf("%v_bucket%v %v\n", f.name, labelText(f.labelNames, s.labelValues, "le", formatFloat(b)), cumulative))
+		}
+		sb.WriteString(fmt.Sprintf("%v_bucket%v %v\n", f.name, labelText(f.labelNames, s.labelValues, "le", "+Inf"), s.count))
+		sb.WriteString(fmt.Sprintf("%v_sum%v %v\n", f.name, labelText(f.labelNames, s.labelValues, "", ""), formatFloat(s.value)))
+		sb.WriteString(fmt.Sprintf("%v_count%v %v\n", f.name, labelText(f.labelNames, s.labelValues, "", ""), s.count))
+	}
+	return sb.String()
+}
+
+// A counter only goes up, except when the process restarts.
+type CounterVec struct {
+	f *family
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.lock.Lock()
+	defer c.f.lock.Unlock()
+	c.f.getSeries(labelValues).value += v
+}
+
+func (c *CounterVec) Get(labelValues ...string) float64 {
+	c.f.lock.Lock()
+	defer c.f.lock.Unlock()
+	return c.f.getSeries(labelValues).value
+}
+
+// A gauge can go up and down.
+type GaugeVec struct {
+	f *family
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.f.lock.Lock()
+	defer g.f.lock.Unlock()
+	g.f.getSeries(labelValues).value = v
+}
+
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.f.lock.Lock()
+	defer g.f.lock.Unlock()
+	g.f.getSeries(labelValues).value += v
+}
+
+func (g *GaugeVec) Get(labelValues ...string) float64 {
+	g.f.lock.Lock()
+	defer g.f.lock.Unlock()
+	return g.f.getSeries(labelValues).value
+}
+
+// Remove all series from the gauge. Collectors use this before recomputing a gauge so that label values which
+// no longer exist (e.g. a state that no agreement is in anymore) disappear from the output.
+func (g *GaugeVec) Reset() {
+	g.f.reset()
+}
+
+// A histogram counts observations into configurable buckets.
+type HistogramVec struct {
+	f *family
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.f.lock.Lock()
+	defer h.f.lock.Unlock()
+	s := h.f.getSeries(labelValues)
+	for ix, b := range h.f.buckets {
+		if v <= b {
+			s.counts[ix] += 1
+			break
+		}
+	}
+	s.count += 1
+	s.value += v
+}
+
+// Returns the number of observations and their sum.
+func (h *HistogramVec) Get(labelValues ...string) (uint64, float64) {
+	h.f.lock.Lock()
+	defer h.f.lock.Unlock()
+	s := h.f.getSeries(labelValues)
+	return s.count, s.value
+}
+
+// Utility functions for writing the text format.
+func labelText(names []string, values []string, extraName string, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for ix, n := range names {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", n, escapeLabel(values[ix])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", extraName, extraValue))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return strings.Replace(s, `"`, `\"`, -1)
+}
+
+func escapeHelp(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
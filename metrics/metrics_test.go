@@ -0,0 +1,127 @@
+//go:build unit
+// +build unit
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Counter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Number of test requests.", "method")
+
+	c.Inc("GET")
+	c.Inc("GET")
+	c.Add(3, "PUT")
+	c.Add(-1, "PUT")
+
+	assert.Equal(t, float64(2), c.Get("GET"))
+	assert.Equal(t, float64(3), c.Get("PUT"))
+
+	var b bytes.Buffer
+	assert.Nil(t, r.Write(&b))
+	out := b.String()
+	assert.Contains(t, out, "# HELP test_requests_total Number of test requests.\n")
+	assert.Contains(t, out, "# TYPE test_requests_total counter\n")
+	assert.Contains(t, out, "test_requests_total{method=\"GET\"} 2\n")
+	assert.Contains(t, out, "test_requests_total{method=\"PUT\"} 3\n")
+}
+
+func Test_Gauge_Collector(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_state", "Things by state.", "state")
+	g.Set(5, "old")
+
+	calls := 0
+	r.RegisterCollector("test", func() {
+		calls += 1
+		g.Reset()
+		g.Set(2, "new")
+	})
+
+	var b bytes.Buffer
+	assert.Nil(t, r.Write(&b))
+	assert.Equal(t, 1, calls)
+	assert.NotContains(t, b.String(), "old")
+	assert.Contains(t, b.String(), "test_state{state=\"new\"} 2\n")
+
+	r.UnregisterCollector("test")
+	b.Reset()
+	assert.Nil(t, r.Write(&b))
+	assert.Equal(t, 1, calls)
+}
+
+func Test_Gauge_NoLabels(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_value", "A value.")
+	g.Set(1.5)
+	g.Add(1)
+
+	var b bytes.Buffer
+	assert.Nil(t, r.Write(&b))
+	assert.Contains(t, b.String(), "test_value 2.5\n")
+}
+
+func Test_Histogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{1, 0.1}, "method")
+
+	h.Observe(0.05, "GET")
+	h.Observe(0.5, "GET")
+	h.Observe(2, "GET")
+
+	count, sum := h.Get("GET")
+	assert.Equal(t, uint64(3), count)
+	assert.Equal(t, 2.55, sum)
+
+	var b bytes.Buffer
+	assert.Nil(t, r.Write(&b))
+	out := b.String()
+	assert.Contains(t, out, "test_latency_seconds_bucket{method=\"GET\",le=\"0.1\"} 1\n")
+	assert.Contains(t, out, "test_latency_seconds_bucket{method=\"GET\",le=\"1\"} 2\n")
+	assert.Contains(t, out, "test_latency_seconds_bucket{method=\"GET\",le=\"+Inf\"} 3\n")
+	assert.Contains(t, out, "test_latency_seconds_count{method=\"GET\"} 3\n")
+	assert.True(t, strings.Index(out, "le=\"0.1\"") < strings.Index(out, "le=\"1\""), "buckets should be sorted")
+}
+
+func Test_LabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_escape", "Escaping.", "name")
+	g.Set(1, "a\"b\\c\nd")
+
+	var b bytes.Buffer
+	assert.Nil(t, r.Write(&b))
+	assert.Contains(t, b.String(), `test_escape{name="a\"b\\c\nd"} 1`)
+}
+
+func Test_ReRegister(t *testing.T) {
+	r := NewRegistry()
+	c1 := r.NewCounterVec("test_total", "Total.", "a")
+	c2 := r.NewCounterVec("test_total", "Total.", "a")
+	c1.Inc("x")
+	assert.Equal(t, float64(1), c2.Get("x"))
+
+	assert.Panics(t, func() { r.NewGaugeVec("test_total", "Total.", "a") })
+}
+
+func Test_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewGaugeVec("test_up", "Up.").Set(1)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, CONTENT_TYPE, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "test_up 1\n")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -533,7 +533,7 @@ func FindOrCreateMicroserviceDef(db *bolt.DB, service_name string, service_org s
 		}
 	}
 
-	if msdef_new, err := CreateMicroserviceDefWithServiceDef(db, sdef, sId, vExp.Get_expression()); err != nil {
+	if msdef_new, err := CreateMicroserviceDefWithServiceDef(db, sdef, sId, vExp.Get_shorthand_expression()); err != nil {
 		return nil, err
 	} else {
 		return msdef_new, nil
@@ -561,7 +561,7 @@ func CreateMicroserviceDef(db *bolt.DB, service_name string, service_org string,
 		return nil, fmt.Errorf("Unable to find the service definition using  %v/%v %v %v in the exchange.", service_org, service_name, vExp.Get_expression(), service_arch)
 	}
 
-	if msdef_new, err := CreateMicroserviceDefWithServiceDef(db, sdef, sId, vExp.Get_expression()); err != nil {
+	if msdef_new, err := CreateMicroserviceDefWithServiceDef(db, sdef, sId, vExp.Get_shorthand_expression()); err != nil {
 		return nil, err
 	} else {
 		return msdef_new, nil
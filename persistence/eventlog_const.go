@@ -128,9 +128,15 @@ const (
 	EC_CONTAINER_STOPPED          = "container_stopped"
 	EC_ERROR_IN_DEPLOYMENT_CONFIG = "error_in_deployment_configuration"
 	EC_ERROR_START_CONTAINER      = "error_start_container"
+	EC_ADMISSION_POLICY_VIOLATION = "admission_policy_violation"
 
 	EC_IMAGE_LOADED                       = "image_loaded"
 	EC_ERROR_IMAGE_LOADE                  = "error_image_load"
+	EC_IMAGE_PULL_STARTED                 = "image_pull_started"
+	EC_IMAGE_PULL_STALLED                 = "image_pull_stalled"
+	EC_IMAGE_PULL_RETRY                   = "image_pull_retry"
+	EC_IMAGE_PULL_COMPLETED               = "image_pull_completed"
+	EC_ERROR_IMAGE_PULL                   = "error_image_pull"
 	EC_ERROR_AGREEMENT_VERIFICATION       = "error_in_agreement_verification"
 	EC_ERROR_DELETE_AGREEMENT_IN_EXCHANGE = "error_delete_agreement_in_exchange"
 
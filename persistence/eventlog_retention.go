@@ -0,0 +1,275 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// table that holds the event log retention bookkeeping
+const EVENT_LOG_RETENTION = "event_log_retention"
+
+// key of the compaction request in the EVENT_LOG_RETENTION table
+const COMPACTION_REQUESTED = "compaction_requested"
+
+// The limits on the event log records kept in the db. A zero value means there is no limit.
+type EventLogLimits struct {
+	MaxAge   uint64 `json:"max_age"`   // seconds
+	MaxCount int    `json:"max_count"` // records
+}
+
+func (l EventLogLimits) String() string {
+	return fmt.Sprintf("MaxAge: %v, MaxCount: %v", l.MaxAge, l.MaxCount)
+}
+
+// The retention policy applies the overall limits to all the records, and the severity limits to the records of
+// each severity. A record is removed when it exceeds any of the limits. The newest records are kept first.
+type EventLogRetentionPolicy struct {
+	EventLogLimits
+	SeverityLimits map[string]EventLogLimits `json:"severity_limits,omitempty"`
+}
+
+func (p EventLogRetentionPolicy) String() string {
+	return fmt.Sprintf("%v, SeverityLimits: %v", p.EventLogLimits.String(), p.SeverityLimits)
+}
+
+// Returns true if the policy does not limit the event log.
+func (p EventLogRetentionPolicy) IsEmpty() bool {
+	if p.MaxAge != 0 || p.MaxCount != 0 {
+		return false
+	}
+	for _, l := range p.SeverityLimits {
+		if l.MaxAge != 0 || l.MaxCount != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// The size of the event log and the db that holds it.
+type EventLogStats struct {
+	Count               int            `json:"count"`
+	SeverityCounts      map[string]int `json:"severity_counts"`
+	OldestTimestamp     uint64         `json:"oldest_timestamp"`
+	NewestTimestamp     uint64         `json:"newest_timestamp"`
+	DBSize              int64          `json:"db_size"`      // bytes
+	DBFreeSize          int            `json:"db_free_size"` // bytes in free pages, reclaimed by compaction
+	CompactionRequested bool           `json:"compaction_requested"`
+}
+
+func (s EventLogStats) String() string {
+	return fmt.Sprintf("Count: %v, SeverityCounts: %v, OldestTimestamp: %v, NewestTimestamp: %v, DBSize: %v, DBFreeSize: %v, CompactionRequested: %v",
+		s.Count, s.SeverityCounts, s.OldestTimestamp, s.NewestTimestamp, s.DBSize, s.DBFreeSize, s.CompactionRequested)
+}
+
+// The parts of an event log record needed to apply the retention policy.
+type eventLogEntry struct {
+	key       []byte
+	id        uint64
+	timestamp uint64
+	severity  string
+}
+
+// read the retention information of all the event log records, newest first.
+func getEventLogEntries(b *bolt.Bucket) []eventLogEntry {
+	entries := make([]eventLogEntry, 0)
+	b.ForEach(func(k, v []byte) error {
+		var el EventLogBase
+		if err := json.Unmarshal(v, &el); err != nil {
+			glog.Errorf("Unable to deserialize event log db record: %v. Error: %v", v, err)
+		} else if id, err := strconv.ParseUint(string(k), 10, 64); err != nil {
+			glog.Errorf("Event log db record key %v is not a sequence number. Error: %v", string(k), err)
+		} else {
+			entries = append(entries, eventLogEntry{key: append([]byte{}, k...), id: id, timestamp: el.Timestamp, severity: el.Severity})
+		}
+		return nil
+	})
+
+	// The keys are sequence numbers in string form, so they are not sorted numerically in the bucket.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id > entries[j].id })
+	return entries
+}
+
+// Remove the event log records that exceed the retention policy at the given time. Returns the number of records
+// removed.
+func PruneEventLogs(db *bolt.DB, policy EventLogRetentionPolicy, now uint64) (int, error) {
+	if policy.IsEmpty() {
+		return 0, nil
+	}
+
+	exceeds := func(l EventLogLimits, count int, timestamp uint64) bool {
+		return (l.MaxCount != 0 && count >= l.MaxCount) || (l.MaxAge != 0 && timestamp+l.MaxAge < now)
+	}
+
+	removed := 0
+	dbErr := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(EVENT_LOGS))
+		if b == nil {
+			return nil
+		}
+
+		kept := 0
+		keptBySeverity := make(map[string]int)
+		for _, e := range getEventLogEntries(b) {
+			if exceeds(policy.EventLogLimits, kept, e.timestamp) || exceeds(policy.SeverityLimits[e.severity], keptBySeverity[e.severity], e.timestamp) {
+				if err := b.Delete(e.key); err != nil {
+					return fmt.Errorf("Unable to delete event log %v. Error: %v", e.id, err)
+				}
+				removed++
+			} else {
+				kept++
+				keptBySeverity[e.severity]++
+			}
+		}
+		return nil
+	})
+
+	if dbErr != nil {
+		return 0, dbErr
+	}
+	return removed, nil
+}
+
+// Get the size of the event log and the db.
+func GetEventLogStats(db *bolt.DB) (*EventLogStats, error) {
+	stats := EventLogStats{SeverityCounts: make(map[string]int)}
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		stats.DBSize = tx.Size()
+
+		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
+			for _, e := range getEventLogEntries(b) {
+				stats.Count++
+				stats.SeverityCounts[e.severity]++
+				if stats.OldestTimestamp == 0 || e.timestamp < stats.OldestTimestamp {
+					stats.OldestTimestamp = e.timestamp
+				}
+				if e.timestamp > stats.NewestTimestamp {
+					stats.NewestTimestamp = e.timestamp
+				}
+			}
+		}
+
+		if b := tx.Bucket([]byte(EVENT_LOG_RETENTION)); b != nil {
+			stats.CompactionRequested = b.Get([]byte(COMPACTION_REQUESTED)) != nil
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	stats.DBFreeSize = db.Stats().FreeAlloc
+	return &stats, nil
+}
+
+// Request compaction of the db file. The file cannot be rewritten while it is open, so the compaction is done by
+// CompactDatabase the next time the agent starts.
+func RequestCompaction(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(EVENT_LOG_RETENTION)); err != nil {
+			return err
+		} else {
+			return bucket.Put([]byte(COMPACTION_REQUESTED), []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+		}
+	})
+}
+
+// Returns true if compaction of the db file has been requested.
+func IsCompactionRequested(db *bolt.DB) (bool, error) {
+	requested := false
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(EVENT_LOG_RETENTION)); b != nil {
+			requested = b.Get([]byte(COMPACTION_REQUESTED)) != nil
+		}
+		return nil
+	})
+	return requested, readErr
+}
+
+// Rewrite the db file without its free pages. The db must not be open. The buckets are copied into a new file which
+// then replaces the original, so an error leaves the original file untouched. The compaction request is cleared.
+// Returns the size of the file before and after compaction.
+func CompactDatabase(dbFile string) (int64, int64, error) {
+	origInfo, err := os.Stat(dbFile)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tmpFile := dbFile + ".compact"
+	os.Remove(tmpFile)
+
+	if err := copyDatabase(dbFile, tmpFile, origInfo.Mode()); err != nil {
+		os.Remove(tmpFile)
+		return 0, 0, err
+	}
+
+	newInfo, err := os.Stat(tmpFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmpFile, dbFile); err != nil {
+		os.Remove(tmpFile)
+		return 0, 0, fmt.Errorf("Unable to replace %v with the compacted file. Error: %v", dbFile, err)
+	}
+	return origInfo.Size(), newInfo.Size(), nil
+}
+
+// copy all the buckets of one db file into a new db file, leaving out the compaction request.
+func copyDatabase(srcFile string, dstFile string, mode os.FileMode) error {
+	src, err := bolt.Open(srcFile, 0600, &bolt.Options{Timeout: 10 * time.Second, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("Unable to open %v for compaction. Error: %v", srcFile, err)
+	}
+	defer src.Close()
+
+	dst, err := bolt.Open(dstFile, mode, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return fmt.Errorf("Unable to create %v for compaction. Error: %v", dstFile, err)
+	}
+	defer dst.Close()
+
+	copyErr := src.View(func(srcTx *bolt.Tx) error {
+		return dst.Update(func(dstTx *bolt.Tx) error {
+			err := srcTx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				if dstB, err := dstTx.CreateBucket(name); err != nil {
+					return err
+				} else {
+					return copyBucket(b, dstB)
+				}
+			})
+			if err == nil {
+				if b := dstTx.Bucket([]byte(EVENT_LOG_RETENTION)); b != nil {
+					err = b.Delete([]byte(COMPACTION_REQUESTED))
+				}
+			}
+			return err
+		})
+	})
+	if copyErr != nil {
+		return fmt.Errorf("Unable to copy %v for compaction. Error: %v", srcFile, copyErr)
+	}
+	return nil
+}
+
+// copy the keys, nested buckets and sequence of a bucket.
+func copyBucket(src *bolt.Bucket, dst *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			if nested, err := dst.CreateBucket(k); err != nil {
+				return err
+			} else {
+				return copyBucket(src.Bucket(k), nested)
+			}
+		}
+		return dst.Put(k, v)
+	})
+}
@@ -0,0 +1,124 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"path"
+	"testing"
+)
+
+// save event logs with the given severities, the record at index i has timestamp 1000+i.
+func saveTestEventLogs(t *testing.T, db *bolt.DB, severities []string) {
+	source := NewNodeEventSource("mynode", "myorg", "", CONFIGSTATE_CONFIGURED)
+	for i, severity := range severities {
+		el := newEventLog1(severity, "test message", nil, EC_NODE_CONFIG_REG_COMPLETE, SRC_TYPE_NODE, *source)
+		el.Timestamp = uint64(1000 + i)
+		if err := SaveEventLog(db, el); err != nil {
+			t.Errorf("Error saving eventlog into db. %v", err)
+		}
+	}
+}
+
+func Test_PruneEventLogs(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// 12 records, so that the keys do not sort numerically.
+	severities := []string{SEVERITY_INFO, SEVERITY_ERROR, SEVERITY_INFO, SEVERITY_INFO, SEVERITY_WARN, SEVERITY_INFO,
+		SEVERITY_INFO, SEVERITY_ERROR, SEVERITY_INFO, SEVERITY_INFO, SEVERITY_INFO, SEVERITY_INFO}
+	saveTestEventLogs(t, db, severities)
+
+	// An empty policy removes nothing.
+	count, err := PruneEventLogs(db, EventLogRetentionPolicy{}, 2000)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count, "Empty policy should not remove any records.")
+
+	// Keep the 4 newest info records, and the records that are at most 5 seconds old.
+	policy := EventLogRetentionPolicy{
+		EventLogLimits: EventLogLimits{MaxAge: 5},
+		SeverityLimits: map[string]EventLogLimits{SEVERITY_INFO: {MaxCount: 4}},
+	}
+	count, err = PruneEventLogs(db, policy, 1011)
+	assert.Nil(t, err)
+
+	els, err := FindAllEventLogs(db)
+	assert.Nil(t, err)
+	ids := make(map[string]bool)
+	for _, el := range els {
+		ids[el.Id] = true
+	}
+
+	// records 7 to 12 are new enough, of those info record 7 is over the info count.
+	assert.Equal(t, 7, count, "Wrong number of records removed.")
+	assert.Equal(t, map[string]bool{"8": true, "9": true, "10": true, "11": true, "12": true}, ids, "Wrong records kept.")
+
+	// The overall count limit keeps the newest records.
+	count, err = PruneEventLogs(db, EventLogRetentionPolicy{EventLogLimits: EventLogLimits{MaxCount: 2}}, 1011)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count, "Wrong number of records removed.")
+
+	stats, err := GetEventLogStats(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stats.Count, "Wrong record count.")
+	assert.Equal(t, map[string]int{SEVERITY_INFO: 2}, stats.SeverityCounts, "Wrong severity counts.")
+	assert.Equal(t, uint64(1010), stats.OldestTimestamp, "Wrong oldest timestamp.")
+	assert.Equal(t, uint64(1011), stats.NewestTimestamp, "Wrong newest timestamp.")
+	assert.True(t, stats.DBSize > 0, "The db size should be set.")
+	assert.False(t, stats.CompactionRequested, "Compaction should not be requested.")
+}
+
+func Test_CompactDatabase(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	severities := make([]string, 500)
+	for i := range severities {
+		severities[i] = SEVERITY_INFO
+	}
+	saveTestEventLogs(t, db, severities)
+
+	_, err = PruneEventLogs(db, EventLogRetentionPolicy{EventLogLimits: EventLogLimits{MaxCount: 10}}, 2000)
+	assert.Nil(t, err)
+
+	assert.Nil(t, RequestCompaction(db))
+	requested, err := IsCompactionRequested(db)
+	assert.Nil(t, err)
+	assert.True(t, requested, "Compaction should be requested.")
+	db.Close()
+
+	dbFile := path.Join(dir, "anax-ut.db")
+	oldSize, newSize, err := CompactDatabase(dbFile)
+	assert.Nil(t, err)
+	assert.True(t, newSize < oldSize, "The compacted file should be smaller, old size %v, new size %v.", oldSize, newSize)
+
+	db, err = bolt.Open(dbFile, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// The records, the sequence for new records and the other buckets are kept, the compaction request is not.
+	els, err := FindAllEventLogs(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(els), "Wrong number of records after compaction.")
+
+	saveTestEventLogs(t, db, []string{SEVERITY_INFO})
+	el, err := FindEventLogWithKey(db, "501")
+	assert.Nil(t, err)
+	assert.NotNil(t, el, "The sequence of the event log bucket should be kept.")
+
+	requested, err = IsCompactionRequested(db)
+	assert.Nil(t, err)
+	assert.False(t, requested, "Compaction request should be cleared.")
+}
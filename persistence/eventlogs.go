@@ -80,6 +80,15 @@ func (w EventLogBase) Matches(selectors map[string][]Selector) bool {
 	return true
 }
 
+// Use the given message printer to translate the message saved in MessageMeta and save it to Message.
+// MessageMeta is set to nil so that it will not get displayed.
+func (w *EventLogBase) TranslateMessage(msgPrinter *message.Printer) {
+	if w.MessageMeta != nil && w.MessageMeta.MessageKey != "" {
+		w.Message = msgPrinter.Sprintf(w.MessageMeta.MessageKey, w.MessageMeta.MessageArgs...)
+		w.MessageMeta = nil
+	}
+}
+
 type EventLog struct {
 	EventLogBase
 	Source EventSourceInterface `json:"event_source"` // source involved for this event.
@@ -481,12 +490,7 @@ func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string
 				if err := json.Unmarshal(v, &el); err != nil {
 					glog.Errorf("Unable to deserialize event log db record: %v. Error: %v", v, err)
 				} else {
-					// Use the given message printer to translate the message saved in MessageMeta and save it to Message.
-					if el.MessageMeta != nil && el.MessageMeta.MessageKey != "" {
-						el.Message = msgPrinter.Sprintf(el.MessageMeta.MessageKey, el.MessageMeta.MessageArgs...)
-						// set MessageMeta to nil so that it will not get displayed.
-						el.MessageMeta = nil
-					}
+					el.EventLogBase.TranslateMessage(msgPrinter)
 
 					if (all_logs || el.Timestamp > last_unreg) && el.EventLogBase.Matches(base_selectors) {
 						if esrc, err := GetRealEventSource(el.SourceType, el.Source); err != nil {
@@ -0,0 +1,139 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"strings"
+)
+
+// image pull table name
+const IMAGE_PULLS = "image_pulls"
+
+// The states of an image pull
+const IMAGE_PULL_WAITING = "waiting"     // waiting for other pulls to finish
+const IMAGE_PULL_PULLING = "pulling"     // the image is being pulled
+const IMAGE_PULL_STALLED = "stalled"     // the pull made no progress for the stall timeout, it is being restarted
+const IMAGE_PULL_RETRYING = "retrying"   // the pull failed, it will be retried at NextRetryTime
+const IMAGE_PULL_COMPLETED = "completed" // the image was pulled
+const IMAGE_PULL_FAILED = "failed"       // the image could not be pulled
+
+// The progress of the pull of a service image. The pull record is keyed by the image name and the agreements it is pulled for,
+// so that the pulls of the same image for different agreements have their own records.
+type ImagePull struct {
+	Image            string   `json:"image"`
+	Status           string   `json:"status"`
+	AgreementIds     []string `json:"agreement_ids"`      // the agreements whose services use the image
+	Attempts         int      `json:"attempts"`           // the number of docker pulls started, from the registry mirrors and the registry of the image
+	LayersTotal      int      `json:"layers_total"`       // the number of layers of the image
+	LayersDone       int      `json:"layers_done"`        // the number of layers that are on the node
+	BytesTotal       int64    `json:"bytes_total"`        // the size of the layers being downloaded, it grows as their sizes are known
+	BytesDone        int64    `json:"bytes_done"`         // the number of bytes downloaded
+	BytesPerSecond   int64    `json:"bytes_per_second"`   // the recent download rate
+	StartTime        uint64   `json:"start_time"`         // the time the first attempt started
+	LastProgressTime uint64   `json:"last_progress_time"` // the last time the pull made any progress
+	NextRetryTime    uint64   `json:"next_retry_time"`    // the time of the next attempt when the pull is retrying
+	EndTime          uint64   `json:"end_time"`           // the time the pull completed or failed
+	Error            string   `json:"error"`              // the error of the last failed attempt
+}
+
+func (p ImagePull) String() string {
+	return fmt.Sprintf("Image: %v, "+
+		"Status: %v, "+
+		"AgreementIds: %v, "+
+		"Attempts: %v, "+
+		"LayersTotal: %v, "+
+		"LayersDone: %v, "+
+		"BytesTotal: %v, "+
+		"BytesDone: %v, "+
+		"BytesPerSecond: %v, "+
+		"StartTime: %v, "+
+		"LastProgressTime: %v, "+
+		"NextRetryTime: %v, "+
+		"EndTime: %v, "+
+		"Error: %v",
+		p.Image, p.Status, p.AgreementIds, p.Attempts, p.LayersTotal, p.LayersDone, p.BytesTotal, p.BytesDone, p.BytesPerSecond,
+		p.StartTime, p.LastProgressTime, p.NextRetryTime, p.EndTime, p.Error)
+}
+
+func (p ImagePull) ShortString() string {
+	return fmt.Sprintf("Image: %v, Status: %v, Attempts: %v, LayersDone: %v/%v, BytesDone: %v/%v", p.Image, p.Status, p.Attempts, p.LayersDone, p.LayersTotal, p.BytesDone, p.BytesTotal)
+}
+
+func (p ImagePull) key() string {
+	if len(p.AgreementIds) == 0 {
+		return p.Image
+	}
+	return p.Image + "|" + strings.Join(p.AgreementIds, ",")
+}
+
+// Returns true when the pull has not completed or failed yet.
+func (p ImagePull) InProgress() bool {
+	return p.Status != IMAGE_PULL_COMPLETED && p.Status != IMAGE_PULL_FAILED
+}
+
+// save or update the given image pull
+func SaveImagePull(db *bolt.DB, pull *ImagePull) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(IMAGE_PULLS)); err != nil {
+			return err
+		} else if serial, err := json.Marshal(pull); err != nil {
+			return fmt.Errorf("Failed to serialize image pull: %v", err)
+		} else {
+			return bucket.Put([]byte(pull.key()), serial)
+		}
+	})
+}
+
+func DeleteImagePull(db *bolt.DB, pull *ImagePull) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(IMAGE_PULLS)); err != nil {
+			return err
+		} else if err := bucket.Delete([]byte(pull.key())); err != nil {
+			return fmt.Errorf("Unable to delete image pull record for %v: %v.", pull.Image, err)
+		}
+		return nil
+	})
+}
+
+func FindImagePulls(db *bolt.DB, filters []IPFilter) ([]ImagePull, error) {
+	pulls := make([]ImagePull, 0)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(IMAGE_PULLS)); bucket != nil {
+			return bucket.ForEach(func(k, v []byte) error {
+				pull := ImagePull{}
+				if err := json.Unmarshal(v, &pull); err != nil {
+					return fmt.Errorf("Unable to deserialize image pull record %v: %v", string(k), err)
+				}
+				for _, filter := range filters {
+					if !filter(pull) {
+						return nil
+					}
+				}
+				pulls = append(pulls, pull)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	return pulls, readErr
+}
+
+type IPFilter func(ImagePull) bool
+
+func InProgressIPFilter() IPFilter {
+	return func(p ImagePull) bool { return p.InProgress() }
+}
+
+func AgreementIPFilter(agreementId string) IPFilter {
+	return func(p ImagePull) bool {
+		for _, id := range p.AgreementIds {
+			if id == agreementId {
+				return true
+			}
+		}
+		return false
+	}
+}
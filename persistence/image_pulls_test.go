@@ -0,0 +1,46 @@
+//go:build unit
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+func Test_ImagePulls(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanTestDir(dir)
+
+	// The same image pulled for two agreements has two records.
+	pull1 := ImagePull{Image: "quay.io/myorg/myservice:1.0.0", Status: IMAGE_PULL_PULLING, AgreementIds: []string{"ag1"}}
+	pull2 := ImagePull{Image: "quay.io/myorg/myservice:1.0.0", Status: IMAGE_PULL_COMPLETED, AgreementIds: []string{"ag2"}}
+	if err := SaveImagePull(db, &pull1); err != nil {
+		t.Fatalf("unable to save image pull, error: %v", err)
+	} else if err := SaveImagePull(db, &pull2); err != nil {
+		t.Fatalf("unable to save image pull, error: %v", err)
+	}
+
+	if pulls, err := FindImagePulls(db, []IPFilter{}); err != nil {
+		t.Errorf("unable to find image pulls, error: %v", err)
+	} else if len(pulls) != 2 {
+		t.Errorf("expected 2 image pulls, got %v", pulls)
+	}
+
+	if pulls, err := FindImagePulls(db, []IPFilter{InProgressIPFilter(), AgreementIPFilter("ag1")}); err != nil {
+		t.Errorf("unable to find image pulls, error: %v", err)
+	} else if len(pulls) != 1 || pulls[0].Status != IMAGE_PULL_PULLING {
+		t.Errorf("expected the pull of agreement ag1, got %v", pulls)
+	} else if pulls, err := FindImagePulls(db, []IPFilter{InProgressIPFilter(), AgreementIPFilter("ag2")}); err != nil || len(pulls) != 0 {
+		t.Errorf("the pull of agreement ag2 is not in progress, got %v, error: %v", pulls, err)
+	}
+
+	if err := DeleteImagePull(db, &pull2); err != nil {
+		t.Errorf("unable to delete image pull, error: %v", err)
+	} else if pulls, err := FindImagePulls(db, []IPFilter{}); err != nil || len(pulls) != 1 || pulls[0].AgreementIds[0] != "ag1" {
+		t.Errorf("only the pull of agreement ag1 should be left, got %v, error: %v", pulls, err)
+	}
+}
@@ -46,6 +46,28 @@ func FindNodeManagementPolicy(db *bolt.DB, policyKey string) (*exchangecommon.Ex
 	return nmpRecord, nil
 }
 
+func FindAllNodeManagementPolicies(db *bolt.DB) (map[string]exchangecommon.ExchangeNodeManagementPolicy, error) {
+	nmpRecords := make(map[string]exchangecommon.ExchangeNodeManagementPolicy)
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(NODE_MANAGEMENT_POLICY)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				nmpRecUnmarsh := exchangecommon.ExchangeNodeManagementPolicy{}
+				if err := json.Unmarshal(v, &nmpRecUnmarsh); err != nil {
+					return fmt.Errorf("Error unmarshaling node management policy record %v: %v", string(k), err)
+				}
+				nmpRecords[string(k)] = nmpRecUnmarsh
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return nmpRecords, nil
+}
+
 func DeleteNodeManagementPolicy(db *bolt.DB, policyKey string) (*exchangecommon.ExchangeNodeManagementPolicy, error) {
 	nmpRecord, err := FindNodeManagementPolicy(db, policyKey)
 	if err != nil {
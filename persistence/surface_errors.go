@@ -121,6 +121,7 @@ func getErrorTypeList() []string {
 		EC_ERROR_IMAGE_LOADE,
 		EC_ERROR_IN_DEPLOYMENT_CONFIG,
 		EC_ERROR_START_CONTAINER,
+		EC_ADMISSION_POLICY_VIOLATION,
 		EC_CANCEL_AGREEMENT_EXECUTION_TIMEOUT,
 		EC_CANCEL_AGREEMENT_SERVICE_SUSPENDED,
 		EC_ERROR_SERVICE_CONFIG,
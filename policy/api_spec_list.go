@@ -195,8 +195,11 @@ func (self *APISpecList) GetCommonVersionRanges() (*APISpecList, error) {
 				} else if err := v.IntersectsWith(v_new); err != nil {
 					// no intersection found, remove the microservice from the list.
 					(*new_list)[i].Version = NO_INTERSECTION
+				} else if v.Get_expression() == v_new.Get_expression() {
+					// keep a caret, tilde or wildcard range as it was written when it is the intersection
+					(*new_list)[i].Version = v_new.Get_shorthand_expression()
 				} else {
-					(*new_list)[i].Version = v.Get_expression()
+					(*new_list)[i].Version = v.Get_shorthand_expression()
 				}
 
 				break
@@ -208,7 +211,7 @@ func (self *APISpecList) GetCommonVersionRanges() (*APISpecList, error) {
 			if vr, err := semanticversion.Version_Expression_Factory(apiSpec.Version); err != nil {
 				return nil, fmt.Errorf("Failed to convert the version string %v to version range. %v", apiSpec.Version, err)
 			} else {
-				apiSpec.Version = vr.Get_expression()
+				apiSpec.Version = vr.Get_shorthand_expression()
 				(*new_list) = append((*new_list), apiSpec)
 			}
 		}
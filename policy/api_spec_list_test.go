@@ -461,3 +461,34 @@ func Test_APISpecification_GetCommonVersionRanges_success3(t *testing.T) {
 		}
 	}
 }
+
+// test that caret, tilde and wildcard ranges are kept as written unless the intersection changes them
+func Test_APISpecification_GetCommonVersionRanges_shorthand(t *testing.T) {
+	var apiSpecList *APISpecList
+
+	prod := `[{"specRef":"http://mycompany.com/dm/gps","organization":"myorg","version":"^1.2","exclusiveAccess":false,"arch":"amd64"},
+	          {"specRef":"http://mycompany.com/dm/network","organization":"myorg","version":"[1.0.0,INFINITY)","exclusiveAccess":false,"arch":"amd64"},
+	          {"specRef":"http://mycompany.com/dm/network","organization":"myorg","version":"~2.1","exclusiveAccess":false,"arch":"amd64"},
+	          {"specRef":"http://mycompany.com/dm/cpu","organization":"myorg","version":"1.x","exclusiveAccess":false,"arch":"amd64"},
+	          {"specRef":"http://mycompany.com/dm/cpu","organization":"myorg","version":"1.5.0","exclusiveAccess":false,"arch":"amd64"}]`
+	if apiSpecList = create_APISpecification(prod, t); apiSpecList != nil {
+		common_apispec_list, err := apiSpecList.GetCommonVersionRanges()
+		if err != nil {
+			t.Errorf("Error: got error but shoulg not be. %v\n", err)
+		} else if len(*common_apispec_list) != 3 {
+			t.Errorf("Error: should have 3 services, but has %v\n", *common_apispec_list)
+		} else {
+			for _, as := range *common_apispec_list {
+				if as.SpecRef == "http://mycompany.com/dm/gps" && as.Version != "^1.2" {
+					t.Errorf("Error: should have version range ^1.2, but is %v\n", as)
+				}
+				if as.SpecRef == "http://mycompany.com/dm/network" && as.Version != "~2.1" {
+					t.Errorf("Error: should have version range ~2.1, but is %v\n", as)
+				}
+				if as.SpecRef == "http://mycompany.com/dm/cpu" && as.Version != "[1.5.0,2.0.0)" {
+					t.Errorf("Error: should have version range [1.5.0,2.0.0), but is %v\n", as)
+				}
+			}
+		}
+	}
+}
@@ -70,9 +70,11 @@ type Policy struct {
 	RequiredWorkload   string                              `json:"requiredWorkload,omitempty"` // Version 2.0
 	NodeH              NodeHealth                          `json:"nodeHealth,omitempty"`       // Version 2.0
 	UserInput          []UserInput                         `json:"userInput,omitempty"`
-	SecretBinding      []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"`    // This structure has the servive secret name to secret provider name mappings
-	SecretDetails      []exchangecommon.SecretBinding      `json:"secretDetails,omitempty"`    // This structure has the service secret name to secret details mappings
-	ClusterNamespace   string                              `json:"clusterNamespace,omitempty"` // the namespace for the service to be deployed
+	SecretBinding      []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"`      // This structure has the servive secret name to secret provider name mappings
+	SecretDetails      []exchangecommon.SecretBinding      `json:"secretDetails,omitempty"`      // This structure has the service secret name to secret details mappings
+	ClusterNamespace   string                              `json:"clusterNamespace,omitempty"`   // the namespace for the service to be deployed
+	Rollout            *Rollout                            `json:"rollout,omitempty"`            // the staged rollout settings for service upgrades
+	MaintenanceWindows exchangecommon.MaintenanceWindows   `json:"maintenanceWindows,omitempty"` // when non-urgent workload changes can be made
 }
 
 // These functions are used to create Policy objects. You can create the base object
@@ -133,6 +135,8 @@ func (self *Policy) DeepCopy() *Policy {
 	}
 
 	newPolicy.ClusterNamespace = self.ClusterNamespace
+	newPolicy.Rollout = self.Rollout.DeepCopy()
+	newPolicy.MaintenanceWindows = self.MaintenanceWindows.DeepCopy()
 
 	return newPolicy
 }
@@ -368,6 +372,9 @@ func Create_Terms_And_Conditions(producer_policy *Policy, consumer_policy *Polic
 
 		merged_pol.ClusterNamespace = consumer_policy.ClusterNamespace
 
+		// the maintenance windows of the deployment policy are honored by the agent too.
+		merged_pol.MaintenanceWindows = consumer_policy.MaintenanceWindows.DeepCopy()
+
 		return merged_pol, nil
 	}
 }
@@ -513,6 +520,12 @@ func (self *Policy) String() string {
 	res += fmt.Sprintf("SecretBinding: %v\n", self.SecretBinding)
 
 	res += fmt.Sprintf("ClusterNamespace: %v\n", self.ClusterNamespace)
+	if self.Rollout != nil {
+		res += fmt.Sprintf("Rollout: %v\n", *self.Rollout)
+	}
+	if len(self.MaintenanceWindows) != 0 {
+		res += fmt.Sprintf("MaintenanceWindows: %v\n", self.MaintenanceWindows)
+	}
 
 	return res
 }
@@ -1134,7 +1147,8 @@ func getPolicyDirectories(homePath string) ([]os.FileInfo, error) {
 		if fileInfo.IsDir() {
 			fileInfoAsFileInfo, err := fileInfo.Info()
 			if err != nil {
-				fmt.Errorf("Unable to get file info for %v, error: %v", fileInfo.Name(), err)
+				glog.Errorf("Unable to get file info for %v, error: %v", fileInfo.Name(), err)
+				continue
 			}
 			res = append(res, fileInfoAsFileInfo)
 		}
@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"fmt"
+)
+
+// Success criteria for the nodes in a staged rollout.
+const ROLLOUT_SUCCESS_FINALIZED = "finalized" // the new agreement has been finalized
+const ROLLOUT_SUCCESS_EXECUTING = "executing" // the new agreement has been finalized and the service containers are running
+
+const DEFAULT_ROLLOUT_SUCCESS_TIMEOUT_S = 600
+
+// The staged rollout settings from a deployment policy. When present, the agbot upgrades the nodes that are running
+// an older service version in waves instead of all at once.
+type Rollout struct {
+	BatchSize            int    `json:"batchSize,omitempty"`            // the number of nodes upgraded in each wave
+	BatchPercentage      int    `json:"batchPercentage,omitempty"`      // the percentage of nodes upgraded in each wave, used when batchSize is not set
+	PauseS               int    `json:"pauseS,omitempty"`               // the number of seconds to wait after a successful wave before starting the next one
+	SuccessCriteria      string `json:"successCriteria,omitempty"`      // finalized or executing (the default)
+	SuccessTimeoutS      int    `json:"successTimeoutS,omitempty"`      // the number of seconds a node has to meet the success criteria
+	MaxFailurePercentage int    `json:"maxFailurePercentage,omitempty"` // the rollout halts when the percentage of failed nodes in a wave exceeds this value
+	Rollback             bool   `json:"rollback,omitempty"`             // when true, the nodes upgraded by a halted rollout go back to their previous service version
+}
+
+func (r Rollout) String() string {
+	return fmt.Sprintf("BatchSize: %v, BatchPercentage: %v, PauseS: %v, SuccessCriteria: %v, SuccessTimeoutS: %v, MaxFailurePercentage: %v, Rollback: %v",
+		r.BatchSize, r.BatchPercentage, r.PauseS, r.SuccessCriteria, r.SuccessTimeoutS, r.MaxFailurePercentage, r.Rollback)
+}
+
+func (r *Rollout) IsSame(compare *Rollout) bool {
+	if r == nil || compare == nil {
+		return r == compare
+	}
+	return *r == *compare
+}
+
+func (r *Rollout) DeepCopy() *Rollout {
+	if r == nil {
+		return nil
+	}
+	newRollout := *r
+	return &newRollout
+}
+
+// Returns the number of nodes to upgrade in each wave, given the total number of nodes in the rollout. A wave
+// always contains at least one node.
+func (r *Rollout) GetWaveSize(total int) int {
+	size := r.BatchSize
+	if size == 0 {
+		size = (total*r.BatchPercentage + 99) / 100
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func (r *Rollout) GetSuccessCriteria() string {
+	if r.SuccessCriteria == "" {
+		return ROLLOUT_SUCCESS_EXECUTING
+	}
+	return r.SuccessCriteria
+}
+
+func (r *Rollout) GetSuccessTimeoutS() int {
+	if r.SuccessTimeoutS == 0 {
+		return DEFAULT_ROLLOUT_SUCCESS_TIMEOUT_S
+	}
+	return r.SuccessTimeoutS
+}
+
+// Returns true if the failed nodes in a wave of the given size should halt the rollout.
+func (r *Rollout) FailureThresholdExceeded(failed int, waveSize int) bool {
+	if failed == 0 || waveSize == 0 {
+		return false
+	}
+	return failed*100 > r.MaxFailurePercentage*waveSize
+}
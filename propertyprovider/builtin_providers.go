@@ -0,0 +1,109 @@
+package propertyprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The device files of the accelerators that are not GPUs, such as the Coral Edge TPU, the Hailo and the devices of
+// the Linux accel subsystem.
+var defaultAcceleratorGlobs = []string{"/dev/apex_*", "/dev/hailo*", "/dev/accel/accel*"}
+
+// The directory of the power supplies in sysfs.
+const defaultPowerSupplyDir = "/sys/class/power_supply"
+
+// The free disk space, in MB, of the file system in Path, or of the root file system.
+func freeDiskProvider(cfg config.DynamicPropertyConfig) (interface{}, error) {
+	path := cfg.Path
+	if path == "" {
+		path = "/"
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to get the free disk space of %v: %v", path, err))
+	}
+	return float64(uint64(st.Bavail) * uint64(st.Bsize) / (1024 * 1024)), nil
+}
+
+// True if there is a device file that matches the glob in Path, or one of the device files of the known accelerators.
+func acceleratorProvider(cfg config.DynamicPropertyConfig) (interface{}, error) {
+	globs := defaultAcceleratorGlobs
+	if cfg.Path != "" {
+		globs = []string{cfg.Path}
+	}
+
+	for _, glob := range globs {
+		if matches, err := filepath.Glob(glob); err != nil {
+			return nil, errors.New(fmt.Sprintf("the accelerator device glob %v is not valid: %v", glob, err))
+		} else if len(matches) != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// The capacity, in percent, of the first battery in the power supply directory in Path or in sysfs.
+func batteryProvider(cfg config.DynamicPropertyConfig) (interface{}, error) {
+	dir := cfg.Path
+	if dir == "" {
+		dir = defaultPowerSupplyDir
+	}
+
+	supplies, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, supply := range supplies {
+		if supplyType, err := os.ReadFile(filepath.Join(supply, "type")); err != nil || strings.TrimSpace(string(supplyType)) != "Battery" {
+			continue
+		} else if capacity, err := os.ReadFile(filepath.Join(supply, "capacity")); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to read the capacity of battery %v: %v", supply, err))
+		} else if percent, err := strconv.ParseFloat(strings.TrimSpace(string(capacity)), 64); err != nil {
+			return nil, errors.New(fmt.Sprintf("the capacity %v of battery %v is not a number", strings.TrimSpace(string(capacity)), supply))
+		} else {
+			return percent, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("there is no battery in %v", dir))
+}
+
+// The content of the file in Path, such as a site id written by the node's provisioning.
+func fileProvider(cfg config.DynamicPropertyConfig) (interface{}, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("the file provider needs a Path")
+	}
+
+	content, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return string(content), nil
+}
+
+// The output of the command in Command. The command fails if it exits with an error or runs for more than TimeoutS.
+func scriptProvider(cfg config.DynamicPropertyConfig) (interface{}, error) {
+	if len(cfg.Command) == 0 {
+		return nil, errors.New("the script provider needs a Command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.GetTimeoutS())*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, cfg.Command[0], cfg.Command[1:]...).Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, errors.New(fmt.Sprintf("the command %v did not finish in %v seconds", cfg.Command, cfg.GetTimeoutS()))
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("the command %v failed: %v", cfg.Command, err))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,260 @@
+package propertyprovider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A property provider computes the current value of a dynamic node property from its config. The value must be a
+// string, a bool or a float64, or a string that is converted to the Type in the config.
+type Provider func(cfg config.DynamicPropertyConfig) (interface{}, error)
+
+const PROVIDER_FREE_DISK = "free_disk"
+const PROVIDER_ACCELERATOR = "accelerator"
+const PROVIDER_BATTERY = "battery"
+const PROVIDER_FILE = "file"
+const PROVIDER_SCRIPT = "script"
+
+var providersLock sync.Mutex
+var providers = map[string]Provider{
+	PROVIDER_FREE_DISK:   freeDiskProvider,
+	PROVIDER_ACCELERATOR: acceleratorProvider,
+	PROVIDER_BATTERY:     batteryProvider,
+	PROVIDER_FILE:        fileProvider,
+	PROVIDER_SCRIPT:      scriptProvider,
+}
+
+// Add a provider that can be configured in the Provider field of the DynamicProperties config. A provider that is
+// already registered is replaced.
+func RegisterProvider(name string, provider Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[name] = provider
+}
+
+func getProvider(name string) (Provider, bool) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// The state of one dynamic property.
+type dynamicProperty struct {
+	cfg       config.DynamicPropertyConfig
+	provider  Provider
+	nextCheck time.Time
+	value     interface{} // The last value computed by the provider, nil until it has been computed.
+}
+
+// The PropertyManager computes the dynamic node properties when they are due and decides which of them have to be
+// pushed to the node policy. The values are compared with the ones in the node policy rather than with the last
+// computed ones, so that a value that changes slowly is still pushed once it has changed enough.
+type PropertyManager struct {
+	properties []*dynamicProperty
+}
+
+// Create a PropertyManager for the dynamic properties in the agent config. An error is returned when a property
+// does not have a name, has the name of another property or of a built-in property, or uses an unknown provider.
+func NewPropertyManager(cfgs []config.DynamicPropertyConfig) (*PropertyManager, error) {
+	m := &PropertyManager{properties: make([]*dynamicProperty, 0, len(cfgs))}
+	names := make(map[string]bool)
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, errors.New(fmt.Sprintf("dynamic property %v does not have a name", cfg))
+		} else if names[cfg.Name] {
+			return nil, errors.New(fmt.Sprintf("dynamic property %v is configured more than once", cfg.Name))
+		} else if externalpolicy.IsNodeBuiltinPropertyName(cfg.Name) {
+			return nil, errors.New(fmt.Sprintf("dynamic property %v is a built-in node property", cfg.Name))
+		} else if !isValidType(cfg.Type) {
+			return nil, errors.New(fmt.Sprintf("dynamic property %v has the type %v, the type must be string, int, float or boolean", cfg.Name, cfg.Type))
+		}
+
+		provider, ok := getProvider(cfg.Provider)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("dynamic property %v has the unknown provider %v", cfg.Name, cfg.Provider))
+		}
+		names[cfg.Name] = true
+		m.properties = append(m.properties, &dynamicProperty{cfg: cfg, provider: provider})
+	}
+	return m, nil
+}
+
+// The number of seconds between the checks of the properties, which is the shortest interval of the properties.
+func (m *PropertyManager) CheckIntervalS() int {
+	interval := 0
+	for _, p := range m.properties {
+		if interval == 0 || p.cfg.GetIntervalS() < interval {
+			interval = p.cfg.GetIntervalS()
+		}
+	}
+	if interval == 0 {
+		interval = config.DynamicPropertyIntervalS_DEFAULT
+	}
+	return interval
+}
+
+// Compute the properties that are due and return the ones that have to be pushed to the node policy, because they
+// are not in the given node policy properties or their value has crossed a threshold. A property that is not due
+// but has been removed from the node policy is returned with its last computed value. The properties that cannot
+// be computed are logged and skipped.
+func (m *PropertyManager) ChangedProperties(current externalpolicy.PropertyList, now time.Time) externalpolicy.PropertyList {
+	changed := externalpolicy.PropertyList{}
+	for _, p := range m.properties {
+		if !now.Before(p.nextCheck) {
+			p.nextCheck = now.Add(time.Duration(p.cfg.GetIntervalS()) * time.Second)
+			if value, err := p.compute(); err != nil {
+				glog.Warningf("PropertyProvider: unable to compute dynamic property %v with provider %v: %v", p.cfg.Name, p.cfg.Provider, err)
+			} else {
+				p.value = value
+			}
+		}
+		if p.value == nil {
+			continue
+		}
+
+		if prop, err := current.GetProperty(p.cfg.Name); err != nil {
+			glog.V(3).Infof("PropertyProvider: dynamic property %v with value %v is not in the node policy", p.cfg.Name, p.value)
+		} else if !SignificantChange(p.cfg, prop.Value, p.value) {
+			continue
+		} else {
+			glog.V(3).Infof("PropertyProvider: dynamic property %v changed from %v to %v", p.cfg.Name, prop.Value, p.value)
+		}
+
+		newProp := externalpolicy.Property_Factory(p.cfg.Name, p.value)
+		newProp.Type = p.cfg.Type
+		changed = append(changed, *newProp)
+	}
+	return changed
+}
+
+// Run the provider of the property and convert its value to the configured type.
+func (p *dynamicProperty) compute() (interface{}, error) {
+	value, err := p.provider(p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return convertValue(value, p.cfg.Type)
+}
+
+// Return true if the new value of a property has to be pushed to the node policy that holds the old value. Numbers
+// are pushed when they move to another band between the Thresholds, or when there are no thresholds, when they
+// change by MinChange or more. Any change of a value that is not a number is pushed.
+func SignificantChange(cfg config.DynamicPropertyConfig, oldValue interface{}, newValue interface{}) bool {
+	oldNum, oldIsNum := toFloat(oldValue)
+	newNum, newIsNum := toFloat(newValue)
+	if !oldIsNum || !newIsNum {
+		return fmt.Sprintf("%v", oldValue) != fmt.Sprintf("%v", newValue)
+	}
+
+	if len(cfg.Thresholds) != 0 {
+		return band(cfg.Thresholds, oldNum) != band(cfg.Thresholds, newNum)
+	} else if cfg.MinChange > 0 {
+		return math.Abs(newNum-oldNum) >= cfg.MinChange
+	}
+	return oldNum != newNum
+}
+
+// The number of thresholds that are less than or equal to the value.
+func band(thresholds []float64, value float64) int {
+	sorted := make([]float64, len(thresholds))
+	copy(sorted, thresholds)
+	sort.Float64s(sorted)
+	return sort.Search(len(sorted), func(i int) bool { return sorted[i] > value })
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		if n, ok := value.(interface{ Float64() (float64, error) }); ok {
+			if f, err := n.Float64(); err == nil {
+				return f, true
+			}
+		}
+		return 0, false
+	}
+}
+
+func isValidType(valueType string) bool {
+	switch valueType {
+	case "", externalpolicy.STRING_TYPE, externalpolicy.INTEGER_TYPE, externalpolicy.FLOAT_TYPE, externalpolicy.BOOLEAN_TYPE:
+		return true
+	default:
+		return false
+	}
+}
+
+// Convert the value returned by a provider to the type of the property. When there is no type, a string is
+// converted to a boolean or a number if it is one.
+func convertValue(value interface{}, valueType string) (interface{}, error) {
+	if num, ok := toFloat(value); ok {
+		value = num
+	}
+
+	s, isString := value.(string)
+	if isString {
+		s = strings.TrimSpace(s)
+	} else {
+		s = fmt.Sprintf("%v", value)
+	}
+
+	if s == "" {
+		return nil, errors.New("the value is empty")
+	}
+
+	switch valueType {
+	case externalpolicy.STRING_TYPE:
+		return s, nil
+	case externalpolicy.BOOLEAN_TYPE:
+		if b, err := strconv.ParseBool(s); err != nil {
+			return nil, errors.New(fmt.Sprintf("the value %v is not a boolean", s))
+		} else {
+			return b, nil
+		}
+	case externalpolicy.INTEGER_TYPE:
+		if f, ok := parseNumber(s); !ok || f != math.Trunc(f) {
+			return nil, errors.New(fmt.Sprintf("the value %v is not an integer", s))
+		} else {
+			return f, nil
+		}
+	case externalpolicy.FLOAT_TYPE:
+		if f, ok := parseNumber(s); !ok {
+			return nil, errors.New(fmt.Sprintf("the value %v is not a number", s))
+		} else {
+			return f, nil
+		}
+	}
+
+	if !isString {
+		return value, nil
+	} else if s == "true" || s == "false" {
+		return s == "true", nil
+	} else if f, ok := parseNumber(s); ok {
+		return f, nil
+	}
+	return s, nil
+}
+
+// Parse a finite number.
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
@@ -0,0 +1,232 @@
+//go:build unit
+// +build unit
+
+package propertyprovider
+
+import (
+	"encoding/json"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SignificantChange(t *testing.T) {
+	thresholds := config.DynamicPropertyConfig{Name: "freeDisk", Thresholds: []float64{1000, 500, 5000}}
+	minChange := config.DynamicPropertyConfig{Name: "battery", MinChange: 10}
+	anyChange := config.DynamicPropertyConfig{Name: "siteId"}
+
+	for _, tc := range []struct {
+		cfg      config.DynamicPropertyConfig
+		old      interface{}
+		new      interface{}
+		expected bool
+	}{
+		{thresholds, float64(4000), float64(1200), false},
+		{thresholds, float64(4000), float64(1000), false},
+		{thresholds, float64(4000), float64(999), true},
+		{thresholds, float64(800), float64(600), false},
+		{thresholds, float64(800), float64(6000), true},
+		{thresholds, json.Number("4000"), float64(4500), false},
+		{minChange, float64(80), float64(71), false},
+		{minChange, float64(80), float64(70), true},
+		{minChange, float64(80), float64(95), true},
+		{anyChange, float64(3), float64(3), false},
+		{anyChange, float64(3), float64(3.5), true},
+		{anyChange, "site-a", "site-a", false},
+		{anyChange, "site-a", "site-b", true},
+		{anyChange, false, true, true},
+		{thresholds, "4000", float64(4000), false},
+	} {
+		if changed := SignificantChange(tc.cfg, tc.old, tc.new); changed != tc.expected {
+			t.Errorf("The change of %v from %v to %v should be significant %v, but was %v", tc.cfg.Name, tc.old, tc.new, tc.expected, changed)
+		}
+	}
+}
+
+func Test_convertValue(t *testing.T) {
+	for _, tc := range []struct {
+		value     interface{}
+		valueType string
+		expected  interface{}
+	}{
+		{"site-a\n", "", "site-a"},
+		{" 87\n", "", float64(87)},
+		{"true", "", true},
+		{"1", "", float64(1)},
+		{"007", externalpolicy.STRING_TYPE, "007"},
+		{"87", externalpolicy.INTEGER_TYPE, float64(87)},
+		{"2.5", externalpolicy.FLOAT_TYPE, float64(2.5)},
+		{"yes", "", "yes"},
+		{true, externalpolicy.STRING_TYPE, "true"},
+		{"1", externalpolicy.BOOLEAN_TYPE, true},
+		{float64(12), "", float64(12)},
+	} {
+		if value, err := convertValue(tc.value, tc.valueType); err != nil {
+			t.Errorf("Converting %q to type %v should not return an error, but returned %v", tc.value, tc.valueType, err)
+		} else if value != tc.expected {
+			t.Errorf("Converting %q to type %v should return %v (%T), but returned %v (%T)", tc.value, tc.valueType, tc.expected, tc.expected, value, value)
+		}
+	}
+
+	for _, tc := range []struct {
+		value     interface{}
+		valueType string
+	}{
+		{"  \n", ""},
+		{"2.5", externalpolicy.INTEGER_TYPE},
+		{"Inf", externalpolicy.FLOAT_TYPE},
+		{"yes", externalpolicy.BOOLEAN_TYPE},
+	} {
+		if _, err := convertValue(tc.value, tc.valueType); err == nil {
+			t.Errorf("Converting %q to type %v should return an error", tc.value, tc.valueType)
+		}
+	}
+}
+
+func Test_NewPropertyManager_Failed(t *testing.T) {
+	for message, cfgs := range map[string][]config.DynamicPropertyConfig{
+		"does not have a name":         {{Provider: PROVIDER_BATTERY}},
+		"is configured more than once": {{Name: "a", Provider: PROVIDER_BATTERY}, {Name: "a", Provider: PROVIDER_FILE}},
+		"is a built-in node property":  {{Name: externalpolicy.PROP_NODE_MEMORY, Provider: PROVIDER_FREE_DISK}},
+		"the type must be":             {{Name: "a", Provider: PROVIDER_FILE, Type: externalpolicy.VERSION_TYPE}},
+		"has the unknown provider":     {{Name: "a", Provider: "gpu"}},
+	} {
+		if _, err := NewPropertyManager(cfgs); err == nil {
+			t.Errorf("Creating a property manager for %v should return an error", cfgs)
+		} else if !strings.Contains(err.Error(), message) {
+			t.Errorf("Error message: %v does not contain the expected error message %v", err, message)
+		}
+	}
+}
+
+func Test_ChangedProperties(t *testing.T) {
+	levels := map[string]string{"battery": "80"}
+	RegisterProvider("test_level", func(cfg config.DynamicPropertyConfig) (interface{}, error) {
+		return levels[cfg.Path], nil
+	})
+
+	m, err := NewPropertyManager([]config.DynamicPropertyConfig{
+		{Name: "batteryLevel", Provider: "test_level", Path: "battery", Type: externalpolicy.INTEGER_TYPE, IntervalS: 30, Thresholds: []float64{20, 50}},
+		{Name: "siteId", Provider: "test_level", Path: "site", IntervalS: 300},
+	})
+	if err != nil {
+		t.Fatalf("Creating the property manager should not return an error, but returned %v", err)
+	} else if m.CheckIntervalS() != 30 {
+		t.Errorf("The check interval should be 30, but was %v", m.CheckIntervalS())
+	}
+
+	// the properties that are not in the node policy are pushed, the site has no value yet
+	now := time.Now()
+	current := externalpolicy.PropertyList{*externalpolicy.Property_Factory("cpu", float64(2))}
+	changed := m.ChangedProperties(current, now)
+	if len(changed) != 1 || changed[0].Name != "batteryLevel" || changed[0].Value != float64(80) || changed[0].Type != externalpolicy.INTEGER_TYPE {
+		t.Errorf("The changed properties should be batteryLevel 80, but were %v", changed)
+	}
+	current.MergeWith(&changed, true)
+
+	// a change that stays between the thresholds is not pushed
+	levels["battery"] = "60"
+	levels["site"] = "site-a"
+	if changed := m.ChangedProperties(current, now.Add(30*time.Second)); len(changed) != 0 {
+		t.Errorf("There should be no changed properties, but there were %v", changed)
+	}
+
+	// a change across a threshold is pushed, the site is not due yet
+	levels["battery"] = "45"
+	changed = m.ChangedProperties(current, now.Add(60*time.Second))
+	if len(changed) != 1 || changed[0].Value != float64(45) {
+		t.Errorf("The changed properties should be batteryLevel 45, but were %v", changed)
+	}
+
+	// the site is due
+	changed = m.ChangedProperties(current, now.Add(300*time.Second))
+	if len(changed) != 2 || changed[1].Name != "siteId" || changed[1].Value != "site-a" {
+		t.Errorf("The changed properties should be batteryLevel and siteId, but were %v", changed)
+	}
+	current.MergeWith(&changed, true)
+
+	// a property removed from the node policy is pushed again with its last value, even when it is not due
+	current = externalpolicy.PropertyList{current[0], current[1]}
+	changed = m.ChangedProperties(current, now.Add(310*time.Second))
+	if len(changed) != 1 || changed[0].Name != "siteId" || changed[0].Value != "site-a" {
+		t.Errorf("The changed properties should be siteId, but were %v", changed)
+	}
+
+	// a property that cannot be computed keeps its last value
+	levels["battery"] = "unknown"
+	if changed := m.ChangedProperties(externalpolicy.PropertyList{}, now.Add(400*time.Second)); len(changed) != 2 || changed[0].Value != float64(45) {
+		t.Errorf("The changed properties should be batteryLevel 45 and siteId, but were %v", changed)
+	}
+}
+
+func Test_builtin_providers(t *testing.T) {
+	dir := t.TempDir()
+
+	// file
+	siteFile := filepath.Join(dir, "site_id")
+	if err := os.WriteFile(siteFile, []byte("factory-7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := fileProvider(config.DynamicPropertyConfig{Path: siteFile}); err != nil || value != "factory-7\n" {
+		t.Errorf("The file provider should return the file content, but returned %v, %v", value, err)
+	}
+	if _, err := fileProvider(config.DynamicPropertyConfig{Path: filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("The file provider should return an error for a missing file")
+	}
+
+	// battery
+	for supply, files := range map[string]map[string]string{"AC": {"type": "Mains\n"}, "BAT0": {"type": "Battery\n", "capacity": "64\n"}} {
+		if err := os.MkdirAll(filepath.Join(dir, "power_supply", supply), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, "power_supply", supply, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if value, err := batteryProvider(config.DynamicPropertyConfig{Path: filepath.Join(dir, "power_supply")}); err != nil || value != float64(64) {
+		t.Errorf("The battery provider should return the battery capacity, but returned %v, %v", value, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "power_supply", "BAT0", "capacity"), []byte("unknown\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if value, err := batteryProvider(config.DynamicPropertyConfig{Path: filepath.Join(dir, "power_supply")}); err == nil {
+		t.Errorf("The battery provider should return an error when the capacity is not a number, but returned %v", value)
+	}
+	if _, err := batteryProvider(config.DynamicPropertyConfig{Path: dir}); err == nil {
+		t.Errorf("The battery provider should return an error when there is no battery")
+	}
+
+	// accelerator
+	if value, err := acceleratorProvider(config.DynamicPropertyConfig{Path: filepath.Join(dir, "apex_*")}); err != nil || value != false {
+		t.Errorf("The accelerator provider should return false, but returned %v, %v", value, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "apex_0"), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := acceleratorProvider(config.DynamicPropertyConfig{Path: filepath.Join(dir, "apex_*")}); err != nil || value != true {
+		t.Errorf("The accelerator provider should return true, but returned %v, %v", value, err)
+	}
+
+	// free disk
+	if value, err := freeDiskProvider(config.DynamicPropertyConfig{Path: dir}); err != nil {
+		t.Errorf("The free disk provider should not return an error, but returned %v", err)
+	} else if _, ok := value.(float64); !ok {
+		t.Errorf("The free disk provider should return a number, but returned %v", value)
+	}
+
+	// script
+	if value, err := scriptProvider(config.DynamicPropertyConfig{Command: []string{"sh", "-c", "echo 42"}}); err != nil || value != "42\n" {
+		t.Errorf("The script provider should return the command output, but returned %v, %v", value, err)
+	}
+	if _, err := scriptProvider(config.DynamicPropertyConfig{Command: []string{"sh", "-c", "exit 3"}}); err == nil {
+		t.Errorf("The script provider should return an error when the command fails")
+	}
+	if _, err := scriptProvider(config.DynamicPropertyConfig{Command: []string{"sleep", "5"}, TimeoutS: 1}); err == nil || !strings.Contains(err.Error(), "did not finish in 1 seconds") {
+		t.Errorf("The script provider should return a timeout error, but returned %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -21,6 +22,10 @@ import (
 	"github.com/open-horizon/edge-utilities/logger/trace"
 )
 
+// The embedded ESS is started when the node is registered. Objects can only be sent to the CSS while it is running.
+var essStarted bool
+var essStartedLock sync.Mutex
+
 type ResourceManager struct {
 	config   *config.HorizonConfig
 	org      string
@@ -191,36 +196,71 @@ func (r ResourceManager) StartFileSyncServiceAndSecretsAPI(am *AuthenticationMan
 		os.Exit(98)
 	}
 
+	setFileSyncServiceStarted(true)
+
 	glog.V(3).Infof(rmLogString(fmt.Sprintf("ESS and Secrets API Started")))
 	return nil
 
 }
 
 func censorAndDumpConfig() {
-	toBeCensored := []*string{&common.Configuration.ServerCertificate, &common.Configuration.ServerKey,
-		&common.Configuration.HTTPCSSCACertificate,
-		&common.Configuration.MQTTUserName, &common.Configuration.MQTTPassword,
-		&common.Configuration.MQTTCACertificate, &common.Configuration.MQTTSSLCert, &common.Configuration.MQTTSSLKey,
-		&common.Configuration.MongoUsername, &common.Configuration.MongoPassword, &common.Configuration.MongoCACertificate}
-	backups := make([]string, len(toBeCensored))
-
-	for index, fieldPointer := range toBeCensored {
-		backups[index] = *fieldPointer
+	trace.Dump("Loaded configuration:", CensoredESSConfig())
+}
+
+// Returns a copy of the embedded ESS config with the certificates, keys and credentials censored, so that it can be logged
+// or added to a diagnostic bundle.
+func CensoredESSConfig() common.Config {
+	censored := common.Configuration
+	toBeCensored := []*string{&censored.ServerCertificate, &censored.ServerKey,
+		&censored.HTTPCSSCACertificate,
+		&censored.MQTTUserName, &censored.MQTTPassword,
+		&censored.MQTTCACertificate, &censored.MQTTSSLCert, &censored.MQTTSSLKey,
+		&censored.MongoUsername, &censored.MongoPassword, &censored.MongoCACertificate}
+
+	for _, fieldPointer := range toBeCensored {
 		if len(*fieldPointer) != 0 {
 			*fieldPointer = "<...>"
 		}
 	}
+	return censored
+}
 
-	trace.Dump("Loaded configuration:", common.Configuration)
+func setFileSyncServiceStarted(started bool) {
+	essStartedLock.Lock()
+	defer essStartedLock.Unlock()
+	essStarted = started
+}
 
-	for index, fieldPointer := range toBeCensored {
-		*fieldPointer = backups[index]
+// Returns true when the embedded ESS is running.
+func IsFileSyncServiceStarted() bool {
+	essStartedLock.Lock()
+	defer essStartedLock.Unlock()
+	return essStarted
+}
+
+// Send an object from the node to the CSS through the embedded ESS. The ESS delivers the object to the CSS in the background,
+// in the org of the node.
+func SendObjectToCSS(org string, objectType string, objectID string, data []byte) error {
+	if !IsFileSyncServiceStarted() {
+		return errors.New(fmt.Sprintf("unable to send object %v/%v to the CSS, the file sync service is not running", objectType, objectID))
+	}
+
+	metaData := common.MetaData{
+		ObjectID:   objectID,
+		ObjectType: objectType,
 	}
+	if err := base.UpdateObject(org, objectType, objectID, metaData, data); err != nil {
+		return errors.New(fmt.Sprintf("unable to send object %v/%v to the CSS, error %v", objectType, objectID, err))
+	}
+
+	glog.V(3).Infof(rmLogString(fmt.Sprintf("Sent object %v/%v/%v of %v bytes to the CSS", org, objectType, objectID, len(data))))
+	return nil
 }
 
 func (r ResourceManager) StopFileSyncService() {
 	if r.pattern != "" {
 		glog.Infof(rmLogString(fmt.Sprintf("ESS Stopping")))
+		setFileSyncServiceStarted(false)
 
 		// Use a channel to communicate that ESS stop is complete.
 		stopChan := make(chan bool)
@@ -34,6 +34,12 @@ import (
 // specifying [x.y.z, INFINITY) which is also expressed as:
 // x.y.z <= a
 //
+// The caret, tilde and wildcard ranges used by npm and cargo are also accepted, they are
+// converted to the equivalent range in the above schema:
+// ^1.2.3 is [1.2.3,2.0.0), ^0.2.3 is [0.2.3,0.3.0) and ^0.0.3 is [0.0.3,0.0.4)
+// ~1.2.3 is [1.2.3,1.3.0) and ~1 is [1.0.0,2.0.0)
+// 1.2.x and 1.2.* are [1.2.0,1.3.0), 1.x is [1.0.0,2.0.0) and * is [0.0.0,INFINITY)
+//
 
 const leftEx = "("
 const leftInc = "["
@@ -43,6 +49,9 @@ const INF = "INFINITY"
 const versionSeperator = ","
 const numberSeperator = "."
 const preReleaseSeperator = "-"
+const caret = "^"
+const tilde = "~"
+const wildcards = "xX*"
 
 type Version_Expression struct {
 	full_expression string
@@ -50,9 +59,13 @@ type Version_Expression struct {
 	start_inclusive bool
 	end             string
 	end_inclusive   bool
+	shorthand       string // the caret, tilde or wildcard range this object was created from, if any
 }
 
 func (ve Version_Expression) String() string {
+	if ve.shorthand != "" {
+		return fmt.Sprintf("Vers Exp: %v %v", ve.shorthand, ve.full_expression)
+	}
 	return fmt.Sprintf("Vers Exp: %v", ve.full_expression)
 }
 
@@ -74,7 +87,17 @@ func Version_Expression_Factory(ver_string string) (*Version_Expression, error)
 		return nil, errors.New(errorString)
 	}
 
-	if singleVersion(ver_string) {
+	shorthand := ""
+	if isShorthandRange(ver_string) {
+		if rangeExpr, ok := expandShorthandRange(ver_string); !ok {
+			errorString := msgPrinter.Sprintf("Version_Expression: %v is not a valid version range.", ver_string)
+			return nil, errors.New(errorString)
+		} else {
+			expr = rangeExpr
+			shorthand = ver_string
+		}
+		glog.V(6).Infof("Version_Expression: Detected caret, tilde or wildcard range input, converted to %v", expr)
+	} else if singleVersion(ver_string) {
 		if !IsVersionString(ver_string) {
 			errorString := msgPrinter.Sprintf("Version_Expression: %v is not a valid version string.", ver_string)
 			return nil, errors.New(errorString)
@@ -130,6 +153,7 @@ func Version_Expression_Factory(ver_string string) (*Version_Expression, error)
 
 	// nomalize the versions in the expression
 	ve.recalc_expression()
+	ve.shorthand = shorthand
 
 	glog.V(6).Infof("Version_Expression: Created %v from %v", ve, expr)
 
@@ -154,6 +178,10 @@ func (self *Version_Expression) recalc_expression() {
 		expr = expr + rightEx
 	}
 
+	// the shorthand no longer describes a range that has been changed
+	if expr != self.full_expression {
+		self.shorthand = ""
+	}
 	self.full_expression = expr
 }
 
@@ -162,6 +190,16 @@ func (self *Version_Expression) Get_expression() string {
 	return self.full_expression
 }
 
+// Return the caret, tilde or wildcard range that was used as input to create this object, so that it can be shown
+// as it was written. The full expression is returned when the input was not one of these ranges, or when the range
+// has been changed by IntersectsWith or ChangeCeiling.
+func (self *Version_Expression) Get_shorthand_expression() string {
+	if self.shorthand != "" {
+		return self.shorthand
+	}
+	return self.full_expression
+}
+
 // Return the start version
 func (self *Version_Expression) Get_start_version() string {
 	return self.start
@@ -242,7 +280,8 @@ func (self *Version_Expression) IntersectsWith(other *Version_Expression) error
 		if c, err := CompareVersions(self.start, self.end); err != nil {
 			return err
 		} else if c == 0 {
-			if !self.start_inclusive && !self.end_inclusive {
+			// a range with the same start and end only has a version when both ends are inclusive
+			if !self.start_inclusive || !self.end_inclusive {
 				return fmt.Errorf(i18n.GetMessagePrinter().Sprintf("No intersection found."))
 			}
 		} else if c == 1 {
@@ -363,13 +402,18 @@ func IsVersionString(expr string) bool {
 	return true
 }
 
-// Return true if the input version string is a full version expression
+// Return true if the input version string is a full version expression, or a caret, tilde or wildcard range.
 func IsVersionExpression(expr string) bool {
 
 	if len(expr) == 0 {
 		return false
 	}
 
+	if isShorthandRange(expr) {
+		_, ok := expandShorthandRange(expr)
+		return ok
+	}
+
 	if !(leftIncluded(expr) || leftExcluded(expr)) && !(rightIncluded(expr) || rightExcluded(expr)) {
 		return false
 	}
@@ -392,6 +436,94 @@ func IsVersionExpression(expr string) bool {
 	return true
 }
 
+// Return true if the input looks like a caret, tilde or wildcard range. That is, it begins with ^ or ~, or one of its
+// version numbers is a wildcard. The range might not be valid.
+func isShorthandRange(expr string) bool {
+	if strings.HasPrefix(expr, caret) || strings.HasPrefix(expr, tilde) {
+		return true
+	}
+	for _, num := range strings.Split(strings.Split(expr, preReleaseSeperator)[0], numberSeperator) {
+		if isWildcard(num) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWildcard(num string) bool {
+	return len(num) == 1 && strings.Contains(wildcards, num)
+}
+
+// Convert a caret, tilde or wildcard range to the equivalent full version expression. The version numbers after a
+// wildcard must also be wildcards, and a version with a wildcard cannot have a pre-release. Return false if the input
+// is not a valid range.
+//
+// A caret range allows the changes that do not modify the left-most non-zero version number, or the last given
+// number when they are all zero. A tilde range allows the changes of the patch number when the minor number is given,
+// and of the minor number otherwise. A wildcard range allows any value of the wildcard numbers.
+func expandShorthandRange(expr string) (string, bool) {
+	prefix := ""
+	if strings.HasPrefix(expr, caret) || strings.HasPrefix(expr, tilde) {
+		prefix = expr[:1]
+		expr = expr[1:]
+	}
+
+	splitExpr := strings.Split(expr, preReleaseSeperator)
+	preRelease := strings.Join(splitExpr[1:], preReleaseSeperator)
+	parts := strings.Split(splitExpr[0], numberSeperator)
+	if len(parts) > 3 {
+		return "", false
+	}
+
+	// the version numbers before the first wildcard
+	nums := make([]int, 0, 3)
+	for i, part := range parts {
+		if isWildcard(part) {
+			for _, rest := range parts[i+1:] {
+				if !isWildcard(rest) {
+					return "", false
+				}
+			}
+			if preRelease != "" {
+				return "", false
+			}
+			break
+		} else if n, err := strconv.Atoi(part); err != nil || strings.Trim(part, "0123456789") != "" {
+			return "", false
+		} else {
+			nums = append(nums, n)
+		}
+	}
+
+	if len(nums) == 0 {
+		return leftInc + "0.0.0" + versionSeperator + INF + rightEx, true
+	} else if prefix == "" && len(nums) == len(parts) {
+		// a single version without a wildcard is not a shorthand range
+		return "", false
+	}
+
+	start := strings.Join(parts[:len(nums)], numberSeperator)
+	if preRelease != "" {
+		start += preReleaseSeperator + preRelease
+	}
+
+	var end string
+	switch {
+	case prefix == caret && (nums[0] > 0 || len(nums) == 1):
+		end = fmt.Sprintf("%v.0.0", nums[0]+1)
+	case prefix == caret && (nums[1] > 0 || len(nums) == 2):
+		end = fmt.Sprintf("0.%v.0", nums[1]+1)
+	case prefix == caret:
+		end = fmt.Sprintf("0.0.%v", nums[2]+1)
+	case len(nums) == 1:
+		end = fmt.Sprintf("%v.0.0", nums[0]+1)
+	default:
+		end = fmt.Sprintf("%v.%v.0", nums[0], nums[1]+1)
+	}
+
+	return leftInc + normalize(start) + versionSeperator + end + rightEx, true
+}
+
 // Return a normalized version string containing all 3 version numbers. The input version string is ASSUMED to
 // be a valid version string. For example, an input version string of 1 will be returned as 1.0.0
 func normalize(expr string) string {
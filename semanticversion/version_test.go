@@ -749,3 +749,88 @@ func TestCompareVersionsWithPrerelease(t *testing.T) {
 	assert.Nil(t, err, fmt.Sprintf("Error should be nil, but got:%v \n", err))
 	assert.Equal(t, 1, c, fmt.Sprintf("%v should be higher than %v.", v2, v1))
 }
+
+// This series of tests verifies that the caret, tilde and wildcard ranges are converted to full version expressions.
+func TestShorthandRanges(t *testing.T) {
+	for shorthand, expected := range map[string]string{
+		"^1.2.3":      "[1.2.3,2.0.0)",
+		"^1.2":        "[1.2.0,2.0.0)",
+		"^1":          "[1.0.0,2.0.0)",
+		"^0.2.3":      "[0.2.3,0.3.0)",
+		"^0.0.3":      "[0.0.3,0.0.4)",
+		"^0.0":        "[0.0.0,0.1.0)",
+		"^0":          "[0.0.0,1.0.0)",
+		"^1.2.x":      "[1.2.0,2.0.0)",
+		"^1.2.3-beta": "[1.2.3-beta,2.0.0)",
+		"~1.2.3":      "[1.2.3,1.3.0)",
+		"~1.2":        "[1.2.0,1.3.0)",
+		"~1":          "[1.0.0,2.0.0)",
+		"~0.0.3":      "[0.0.3,0.1.0)",
+		"~1.x":        "[1.0.0,2.0.0)",
+		"1.2.x":       "[1.2.0,1.3.0)",
+		"1.2.*":       "[1.2.0,1.3.0)",
+		"1.x":         "[1.0.0,2.0.0)",
+		"1.X.x":       "[1.0.0,2.0.0)",
+		"*":           "[0.0.0,INFINITY)",
+		"x.x.x":       "[0.0.0,INFINITY)",
+	} {
+		c, err := Version_Expression_Factory(shorthand)
+		if assert.Nil(t, err, fmt.Sprintf("Factory returned an error for %v: %v", shorthand, err)) {
+			assert.Equal(t, expected, c.Get_expression(), fmt.Sprintf("%v should be converted to %v", shorthand, expected))
+			assert.Equal(t, shorthand, c.Get_shorthand_expression(), fmt.Sprintf("%v should be kept as the shorthand", shorthand))
+			assert.True(t, IsVersionExpression(shorthand), fmt.Sprintf("%v should be a version expression", shorthand))
+
+			// the full expression is parsed back into the same range
+			full, err := Version_Expression_Factory(c.Get_expression())
+			assert.Nil(t, err, fmt.Sprintf("Factory returned an error for %v: %v", c.Get_expression(), err))
+			assert.Equal(t, expected, full.Get_expression(), fmt.Sprintf("%v should not be changed", expected))
+			assert.Equal(t, expected, full.Get_shorthand_expression(), fmt.Sprintf("%v does not have a shorthand", expected))
+		}
+	}
+
+	for _, shorthand := range []string{"^", "~", "^a.b", "^1.2.3.4", "1.x.3", "^1.x-beta", "~INFINITY", "^1,2", "^-1", "1.*a"} {
+		c, err := Version_Expression_Factory(shorthand)
+		assert.NotNil(t, err, fmt.Sprintf("Factory should return an error for %v, but returned %v", shorthand, c))
+		assert.False(t, IsVersionExpression(shorthand), fmt.Sprintf("%v should not be a version expression", shorthand))
+	}
+}
+
+// This series of tests verifies Is_within_range, IntersectsWith and ChangeCeiling with the caret, tilde and wildcard ranges.
+func TestShorthandRangeOperations(t *testing.T) {
+	v1, err := Version_Expression_Factory("^1.2")
+	assert.Nil(t, err, fmt.Sprintf("Factory returned nil, but should not. Error: %v \n", err))
+	for version, within := range map[string]bool{"1.2.0": true, "1.9.9": true, "2.0.0": false, "1.1.9": false, "2.0.0-beta": true} {
+		inRange, err := v1.Is_within_range(version)
+		assert.Nil(t, err, "Should return no error")
+		assert.Equal(t, within, inRange, fmt.Sprintf("%v should be within %v: %v", version, v1, within))
+	}
+
+	// the shorthand is kept when the range does not change
+	v2, err := Version_Expression_Factory("1.0")
+	assert.Nil(t, err, fmt.Sprintf("Factory returned nil, but should not. Error: %v \n", err))
+	err = v1.IntersectsWith(v2)
+	assert.Nil(t, err, "Shold return no error")
+	assert.Equal(t, "^1.2", v1.Get_shorthand_expression(), "The shorthand should be kept.")
+	assert.Equal(t, "Vers Exp: ^1.2 [1.2.0,2.0.0)", v1.String(), "The string should show the shorthand and the range.")
+
+	v3, err := Version_Expression_Factory("~1.5")
+	assert.Nil(t, err, fmt.Sprintf("Factory returned nil, but should not. Error: %v \n", err))
+	err = v1.IntersectsWith(v3)
+	assert.Nil(t, err, "Shold return no error")
+	assert.Equal(t, "[1.5.0,1.6.0)", v1.Get_expression(), "Intersection should be [1.5.0,1.6.0).")
+	assert.Equal(t, "[1.5.0,1.6.0)", v1.Get_shorthand_expression(), "The shorthand should be removed.")
+
+	v4, err := Version_Expression_Factory("1.x")
+	assert.Nil(t, err, fmt.Sprintf("Factory returned nil, but should not. Error: %v \n", err))
+	v5, err := Version_Expression_Factory("^2")
+	assert.Nil(t, err, fmt.Sprintf("Factory returned nil, but should not. Error: %v \n", err))
+	err = v4.IntersectsWith(v5)
+	assert.NotNil(t, err, "Should return error.")
+
+	v6, err := Version_Expression_Factory("~1.2.3")
+	assert.Nil(t, err, fmt.Sprintf("Factory returned nil, but should not. Error: %v \n", err))
+	err = v6.ChangeCeiling("1.2.7", false)
+	assert.Nil(t, err, fmt.Sprintf("ChangeCeiling returned error, but should not. Error: %v \n", err))
+	assert.Equal(t, "[1.2.3,1.2.7)", v6.Get_expression(), "Version range should be [1.2.3,1.2.7)")
+	assert.Equal(t, "[1.2.3,1.2.7)", v6.Get_shorthand_expression(), "The shorthand should be removed.")
+}
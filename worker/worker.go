@@ -319,6 +319,7 @@ func (w *BaseWorker) internalCommandhandler(worker Worker, command Command) bool
 	}
 
 	// Handle domain specific commands
+	workerCommandsHandled.Inc(w.GetName())
 	if handled := worker.CommandHandler(command); !handled {
 		glog.Errorf(cdLogString(fmt.Sprintf("%v received unknown command (%T): %v", w.GetName(), command, command)))
 	} else {
@@ -337,6 +338,10 @@ func (w *BaseWorker) Start(worker Worker, noWorkInterval int) {
 		// log worker status
 		workerStatusManager.SetWorkerStatus(w.GetName(), STATUS_STARTED)
 
+		// Make the command queue of this worker visible to the metrics collector.
+		addRunningWorker(w)
+		defer removeRunningWorker(w.GetName())
+
 		// Allow the worker to initialize itself, or stop it if initialization determines that.
 		if !worker.Initialize() {
 			workerStatusManager.SetWorkerStatus(w.GetName(), STATUS_INIT_FAILED)
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"github.com/open-horizon/anax/metrics"
+	"sync"
+)
+
+// Metrics describing the health of the worker framework. The command queue depth is sampled each time the metrics
+// are exposed, so that a worker which is not keeping up with its commands can be detected.
+var (
+	workerCommandQueueDepth = metrics.DefaultRegistry.NewGaugeVec("anax_worker_command_queue_depth",
+		"Number of commands waiting on the worker's command queue.", "worker")
+	workerCommandQueueCapacity = metrics.DefaultRegistry.NewGaugeVec("anax_worker_command_queue_capacity",
+		"Maximum number of commands that can be queued for the worker.", "worker")
+	workerStatusGauge = metrics.DefaultRegistry.NewGaugeVec("anax_worker_status",
+		"Set to 1 for the current status of each worker.", "worker", "status")
+	workerCommandsHandled = metrics.DefaultRegistry.NewCounterVec("anax_worker_commands_handled_total",
+		"Number of commands handled by the worker.", "worker")
+)
+
+// The workers whose command queues are sampled by the metrics collector, keyed by worker name.
+var runningWorkers = make(map[string]*BaseWorker)
+var runningWorkersLock sync.Mutex
+
+func init() {
+	metrics.DefaultRegistry.RegisterCollector("workers", collectWorkerMetrics)
+}
+
+func addRunningWorker(w *BaseWorker) {
+	runningWorkersLock.Lock()
+	defer runningWorkersLock.Unlock()
+	runningWorkers[w.GetName()] = w
+}
+
+func removeRunningWorker(name string) {
+	runningWorkersLock.Lock()
+	defer runningWorkersLock.Unlock()
+	delete(runningWorkers, name)
+}
+
+func collectWorkerMetrics() {
+	workerCommandQueueDepth.Reset()
+	workerCommandQueueCapacity.Reset()
+
+	runningWorkersLock.Lock()
+	for name, w := range runningWorkers {
+		workerCommandQueueDepth.Set(float64(len(w.Commands)), name)
+		workerCommandQueueCapacity.Set(float64(cap(w.Commands)), name)
+	}
+	runningWorkersLock.Unlock()
+
+	workerStatusGauge.Reset()
+
+	wsm := GetWorkerStatusManager()
+	wsm.ManagerLock.Lock()
+	defer wsm.ManagerLock.Unlock()
+	for name, ws := range wsm.Workers {
+		ws.StatusLock.Lock()
+		workerStatusGauge.Set(1, name, ws.Status)
+		ws.StatusLock.Unlock()
+	}
+}